@@ -7,6 +7,7 @@ CREATE TABLE `zz_admin_member` (
   `password_hash` TEXT NOT NULL DEFAULT '',
   `salt` TEXT NOT NULL,
   `password_reset_token` TEXT DEFAULT '',
+  `must_change_password` INTEGER NOT NULL DEFAULT 0,
   `avatar` TEXT DEFAULT '',
   `sex` INTEGER DEFAULT 3,
   `email` TEXT DEFAULT '',
@@ -65,6 +66,7 @@ CREATE TABLE `zz_admin_role` (
   `remark` TEXT DEFAULT NULL,
   `sort` INTEGER NOT NULL DEFAULT 0,
   `status` INTEGER NOT NULL DEFAULT 1,
+  `mfa_required` INTEGER NOT NULL DEFAULT 0,
   `created_at` TEXT DEFAULT NULL,
   `updated_at` TEXT DEFAULT NULL
 );
@@ -136,3 +138,84 @@ CREATE TABLE `zz_sys_attachment` (
   `created_at` TEXT DEFAULT (datetime('now','localtime')),
   `updated_at` TEXT DEFAULT (datetime('now','localtime'))
 );
+
+
+-- 管理员_双因素认证
+CREATE TABLE `zz_admin_member_mfa` (
+  `member_id` INTEGER PRIMARY KEY,
+  `secret` TEXT NOT NULL DEFAULT '',
+  `recovery_codes` TEXT DEFAULT NULL,
+  `last_step` INTEGER NOT NULL DEFAULT 0,
+  `status` INTEGER NOT NULL DEFAULT 2,
+  `enabled_at` TEXT DEFAULT NULL,
+  `created_at` TEXT DEFAULT NULL,
+  `updated_at` TEXT DEFAULT NULL
+);
+
+
+-- 管理员_历史密码
+CREATE TABLE `zz_admin_member_password_history` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `member_id` INTEGER NOT NULL,
+  `password_hash` TEXT NOT NULL DEFAULT '',
+  `created_at` TEXT DEFAULT NULL
+);
+
+CREATE INDEX `idx_password_history_member_id` ON `zz_admin_member_password_history` (`member_id`);
+
+
+-- 系统_消息发件箱
+CREATE TABLE `zz_sys_outbox` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `topic` TEXT NOT NULL,
+  `msg_id` TEXT NOT NULL,
+  `body` TEXT DEFAULT NULL,
+  `status` INTEGER NOT NULL DEFAULT 0,
+  `attempts` INTEGER NOT NULL DEFAULT 0,
+  `last_error` TEXT DEFAULT NULL,
+  `locked_at` TEXT DEFAULT NULL,
+  `sent_at` TEXT DEFAULT NULL,
+  `created_at` TEXT DEFAULT NULL,
+  `updated_at` TEXT DEFAULT NULL
+);
+
+CREATE INDEX `idx_status` ON `zz_sys_outbox` (`status`, `id`);
+
+
+-- 系统_队列已处理消息
+CREATE TABLE `zz_sys_queue_processed` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `msg_key` TEXT NOT NULL UNIQUE,
+  `expired_at` TEXT NOT NULL,
+  `created_at` TEXT DEFAULT NULL
+);
+
+CREATE INDEX `idx_expired_at` ON `zz_sys_queue_processed` (`expired_at`);
+
+
+-- 系统_队列消息
+CREATE TABLE `zz_sys_queue_message` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `topic` TEXT NOT NULL,
+  `consumer_group` TEXT NOT NULL DEFAULT '',
+  `msg_id` TEXT NOT NULL,
+  `body` TEXT DEFAULT NULL,
+  `visible_at` INTEGER NOT NULL DEFAULT 0,
+  `deliveries` INTEGER NOT NULL DEFAULT 0,
+  `receipt` TEXT DEFAULT NULL,
+  `created_at` TEXT DEFAULT NULL
+);
+
+CREATE INDEX `idx_topic_group` ON `zz_sys_queue_message` (`topic`, `consumer_group`, `visible_at`);
+CREATE INDEX `idx_msg_id` ON `zz_sys_queue_message` (`msg_id`);
+
+
+-- 系统_队列消费者组
+CREATE TABLE `zz_sys_queue_group` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `topic` TEXT NOT NULL,
+  `consumer_group` TEXT NOT NULL,
+  `created_at` TEXT DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX `uk_topic_group` ON `zz_sys_queue_group` (`topic`, `consumer_group`);
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// adminMemberMfaDao is the data access object for the table zz_admin_member_mfa.
+// You can define custom methods on it to extend its functionality as needed.
+type adminMemberMfaDao struct {
+	*internal.AdminMemberMfaDao
+}
+
+var (
+	// AdminMemberMfa is a globally accessible object for table zz_admin_member_mfa operations.
+	AdminMemberMfa = adminMemberMfaDao{internal.NewAdminMemberMfaDao()}
+)
+
+// Add your custom methods and functionality below.
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// adminMemberPasswordHistoryDao is the data access object for the table zz_admin_member_password_history.
+// You can define custom methods on it to extend its functionality as needed.
+type adminMemberPasswordHistoryDao struct {
+	*internal.AdminMemberPasswordHistoryDao
+}
+
+var (
+	// AdminMemberPasswordHistory is a globally accessible object for table zz_admin_member_password_history operations.
+	AdminMemberPasswordHistory = adminMemberPasswordHistoryDao{internal.NewAdminMemberPasswordHistoryDao()}
+)
+
+// Add your custom methods and functionality below.
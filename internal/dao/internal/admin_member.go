@@ -28,6 +28,7 @@ type AdminMemberColumns struct {
 	PasswordHash       string // 密码
 	Salt               string // 密码盐
 	PasswordResetToken string // 密码重置令牌
+	MustChangePassword string // 是否需要修改密码
 	Avatar             string // 头像
 	Sex                string // 性别
 	Email              string // 邮箱
@@ -48,6 +49,7 @@ var adminMemberColumns = AdminMemberColumns{
 	PasswordHash:       "password_hash",
 	Salt:               "salt",
 	PasswordResetToken: "password_reset_token",
+	MustChangePassword: "must_change_password",
 	Avatar:             "avatar",
 	Sex:                "sex",
 	Email:              "email",
@@ -21,26 +21,28 @@ type AdminRoleDao struct {
 
 // AdminRoleColumns defines and stores column names for the table zz_admin_role.
 type AdminRoleColumns struct {
-	Id        string // 角色ID
-	Name      string // 角色名称
-	Key       string // 角色权限字符串
-	Remark    string // 备注
-	Sort      string // 排序
-	Status    string // 角色状态
-	CreatedAt string // 创建时间
-	UpdatedAt string // 更新时间
+	Id          string // 角色ID
+	Name        string // 角色名称
+	Key         string // 角色权限字符串
+	Remark      string // 备注
+	Sort        string // 排序
+	Status      string // 角色状态
+	MfaRequired string // 是否强制双因素认证
+	CreatedAt   string // 创建时间
+	UpdatedAt   string // 更新时间
 }
 
 // adminRoleColumns holds the columns for the table zz_admin_role.
 var adminRoleColumns = AdminRoleColumns{
-	Id:        "id",
-	Name:      "name",
-	Key:       "key",
-	Remark:    "remark",
-	Sort:      "sort",
-	Status:    "status",
-	CreatedAt: "created_at",
-	UpdatedAt: "updated_at",
+	Id:          "id",
+	Name:        "name",
+	Key:         "key",
+	Remark:      "remark",
+	Sort:        "sort",
+	Status:      "status",
+	MfaRequired: "mfa_required",
+	CreatedAt:   "created_at",
+	UpdatedAt:   "updated_at",
 }
 
 // NewAdminRoleDao creates and returns a new DAO object for table data access.
@@ -0,0 +1,99 @@
+// ==========================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// ==========================================================================
+
+package internal
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// SysOutboxDao is the data access object for the table zz_sys_outbox.
+type SysOutboxDao struct {
+	table    string             // table is the underlying table name of the DAO.
+	group    string             // group is the database configuration group name of the current DAO.
+	columns  SysOutboxColumns   // columns contains all the column names of Table for convenient usage.
+	handlers []gdb.ModelHandler // handlers for customized model modification.
+}
+
+// SysOutboxColumns defines and stores column names for the table zz_sys_outbox.
+type SysOutboxColumns struct {
+	Id        string // ID
+	Topic     string // 主题
+	MsgId     string // 消息ID
+	Body      string // 消息内容
+	Status    string // 状态
+	Attempts  string // 发送失败次数
+	LastError string // 最后一次发送失败的原因
+	LockedAt  string // 锁定时间
+	SentAt    string // 发送时间
+	CreatedAt string // 创建时间
+	UpdatedAt string // 修改时间
+}
+
+// sysOutboxColumns holds the columns for the table zz_sys_outbox.
+var sysOutboxColumns = SysOutboxColumns{
+	Id:        "id",
+	Topic:     "topic",
+	MsgId:     "msg_id",
+	Body:      "body",
+	Status:    "status",
+	Attempts:  "attempts",
+	LastError: "last_error",
+	LockedAt:  "locked_at",
+	SentAt:    "sent_at",
+	CreatedAt: "created_at",
+	UpdatedAt: "updated_at",
+}
+
+// NewSysOutboxDao creates and returns a new DAO object for table data access.
+func NewSysOutboxDao(handlers ...gdb.ModelHandler) *SysOutboxDao {
+	return &SysOutboxDao{
+		group:    "default",
+		table:    "zz_sys_outbox",
+		columns:  sysOutboxColumns,
+		handlers: handlers,
+	}
+}
+
+// DB retrieves and returns the underlying raw database management object of the current DAO.
+func (dao *SysOutboxDao) DB() gdb.DB {
+	return g.DB(dao.group)
+}
+
+// Table returns the table name of the current DAO.
+func (dao *SysOutboxDao) Table() string {
+	return dao.table
+}
+
+// Columns returns all column names of the current DAO.
+func (dao *SysOutboxDao) Columns() SysOutboxColumns {
+	return dao.columns
+}
+
+// Group returns the database configuration group name of the current DAO.
+func (dao *SysOutboxDao) Group() string {
+	return dao.group
+}
+
+// Ctx creates and returns a Model for the current DAO. It automatically sets the context for the current operation.
+func (dao *SysOutboxDao) Ctx(ctx context.Context) *gdb.Model {
+	model := dao.DB().Model(dao.table)
+	for _, handler := range dao.handlers {
+		model = handler(model)
+	}
+	return model.Safe().Ctx(ctx)
+}
+
+// Transaction wraps the transaction logic using function f.
+// It rolls back the transaction and returns the error if function f returns a non-nil error.
+// It commits the transaction and returns nil if function f returns nil.
+//
+// Note: Do not commit or roll back the transaction in function f,
+// as it is automatically handled by this function.
+func (dao *SysOutboxDao) Transaction(ctx context.Context, f func(ctx context.Context, tx gdb.TX) error) (err error) {
+	return dao.Ctx(ctx).Transaction(ctx, f)
+}
@@ -0,0 +1,85 @@
+// ==========================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// ==========================================================================
+
+package internal
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// SysQueueGroupDao is the data access object for the table zz_sys_queue_group.
+type SysQueueGroupDao struct {
+	table    string               // table is the underlying table name of the DAO.
+	group    string               // group is the database configuration group name of the current DAO.
+	columns  SysQueueGroupColumns // columns contains all the column names of Table for convenient usage.
+	handlers []gdb.ModelHandler   // handlers for customized model modification.
+}
+
+// SysQueueGroupColumns defines and stores column names for the table zz_sys_queue_group.
+type SysQueueGroupColumns struct {
+	Id            string // ID
+	Topic         string // 主题
+	ConsumerGroup string // 消费者组
+	CreatedAt     string // 创建时间
+}
+
+// sysQueueGroupColumns holds the columns for the table zz_sys_queue_group.
+var sysQueueGroupColumns = SysQueueGroupColumns{
+	Id:            "id",
+	Topic:         "topic",
+	ConsumerGroup: "consumer_group",
+	CreatedAt:     "created_at",
+}
+
+// NewSysQueueGroupDao creates and returns a new DAO object for table data access.
+func NewSysQueueGroupDao(handlers ...gdb.ModelHandler) *SysQueueGroupDao {
+	return &SysQueueGroupDao{
+		group:    "default",
+		table:    "zz_sys_queue_group",
+		columns:  sysQueueGroupColumns,
+		handlers: handlers,
+	}
+}
+
+// DB retrieves and returns the underlying raw database management object of the current DAO.
+func (dao *SysQueueGroupDao) DB() gdb.DB {
+	return g.DB(dao.group)
+}
+
+// Table returns the table name of the current DAO.
+func (dao *SysQueueGroupDao) Table() string {
+	return dao.table
+}
+
+// Columns returns all column names of the current DAO.
+func (dao *SysQueueGroupDao) Columns() SysQueueGroupColumns {
+	return dao.columns
+}
+
+// Group returns the database configuration group name of the current DAO.
+func (dao *SysQueueGroupDao) Group() string {
+	return dao.group
+}
+
+// Ctx creates and returns a Model for the current DAO. It automatically sets the context for the current operation.
+func (dao *SysQueueGroupDao) Ctx(ctx context.Context) *gdb.Model {
+	model := dao.DB().Model(dao.table)
+	for _, handler := range dao.handlers {
+		model = handler(model)
+	}
+	return model.Safe().Ctx(ctx)
+}
+
+// Transaction wraps the transaction logic using function f.
+// It rolls back the transaction and returns the error if function f returns a non-nil error.
+// It commits the transaction and returns nil if function f returns nil.
+//
+// Note: Do not commit or roll back the transaction in function f,
+// as it is automatically handled by this function.
+func (dao *SysQueueGroupDao) Transaction(ctx context.Context, f func(ctx context.Context, tx gdb.TX) error) (err error) {
+	return dao.Ctx(ctx).Transaction(ctx, f)
+}
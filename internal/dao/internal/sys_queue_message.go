@@ -0,0 +1,95 @@
+// ==========================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// ==========================================================================
+
+package internal
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// SysQueueMessageDao is the data access object for the table zz_sys_queue_message.
+type SysQueueMessageDao struct {
+	table    string                 // table is the underlying table name of the DAO.
+	group    string                 // group is the database configuration group name of the current DAO.
+	columns  SysQueueMessageColumns // columns contains all the column names of Table for convenient usage.
+	handlers []gdb.ModelHandler     // handlers for customized model modification.
+}
+
+// SysQueueMessageColumns defines and stores column names for the table zz_sys_queue_message.
+type SysQueueMessageColumns struct {
+	Id            string // ID
+	Topic         string // 主题
+	ConsumerGroup string // 消费者组
+	MsgId         string // 消息ID
+	Body          string // 消息内容
+	VisibleAt     string // 可见时间（毫秒时间戳）
+	Deliveries    string // 投递次数
+	Receipt       string // 读取凭证
+	CreatedAt     string // 创建时间
+}
+
+// sysQueueMessageColumns holds the columns for the table zz_sys_queue_message.
+var sysQueueMessageColumns = SysQueueMessageColumns{
+	Id:            "id",
+	Topic:         "topic",
+	ConsumerGroup: "consumer_group",
+	MsgId:         "msg_id",
+	Body:          "body",
+	VisibleAt:     "visible_at",
+	Deliveries:    "deliveries",
+	Receipt:       "receipt",
+	CreatedAt:     "created_at",
+}
+
+// NewSysQueueMessageDao creates and returns a new DAO object for table data access.
+func NewSysQueueMessageDao(handlers ...gdb.ModelHandler) *SysQueueMessageDao {
+	return &SysQueueMessageDao{
+		group:    "default",
+		table:    "zz_sys_queue_message",
+		columns:  sysQueueMessageColumns,
+		handlers: handlers,
+	}
+}
+
+// DB retrieves and returns the underlying raw database management object of the current DAO.
+func (dao *SysQueueMessageDao) DB() gdb.DB {
+	return g.DB(dao.group)
+}
+
+// Table returns the table name of the current DAO.
+func (dao *SysQueueMessageDao) Table() string {
+	return dao.table
+}
+
+// Columns returns all column names of the current DAO.
+func (dao *SysQueueMessageDao) Columns() SysQueueMessageColumns {
+	return dao.columns
+}
+
+// Group returns the database configuration group name of the current DAO.
+func (dao *SysQueueMessageDao) Group() string {
+	return dao.group
+}
+
+// Ctx creates and returns a Model for the current DAO. It automatically sets the context for the current operation.
+func (dao *SysQueueMessageDao) Ctx(ctx context.Context) *gdb.Model {
+	model := dao.DB().Model(dao.table)
+	for _, handler := range dao.handlers {
+		model = handler(model)
+	}
+	return model.Safe().Ctx(ctx)
+}
+
+// Transaction wraps the transaction logic using function f.
+// It rolls back the transaction and returns the error if function f returns a non-nil error.
+// It commits the transaction and returns nil if function f returns nil.
+//
+// Note: Do not commit or roll back the transaction in function f,
+// as it is automatically handled by this function.
+func (dao *SysQueueMessageDao) Transaction(ctx context.Context, f func(ctx context.Context, tx gdb.TX) error) (err error) {
+	return dao.Ctx(ctx).Transaction(ctx, f)
+}
@@ -0,0 +1,85 @@
+// ==========================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// ==========================================================================
+
+package internal
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// SysQueueProcessedDao is the data access object for the table zz_sys_queue_processed.
+type SysQueueProcessedDao struct {
+	table    string                   // table is the underlying table name of the DAO.
+	group    string                   // group is the database configuration group name of the current DAO.
+	columns  SysQueueProcessedColumns // columns contains all the column names of Table for convenient usage.
+	handlers []gdb.ModelHandler       // handlers for customized model modification.
+}
+
+// SysQueueProcessedColumns defines and stores column names for the table zz_sys_queue_processed.
+type SysQueueProcessedColumns struct {
+	Id        string // ID
+	MsgKey    string // 消息标识
+	ExpiredAt string // 过期时间
+	CreatedAt string // 创建时间
+}
+
+// sysQueueProcessedColumns holds the columns for the table zz_sys_queue_processed.
+var sysQueueProcessedColumns = SysQueueProcessedColumns{
+	Id:        "id",
+	MsgKey:    "msg_key",
+	ExpiredAt: "expired_at",
+	CreatedAt: "created_at",
+}
+
+// NewSysQueueProcessedDao creates and returns a new DAO object for table data access.
+func NewSysQueueProcessedDao(handlers ...gdb.ModelHandler) *SysQueueProcessedDao {
+	return &SysQueueProcessedDao{
+		group:    "default",
+		table:    "zz_sys_queue_processed",
+		columns:  sysQueueProcessedColumns,
+		handlers: handlers,
+	}
+}
+
+// DB retrieves and returns the underlying raw database management object of the current DAO.
+func (dao *SysQueueProcessedDao) DB() gdb.DB {
+	return g.DB(dao.group)
+}
+
+// Table returns the table name of the current DAO.
+func (dao *SysQueueProcessedDao) Table() string {
+	return dao.table
+}
+
+// Columns returns all column names of the current DAO.
+func (dao *SysQueueProcessedDao) Columns() SysQueueProcessedColumns {
+	return dao.columns
+}
+
+// Group returns the database configuration group name of the current DAO.
+func (dao *SysQueueProcessedDao) Group() string {
+	return dao.group
+}
+
+// Ctx creates and returns a Model for the current DAO. It automatically sets the context for the current operation.
+func (dao *SysQueueProcessedDao) Ctx(ctx context.Context) *gdb.Model {
+	model := dao.DB().Model(dao.table)
+	for _, handler := range dao.handlers {
+		model = handler(model)
+	}
+	return model.Safe().Ctx(ctx)
+}
+
+// Transaction wraps the transaction logic using function f.
+// It rolls back the transaction and returns the error if function f returns a non-nil error.
+// It commits the transaction and returns nil if function f returns nil.
+//
+// Note: Do not commit or roll back the transaction in function f,
+// as it is automatically handled by this function.
+func (dao *SysQueueProcessedDao) Transaction(ctx context.Context, f func(ctx context.Context, tx gdb.TX) error) (err error) {
+	return dao.Ctx(ctx).Transaction(ctx, f)
+}
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// sysOutboxDao is the data access object for the table zz_sys_outbox.
+// You can define custom methods on it to extend its functionality as needed.
+type sysOutboxDao struct {
+	*internal.SysOutboxDao
+}
+
+var (
+	// SysOutbox is a globally accessible object for table zz_sys_outbox operations.
+	SysOutbox = sysOutboxDao{internal.NewSysOutboxDao()}
+)
+
+// Add your custom methods and functionality below.
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// sysQueueGroupDao is the data access object for the table zz_sys_queue_group.
+// You can define custom methods on it to extend its functionality as needed.
+type sysQueueGroupDao struct {
+	*internal.SysQueueGroupDao
+}
+
+var (
+	// SysQueueGroup is a globally accessible object for table zz_sys_queue_group operations.
+	SysQueueGroup = sysQueueGroupDao{internal.NewSysQueueGroupDao()}
+)
+
+// Add your custom methods and functionality below.
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// sysQueueMessageDao is the data access object for the table zz_sys_queue_message.
+// You can define custom methods on it to extend its functionality as needed.
+type sysQueueMessageDao struct {
+	*internal.SysQueueMessageDao
+}
+
+var (
+	// SysQueueMessage is a globally accessible object for table zz_sys_queue_message operations.
+	SysQueueMessage = sysQueueMessageDao{internal.NewSysQueueMessageDao()}
+)
+
+// Add your custom methods and functionality below.
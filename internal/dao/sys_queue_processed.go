@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tool. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"github.com/denghuo98/zzframe/internal/dao/internal"
+)
+
+// sysQueueProcessedDao is the data access object for the table zz_sys_queue_processed.
+// You can define custom methods on it to extend its functionality as needed.
+type sysQueueProcessedDao struct {
+	*internal.SysQueueProcessedDao
+}
+
+var (
+	// SysQueueProcessed is a globally accessible object for table zz_sys_queue_processed operations.
+	SysQueueProcessed = sysQueueProcessedDao{internal.NewSysQueueProcessedDao()}
+)
+
+// Add your custom methods and functionality below.
@@ -19,6 +19,7 @@ type AdminMember struct {
 	PasswordHash       any         // 密码
 	Salt               any         // 密码盐
 	PasswordResetToken any         // 密码重置令牌
+	MustChangePassword any         // 是否需要修改密码
 	Avatar             any         // 头像
 	Sex                any         // 性别
 	Email              any         // 邮箱
@@ -0,0 +1,23 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package do
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// AdminMemberMfa is the golang structure of table zz_admin_member_mfa for DAO operations like Where/Data.
+type AdminMemberMfa struct {
+	g.Meta        `orm:"table:zz_admin_member_mfa, do:true"`
+	MemberId      any         // 管理员ID
+	Secret        any         // TOTP密钥
+	RecoveryCodes any         // 恢复码哈希
+	LastStep      any         // 最后使用的时间步
+	Status        any         // 状态
+	EnabledAt     *gtime.Time // 启用时间
+	CreatedAt     *gtime.Time // 创建时间
+	UpdatedAt     *gtime.Time // 修改时间
+}
@@ -0,0 +1,26 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package do
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysOutbox is the golang structure of table zz_sys_outbox for DAO operations like Where/Data.
+type SysOutbox struct {
+	g.Meta    `orm:"table:zz_sys_outbox, do:true"`
+	Id        any         // ID
+	Topic     any         // 主题
+	MsgId     any         // 消息ID
+	Body      any         // 消息内容
+	Status    any         // 状态
+	Attempts  any         // 发送失败次数
+	LastError any         // 最后一次发送失败的原因
+	LockedAt  *gtime.Time // 锁定时间
+	SentAt    *gtime.Time // 发送时间
+	CreatedAt *gtime.Time // 创建时间
+	UpdatedAt *gtime.Time // 修改时间
+}
@@ -0,0 +1,24 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package do
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysQueueMessage is the golang structure of table zz_sys_queue_message for DAO operations like Where/Data.
+type SysQueueMessage struct {
+	g.Meta        `orm:"table:zz_sys_queue_message, do:true"`
+	Id            any         // ID
+	Topic         any         // 主题
+	ConsumerGroup any         // 消费者组
+	MsgId         any         // 消息ID
+	Body          any         // 消息内容
+	VisibleAt     any         // 可见时间（毫秒时间戳）
+	Deliveries    any         // 投递次数
+	Receipt       any         // 读取凭证
+	CreatedAt     *gtime.Time // 创建时间
+}
@@ -17,6 +17,7 @@ type AdminMember struct {
 	PasswordHash       string      `json:"passwordHash"       orm:"password_hash"        description:"密码"`
 	Salt               string      `json:"salt"               orm:"salt"                 description:"密码盐"`
 	PasswordResetToken string      `json:"passwordResetToken" orm:"password_reset_token" description:"密码重置令牌"`
+	MustChangePassword int         `json:"mustChangePassword" orm:"must_change_password" description:"是否需要修改密码"`
 	Avatar             string      `json:"avatar"             orm:"avatar"               description:"头像"`
 	Sex                int         `json:"sex"                orm:"sex"                  description:"性别"`
 	Email              string      `json:"email"              orm:"email"                description:"邮箱"`
@@ -0,0 +1,21 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// AdminMemberMfa is the golang structure for table admin_member_mfa.
+type AdminMemberMfa struct {
+	MemberId      int64       `json:"memberId"      orm:"member_id"      description:"管理员ID"`
+	Secret        string      `json:"secret"        orm:"secret"         description:"TOTP密钥"`
+	RecoveryCodes string      `json:"recoveryCodes" orm:"recovery_codes" description:"恢复码哈希"`
+	LastStep      int64       `json:"lastStep"      orm:"last_step"      description:"最后使用的时间步"`
+	Status        int         `json:"status"        orm:"status"         description:"状态"`
+	EnabledAt     *gtime.Time `json:"enabledAt"     orm:"enabled_at"     description:"启用时间"`
+	CreatedAt     *gtime.Time `json:"createdAt"     orm:"created_at"     description:"创建时间"`
+	UpdatedAt     *gtime.Time `json:"updatedAt"     orm:"updated_at"     description:"修改时间"`
+}
@@ -0,0 +1,17 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// AdminMemberPasswordHistory is the golang structure for table admin_member_password_history.
+type AdminMemberPasswordHistory struct {
+	Id           int64       `json:"id"           orm:"id"            description:"ID"`
+	MemberId     int64       `json:"memberId"     orm:"member_id"     description:"管理员ID"`
+	PasswordHash string      `json:"passwordHash" orm:"password_hash" description:"密码"`
+	CreatedAt    *gtime.Time `json:"createdAt"    orm:"created_at"    description:"创建时间"`
+}
@@ -0,0 +1,24 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysOutbox is the golang structure for table sys_outbox.
+type SysOutbox struct {
+	Id        int64       `json:"id"        orm:"id"         description:"ID"`
+	Topic     string      `json:"topic"     orm:"topic"      description:"主题"`
+	MsgId     string      `json:"msgId"     orm:"msg_id"     description:"消息ID"`
+	Body      string      `json:"body"      orm:"body"       description:"消息内容"`
+	Status    int         `json:"status"    orm:"status"     description:"状态"`
+	Attempts  int         `json:"attempts"  orm:"attempts"   description:"发送失败次数"`
+	LastError string      `json:"lastError" orm:"last_error" description:"最后一次发送失败的原因"`
+	LockedAt  *gtime.Time `json:"lockedAt"  orm:"locked_at"  description:"锁定时间"`
+	SentAt    *gtime.Time `json:"sentAt"    orm:"sent_at"    description:"发送时间"`
+	CreatedAt *gtime.Time `json:"createdAt" orm:"created_at" description:"创建时间"`
+	UpdatedAt *gtime.Time `json:"updatedAt" orm:"updated_at" description:"修改时间"`
+}
@@ -0,0 +1,17 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysQueueGroup is the golang structure for table sys_queue_group.
+type SysQueueGroup struct {
+	Id            int64       `json:"id"            orm:"id"             description:"ID"`
+	Topic         string      `json:"topic"         orm:"topic"          description:"主题"`
+	ConsumerGroup string      `json:"consumerGroup" orm:"consumer_group" description:"消费者组"`
+	CreatedAt     *gtime.Time `json:"createdAt"     orm:"created_at"     description:"创建时间"`
+}
@@ -0,0 +1,22 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysQueueMessage is the golang structure for table sys_queue_message.
+type SysQueueMessage struct {
+	Id            int64       `json:"id"            orm:"id"             description:"ID"`
+	Topic         string      `json:"topic"         orm:"topic"          description:"主题"`
+	ConsumerGroup string      `json:"consumerGroup" orm:"consumer_group" description:"消费者组"`
+	MsgId         string      `json:"msgId"         orm:"msg_id"         description:"消息ID"`
+	Body          string      `json:"body"          orm:"body"           description:"消息内容"`
+	VisibleAt     int64       `json:"visibleAt"     orm:"visible_at"     description:"可见时间（毫秒时间戳）"`
+	Deliveries    int         `json:"deliveries"    orm:"deliveries"     description:"投递次数"`
+	Receipt       string      `json:"receipt"       orm:"receipt"        description:"读取凭证"`
+	CreatedAt     *gtime.Time `json:"createdAt"     orm:"created_at"     description:"创建时间"`
+}
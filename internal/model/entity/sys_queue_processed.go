@@ -0,0 +1,17 @@
+// =================================================================================
+// Code generated and maintained by GoFrame CLI tool. DO NOT EDIT.
+// =================================================================================
+
+package entity
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysQueueProcessed is the golang structure for table sys_queue_processed.
+type SysQueueProcessed struct {
+	Id        int64       `json:"id"        orm:"id"         description:"ID"`
+	MsgKey    string      `json:"msgKey"    orm:"msg_key"    description:"消息标识"`
+	ExpiredAt *gtime.Time `json:"expiredAt" orm:"expired_at" description:"过期时间"`
+	CreatedAt *gtime.Time `json:"createdAt" orm:"created_at" description:"创建时间"`
+}
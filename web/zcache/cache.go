@@ -2,17 +2,24 @@ package zcache
 
 import (
 	"context"
+	"time"
 
+	"github.com/gogf/gf/v2/database/gredis"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gcache"
 	"github.com/gogf/gf/v2/os/gfile"
 
 	"github.com/denghuo98/zzframe/web/zcache/file"
+	"github.com/denghuo98/zzframe/web/zcache/layered"
 	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
 )
 
-// cache 缓存驱动
-var cache *gcache.Cache
+var (
+	// cache 缓存驱动
+	cache *gcache.Cache
+	// adapter 当前使用的缓存适配器
+	adapter gcache.Adapter
+)
 
 // Instance 缓存实例
 func Instance() *gcache.Cache {
@@ -24,11 +31,20 @@ func Instance() *gcache.Cache {
 
 // SetAdapter 设置缓存适配器
 func SetAdapter(ctx context.Context, cfg *webSchema.CacheConfig) {
-	var adapter gcache.Adapter
+	// 标签索引使用的 Redis，为 nil 时由适配器自己实现或者保存在内存中
+	var tagRedis *gredis.Redis
 
 	switch cfg.Adapter {
 	case "redis":
-		adapter = gcache.NewAdapterRedis(g.Redis())
+		tagRedis = g.Redis()
+		adapter = gcache.NewAdapterRedis(tagRedis)
+	case "layered":
+		// 本地缓存 + Redis 二级缓存，写入时通过 Redis 发布订阅通知所有实例删除本地缓存
+		tagRedis = g.Redis()
+		adapter = layered.NewAdapterLayered(tagRedis, layered.Options{
+			Size: cfg.L1Size,
+			TTL:  time.Duration(cfg.L1Ttl) * time.Millisecond,
+		})
 	case "file":
 		fileDir := cfg.FileDir
 		if fileDir == "" {
@@ -53,4 +69,36 @@ func SetAdapter(ctx context.Context, cfg *webSchema.CacheConfig) {
 	// 通用缓存
 	cache = gcache.New()
 	cache.SetAdapter(adapter)
+	// 缓存标签
+	tagStore = newTagStore(adapter, tagRedis)
+}
+
+// Adapter 当前使用的缓存适配器，未初始化时返回 nil
+func Adapter() gcache.Adapter {
+	return adapter
+}
+
+// LayeredStats 二级缓存各层的命中统计，未使用二级缓存时 ok 为 false
+func LayeredStats() (stats layered.Stats, ok bool) {
+	l, ok := adapter.(*layered.AdapterLayered)
+	if !ok {
+		return
+	}
+	return l.Stats(), true
+}
+
+// AdapterName 当前使用的缓存适配器名称
+func AdapterName() string {
+	switch adapter.(type) {
+	case *layered.AdapterLayered:
+		return "layered"
+	case *gcache.AdapterRedis:
+		return "redis"
+	case *file.AdapterFile:
+		return "file"
+	case nil:
+		return ""
+	default:
+		return "memory"
+	}
 }
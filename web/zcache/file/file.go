@@ -9,38 +9,69 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gogf/gf/v2/container/gvar"
-	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/os/gcache"
 	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/gtimer"
 	"github.com/gogf/gf/v2/util/gconv"
 )
 
 type (
 	// AdapterFile is the gcache adapter implements using file server.
+	// Each key is stored in its own file, which also records the original key,
+	// so the directory itself is the key index used for enumeration.
+	// Writes are atomic (temp file + rename) and the *Lock variants are
+	// guarded by file locks, so several processes can share one directory.
 	AdapterFile struct {
-		dir string
+		dir     string
+		sweeper *gtimer.Entry
 	}
 
 	fileContent struct {
-		Duration int64       `json:"duration"`
+		Key      string      `json:"key,omitempty"`
+		Expire   int64       `json:"expire,omitempty"`   // expire time in milliseconds, 0 means never expires
+		Duration int64       `json:"duration,omitempty"` // expire time in seconds written by older versions
 		Data     interface{} `json:"data,omitempty"`
 	}
 )
 
-const perm = 0o666
+const (
+	perm = 0o666
+
+	cacheExt = ".cache"
+	tempExt  = ".tmp"
+	lockDir  = ".lock"
+	tagDir   = ".tag"
+	tagExt   = ".tag"
+
+	sweepInterval = time.Minute // interval of deleting expired entries
+	tempExpire    = time.Hour   // temp files older than this are left by crashed writers
+)
 
 var (
 	CacheExpiredErr = errors.New("cache expired")
 )
 
-// NewAdapterFile creates and returns a new memory cache object.
+var _ gcache.Adapter = (*AdapterFile)(nil)
+
+// NewAdapterFile creates and returns a new file cache object.
+// Expired entries are deleted by a background sweeper until Close is called.
 func NewAdapterFile(dir string) gcache.Adapter {
-	return &AdapterFile{
+	c := &AdapterFile{
 		dir: dir,
 	}
+	c.sweeper = gtimer.AddSingleton(context.Background(), sweepInterval, func(ctx context.Context) {
+		c.Sweep()
+	})
+	return c
+}
+
+// Dir returns the cache directory.
+func (c *AdapterFile) Dir() string {
+	return c.dir
 }
 
 func (c *AdapterFile) Set(ctx context.Context, key interface{}, value interface{}, lifeTime time.Duration) (err error) {
@@ -52,127 +83,206 @@ func (c *AdapterFile) Set(ctx context.Context, key interface{}, value interface{
 }
 
 func (c *AdapterFile) SetMap(ctx context.Context, data map[interface{}]interface{}, duration time.Duration) (err error) {
-	return gerror.New("implement me")
+	for key, value := range data {
+		if err = c.Set(ctx, key, value, duration); err != nil {
+			return
+		}
+	}
+	return
 }
 
 func (c *AdapterFile) SetIfNotExist(ctx context.Context, key interface{}, value interface{}, duration time.Duration) (ok bool, err error) {
-	return false, gerror.New("implement me")
+	if f, isFunc := value.(gcache.Func); isFunc {
+		return c.SetIfNotExistFuncLock(ctx, key, f, duration)
+	}
+	fileKey := gconv.String(key)
+	err = c.withLock(fileKey, func() error {
+		if c.Has(fileKey) {
+			return nil
+		}
+		ok = true
+		return c.Set(ctx, fileKey, value, duration)
+	})
+	return
 }
 
+// SetIfNotExistFunc calls `f` without holding the lock, `f` may be called by several callers at the same time.
 func (c *AdapterFile) SetIfNotExistFunc(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (ok bool, err error) {
-	return false, gerror.New("implement me")
+	fileKey := gconv.String(key)
+	if c.Has(fileKey) {
+		return false, nil
+	}
+	value, err := f(ctx)
+	if err != nil {
+		return false, err
+	}
+	return c.SetIfNotExist(ctx, fileKey, value, duration)
 }
 
+// SetIfNotExistFuncLock calls `f` while holding the file lock of `key`, so only one caller across processes calls it.
 func (c *AdapterFile) SetIfNotExistFuncLock(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (ok bool, err error) {
-	return false, gerror.New("implement me")
+	fileKey := gconv.String(key)
+	if c.Has(fileKey) {
+		return false, nil
+	}
+	err = c.withLock(fileKey, func() error {
+		if c.Has(fileKey) {
+			return nil
+		}
+		value, err := f(ctx)
+		if err != nil {
+			return err
+		}
+		ok = true
+		return c.Set(ctx, fileKey, value, duration)
+	})
+	return
 }
 
+// Get returns nil if `key` does not exist or it's expired.
 func (c *AdapterFile) Get(ctx context.Context, key interface{}) (*gvar.Var, error) {
 	fetch, err := c.Fetch(gconv.String(key))
 	if err != nil {
 		return nil, err
 	}
+	if fetch == nil {
+		return nil, nil
+	}
 	return gvar.New(fetch), nil
 }
 
 func (c *AdapterFile) GetOrSet(ctx context.Context, key interface{}, value interface{}, duration time.Duration) (result *gvar.Var, err error) {
-	result, err = c.Get(ctx, key)
-	if err != nil && !errors.Is(err, CacheExpiredErr) {
-		return nil, err
+	if f, isFunc := value.(gcache.Func); isFunc {
+		return c.GetOrSetFuncLock(ctx, key, f, duration)
 	}
-	if result.IsNil() {
-		return gvar.New(value), c.Set(ctx, key, value, duration)
+	if result, err = c.Get(ctx, key); err != nil || result != nil {
+		return
 	}
-	return
+	if value == nil {
+		return nil, nil
+	}
+	return gvar.New(value), c.Set(ctx, key, value, duration)
 }
 
 func (c *AdapterFile) GetOrSetFunc(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (result *gvar.Var, err error) {
-	v, err := c.Get(ctx, key)
-	if err != nil && !errors.Is(err, CacheExpiredErr) {
+	if result, err = c.Get(ctx, key); err != nil || result != nil {
+		return
+	}
+	value, err := f(ctx)
+	if err != nil {
 		return nil, err
 	}
-	if v.IsNil() {
-		value, err := f(ctx)
-		if err != nil {
-			return nil, err
-		}
-		if value == nil {
-			return nil, nil
-		}
-		return gvar.New(value), c.Set(ctx, key, value, duration)
-	} else {
-		return v, nil
+	if value == nil {
+		return nil, nil
 	}
+	return gvar.New(value), c.Set(ctx, key, value, duration)
 }
 
+// GetOrSetFuncLock calls `f` while holding the file lock of `key`, so only one caller across processes calls it.
 func (c *AdapterFile) GetOrSetFuncLock(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (result *gvar.Var, err error) {
-	return c.GetOrSetFunc(ctx, key, f, duration)
+	fileKey := gconv.String(key)
+	if result, err = c.Get(ctx, fileKey); err != nil || result != nil {
+		return
+	}
+	err = c.withLock(fileKey, func() error {
+		if result, err = c.Get(ctx, fileKey); err != nil || result != nil {
+			return err
+		}
+		value, err := f(ctx)
+		if err != nil || value == nil {
+			return err
+		}
+		result = gvar.New(value)
+		return c.Set(ctx, fileKey, value, duration)
+	})
+	return
 }
 
 func (c *AdapterFile) Contains(ctx context.Context, key interface{}) (bool, error) {
 	return c.Has(gconv.String(key)), nil
 }
 
+// Size returns the number of entries that are not expired.
 func (c *AdapterFile) Size(ctx context.Context) (size int, err error) {
-	return 0, nil
+	err = c.walk(func(content *fileContent) {
+		size++
+	})
+	return
 }
 
+// Data returns all entries that are not expired, entries written by older versions
+// without the original key are skipped.
 func (c *AdapterFile) Data(ctx context.Context) (data map[interface{}]interface{}, err error) {
-	return nil, gerror.New("implement me")
+	data = make(map[interface{}]interface{})
+	err = c.walk(func(content *fileContent) {
+		if content.Key != "" {
+			data[content.Key] = content.Data
+		}
+	})
+	return
 }
 
 func (c *AdapterFile) Keys(ctx context.Context) (keys []interface{}, err error) {
-	return nil, gerror.New("implement me")
+	err = c.walk(func(content *fileContent) {
+		if content.Key != "" {
+			keys = append(keys, content.Key)
+		}
+	})
+	return
 }
 
 func (c *AdapterFile) Values(ctx context.Context) (values []interface{}, err error) {
-	return nil, gerror.New("implement me")
+	err = c.walk(func(content *fileContent) {
+		values = append(values, content.Data)
+	})
+	return
 }
 
+// Update updates the value of `key` without changing its expiration.
+// It does nothing if `key` does not exist and deletes `key` if `value` is nil.
 func (c *AdapterFile) Update(ctx context.Context, key interface{}, value interface{}) (oldValue *gvar.Var, exist bool, err error) {
-	return nil, false, gerror.New("implement me")
+	fileKey := gconv.String(key)
+	err = c.withLock(fileKey, func() error {
+		content, err := c.read(fileKey)
+		if err != nil || content == nil {
+			return ignoreExpired(err)
+		}
+		oldValue, exist = gvar.New(content.Data), true
+		if value == nil {
+			return c.Delete(fileKey)
+		}
+		content.Data = gconv.String(value)
+		return c.write(content)
+	})
+	return
 }
 
+// UpdateExpire returns -1 and does nothing if `key` does not exist, it deletes `key` if `duration` < 0.
 func (c *AdapterFile) UpdateExpire(ctx context.Context, key interface{}, duration time.Duration) (oldDuration time.Duration, err error) {
-	var (
-		v       *gvar.Var
-		oldTTL  int64
-		fileKey = gconv.String(key)
-	)
-	// TTL.
-	expire, err := c.GetExpire(ctx, fileKey)
-	if err != nil {
-		return
-	}
-	oldTTL = int64(expire)
-	if oldTTL == -2 {
-		// It does not exist.
-		return
-	}
-	oldDuration = time.Duration(oldTTL) * time.Second
-	// DEL.
-	if duration < 0 {
-		err = c.Delete(fileKey)
-		return
-	}
-	v, err = c.Get(ctx, fileKey)
-	if err != nil {
-		return
-	}
-	err = c.Set(ctx, fileKey, v.Val(), duration)
+	fileKey := gconv.String(key)
+	oldDuration = -1
+	err = c.withLock(fileKey, func() error {
+		content, err := c.read(fileKey)
+		if err != nil || content == nil {
+			return ignoreExpired(err)
+		}
+		oldDuration = content.ttl()
+		if duration < 0 {
+			return c.Delete(fileKey)
+		}
+		content.Expire = expireAt(duration)
+		return c.write(content)
+	})
 	return
 }
 
+// GetExpire returns 0 if `key` does not expire and -1 if `key` does not exist.
 func (c *AdapterFile) GetExpire(ctx context.Context, key interface{}) (time.Duration, error) {
-	content, err := c.read(gconv.String(key))
-	if err != nil {
-		return -1, nil
+	content, err := c.fetch(gconv.String(key))
+	if err != nil || content == nil {
+		return -1, ignoreExpired(err)
 	}
-
-	if content.Duration <= time.Now().Unix() {
-		return -1, nil
-	}
-	return time.Duration(time.Now().Unix()-content.Duration) * time.Second, nil
+	return content.ttl(), nil
 }
 
 func (c *AdapterFile) Remove(ctx context.Context, keys ...interface{}) (lastValue *gvar.Var, err error) {
@@ -192,25 +302,68 @@ func (c *AdapterFile) Clear(ctx context.Context) error {
 	return c.Flush()
 }
 
+// Close stops the background sweeper.
 func (c *AdapterFile) Close(ctx context.Context) error {
+	if c.sweeper != nil {
+		c.sweeper.Close()
+	}
 	return nil
 }
 
-func (c *AdapterFile) createName(key string) string {
+func (c *AdapterFile) hash(key string) string {
 	h := sha256.New()
 	_, _ = h.Write([]byte(key))
-	hash := hex.EncodeToString(h.Sum(nil))
-	return filepath.Join(c.dir, fmt.Sprintf("%s.cache", hash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *AdapterFile) createName(key string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s%s", c.hash(key), cacheExt))
 }
 
+// read returns CacheExpiredErr if `key` has expired. It does not take the file lock of `key`,
+// so it may be called while holding it, expired entries are deleted by fetch and Sweep.
 func (c *AdapterFile) read(key string) (*fileContent, error) {
-	rp := gfile.RealPath(c.createName(key))
-	if rp == "" {
-		return nil, nil
+	content, err := c.readFile(c.createName(key))
+	if err != nil || content == nil {
+		return nil, err
+	}
+	if content.expired() {
+		return nil, CacheExpiredErr
 	}
+	return content, nil
+}
 
-	value, err := os.ReadFile(rp)
+// fetch reads `key` and deletes it if it has expired, the entry is left to Sweep when its file lock is held.
+func (c *AdapterFile) fetch(key string) (*fileContent, error) {
+	content, err := c.read(key)
+	if errors.Is(err, CacheExpiredErr) {
+		_ = c.tryWithLock(key, func() error {
+			return c.removeExpired(c.createName(key))
+		})
+	}
+	return content, err
+}
+
+// removeExpired deletes the file `name` if it is still expired, an entry written again after it was read is kept.
+// The caller holds the file lock of the key.
+func (c *AdapterFile) removeExpired(name string) error {
+	content, err := c.readFile(name)
+	if err != nil || content == nil || !content.expired() {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readFile returns nil if the file does not exist.
+func (c *AdapterFile) readFile(name string) (*fileContent, error) {
+	value, err := os.ReadFile(name)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -218,16 +371,151 @@ func (c *AdapterFile) read(key string) (*fileContent, error) {
 	if err := json.Unmarshal(value, content); err != nil {
 		return nil, err
 	}
+	if content.Expire == 0 && content.Duration > 0 {
+		content.Expire = content.Duration * 1000
+	}
+	return content, nil
+}
 
-	if content.Duration == 0 {
-		return content, nil
+// write replaces the entry atomically, readers never see a partially written file.
+func (c *AdapterFile) write(content *fileContent) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
 	}
+	return c.writeFile(c.createName(content.Key), data)
+}
 
-	if content.Duration <= time.Now().Unix() {
-		_ = c.Delete(key)
-		return nil, CacheExpiredErr
+// writeFile replaces the file `name` atomically with `data`.
+func (c *AdapterFile) writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(c.dir, "*"+tempExt)
+	if err != nil {
+		return err
 	}
-	return content, nil
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// walk calls `f` for every entry that is not expired.
+func (c *AdapterFile) walk(f func(content *fileContent)) error {
+	names, err := filepath.Glob(filepath.Join(c.dir, "*"+cacheExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		content, err := c.readFile(name)
+		if err != nil || content == nil || content.expired() {
+			continue
+		}
+		f(content)
+	}
+	return nil
+}
+
+// withLock calls `f` while holding the file lock of `key`.
+// Keys are spread over 256 lock files, so lock files never need to be deleted.
+func (c *AdapterFile) withLock(key string, f func() error) error {
+	return c.lock(key, true, f)
+}
+
+// tryWithLock calls `f` only if the file lock of `key` is free. It never blocks,
+// so it may be called while holding the lock of another key sharing the lock file.
+func (c *AdapterFile) tryWithLock(key string, f func() error) error {
+	return c.lock(key, false, f)
+}
+
+func (c *AdapterFile) lock(key string, wait bool, f func() error) error {
+	dir := filepath.Join(c.dir, lockDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, c.hash(key)[:2]), os.O_CREATE|os.O_RDWR, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if wait {
+		err = lockFile(file)
+	} else {
+		var ok bool
+		if ok, err = tryLockFile(file); err == nil && !ok {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = unlockFile(file)
+	}()
+	return f()
+}
+
+// Sweep deletes expired entries, temp files left by crashed writers and tag indexes without live keys.
+func (c *AdapterFile) Sweep() {
+	names, _ := filepath.Glob(filepath.Join(c.dir, "*"+cacheExt))
+	for _, name := range names {
+		content, err := c.readFile(name)
+		if err == nil && content != nil && content.expired() {
+			_ = c.withLock(content.Key, func() error {
+				return c.removeExpired(name)
+			})
+		}
+	}
+
+	temps, _ := filepath.Glob(filepath.Join(c.dir, "*"+tempExt))
+	for _, name := range temps {
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > tempExpire {
+			_ = os.Remove(name)
+		}
+	}
+	c.sweepTags()
+}
+
+func (f *fileContent) expired() bool {
+	return f.Expire > 0 && f.Expire <= time.Now().UnixMilli()
+}
+
+// ttl returns 0 if the entry does not expire.
+func (f *fileContent) ttl() time.Duration {
+	if f.Expire == 0 {
+		return 0
+	}
+	return time.Duration(f.Expire-time.Now().UnixMilli()) * time.Millisecond
+}
+
+// expireAt returns 0 if `lifeTime` is 0, which means never expires.
+func expireAt(lifeTime time.Duration) int64 {
+	if lifeTime <= 0 {
+		return 0
+	}
+	return time.Now().Add(lifeTime).UnixMilli()
+}
+
+func ignoreExpired(err error) error {
+	if errors.Is(err, CacheExpiredErr) {
+		return nil
+	}
+	return err
 }
 
 // Has checks if the cached key exists into the File storage
@@ -238,11 +526,11 @@ func (c *AdapterFile) Has(key string) bool {
 
 // Delete the cached key from File storage
 func (c *AdapterFile) Delete(key string) error {
-	_, err := os.Stat(c.createName(key))
+	err := os.Remove(c.createName(key))
 	if err != nil && os.IsNotExist(err) {
 		return nil
 	}
-	return os.Remove(c.createName(key))
+	return err
 }
 
 // DeleteMulti the cached key from File storage
@@ -255,15 +543,11 @@ func (c *AdapterFile) DeleteMulti(keys ...string) (err error) {
 	return
 }
 
-// Fetch retrieves the cached value from key of the File storage
+// Fetch retrieves the cached value from key of the File storage, it returns nil if the key is expired
 func (c *AdapterFile) Fetch(key string) (interface{}, error) {
-	content, err := c.read(key)
-	if err != nil {
-		return nil, err
-	}
-
-	if content == nil {
-		return nil, nil
+	content, err := c.fetch(key)
+	if err != nil || content == nil {
+		return nil, ignoreExpired(err)
 	}
 	return content.Data, nil
 }
@@ -272,7 +556,7 @@ func (c *AdapterFile) Fetch(key string) (interface{}, error) {
 func (c *AdapterFile) FetchMulti(keys []string) map[string]interface{} {
 	result := make(map[string]interface{})
 	for _, key := range keys {
-		if value, err := c.Fetch(key); err == nil {
+		if value, err := c.Fetch(key); err == nil && value != nil {
 			result[key] = value
 		}
 	}
@@ -281,38 +565,22 @@ func (c *AdapterFile) FetchMulti(keys []string) map[string]interface{} {
 
 // Flush removes all cached keys of the File storage
 func (c *AdapterFile) Flush() error {
-	dir, err := os.Open(c.dir)
+	if !gfile.Exists(c.dir) {
+		return nil
+	}
+	names, err := filepath.Glob(filepath.Join(c.dir, "*"))
 	if err != nil {
 		return err
 	}
-
-	defer func() {
-		_ = dir.Close()
-	}()
-
-	names, _ := dir.Readdirnames(-1)
-
 	for _, name := range names {
-		_ = os.Remove(filepath.Join(c.dir, name))
+		if strings.HasSuffix(name, cacheExt) || strings.HasSuffix(name, tempExt) {
+			_ = os.Remove(name)
+		}
 	}
-	return nil
+	return os.RemoveAll(filepath.Join(c.dir, tagDir))
 }
 
 // Save a value in File storage by key
 func (c *AdapterFile) Save(key string, value string, lifeTime time.Duration) error {
-	duration := int64(0)
-
-	if lifeTime > 0 {
-		duration = time.Now().Unix() + int64(lifeTime.Seconds())
-	}
-
-	content := &fileContent{duration, value}
-
-	data, err := json.Marshal(content)
-	if err != nil {
-		return err
-	}
-
-	err = os.WriteFile(c.createName(key), data, perm)
-	return err
+	return c.write(&fileContent{Key: key, Expire: expireAt(lifeTime), Data: value})
 }
@@ -0,0 +1,120 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tagContent is the index file of a tag, mapping the tagged keys to their expire time.
+// Expired keys are pruned when the tag is written, the index expires with its last key.
+type tagContent struct {
+	Tag  string           `json:"tag"`
+	Keys map[string]int64 `json:"keys"` // key -> expire time in milliseconds, 0 means never expires
+}
+
+// TagKey adds `key` to the index of each tag.
+func (c *AdapterFile) TagKey(ctx context.Context, key string, tags []string, duration time.Duration) error {
+	if err := os.MkdirAll(filepath.Join(c.dir, tagDir), 0o755); err != nil {
+		return err
+	}
+	expire := expireAt(duration)
+	for _, tag := range tags {
+		err := c.withLock(tagDir+tag, func() error {
+			content, err := c.readTag(tag)
+			if err != nil {
+				return err
+			}
+			content.prune()
+			content.Keys[key] = expire
+			data, err := json.Marshal(content)
+			if err != nil {
+				return err
+			}
+			return c.writeFile(c.tagName(tag), data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushTags deletes the index of each tag and returns the keys in them.
+func (c *AdapterFile) FlushTags(ctx context.Context, tags ...string) (keys []string, err error) {
+	for _, tag := range tags {
+		err = c.withLock(tagDir+tag, func() error {
+			content, err := c.readTag(tag)
+			if err != nil {
+				return err
+			}
+			for k := range content.Keys {
+				keys = append(keys, k)
+			}
+			if err = os.Remove(c.tagName(tag)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sweepTags deletes the index files whose keys are all expired.
+func (c *AdapterFile) sweepTags() {
+	names, _ := filepath.Glob(filepath.Join(c.dir, tagDir, "*"+tagExt))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		var content tagContent
+		if json.Unmarshal(data, &content) != nil || content.Tag == "" {
+			continue
+		}
+		_ = c.withLock(tagDir+content.Tag, func() error {
+			current, err := c.readTag(content.Tag)
+			if err == nil && current.prune() == 0 {
+				_ = os.Remove(name)
+			}
+			return nil
+		})
+	}
+}
+
+func (c *AdapterFile) tagName(tag string) string {
+	return filepath.Join(c.dir, tagDir, c.hash(tag)+tagExt)
+}
+
+func (c *AdapterFile) readTag(tag string) (*tagContent, error) {
+	content := &tagContent{Tag: tag, Keys: make(map[string]int64)}
+	data, err := os.ReadFile(c.tagName(tag))
+	if errors.Is(err, os.ErrNotExist) {
+		return content, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, content); err != nil || content.Keys == nil {
+		// a broken index is dropped, the tagged entries still expire on their own
+		return &tagContent{Tag: tag, Keys: make(map[string]int64)}, nil
+	}
+	return content, nil
+}
+
+// prune deletes the expired keys and returns the number of keys left.
+func (t *tagContent) prune() int {
+	now := time.Now().UnixMilli()
+	for k, e := range t.Keys {
+		if e != 0 && e <= now {
+			delete(t.Keys, k)
+		}
+	}
+	return len(t.Keys)
+}
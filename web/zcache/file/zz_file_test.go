@@ -0,0 +1,239 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+func newTestCache(t *gtest.T) (*gcache.Cache, *AdapterFile) {
+	adapter := NewAdapterFile(t.T.TempDir()).(*AdapterFile)
+	t.T.Cleanup(func() { _ = adapter.Close(context.Background()) })
+	cache := gcache.New()
+	cache.SetAdapter(adapter)
+	return cache, adapter
+}
+
+func TestAdapterFile_SetGet(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx      = context.Background()
+			cache, _ = newTestCache(t)
+		)
+
+		t.AssertNil(cache.Set(ctx, "k1", "v1", 0))
+		v, err := cache.Get(ctx, "k1")
+		t.AssertNil(err)
+		t.Assert(v, "v1")
+
+		// 不存在和已过期的 key 返回 nil
+		v, err = cache.Get(ctx, "none")
+		t.AssertNil(err)
+		t.Assert(v.IsNil(), true)
+
+		t.AssertNil(cache.Set(ctx, "k2", "v2", 100*time.Millisecond))
+		expire, err := cache.GetExpire(ctx, "k2")
+		t.AssertNil(err)
+		t.Assert(expire > 0 && expire <= 100*time.Millisecond, true)
+		time.Sleep(150 * time.Millisecond)
+		v, err = cache.Get(ctx, "k2")
+		t.AssertNil(err)
+		t.Assert(v.IsNil(), true)
+
+		expire, err = cache.GetExpire(ctx, "k1")
+		t.AssertNil(err)
+		t.Assert(expire == 0, true)
+		expire, err = cache.GetExpire(ctx, "k2")
+		t.AssertNil(err)
+		t.Assert(expire == -1, true)
+
+		t.AssertNil(cache.SetMap(ctx, map[interface{}]interface{}{"k3": "v3", "k4": "v4"}, time.Minute))
+		keys, err := cache.KeyStrings(ctx)
+		t.AssertNil(err)
+		sort.Strings(keys)
+		t.Assert(keys, []string{"k1", "k3", "k4"})
+		size, err := cache.Size(ctx)
+		t.AssertNil(err)
+		t.Assert(size, 3)
+		data, err := cache.Data(ctx)
+		t.AssertNil(err)
+		t.Assert(data, map[interface{}]interface{}{"k1": "v1", "k3": "v3", "k4": "v4"})
+		values, err := cache.Values(ctx)
+		t.AssertNil(err)
+		t.Assert(len(values), 3)
+
+		removed, err := cache.Remove(ctx, "k3", "k4")
+		t.AssertNil(err)
+		t.Assert(removed, "v4")
+		t.AssertNil(cache.Clear(ctx))
+		size, err = cache.Size(ctx)
+		t.AssertNil(err)
+		t.Assert(size, 0)
+	})
+}
+
+func TestAdapterFile_SetIfNotExist(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx      = context.Background()
+			cache, _ = newTestCache(t)
+		)
+
+		ok, err := cache.SetIfNotExist(ctx, "k1", "v1", 0)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		ok, err = cache.SetIfNotExist(ctx, "k1", "v2", 0)
+		t.AssertNil(err)
+		t.Assert(ok, false)
+		v, _ := cache.Get(ctx, "k1")
+		t.Assert(v, "v1")
+
+		ok, err = cache.SetIfNotExistFunc(ctx, "k2", func(ctx context.Context) (interface{}, error) {
+			return "v2", nil
+		}, 0)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+
+		// 并发调用时 f 只执行一次
+		var (
+			calls int32
+			wg    sync.WaitGroup
+			f     = func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(20 * time.Millisecond)
+				return "v3", nil
+			}
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := cache.GetOrSetFuncLock(ctx, "k3", f, 0)
+				t.AssertNil(err)
+				t.Assert(v, "v3")
+			}()
+		}
+		wg.Wait()
+		t.Assert(calls, 1)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cache.SetIfNotExistFuncLock(ctx, "k4", f, 0)
+				t.AssertNil(err)
+			}()
+		}
+		wg.Wait()
+		t.Assert(calls, 2)
+	})
+}
+
+func TestAdapterFile_Update(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx      = context.Background()
+			cache, _ = newTestCache(t)
+		)
+
+		old, exist, err := cache.Update(ctx, "k1", "v1")
+		t.AssertNil(err)
+		t.Assert(exist, false)
+		t.Assert(old.IsNil(), true)
+
+		t.AssertNil(cache.Set(ctx, "k1", "v1", time.Minute))
+		old, exist, err = cache.Update(ctx, "k1", "v2")
+		t.AssertNil(err)
+		t.Assert(exist, true)
+		t.Assert(old, "v1")
+		v, _ := cache.Get(ctx, "k1")
+		t.Assert(v, "v2")
+		expire, _ := cache.GetExpire(ctx, "k1")
+		t.Assert(expire > 50*time.Second, true)
+
+		oldExpire, err := cache.UpdateExpire(ctx, "k1", 0)
+		t.AssertNil(err)
+		t.Assert(oldExpire > 50*time.Second, true)
+		expire, _ = cache.GetExpire(ctx, "k1")
+		t.Assert(expire == 0, true)
+
+		oldExpire, err = cache.UpdateExpire(ctx, "none", time.Minute)
+		t.AssertNil(err)
+		t.Assert(oldExpire == -1, true)
+
+		_, err = cache.UpdateExpire(ctx, "k1", -1)
+		t.AssertNil(err)
+		ok, _ := cache.Contains(ctx, "k1")
+		t.Assert(ok, false)
+	})
+}
+
+func TestAdapterFile_Sweep(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx            = context.Background()
+			cache, adapter = newTestCache(t)
+		)
+
+		t.AssertNil(cache.Set(ctx, "k1", "v1", 50*time.Millisecond))
+		t.AssertNil(cache.Set(ctx, "k2", "v2", 0))
+		// 旧版本写入的文件没有 key，过期时间为秒
+		legacy := filepath.Join(adapter.dir, "legacy"+cacheExt)
+		t.AssertNil(os.WriteFile(legacy, []byte(`{"duration":`+gconv.String(time.Now().Unix()-1)+`,"data":"v"}`), perm))
+		time.Sleep(100 * time.Millisecond)
+
+		adapter.Sweep()
+		names, _ := filepath.Glob(filepath.Join(adapter.dir, "*"+cacheExt))
+		t.Assert(len(names), 1)
+		v, _ := cache.Get(ctx, "k2")
+		t.Assert(v, "v2")
+	})
+}
+
+func TestAdapterFile_RemoveExpired(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx            = context.Background()
+			cache, adapter = newTestCache(t)
+		)
+
+		t.AssertNil(cache.Set(ctx, "k1", "v1", 50*time.Millisecond))
+		time.Sleep(100 * time.Millisecond)
+
+		// 其他调用方持有锁时读取不删除，锁内重新写入的值不会被删除
+		t.AssertNil(adapter.withLock("k1", func() error {
+			v, err := cache.Get(ctx, "k1")
+			t.AssertNil(err)
+			t.AssertNil(v)
+			return cache.Set(ctx, "k1", "v2", 0)
+		}))
+		v, err := cache.Get(ctx, "k1")
+		t.AssertNil(err)
+		t.Assert(v, "v2")
+
+		// 清理前重新检查过期时间
+		name := adapter.createName("k1")
+		adapter.Sweep()
+		t.AssertNil(adapter.removeExpired(name))
+		_, err = os.Stat(name)
+		t.AssertNil(err)
+
+		// 没有持有锁时读取删除过期的 key
+		t.AssertNil(cache.Set(ctx, "k2", "v2", 50*time.Millisecond))
+		time.Sleep(100 * time.Millisecond)
+		v, err = cache.Get(ctx, "k2")
+		t.AssertNil(err)
+		t.AssertNil(v)
+		_, err = os.Stat(adapter.createName("k2"))
+		t.Assert(os.IsNotExist(err), true)
+	})
+}
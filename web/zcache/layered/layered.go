@@ -0,0 +1,367 @@
+package layered
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+type (
+	// AdapterLayered is the gcache adapter with an in-process LRU (L1) in front of redis (L2).
+	// Reads are served from L1 when possible, writes go to L2 and evict the key from L1
+	// on every instance through redis pub/sub. L1 entries live at most Options.TTL,
+	// which bounds staleness when an invalidation is lost.
+	AdapterLayered struct {
+		l1      *gcache.AdapterMemory
+		l2      gcache.Adapter
+		redis   *gredis.Redis
+		options Options
+		id      string // instance id, used to skip invalidations published by itself
+
+		generation atomic.Int64 // increased on every invalidation, guards L1 fills racing with writes
+		stats      counters
+		cancel     context.CancelFunc
+		done       chan struct{}
+	}
+
+	// Options of the layered adapter.
+	Options struct {
+		Size    int           // capacity of L1, least recently used entries are evicted
+		TTL     time.Duration // max lifetime of L1 entries
+		Channel string        // redis pub/sub channel for invalidations
+	}
+
+	// Stats is the hit/miss counters of each layer.
+	Stats struct {
+		L1Hits      int64 // reads served by L1
+		L1Misses    int64 // reads falling through to L2
+		L1Size      int   // entries in L1
+		L2Hits      int64 // reads served by L2
+		L2Misses    int64 // reads missing in both layers
+		Published   int64 // invalidations published
+		Received    int64 // invalidations received from other instances
+		Reconnected int64 // times the subscription was re-established, L1 is cleared each time
+	}
+
+	counters struct {
+		l1Hits, l1Misses, l2Hits, l2Misses, published, received, reconnected atomic.Int64
+	}
+
+	// invalidation is the message published on writes.
+	invalidation struct {
+		Id    string   `json:"id"`
+		Keys  []string `json:"keys,omitempty"`
+		Clear bool     `json:"clear,omitempty"`
+	}
+)
+
+const (
+	DefaultSize    = 10000
+	DefaultTTL     = 5 * time.Second
+	DefaultChannel = "zcache:invalidate"
+
+	reconnectDelay = time.Second
+)
+
+var _ gcache.Adapter = (*AdapterLayered)(nil)
+
+// NewAdapterLayered creates and returns a new layered cache object using the given redis as L2.
+// Invalidations are received by a background subscriber until Close is called.
+func NewAdapterLayered(redis *gredis.Redis, options Options) *AdapterLayered {
+	if options.Size <= 0 {
+		options.Size = DefaultSize
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultTTL
+	}
+	if options.Channel == "" {
+		options.Channel = DefaultChannel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &AdapterLayered{
+		l1:      gcache.NewAdapterMemoryLru(options.Size),
+		l2:      gcache.NewAdapterRedis(redis),
+		redis:   redis,
+		options: options,
+		id:      guid.S(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	ready := make(chan struct{})
+	go c.subscribe(ctx, ready)
+	<-ready
+	return c
+}
+
+// Stats returns the hit/miss counters of each layer.
+func (c *AdapterLayered) Stats() Stats {
+	size, _ := c.l1.Size(context.Background())
+	return Stats{
+		L1Hits:      c.stats.l1Hits.Load(),
+		L1Misses:    c.stats.l1Misses.Load(),
+		L1Size:      size,
+		L2Hits:      c.stats.l2Hits.Load(),
+		L2Misses:    c.stats.l2Misses.Load(),
+		Published:   c.stats.published.Load(),
+		Received:    c.stats.received.Load(),
+		Reconnected: c.stats.reconnected.Load(),
+	}
+}
+
+func (c *AdapterLayered) Set(ctx context.Context, key interface{}, value interface{}, lifeTime time.Duration) (err error) {
+	if err = c.l2.Set(ctx, key, value, lifeTime); err != nil {
+		return
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) SetMap(ctx context.Context, data map[interface{}]interface{}, duration time.Duration) (err error) {
+	if len(data) == 0 {
+		return nil
+	}
+	if err = c.l2.SetMap(ctx, data, duration); err != nil {
+		return
+	}
+	keys := make([]interface{}, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *AdapterLayered) SetIfNotExist(ctx context.Context, key interface{}, value interface{}, duration time.Duration) (ok bool, err error) {
+	if ok, err = c.l2.SetIfNotExist(ctx, key, value, duration); err != nil || !ok {
+		return
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) SetIfNotExistFunc(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (ok bool, err error) {
+	if ok, err = c.l2.SetIfNotExistFunc(ctx, key, f, duration); err != nil || !ok {
+		return
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) SetIfNotExistFuncLock(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (ok bool, err error) {
+	if ok, err = c.l2.SetIfNotExistFuncLock(ctx, key, f, duration); err != nil || !ok {
+		return
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+// Get reads L1 first, and fills L1 from L2 on a miss.
+// The L1 entry never outlives the L2 one, so an expired token is not served from L1.
+func (c *AdapterLayered) Get(ctx context.Context, key interface{}) (*gvar.Var, error) {
+	k := gconv.String(key)
+	if v, err := c.l1.Get(ctx, k); err == nil && !v.IsNil() {
+		c.stats.l1Hits.Add(1)
+		return v, nil
+	}
+	c.stats.l1Misses.Add(1)
+
+	generation := c.generation.Load()
+	v, err := c.l2.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	if v.IsNil() {
+		c.stats.l2Misses.Add(1)
+		return v, nil
+	}
+	c.stats.l2Hits.Add(1)
+
+	ttl := c.options.TTL
+	if expire, err := c.l2.GetExpire(ctx, k); err != nil || expire < 0 {
+		return v, nil
+	} else if expire > 0 && expire < ttl {
+		ttl = expire
+	}
+	// skip the fill when an invalidation arrived during the read, the value may be stale
+	if c.generation.Load() == generation {
+		_ = c.l1.Set(ctx, k, v.Val(), ttl)
+	}
+	return v, nil
+}
+
+func (c *AdapterLayered) GetOrSet(ctx context.Context, key interface{}, value interface{}, duration time.Duration) (*gvar.Var, error) {
+	if v, err := c.Get(ctx, key); err != nil || !v.IsNil() {
+		return v, err
+	}
+	v, err := c.l2.GetOrSet(ctx, key, value, duration)
+	if err != nil {
+		return nil, err
+	}
+	return v, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) GetOrSetFunc(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (*gvar.Var, error) {
+	if v, err := c.Get(ctx, key); err != nil || !v.IsNil() {
+		return v, err
+	}
+	v, err := c.l2.GetOrSetFunc(ctx, key, f, duration)
+	if err != nil {
+		return nil, err
+	}
+	return v, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) GetOrSetFuncLock(ctx context.Context, key interface{}, f gcache.Func, duration time.Duration) (*gvar.Var, error) {
+	if v, err := c.Get(ctx, key); err != nil || !v.IsNil() {
+		return v, err
+	}
+	v, err := c.l2.GetOrSetFuncLock(ctx, key, f, duration)
+	if err != nil {
+		return nil, err
+	}
+	return v, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) Contains(ctx context.Context, key interface{}) (bool, error) {
+	if ok, err := c.l1.Contains(ctx, gconv.String(key)); err == nil && ok {
+		return true, nil
+	}
+	return c.l2.Contains(ctx, key)
+}
+
+func (c *AdapterLayered) Size(ctx context.Context) (size int, err error) {
+	return c.l2.Size(ctx)
+}
+
+func (c *AdapterLayered) Data(ctx context.Context) (data map[interface{}]interface{}, err error) {
+	return c.l2.Data(ctx)
+}
+
+func (c *AdapterLayered) Keys(ctx context.Context) (keys []interface{}, err error) {
+	return c.l2.Keys(ctx)
+}
+
+func (c *AdapterLayered) Values(ctx context.Context) (values []interface{}, err error) {
+	return c.l2.Values(ctx)
+}
+
+func (c *AdapterLayered) Update(ctx context.Context, key interface{}, value interface{}) (oldValue *gvar.Var, exist bool, err error) {
+	if oldValue, exist, err = c.l2.Update(ctx, key, value); err != nil || !exist {
+		return
+	}
+	return oldValue, exist, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) UpdateExpire(ctx context.Context, key interface{}, duration time.Duration) (oldDuration time.Duration, err error) {
+	if oldDuration, err = c.l2.UpdateExpire(ctx, key, duration); err != nil || oldDuration < 0 {
+		return
+	}
+	return oldDuration, c.invalidate(ctx, key)
+}
+
+func (c *AdapterLayered) GetExpire(ctx context.Context, key interface{}) (time.Duration, error) {
+	return c.l2.GetExpire(ctx, key)
+}
+
+func (c *AdapterLayered) Remove(ctx context.Context, keys ...interface{}) (lastValue *gvar.Var, err error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if lastValue, err = c.l2.Remove(ctx, keys...); err != nil {
+		return
+	}
+	return lastValue, c.invalidate(ctx, keys...)
+}
+
+func (c *AdapterLayered) Clear(ctx context.Context) (err error) {
+	if err = c.l2.Clear(ctx); err != nil {
+		return
+	}
+	c.generation.Add(1)
+	_ = c.l1.Clear(ctx)
+	return c.publish(ctx, invalidation{Id: c.id, Clear: true})
+}
+
+// Close stops the subscriber, L2 is left open as the redis client is shared.
+func (c *AdapterLayered) Close(ctx context.Context) error {
+	c.cancel()
+	<-c.done
+	return c.l1.Close(ctx)
+}
+
+// invalidate evicts the keys from the local L1 and publishes them to other instances.
+func (c *AdapterLayered) invalidate(ctx context.Context, keys ...interface{}) error {
+	c.generation.Add(1)
+	msg := invalidation{Id: c.id, Keys: make([]string, 0, len(keys))}
+	for _, key := range keys {
+		k := gconv.String(key)
+		_, _ = c.l1.Remove(ctx, k)
+		msg.Keys = append(msg.Keys, k)
+	}
+	return c.publish(ctx, msg)
+}
+
+func (c *AdapterLayered) publish(ctx context.Context, msg invalidation) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = c.redis.Publish(ctx, c.options.Channel, string(payload)); err != nil {
+		return err
+	}
+	c.stats.published.Add(1)
+	return nil
+}
+
+// subscribe receives invalidations until ctx is canceled.
+// Invalidations published while the subscription is down are lost, so L1 is cleared on reconnect.
+func (c *AdapterLayered) subscribe(ctx context.Context, ready chan struct{}) {
+	defer close(c.done)
+	for first := true; ; first = false {
+		conn, _, err := c.redis.Subscribe(ctx, c.options.Channel)
+		if first {
+			close(ready)
+		} else if err == nil {
+			c.stats.reconnected.Add(1)
+		}
+		if err == nil {
+			c.generation.Add(1)
+			_ = c.l1.Clear(ctx)
+			c.receive(ctx, conn)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (c *AdapterLayered) receive(ctx context.Context, conn gredis.Conn) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close(context.Background()) })
+	defer stop()
+	for {
+		message, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				_ = conn.Close(ctx)
+			}
+			return
+		}
+		var msg invalidation
+		if err = json.Unmarshal([]byte(message.Payload), &msg); err != nil || msg.Id == c.id {
+			continue
+		}
+		c.stats.received.Add(1)
+		c.generation.Add(1)
+		if msg.Clear {
+			_ = c.l1.Clear(ctx)
+			continue
+		}
+		for _, key := range msg.Keys {
+			_, _ = c.l1.Remove(ctx, key)
+		}
+	}
+}
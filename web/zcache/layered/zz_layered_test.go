@@ -0,0 +1,111 @@
+package layered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// newTestCache 创建连接同一个 redis 的缓存，模拟多个实例
+func newTestCache(t *gtest.T, addr string) (*gcache.Cache, *AdapterLayered) {
+	redis, err := gredis.New(&gredis.Config{Address: addr})
+	t.AssertNil(err)
+	adapter := NewAdapterLayered(redis, Options{Size: 100, TTL: time.Minute})
+	t.T.Cleanup(func() {
+		_ = adapter.Close(context.Background())
+		_ = redis.Close(context.Background())
+	})
+	cache := gcache.New()
+	cache.SetAdapter(adapter)
+	return cache, adapter
+}
+
+// waitReceived 等待收到其他实例的失效通知
+func waitReceived(adapter *AdapterLayered, n int64) {
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if adapter.Stats().Received >= n {
+			return
+		}
+	}
+}
+
+func TestAdapterLayered_Get(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx          = context.Background()
+			mr           = miniredis.RunT(t.T)
+			cache, layer = newTestCache(t, mr.Addr())
+		)
+
+		t.AssertNil(cache.Set(ctx, "k1", "v1", 0))
+		v, err := cache.Get(ctx, "k1")
+		t.AssertNil(err)
+		t.Assert(v, "v1")
+		v, err = cache.Get(ctx, "k1")
+		t.AssertNil(err)
+		t.Assert(v, "v1")
+		v, err = cache.Get(ctx, "none")
+		t.AssertNil(err)
+		t.Assert(v.IsNil(), true)
+
+		stats := layer.Stats()
+		t.Assert(stats.L1Hits, 1)
+		t.Assert(stats.L1Misses, 2)
+		t.Assert(stats.L2Hits, 1)
+		t.Assert(stats.L2Misses, 1)
+		t.Assert(stats.L1Size, 1)
+
+		// 本地缓存不会超过 redis 中的有效期
+		t.AssertNil(cache.Set(ctx, "k2", "v2", time.Second))
+		_, _ = cache.Get(ctx, "k2")
+		mr.FastForward(2 * time.Second)
+		time.Sleep(1100 * time.Millisecond)
+		v, err = cache.Get(ctx, "k2")
+		t.AssertNil(err)
+		t.Assert(v.IsNil(), true)
+	})
+}
+
+func TestAdapterLayered_Invalidate(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx        = context.Background()
+			mr         = miniredis.RunT(t.T)
+			c1, _      = newTestCache(t, mr.Addr())
+			c2, layer2 = newTestCache(t, mr.Addr())
+		)
+
+		t.AssertNil(c1.Set(ctx, "k1", "v1", 0))
+		v, _ := c2.Get(ctx, "k1")
+		t.Assert(v, "v1")
+
+		// 其他实例写入后删除本地缓存
+		t.AssertNil(c1.Set(ctx, "k1", "v2", 0))
+		waitReceived(layer2, 2)
+		v, _ = c2.Get(ctx, "k1")
+		t.Assert(v, "v2")
+
+		_, err := c1.Remove(ctx, "k1")
+		t.AssertNil(err)
+		waitReceived(layer2, 3)
+		v, _ = c2.Get(ctx, "k1")
+		t.Assert(v.IsNil(), true)
+
+		t.AssertNil(c1.SetMap(ctx, map[interface{}]interface{}{"k2": "v2", "k3": "v3"}, 0))
+		waitReceived(layer2, 4)
+		v, _ = c2.Get(ctx, "k3")
+		t.Assert(v, "v3")
+		t.AssertNil(c1.Clear(ctx))
+		waitReceived(layer2, 5)
+		v, _ = c2.Get(ctx, "k3")
+		t.Assert(v.IsNil(), true)
+		t.Assert(layer2.Stats().L1Size, 0)
+	})
+}
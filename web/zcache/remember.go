@@ -0,0 +1,231 @@
+package zcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/grand"
+)
+
+// ErrNotFound 加载函数返回该错误表示数据不存在，开启 WithNegative 时缓存空结果
+var ErrNotFound = gerror.New("数据不存在")
+
+// DefaultJitter 默认在有效期上随机增加的比例，避免同时写入的缓存同时过期
+const DefaultJitter = 0.1
+
+// RememberOption Remember 的可选项
+type RememberOption func(o *rememberOptions)
+
+type rememberOptions struct {
+	jitter   float64       // 有效期随机增加的比例
+	negative time.Duration // 空结果的缓存时间
+	stale    time.Duration // 过期后继续返回旧值的时间
+}
+
+// WithJitter 有效期随机增加 0 到 ttl*ratio，为 0 时不增加，默认 DefaultJitter
+func WithJitter(ratio float64) RememberOption {
+	return func(o *rememberOptions) {
+		o.jitter = ratio
+	}
+}
+
+// WithNegative 加载函数返回 ErrNotFound 时缓存空结果 ttl，期间直接返回 ErrNotFound，防止缓存穿透
+func WithNegative(ttl time.Duration) RememberOption {
+	return func(o *rememberOptions) {
+		o.negative = ttl
+	}
+}
+
+// WithStale 过期后 stale 内继续返回旧值，同时在后台刷新，热点 key 过期时请求不会等待加载
+func WithStale(stale time.Duration) RememberOption {
+	return func(o *rememberOptions) {
+		o.stale = stale
+	}
+}
+
+// rememberEntry 缓存中保存的结果，记录逻辑过期时间，实际缓存时间包含 stale
+type rememberEntry struct {
+	Remember int             `json:"zr"`          // 固定为 1，区分其他方式写入的缓存
+	Value    json.RawMessage `json:"v,omitempty"` // 加载结果的 JSON
+	ExpireAt int64           `json:"e,omitempty"` // 逻辑过期时间（毫秒），0 为不过期
+	NotFound bool            `json:"n,omitempty"` // 数据不存在
+}
+
+// Remember 读取缓存，不存在时调用 loader 加载并写入缓存，ttl 为 0 时不过期
+// 同一进程内同一 key 并发加载时只调用一次 loader，加载失败时不写入缓存
+func Remember(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (interface{}, error), options ...RememberOption) (*gvar.Var, error) {
+	entry, err := remember(ctx, key, ttl, loader, options)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(entry.Value))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return nil, gerror.Wrapf(err, "解析缓存 %s 失败", key)
+	}
+	return gvar.New(value), nil
+}
+
+// RememberT 和 Remember 相同，结果通过 JSON 转换为 T
+//
+//	menus, err := zcache.RememberT(ctx, key, time.Hour, func(ctx context.Context) ([]*Menu, error) {
+//		return loadMenus(ctx)
+//	}, zcache.WithStale(time.Minute))
+func RememberT[T any](ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), options ...RememberOption) (value T, err error) {
+	entry, err := remember(ctx, key, ttl, func(ctx context.Context) (interface{}, error) {
+		return loader(ctx)
+	}, options)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(entry.Value, &value); err != nil {
+		err = gerror.Wrapf(err, "解析缓存 %s 失败", key)
+	}
+	return
+}
+
+func remember(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (interface{}, error), options []RememberOption) (entry *rememberEntry, err error) {
+	o := &rememberOptions{jitter: DefaultJitter}
+	for _, option := range options {
+		option(o)
+	}
+
+	v, err := Instance().Get(ctx, key)
+	if err != nil {
+		// 缓存不可用时直接加载，写入失败只记录日志
+		g.Log().Warningf(ctx, "zcache remember get %s err:%+v", key, err)
+	} else if entry = decodeRememberEntry(v); entry != nil {
+		now := time.Now().UnixMilli()
+		if entry.ExpireAt != 0 && entry.ExpireAt <= now {
+			if entry.NotFound || now >= entry.ExpireAt+o.stale.Milliseconds() {
+				entry = nil
+			} else {
+				// 返回旧值，后台刷新
+				go func() {
+					ctx := context.WithoutCancel(ctx)
+					if _, err := loadRemember(ctx, key, ttl, loader, o); err != nil {
+						g.Log().Warningf(ctx, "zcache remember refresh %s err:%+v", key, err)
+					}
+				}()
+			}
+		}
+	}
+
+	if entry == nil {
+		if entry, err = loadRemember(ctx, key, ttl, loader, o); err != nil {
+			return nil, err
+		}
+	}
+	if entry.NotFound {
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
+
+// loadRemember 加载并写入缓存，同一 key 同时只有一个加载，其他调用等待并共享结果
+func loadRemember(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (interface{}, error), o *rememberOptions) (*rememberEntry, error) {
+	return rememberFlight.do(key, func() (*rememberEntry, error) {
+		var (
+			entry    = &rememberEntry{Remember: 1}
+			lifeTime time.Duration
+		)
+		value, err := loader(ctx)
+		switch {
+		case err == nil:
+			if entry.Value, err = json.Marshal(value); err != nil {
+				return nil, err
+			}
+			if ttl > 0 {
+				ttl = jitter(ttl, o.jitter)
+				entry.ExpireAt = time.Now().Add(ttl).UnixMilli()
+				lifeTime = ttl + o.stale
+			}
+		case errors.Is(err, ErrNotFound) && o.negative > 0:
+			entry.NotFound = true
+			entry.ExpireAt = time.Now().Add(o.negative).UnixMilli()
+			lifeTime = o.negative
+		default:
+			return nil, err
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		if err = Instance().Set(ctx, key, string(data), lifeTime); err != nil {
+			g.Log().Warningf(ctx, "zcache remember set %s err:%+v", key, err)
+		}
+		return entry, nil
+	})
+}
+
+// decodeRememberEntry 解析缓存中的结果，不是 Remember 写入的缓存返回 nil
+func decodeRememberEntry(v *gvar.Var) *rememberEntry {
+	if v.IsNil() {
+		return nil
+	}
+	var entry rememberEntry
+	if err := json.Unmarshal(v.Bytes(), &entry); err != nil || entry.Remember != 1 {
+		return nil
+	}
+	return &entry
+}
+
+// jitter 有效期随机增加 0 到 ttl*ratio
+func jitter(ttl time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 {
+		return ttl
+	}
+	max := int(float64(ttl) * ratio / float64(time.Millisecond))
+	if max <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(grand.N(0, max))*time.Millisecond
+}
+
+// rememberFlight 同一进程内合并同一 key 的并发加载
+var rememberFlight = &flightGroup{calls: make(map[string]*flightCall)}
+
+type flightGroup struct {
+	sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	entry *rememberEntry
+	err   error
+}
+
+func (f *flightGroup) do(key string, fn func() (*rememberEntry, error)) (*rememberEntry, error) {
+	f.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.Unlock()
+		<-c.done
+		return c.entry, c.err
+	}
+	c := &flightCall{done: make(chan struct{})}
+	f.calls[key] = c
+	f.Unlock()
+
+	defer func() {
+		if c.entry == nil && c.err == nil {
+			// fn panic 时等待的调用返回错误，panic 由当前调用继续抛出
+			c.err = gerror.Newf("加载缓存 %s 失败", key)
+		}
+		f.Lock()
+		delete(f.calls, key)
+		f.Unlock()
+		close(c.done)
+	}()
+	c.entry, c.err = fn()
+	return c.entry, c.err
+}
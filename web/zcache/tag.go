@@ -0,0 +1,194 @@
+package zcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/os/gcache"
+)
+
+// TagStore 记录标签下的缓存 key，缓存适配器实现该接口时使用适配器自己的标签索引
+type TagStore interface {
+	// TagKey 将 key 加入各标签，标签在其中最晚过期的 key 过期后删除，duration 为 0 时不过期
+	TagKey(ctx context.Context, key string, tags []string, duration time.Duration) error
+	// FlushTags 删除各标签并返回标签下的 key，之后加入的 key 不受影响
+	FlushTags(ctx context.Context, tags ...string) (keys []string, err error)
+}
+
+// tagStore 当前适配器使用的标签索引
+var tagStore TagStore
+
+// newTagStore redis 和二级缓存使用 Redis 集合，其他适配器没有实现 TagStore 时使用内存
+func newTagStore(adapter gcache.Adapter, redis *gredis.Redis) TagStore {
+	if store, ok := adapter.(TagStore); ok {
+		return store
+	}
+	if redis != nil {
+		return &redisTags{redis: redis}
+	}
+	return &memoryTags{tags: make(map[string]map[string]int64)}
+}
+
+// TaggedCache 带标签的缓存，key 和普通缓存共用，按标签批量删除
+type TaggedCache struct {
+	tags []string
+}
+
+// Tags 返回带标签的缓存，写入的 key 加入全部标签，Flush 删除任一标签下的全部 key
+//
+//	zcache.Tags("member:12", "role:3").Set(ctx, key, value, time.Hour)
+//	zcache.Tags("role:3").Flush(ctx)
+func Tags(tags ...string) *TaggedCache {
+	return &TaggedCache{tags: tags}
+}
+
+// Set 设置缓存并加入标签，先写入缓存再记录标签，缓存中的 key 总能被之后的 Flush 删除
+// 和 Flush 并发时，Flush 之前读取的旧值可能在 Flush 之后写入，保留到下一次 Flush 或过期
+func (t *TaggedCache) Set(ctx context.Context, key string, value interface{}, duration time.Duration) (err error) {
+	if err = Instance().Set(ctx, key, value, duration); err != nil {
+		return
+	}
+	if err = getTagStore().TagKey(ctx, key, t.tags, duration); err != nil {
+		// 没有加入标签的 key 无法通过 Flush 删除
+		_, _ = Instance().Remove(ctx, key)
+	}
+	return
+}
+
+// Get 获取缓存，和 Instance().Get 相同
+func (t *TaggedCache) Get(ctx context.Context, key string) (*gvar.Var, error) {
+	return Instance().Get(ctx, key)
+}
+
+// GetOrSetFunc 获取缓存，不存在时调用 f 并将不为 nil 的结果写入缓存和标签
+func (t *TaggedCache) GetOrSetFunc(ctx context.Context, key string, f gcache.Func, duration time.Duration) (*gvar.Var, error) {
+	v, err := Instance().Get(ctx, key)
+	if err != nil || !v.IsNil() {
+		return v, err
+	}
+	value, err := f(ctx)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if err = t.Set(ctx, key, value, duration); err != nil {
+		return nil, err
+	}
+	return gvar.New(value), nil
+}
+
+// Flush 删除各标签下的全部缓存
+func (t *TaggedCache) Flush(ctx context.Context) (err error) {
+	keys, err := getTagStore().FlushTags(ctx, t.tags...)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, key)
+	}
+	_, err = Instance().Remove(ctx, list...)
+	return
+}
+
+func getTagStore() TagStore {
+	if tagStore == nil {
+		panic("cache uninitialized.")
+	}
+	return tagStore
+}
+
+// redisTags 每个标签保存为一个 Redis 集合
+type redisTags struct {
+	redis *gredis.Redis
+}
+
+const (
+	redisTagPrefix = "zcache:tag:"
+
+	// 集合的过期时间延长到最晚过期的 key，有 key 不过期时集合也不过期
+	redisTagKeyScript = `
+local exists = redis.call('EXISTS', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[2])
+local duration = tonumber(ARGV[1])
+if duration <= 0 then
+	redis.call('PERSIST', KEYS[1])
+elseif exists == 0 or (ttl >= 0 and ttl < duration) then
+	redis.call('PEXPIRE', KEYS[1], duration)
+end
+return 1`
+
+	redisFlushTagScript = `
+local keys = redis.call('SMEMBERS', KEYS[1])
+redis.call('DEL', KEYS[1])
+return keys`
+)
+
+func (s *redisTags) TagKey(ctx context.Context, key string, tags []string, duration time.Duration) error {
+	for _, tag := range tags {
+		_, err := s.redis.Eval(ctx, redisTagKeyScript, 1, []string{redisTagPrefix + tag}, []interface{}{duration.Milliseconds(), key})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushTags 每个标签单独执行，读取和删除集合是原子的，集群模式下标签可以在不同的节点
+func (s *redisTags) FlushTags(ctx context.Context, tags ...string) (keys []string, err error) {
+	for _, tag := range tags {
+		v, err := s.redis.Eval(ctx, redisFlushTagScript, 1, []string{redisTagPrefix + tag}, nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, v.Strings()...)
+	}
+	return
+}
+
+// memoryTags 内存缓存的标签索引，只在当前进程有效
+type memoryTags struct {
+	sync.Mutex
+	tags map[string]map[string]int64 // 标签 -> key -> 过期时间（毫秒），0 为不过期
+}
+
+func (s *memoryTags) TagKey(ctx context.Context, key string, tags []string, duration time.Duration) error {
+	s.Lock()
+	defer s.Unlock()
+	var (
+		now    = time.Now().UnixMilli()
+		expire int64
+	)
+	if duration > 0 {
+		expire = now + duration.Milliseconds()
+	}
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]int64)
+			s.tags[tag] = keys
+		}
+		for k, e := range keys {
+			if e != 0 && e <= now {
+				delete(keys, k)
+			}
+		}
+		keys[key] = expire
+	}
+	return nil
+}
+
+func (s *memoryTags) FlushTags(ctx context.Context, tags ...string) (keys []string, err error) {
+	s.Lock()
+	defer s.Unlock()
+	for _, tag := range tags {
+		for k := range s.tags[tag] {
+			keys = append(keys, k)
+		}
+		delete(s.tags, tag)
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package zcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/test/gtest"
+)
+
+func TestRemember(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestCache(ctx)
+
+		// 并发读取时只加载一次
+		var (
+			calls  int32
+			wg     sync.WaitGroup
+			loader = func(ctx context.Context) ([]string, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return []string{"/login", "/logout"}, nil
+			}
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := RememberT(ctx, "k1", time.Minute, loader)
+				t.AssertNil(err)
+				t.Assert(v, []string{"/login", "/logout"})
+			}()
+		}
+		wg.Wait()
+		t.Assert(calls, 1)
+
+		v, err := Remember(ctx, "k1", time.Minute, func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		})
+		t.AssertNil(err)
+		t.Assert(v.Strings(), []string{"/login", "/logout"})
+
+		// 其他方式写入的缓存重新加载
+		t.AssertNil(Instance().Set(ctx, "k2", "plain", 0))
+		n, err := RememberT(ctx, "k2", time.Minute, func(ctx context.Context) (int64, error) {
+			return 1, nil
+		})
+		t.AssertNil(err)
+		t.Assert(n, 1)
+
+		// 有效期随机增加
+		for i := 0; i < 10; i++ {
+			d := jitter(time.Second, 0.1)
+			t.Assert(d >= time.Second && d <= 1100*time.Millisecond, true)
+		}
+	})
+}
+
+func TestRemember_Negative(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestCache(ctx)
+
+		var (
+			calls  int32
+			loader = func(ctx context.Context) (string, error) {
+				atomic.AddInt32(&calls, 1)
+				return "", ErrNotFound
+			}
+		)
+		// 不开启时每次都加载
+		_, err := RememberT(ctx, "k1", time.Minute, loader)
+		t.Assert(err, ErrNotFound)
+		_, err = RememberT(ctx, "k1", time.Minute, loader)
+		t.Assert(err, ErrNotFound)
+		t.Assert(calls, 2)
+
+		_, err = RememberT(ctx, "k2", time.Minute, loader, WithNegative(100*time.Millisecond))
+		t.Assert(err, ErrNotFound)
+		_, err = RememberT(ctx, "k2", time.Minute, loader, WithNegative(100*time.Millisecond))
+		t.Assert(err, ErrNotFound)
+		t.Assert(calls, 3)
+		time.Sleep(150 * time.Millisecond)
+		_, err = RememberT(ctx, "k2", time.Minute, loader, WithNegative(100*time.Millisecond))
+		t.Assert(err, ErrNotFound)
+		t.Assert(calls, 4)
+	})
+}
+
+func TestRemember_Stale(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestCache(ctx)
+
+		var (
+			calls  int32
+			loader = func(ctx context.Context) (int32, error) {
+				return atomic.AddInt32(&calls, 1), nil
+			}
+			options = []RememberOption{WithJitter(0), WithStale(time.Minute)}
+		)
+		v, err := RememberT(ctx, "k1", 100*time.Millisecond, loader, options...)
+		t.AssertNil(err)
+		t.Assert(v, 1)
+
+		// 过期后返回旧值并在后台刷新
+		time.Sleep(150 * time.Millisecond)
+		v, err = RememberT(ctx, "k1", 100*time.Millisecond, loader, options...)
+		t.AssertNil(err)
+		t.Assert(v, 1)
+		time.Sleep(50 * time.Millisecond)
+		v, err = RememberT(ctx, "k1", 100*time.Millisecond, loader, options...)
+		t.AssertNil(err)
+		t.Assert(v, 2)
+	})
+}
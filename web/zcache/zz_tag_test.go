@@ -0,0 +1,120 @@
+package zcache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/web/zcache/file"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+
+	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// setupTestCache 使用内存缓存，数据库缓存使用同一个适配器，需要配置数据库
+func setupTestCache(ctx context.Context) {
+	gdb.SetConfig(gdb.Config{"default": gdb.ConfigGroup{gdb.ConfigNode{Link: "sqlite::@file(:memory:)"}}})
+	SetAdapter(ctx, &webSchema.CacheConfig{Adapter: "memory"})
+}
+
+// testTagStore 各适配器标签索引的公共用例
+func testTagStore(t *gtest.T, store TagStore) {
+	ctx := context.Background()
+	t.AssertNil(store.TagKey(ctx, "k1", []string{"member:1", "role:1"}, 0))
+	t.AssertNil(store.TagKey(ctx, "k2", []string{"role:1"}, time.Minute))
+	t.AssertNil(store.TagKey(ctx, "k3", []string{"role:2"}, time.Minute))
+
+	keys, err := store.FlushTags(ctx, "role:1")
+	t.AssertNil(err)
+	sort.Strings(keys)
+	t.Assert(keys, []string{"k1", "k2"})
+
+	// 删除后标签为空，其他标签不受影响
+	keys, err = store.FlushTags(ctx, "role:1", "none")
+	t.AssertNil(err)
+	t.Assert(len(keys), 0)
+	keys, err = store.FlushTags(ctx, "member:1", "role:2")
+	t.AssertNil(err)
+	sort.Strings(keys)
+	t.Assert(keys, []string{"k1", "k3"})
+}
+
+func TestTagStore(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		testTagStore(t, newTagStore(gcache.NewAdapterMemory(), nil))
+
+		adapter := file.NewAdapterFile(t.T.TempDir())
+		defer adapter.Close(context.Background())
+		store := newTagStore(adapter, nil)
+		_, ok := store.(*file.AdapterFile)
+		t.Assert(ok, true)
+		testTagStore(t, store)
+
+		redis, err := gredis.New(&gredis.Config{Address: miniredis.RunT(t.T).Addr()})
+		t.AssertNil(err)
+		defer redis.Close(context.Background())
+		testTagStore(t, newTagStore(gcache.NewAdapterRedis(redis), redis))
+	})
+}
+
+func TestTags(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestCache(ctx)
+
+		t.AssertNil(Tags("member:1", "role:1").Set(ctx, "k1", "v1", 0))
+		t.AssertNil(Tags("role:2").Set(ctx, "k2", "v2", 0))
+		t.AssertNil(Instance().Set(ctx, "k3", "v3", 0))
+
+		calls := 0
+		v, err := Tags("role:1").GetOrSetFunc(ctx, "k4", func(ctx context.Context) (interface{}, error) {
+			calls++
+			return "v4", nil
+		}, time.Minute)
+		t.AssertNil(err)
+		t.Assert(v, "v4")
+		v, err = Tags("role:1").GetOrSetFunc(ctx, "k4", func(ctx context.Context) (interface{}, error) {
+			calls++
+			return "v4", nil
+		}, time.Minute)
+		t.AssertNil(err)
+		t.Assert(v, "v4")
+		t.Assert(calls, 1)
+
+		t.AssertNil(Tags("role:1").Flush(ctx))
+		for key, exist := range map[string]bool{"k1": false, "k2": true, "k3": true, "k4": false} {
+			ok, err := Instance().Contains(ctx, key)
+			t.AssertNil(err)
+			t.Assert(ok, exist)
+		}
+
+		// 加入标签失败时删除已写入的 key
+		old := tagStore
+		tagStore = failTagStore{}
+		defer func() { tagStore = old }()
+		t.AssertNE(Tags("role:1").Set(ctx, "k5", "v5", 0), nil)
+		ok, err := Instance().Contains(ctx, "k5")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+	})
+}
+
+// failTagStore 标签索引不可用
+type failTagStore struct{}
+
+func (failTagStore) TagKey(ctx context.Context, key string, tags []string, duration time.Duration) error {
+	return errors.New("tag store unavailable")
+}
+
+func (failTagStore) FlushTags(ctx context.Context, tags ...string) ([]string, error) {
+	return nil, errors.New("tag store unavailable")
+}
@@ -0,0 +1,69 @@
+package zlock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lockDir 锁文件所在的目录，位于文件缓存目录下
+const lockDir = ".zlock"
+
+// fileLocker 使用文件锁，共用同一个缓存目录的进程之间互斥
+// 锁在 Unlock 或进程退出时由系统释放，ttl 不生效，持有期间续期总是成功
+type fileLocker struct {
+	sync.Mutex
+	dir   string
+	files map[string]*os.File // token -> 持有锁的文件
+}
+
+func newFileLocker(cacheDir string) *fileLocker {
+	return &fileLocker{dir: filepath.Join(cacheDir, lockDir), files: make(map[string]*os.File)}
+}
+
+func (l *fileLocker) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return false, err
+	}
+	// 锁文件不删除，删除后其他进程可能锁住已删除的文件
+	sum := sha256.Sum256([]byte(key))
+	file, err := os.OpenFile(filepath.Join(l.dir, hex.EncodeToString(sum[:])+".lock"), os.O_CREATE|os.O_RDWR, 0o666)
+	if err != nil {
+		return false, err
+	}
+	ok, err := tryLockFile(file)
+	if err != nil || !ok {
+		_ = file.Close()
+		return false, err
+	}
+
+	l.Lock()
+	l.files[token] = file
+	l.Unlock()
+	return true, nil
+}
+
+func (l *fileLocker) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	_, ok := l.files[token]
+	return ok, nil
+}
+
+func (l *fileLocker) release(ctx context.Context, key, token string) (bool, error) {
+	l.Lock()
+	file, ok := l.files[token]
+	delete(l.files, token)
+	l.Unlock()
+	if !ok {
+		return false, nil
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return true, unlockFile(file)
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package zlock
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile 获取 file 的排他锁，已被其他文件描述符锁住时返回 false
+// 同一进程内重复打开的文件之间也互斥
+func tryLockFile(file *os.File) (bool, error) {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		switch err {
+		case nil:
+			return true, nil
+		case syscall.EWOULDBLOCK:
+			return false, nil
+		case syscall.EINTR:
+			continue
+		default:
+			return false, err
+		}
+	}
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,285 @@
+package zlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/util/grand"
+	"github.com/gogf/gf/v2/util/guid"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zcache/file"
+	"github.com/denghuo98/zzframe/web/zcache/layered"
+)
+
+var (
+	// ErrLocked TryLock 时锁已被其他持有者持有
+	ErrLocked = gerror.New("锁已被占用")
+	// ErrNotOwner 释放锁时锁已过期或已被其他持有者获取
+	ErrNotOwner = gerror.New("锁已过期或不属于当前持有者")
+)
+
+const (
+	// DefaultTTL 锁的默认有效期，持有期间自动续期
+	DefaultTTL = 30 * time.Second
+	// MinTTL 锁的最短有效期，更短的 ttl 按 MinTTL 处理，避免续期过于频繁
+	MinTTL = 100 * time.Millisecond
+
+	minRetryInterval = 20 * time.Millisecond  // Lock 第一次重试的等待时间
+	maxRetryInterval = 500 * time.Millisecond // Lock 重试的最长等待时间
+)
+
+// locker 锁的存储，和缓存适配器对应
+type locker interface {
+	// acquire 锁不存在时设置为 token 并返回 true
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// refresh 锁仍属于 token 时延长有效期并返回 true
+	refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// release 锁仍属于 token 时删除并返回 true
+	release(ctx context.Context, key, token string) (bool, error)
+}
+
+// Lease 持有的锁，持有期间每 ttl/3 续期一次，直到 Unlock
+type Lease struct {
+	key    string
+	token  string
+	ttl    time.Duration
+	locker locker
+	cancel context.CancelFunc
+	done   chan struct{} // 续期已停止
+	lost   chan struct{} // 续期失败，锁已过期
+
+	unlocked atomic.Bool
+}
+
+// Key 锁的名称
+func (l *Lease) Key() string {
+	return l.key
+}
+
+// Token 持有者标识，每次获取锁时随机生成
+func (l *Lease) Token() string {
+	return l.token
+}
+
+// Lost 续期失败时关闭，此时锁可能已被其他持有者获取，应停止受保护的操作
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock 停止续期并释放锁，锁已过期或已被其他持有者获取时返回 ErrNotOwner
+func (l *Lease) Unlock(ctx context.Context) error {
+	if l.unlocked.Swap(true) {
+		return ErrNotOwner
+	}
+	l.cancel()
+	<-l.done
+	held.Add(-1)
+
+	ok, err := l.locker.release(ctx, l.key, l.token)
+	if err != nil {
+		return gerror.Wrapf(err, "释放锁 %s 失败", l.key)
+	}
+	if !ok {
+		return ErrNotOwner
+	}
+	counters.released.Add(1)
+	return nil
+}
+
+// renew 定时续期，连续失败超过 ttl 或锁已不属于当前持有者时关闭 lost
+func (l *Lease) renew(ctx context.Context) {
+	defer close(l.done)
+	var (
+		ticker  = time.NewTicker(l.ttl / 3)
+		renewed = time.Now()
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := l.locker.refresh(ctx, l.key, l.token, l.ttl)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && ok {
+			renewed = time.Now()
+			continue
+		}
+		if err != nil && time.Since(renewed) < l.ttl {
+			g.Log().Warningf(ctx, "zlock renew %s err:%+v", l.key, err)
+			continue
+		}
+		counters.expired.Add(1)
+		g.Log().Warningf(ctx, "zlock %s 续期失败，锁已过期, err:%v", l.key, err)
+		close(l.lost)
+		return
+	}
+}
+
+// Lock 获取锁，锁被占用时等待直到获取成功或 ctx 结束
+// ttl 为 0 时使用 DefaultTTL，小于 MinTTL 时使用 MinTTL，持有期间自动续期，进程退出后最多 ttl 时间锁自动释放
+func Lock(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	var (
+		start    = time.Now()
+		interval = minRetryInterval
+		waited   bool
+	)
+	for {
+		lease, err := TryLock(ctx, key, ttl)
+		if err == nil {
+			if waited {
+				counters.waitTime.Add(time.Since(start).Milliseconds())
+			}
+			return lease, nil
+		}
+		if err != ErrLocked {
+			return nil, err
+		}
+		waited = true
+
+		// 随机增加等待时间，避免等待同一个锁的请求同时重试
+		wait := interval + time.Duration(grand.N(0, int(interval/2)))
+		select {
+		case <-ctx.Done():
+			counters.timeout.Add(1)
+			counters.waitTime.Add(time.Since(start).Milliseconds())
+			return nil, gerror.Wrapf(ctx.Err(), "等待锁 %s 超时", key)
+		case <-time.After(wait):
+		}
+		if interval *= 2; interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+// TryLock 获取锁，锁被占用时立即返回 ErrLocked
+func TryLock(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	if key == "" {
+		return nil, gerror.New("锁的名称不能为空")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	ttl = max(ttl, MinTTL)
+
+	l := getLocker()
+	token := guid.S()
+	ok, err := l.acquire(ctx, key, token, ttl)
+	if err != nil {
+		return nil, gerror.Wrapf(err, "获取锁 %s 失败", key)
+	}
+	if !ok {
+		counters.contended.Add(1)
+		return nil, ErrLocked
+	}
+	counters.acquired.Add(1)
+	held.Add(1)
+
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	lease := &Lease{
+		key:    key,
+		token:  token,
+		ttl:    ttl,
+		locker: l,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	go lease.renew(renewCtx)
+	return lease, nil
+}
+
+// Do 持有锁时执行 f，执行完成后释放锁
+func Do(ctx context.Context, key string, ttl time.Duration, f func(ctx context.Context) error) (err error) {
+	lease, err := Lock(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := lease.Unlock(ctx); unlockErr != nil {
+			g.Log().Warningf(ctx, "zlock unlock %s err:%+v", key, unlockErr)
+		}
+	}()
+	return f(ctx)
+}
+
+// Stats 当前进程获取锁的统计
+type Stats struct {
+	Backend   string // 锁的存储：redis、file、memory
+	Held      int64  // 当前持有的锁数量
+	Acquired  int64  // 获取成功的次数
+	Contended int64  // 锁被占用的次数，Lock 每次重试都会计入
+	Timeout   int64  // Lock 等待超时的次数
+	WaitTime  int64  // Lock 等待的总时间（毫秒）
+	Expired   int64  // 持有期间续期失败、锁已过期的次数
+	Released  int64  // 释放成功的次数
+}
+
+var (
+	held     atomic.Int64
+	counters struct {
+		acquired, contended, timeout, waitTime, expired, released atomic.Int64
+	}
+)
+
+// GetStats 当前进程获取锁的统计
+func GetStats() Stats {
+	return Stats{
+		Backend:   backendName(getLocker()),
+		Held:      held.Load(),
+		Acquired:  counters.acquired.Load(),
+		Contended: counters.contended.Load(),
+		Timeout:   counters.timeout.Load(),
+		WaitTime:  counters.waitTime.Load(),
+		Expired:   counters.expired.Load(),
+		Released:  counters.released.Load(),
+	}
+}
+
+// current 和当前缓存适配器对应的锁存储，缓存适配器变化时重新创建
+var current struct {
+	sync.Mutex
+	adapter gcache.Adapter
+	locker  locker
+}
+
+// getLocker redis 和二级缓存使用 Redis，文件缓存使用缓存目录下的文件锁，其他使用进程内的锁
+func getLocker() locker {
+	current.Lock()
+	defer current.Unlock()
+	adapter := zcache.Adapter()
+	if current.locker != nil && current.adapter == adapter {
+		return current.locker
+	}
+
+	switch a := adapter.(type) {
+	case *gcache.AdapterRedis, *layered.AdapterLayered:
+		current.locker = newRedisLocker(g.Redis())
+	case *file.AdapterFile:
+		current.locker = newFileLocker(a.Dir())
+	default:
+		current.locker = newMemoryLocker()
+	}
+	current.adapter = adapter
+	return current.locker
+}
+
+func backendName(l locker) string {
+	switch l.(type) {
+	case *redisLocker:
+		return "redis"
+	case *fileLocker:
+		return "file"
+	default:
+		return "memory"
+	}
+}
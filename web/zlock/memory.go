@@ -0,0 +1,53 @@
+package zlock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryLocker 进程内的锁，只在使用内存缓存的单实例部署中有效
+type memoryLocker struct {
+	sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token    string
+	expireAt time.Time
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{locks: make(map[string]memoryLock)}
+}
+
+func (l *memoryLocker) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if lock, ok := l.locks[key]; ok && time.Now().Before(lock.expireAt) {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if lock, ok := l.locks[key]; !ok || lock.token != token {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) release(ctx context.Context, key, token string) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	lock, ok := l.locks[key]
+	if !ok || lock.token != token {
+		return false, nil
+	}
+	delete(l.locks, key)
+	return time.Now().Before(lock.expireAt), nil
+}
@@ -0,0 +1,59 @@
+package zlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gredis"
+)
+
+const (
+	redisKeyPrefix = "zlock:"
+
+	// 锁仍属于 token 时延长有效期
+	redisRefreshScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`
+
+	// 锁仍属于 token 时删除
+	redisReleaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// redisLocker 使用 SET NX PX 获取锁，续期和释放时用脚本校验持有者
+type redisLocker struct {
+	redis *gredis.Redis
+}
+
+func newRedisLocker(redis *gredis.Redis) *redisLocker {
+	return &redisLocker{redis: redis}
+}
+
+func (l *redisLocker) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	v, err := l.redis.Do(ctx, "SET", redisKeyPrefix+key, token, "PX", ttl.Milliseconds(), "NX")
+	if err != nil {
+		return false, err
+	}
+	return v.String() == "OK", nil
+}
+
+func (l *redisLocker) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	v, err := l.redis.Eval(ctx, redisRefreshScript, 1, []string{redisKeyPrefix + key}, []interface{}{token, ttl.Milliseconds()})
+	if err != nil {
+		return false, err
+	}
+	return v.Int() == 1, nil
+}
+
+func (l *redisLocker) release(ctx context.Context, key, token string) (bool, error) {
+	v, err := l.redis.Eval(ctx, redisReleaseScript, 1, []string{redisKeyPrefix + key}, []interface{}{token})
+	if err != nil {
+		return false, err
+	}
+	return v.Int() == 1, nil
+}
@@ -0,0 +1,134 @@
+package zlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// useLocker 测试期间使用指定的锁存储
+func useLocker(t *gtest.T, l locker) {
+	current.Lock()
+	current.adapter, current.locker = nil, l
+	current.Unlock()
+	t.T.Cleanup(func() {
+		current.Lock()
+		current.locker = nil
+		current.Unlock()
+	})
+}
+
+// testLock 各存储的公共用例
+func testLock(t *gtest.T) {
+	ctx := context.Background()
+
+	lease, err := TryLock(ctx, "job", 300*time.Millisecond)
+	t.AssertNil(err)
+	_, err = TryLock(ctx, "job", 300*time.Millisecond)
+	t.Assert(err, ErrLocked)
+
+	// 持有期间自动续期
+	time.Sleep(500 * time.Millisecond)
+	_, err = TryLock(ctx, "job", 300*time.Millisecond)
+	t.Assert(err, ErrLocked)
+
+	// 释放后其他等待的调用获取锁
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		t.AssertNil(lease.Unlock(ctx))
+	}()
+	waitCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	lease2, err := Lock(waitCtx, "job", 0)
+	t.AssertNil(err)
+	t.AssertNE(lease2.Token(), lease.Token())
+	t.Assert(lease.Unlock(ctx), ErrNotOwner)
+
+	// 等待超时
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer timeoutCancel()
+	_, err = Lock(timeoutCtx, "job", 0)
+	t.AssertNE(err, nil)
+	t.AssertNil(lease2.Unlock(ctx))
+
+	ran := false
+	t.AssertNil(Do(ctx, "job", 0, func(ctx context.Context) error {
+		ran = true
+		_, err := TryLock(ctx, "job", 0)
+		t.Assert(err, ErrLocked)
+		return nil
+	}))
+	t.Assert(ran, true)
+	lease, err = TryLock(ctx, "job", 0)
+	t.AssertNil(err)
+	t.AssertNil(lease.Unlock(ctx))
+
+	// 过短的 ttl 按 MinTTL 处理
+	lease, err = TryLock(ctx, "job", time.Nanosecond)
+	t.AssertNil(err)
+	t.Assert(lease.ttl, MinTTL)
+	time.Sleep(2 * MinTTL)
+	_, err = TryLock(ctx, "job", 0)
+	t.Assert(err, ErrLocked)
+	t.AssertNil(lease.Unlock(ctx))
+}
+
+func TestLock_Memory(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		l := newMemoryLocker()
+		useLocker(t, l)
+		testLock(t)
+
+		// 锁被删除后续期失败
+		ctx := context.Background()
+		expired := GetStats().Expired
+		lease, err := TryLock(ctx, "job", 150*time.Millisecond)
+		t.AssertNil(err)
+		l.Lock()
+		delete(l.locks, "job")
+		l.Unlock()
+		select {
+		case <-lease.Lost():
+		case <-time.After(time.Second):
+			t.Error("lease not lost")
+		}
+		t.Assert(lease.Unlock(ctx), ErrNotOwner)
+		t.Assert(GetStats().Expired, expired+1)
+		t.Assert(GetStats().Backend, "memory")
+	})
+}
+
+func TestLock_File(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		useLocker(t, newFileLocker(t.T.TempDir()))
+		testLock(t)
+	})
+}
+
+func TestLock_Redis(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		mr := miniredis.RunT(t.T)
+		redis, err := gredis.New(&gredis.Config{Address: mr.Addr()})
+		t.AssertNil(err)
+		defer redis.Close(context.Background())
+		useLocker(t, newRedisLocker(redis))
+		testLock(t)
+
+		// 持有者以外的 token 不能释放
+		ctx := context.Background()
+		lease, err := TryLock(ctx, "job", 0)
+		t.AssertNil(err)
+		t.Assert(mr.Exists(redisKeyPrefix+"job"), true)
+		ok, err := newRedisLocker(redis).release(ctx, "job", "other")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+		t.AssertNil(lease.Unlock(ctx))
+		t.Assert(mr.Exists(redisKeyPrefix+"job"), false)
+	})
+}
@@ -0,0 +1,38 @@
+package znotify
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gfile"
+)
+
+// logNotifier 将消息输出到日志，日志中包含消息内容，只用于开发环境
+type logNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Send(ctx context.Context, msg *Message) error {
+	g.Log().Infof(ctx, "notify to:%v, subject:%v, content:%v", msg.To, msg.Subject, msg.Content)
+	return nil
+}
+
+// fileNotifier 将消息追加写入文件，每条消息一行 JSON，用于测试环境
+type fileNotifier struct {
+	path string
+}
+
+func NewFileNotifier(path string) Notifier {
+	return &fileNotifier{path: path}
+}
+
+func (n *fileNotifier) Send(ctx context.Context, msg *Message) error {
+	content, err := gjson.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return gfile.PutBytesAppend(n.path, append(content, '\n'))
+}
@@ -0,0 +1,101 @@
+// Package znotify 消息通知
+// 通过统一的 Notifier 接口发送邮件等通知，便于替换为短信、站内信等实现
+package znotify
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// 通知驱动
+const (
+	DriverSmtp = "smtp"
+	DriverLog  = "log"
+	DriverFile = "file"
+)
+
+// Message 通知消息
+type Message struct {
+	To      string `json:"to"`      // 接收人
+	Subject string `json:"subject"` // 标题
+	Content string `json:"content"` // 内容
+}
+
+// Notifier 通知发送接口
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// 未配置通知驱动时不发送消息，需要发送通知的功能应先通过 Enabled 判断
+var (
+	mu       sync.RWMutex
+	notifier Notifier
+)
+
+// SetConfig 根据配置设置通知驱动，driver 为空时关闭通知
+func SetConfig(c *webSchema.NotifyConfig) error {
+	if c == nil {
+		c = &webSchema.NotifyConfig{}
+	}
+
+	var n Notifier
+	switch c.Driver {
+	case "":
+	case DriverLog:
+		n = NewLogNotifier()
+	case DriverFile:
+		if c.File == "" {
+			return gerror.New("file 通知驱动需要配置输出文件")
+		}
+		n = NewFileNotifier(c.File)
+	case DriverSmtp:
+		if c.Smtp == nil || c.Smtp.Host == "" {
+			return gerror.New("smtp 通知驱动需要配置服务器地址")
+		}
+		n = NewSmtpNotifier(c.Smtp)
+	default:
+		return gerror.Newf("不支持的通知驱动: %v", c.Driver)
+	}
+
+	SetNotifier(n)
+	return nil
+}
+
+// SetNotifier 设置自定义的通知实现
+func SetNotifier(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifier = n
+}
+
+// Instance 当前使用的通知实现，未配置时为 nil
+func Instance() Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	return notifier
+}
+
+// Enabled 是否已配置通知驱动
+func Enabled() bool {
+	return Instance() != nil
+}
+
+// Send 使用当前的通知实现发送消息
+func Send(ctx context.Context, msg *Message) error {
+	if msg == nil || msg.To == "" {
+		return gerror.New("通知接收人不能为空")
+	}
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return gerror.New("通知接收人或标题不能包含换行符")
+	}
+	n := Instance()
+	if n == nil {
+		return gerror.New("未配置消息通知驱动")
+	}
+	return n.Send(ctx, msg)
+}
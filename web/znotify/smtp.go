@@ -0,0 +1,123 @@
+package znotify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// smtpNotifier 通过 SMTP 发送邮件
+type smtpNotifier struct {
+	conf *webSchema.SmtpConfig
+}
+
+func NewSmtpNotifier(conf *webSchema.SmtpConfig) Notifier {
+	return &smtpNotifier{conf: conf}
+}
+
+// sendTimeout 单次发送的最长耗时
+const sendTimeout = 30 * time.Second
+
+func (n *smtpNotifier) Send(ctx context.Context, msg *Message) (err error) {
+	from := n.conf.From
+	if from == "" {
+		from = n.conf.Username
+	}
+	addr := net.JoinHostPort(n.conf.Host, fmt.Sprint(n.port()))
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if n.conf.Ssl {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: n.conf.Host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return gerror.Wrap(err, "连接邮件服务器失败")
+	}
+
+	// 上下文结束时关闭连接，中断阻塞中的读写
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.conf.Host)
+	if err != nil {
+		conn.Close()
+		return gerror.Wrap(err, "连接邮件服务器失败")
+	}
+	defer client.Close()
+
+	// 非 SSL 连接在服务器支持时升级为 STARTTLS
+	if !n.conf.Ssl {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(&tls.Config{ServerName: n.conf.Host}); err != nil {
+				return gerror.Wrap(err, "连接邮件服务器失败")
+			}
+		}
+	}
+
+	if n.conf.Username != "" {
+		auth := smtp.PlainAuth("", n.conf.Username, n.conf.Password, n.conf.Host)
+		if err = client.Auth(auth); err != nil {
+			return gerror.Wrap(err, "邮件服务器认证失败")
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	if err = client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(n.build(from, msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (n *smtpNotifier) port() int {
+	if n.conf.Port > 0 {
+		return n.conf.Port
+	}
+	if n.conf.Ssl {
+		return 465
+	}
+	return 25
+}
+
+// build 构建邮件内容
+func (n *smtpNotifier) build(from string, msg *Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Content)
+	return []byte(b.String())
+}
@@ -0,0 +1,149 @@
+package zpassword
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id 默认参数，参考 OWASP 密码存储建议
+const (
+	argon2idMemory      = 19 * 1024
+	argon2idIterations  = 2
+	argon2idParallelism = 1
+	argon2idSaltLength  = 16
+	argon2idKeyLength   = 32
+
+	// 校验哈希时允许的最大内存开销、迭代次数和并行度，防止篡改的哈希参数耗尽内存或CPU
+	argon2idMaxMemory      = 64 * 1024
+	argon2idMaxIterations  = 16
+	argon2idMaxParallelism = 16
+)
+
+// argon2idHasher argon2id 哈希
+// 格式：$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	maxMemory   uint32
+	maxIter     uint32
+	maxParallel uint8
+}
+
+// argon2idParams 从哈希中解析出的参数
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func newArgon2idHasher(memory, iterations uint32, parallelism uint8, maxMemory uint32) *argon2idHasher {
+	if memory == 0 {
+		memory = argon2idMemory
+	}
+	if iterations == 0 {
+		iterations = argon2idIterations
+	}
+	if parallelism == 0 {
+		parallelism = argon2idParallelism
+	}
+	if maxMemory == 0 {
+		maxMemory = argon2idMaxMemory
+	}
+	// 当前配置生成的哈希必须能够校验
+	return &argon2idHasher{
+		memory:      memory,
+		iterations:  iterations,
+		parallelism: parallelism,
+		maxMemory:   max(maxMemory, memory),
+		maxIter:     max(argon2idMaxIterations, iterations),
+		maxParallel: max(argon2idMaxParallelism, parallelism),
+	}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	p, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (h *argon2idHasher) Match(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	p, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory != h.memory || p.iterations != h.iterations || p.parallelism != h.parallelism
+}
+
+// decode 解析 PHC 格式的哈希
+func (h *argon2idHasher) decode(encoded string) (p *argon2idParams, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, gerror.New("argon2id 哈希格式错误")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, gerror.Wrap(err, "argon2id 哈希版本错误")
+	}
+	if version != argon2.Version {
+		return nil, gerror.Newf("不支持的 argon2id 版本: %d", version)
+	}
+
+	p = new(argon2idParams)
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, gerror.Wrap(err, "argon2id 哈希参数错误")
+	}
+	if p.iterations < 1 || p.parallelism < 1 {
+		return nil, gerror.New("argon2id 哈希参数错误")
+	}
+	if p.memory > h.maxMemory {
+		return nil, gerror.Newf("argon2id 内存开销超过上限: %d KiB", h.maxMemory)
+	}
+	if p.iterations > h.maxIter {
+		return nil, gerror.Newf("argon2id 迭代次数超过上限: %d", h.maxIter)
+	}
+	if p.parallelism > h.maxParallel {
+		return nil, gerror.Newf("argon2id 并行度超过上限: %d", h.maxParallel)
+	}
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, gerror.Wrap(err, "argon2id 哈希盐错误")
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, gerror.Wrap(err, "argon2id 哈希值错误")
+	}
+	if len(p.salt) == 0 || len(p.key) == 0 {
+		return nil, gerror.New("argon2id 哈希格式错误")
+	}
+	return p, nil
+}
@@ -0,0 +1,229 @@
+// Package zpassword 密码哈希与密码策略
+// 新密码以 PHC 字符串格式保存在 password_hash 中，兼容旧版本的 md5(密码+盐) 格式
+package zpassword
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gogf/gf/v2/crypto/gmd5"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/text/gregex"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// 密码哈希算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// 字符类型名称
+var charClassNames = []string{"大写字母", "小写字母", "数字", "特殊字符"}
+
+// 随机密码使用的字符，去掉了容易混淆的字符
+var charClassSets = []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnpqrstuvwxyz", "23456789", "!@#$%^&*-_=+?"}
+
+// generateLength 随机密码的默认长度
+const generateLength = 12
+
+// Hasher 密码哈希算法
+type Hasher interface {
+	// Hash 生成 PHC 格式的密码哈希
+	Hash(password string) (string, error)
+	// Verify 校验密码是否与哈希匹配
+	Verify(password, encoded string) (bool, error)
+	// Match 哈希是否由当前算法生成
+	Match(encoded string) bool
+	// NeedsRehash 哈希参数与当前配置不一致时需要重新生成
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	mu      sync.RWMutex
+	config  *webSchema.PasswordConfig
+	current Hasher
+	hashers []Hasher
+)
+
+func init() {
+	_ = SetConfig(&webSchema.PasswordConfig{})
+}
+
+// SetConfig 设置密码配置，未配置的参数使用默认值
+func SetConfig(c *webSchema.PasswordConfig) error {
+	if c == nil {
+		c = &webSchema.PasswordConfig{}
+	}
+	conf := *c
+	if conf.Algorithm == "" {
+		conf.Algorithm = AlgorithmArgon2id
+	}
+	if conf.MinLength <= 0 {
+		conf.MinLength = 6
+	}
+	if conf.MaxLength <= 0 {
+		conf.MaxLength = 64
+	}
+	if conf.CharClasses <= 0 {
+		conf.CharClasses = 1
+	}
+	if conf.ResetExpires <= 0 {
+		conf.ResetExpires = 1800
+	}
+	if conf.CharClasses > len(charClassNames) {
+		return gerror.Newf("密码字符类型最多为%d种", len(charClassNames))
+	}
+	if conf.MinLength > conf.MaxLength {
+		return gerror.New("密码最小长度不能大于最大长度")
+	}
+	if conf.Algorithm == AlgorithmBcrypt && conf.MaxLength > bcryptMaxBytes {
+		return gerror.Newf("bcrypt 密码最大长度不能超过%d位", bcryptMaxBytes)
+	}
+
+	argon := newArgon2idHasher(conf.Memory, conf.Iterations, conf.Parallelism, conf.MaxMemory)
+	bcrypt := newBcryptHasher(conf.Cost)
+
+	var h Hasher
+	switch conf.Algorithm {
+	case AlgorithmArgon2id:
+		h = argon
+	case AlgorithmBcrypt:
+		h = bcrypt
+	default:
+		return gerror.Newf("不支持的密码哈希算法: %v", conf.Algorithm)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	config = &conf
+	current = h
+	hashers = []Hasher{argon, bcrypt}
+	return nil
+}
+
+// GetConfig 获取密码配置
+func GetConfig() *webSchema.PasswordConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config
+}
+
+// Hash 使用当前配置的算法生成密码哈希
+func Hash(password string) (string, error) {
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	return h.Hash(password)
+}
+
+// Verify 校验密码，salt 仅用于校验旧版本的 md5 哈希
+func Verify(password, salt, encoded string) (bool, error) {
+	mu.RLock()
+	list := hashers
+	mu.RUnlock()
+
+	for _, h := range list {
+		if h.Match(encoded) {
+			return h.Verify(password, encoded)
+		}
+	}
+
+	if isLegacy(encoded) {
+		hash := gmd5.MustEncryptString(password + salt)
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(encoded)) == 1, nil
+	}
+	return false, gerror.New("不支持的密码哈希格式")
+}
+
+// NeedsRehash 哈希不是由当前算法和参数生成时需要重新生成
+func NeedsRehash(encoded string) bool {
+	mu.RLock()
+	h := current
+	mu.RUnlock()
+	return !h.Match(encoded) || h.NeedsRehash(encoded)
+}
+
+// CheckPolicy 校验密码是否符合密码策略
+func CheckPolicy(password string) error {
+	conf := GetConfig()
+
+	length := utf8.RuneCountInString(password)
+	if length < conf.MinLength {
+		return gerror.Newf("密码长度不能少于%d位", conf.MinLength)
+	}
+	if length > conf.MaxLength {
+		return gerror.Newf("密码长度不能超过%d位", conf.MaxLength)
+	}
+	// 多字节字符按字节计算是否超过 bcrypt 的长度限制
+	if conf.Algorithm == AlgorithmBcrypt && len(password) > bcryptMaxBytes {
+		return gerror.Newf("密码长度不能超过%d字节，一个中文字符占3字节", bcryptMaxBytes)
+	}
+
+	if charClasses(password) < conf.CharClasses {
+		return gerror.Newf("密码至少需要包含%v中的%d种", strings.Join(charClassNames, "、"), conf.CharClasses)
+	}
+	return nil
+}
+
+// Generate 生成符合密码策略的随机密码，包含全部字符类型
+func Generate() (string, error) {
+	conf := GetConfig()
+	length := max(conf.MinLength, generateLength)
+	length = max(min(length, conf.MaxLength), len(charClassSets))
+
+	// 每种字符至少一个，其余从全部字符中随机选取
+	all := strings.Join(charClassSets, "")
+	password := make([]byte, length)
+	for i := range password {
+		set := all
+		if i < len(charClassSets) {
+			set = charClassSets[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = set[n.Int64()]
+	}
+
+	// 打乱顺序
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// charClasses 统计密码包含的字符类型数量
+func charClasses(password string) int {
+	var upper, lower, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return upper + lower + digit + symbol
+}
+
+// isLegacy 是否为旧版本的 md5 哈希
+func isLegacy(encoded string) bool {
+	return gregex.IsMatchString(`^[0-9a-f]{32}$`, encoded)
+}
@@ -0,0 +1,157 @@
+package zpassword
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/crypto/gmd5"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// 测试使用较小的 argon2id 参数
+var testConfig = &webSchema.PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1, Cost: 4}
+
+func TestHashVerify(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		defer SetConfig(nil)
+
+		for _, algorithm := range []string{AlgorithmArgon2id, AlgorithmBcrypt} {
+			conf := *testConfig
+			conf.Algorithm = algorithm
+			t.AssertNil(SetConfig(&conf))
+
+			hash, err := Hash("Passw0rd!")
+			t.AssertNil(err)
+			ok, err := Verify("Passw0rd!", "", hash)
+			t.AssertNil(err)
+			t.Assert(ok, true)
+			ok, err = Verify("wrong", "", hash)
+			t.AssertNil(err)
+			t.Assert(ok, false)
+			t.Assert(NeedsRehash(hash), false)
+		}
+
+		// 旧版本的 md5 哈希
+		legacy := gmd5.MustEncryptString("Passw0rd!" + "salt")
+		ok, err := Verify("Passw0rd!", "salt", legacy)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		ok, err = Verify("Passw0rd!", "other", legacy)
+		t.AssertNil(err)
+		t.Assert(ok, false)
+		t.Assert(NeedsRehash(legacy), true)
+
+		_, err = Verify("Passw0rd!", "", "plain")
+		t.AssertNE(err, nil)
+	})
+}
+
+func TestNeedsRehash(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		defer SetConfig(nil)
+
+		conf := *testConfig
+		t.AssertNil(SetConfig(&conf))
+		hash, err := Hash("Passw0rd!")
+		t.AssertNil(err)
+
+		// argon2id 参数变化
+		conf.Iterations = 2
+		t.AssertNil(SetConfig(&conf))
+		t.Assert(NeedsRehash(hash), true)
+
+		// 切换算法后仍可校验旧算法的哈希
+		conf.Algorithm = AlgorithmBcrypt
+		t.AssertNil(SetConfig(&conf))
+		t.Assert(NeedsRehash(hash), true)
+		ok, err := Verify("Passw0rd!", "", hash)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+
+		// bcrypt 计算强度变化
+		hash, err = Hash("Passw0rd!")
+		t.AssertNil(err)
+		conf.Cost = 5
+		t.AssertNil(SetConfig(&conf))
+		t.Assert(NeedsRehash(hash), true)
+	})
+}
+
+func TestArgon2id_Decode(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		h := newArgon2idHasher(1024, 1, 1, 4096)
+		const salt = "c2FsdHNhbHRzYWx0c2FsdA"
+		const key = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+		_, err := h.decode("$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$" + key)
+		t.AssertNil(err)
+
+		for _, encoded := range []string{
+			"$argon2id$v=19$m=1024,t=1,p=1$" + salt,
+			"$argon2i$v=19$m=1024,t=1,p=1$" + salt + "$" + key,
+			"$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=256$" + salt + "$" + key,
+			"$argon2id$v=19$m=4097,t=1,p=1$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=17,p=1$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=4294967295,p=1$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=17$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=255$" + salt + "$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=1$!$" + key,
+			"$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$",
+			"$argon2id$v=19$m=1024,t=1,p=1$$" + key,
+		} {
+			_, err = h.decode(encoded)
+			t.AssertNE(err, nil)
+			ok, err := h.Verify("Passw0rd!", encoded)
+			t.AssertNE(err, nil)
+			t.Assert(ok, false)
+			t.Assert(h.NeedsRehash(encoded), true)
+		}
+
+		// 上限不低于当前配置的内存开销
+		t.Assert(newArgon2idHasher(8192, 1, 1, 4096).maxMemory, 8192)
+		t.Assert(newArgon2idHasher(0, 0, 0, 0).maxMemory, argon2idMaxMemory)
+		t.Assert(newArgon2idHasher(0, 32, 1, 0).maxIter, 32)
+		t.Assert(newArgon2idHasher(0, 1, 32, 0).maxParallel, 32)
+		t.Assert(newArgon2idHasher(0, 0, 0, 0).maxIter, argon2idMaxIterations)
+		t.Assert(newArgon2idHasher(0, 0, 0, 0).maxParallel, argon2idMaxParallelism)
+	})
+}
+
+func TestCheckPolicy(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		defer SetConfig(nil)
+
+		t.AssertNil(SetConfig(&webSchema.PasswordConfig{MinLength: 8, MaxLength: 16, CharClasses: 3}))
+		t.AssertNE(CheckPolicy("Ab1!"), nil)
+		t.AssertNE(CheckPolicy("Abcdefgh1!Abcdefgh1!"), nil)
+		t.AssertNE(CheckPolicy("abcdefgh1"), nil)
+		t.AssertNil(CheckPolicy("Abcdefgh1"))
+
+		password, err := Generate()
+		t.AssertNil(err)
+		t.AssertNil(CheckPolicy(password))
+
+		t.AssertNE(SetConfig(&webSchema.PasswordConfig{CharClasses: 5}), nil)
+		t.AssertNE(SetConfig(&webSchema.PasswordConfig{MinLength: 10, MaxLength: 8}), nil)
+		t.AssertNE(SetConfig(&webSchema.PasswordConfig{Algorithm: "md5"}), nil)
+
+		// bcrypt 按字节限制长度，符合策略的密码都可以生成哈希
+		t.AssertNE(SetConfig(&webSchema.PasswordConfig{Algorithm: AlgorithmBcrypt, MaxLength: 100}), nil)
+		t.AssertNil(SetConfig(&webSchema.PasswordConfig{Algorithm: AlgorithmBcrypt, Cost: 4}))
+		long := strings.Repeat("密", 25)
+		t.AssertNE(CheckPolicy(long), nil)
+		_, err = Hash(long)
+		t.AssertNE(err, nil)
+		password = strings.Repeat("密", 24)
+		t.AssertNil(CheckPolicy(password))
+		_, err = Hash(password)
+		t.AssertNil(err)
+		t.AssertNil(SetConfig(nil))
+		t.AssertNil(CheckPolicy(long))
+	})
+}
@@ -0,0 +1,168 @@
+package zqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+// BroadcastTopicPrefix 广播主题前缀，和普通主题区分
+const BroadcastTopicPrefix = "broadcast."
+
+// BroadcastHandler 处理广播消息，返回错误时只记录日志，不会重试
+type BroadcastHandler func(ctx context.Context, mqMsg MqMsg) (err error)
+
+// subscription 当前实例订阅的广播主题
+type subscription struct {
+	since    time.Time // 开始订阅的时间，之前发送的广播不再处理
+	handlers []BroadcastHandler
+}
+
+// subscriptions 当前实例的广播订阅，每个主题只有一个监听，收到广播后依次调用各订阅的 handler
+var subscriptions = struct {
+	sync.Mutex
+	topics map[string]*subscription
+	cancel context.CancelFunc // 停止所有监听
+	ctx    context.Context
+	wg     *sync.WaitGroup // 正在运行的监听
+}{topics: make(map[string]*subscription)}
+
+// BroadcastTopic 广播对应的队列主题
+func BroadcastTopic(topic string) string {
+	return BroadcastTopicPrefix + topic
+}
+
+// Broadcast 广播消息，所有运行中订阅了该主题的实例都会收到，如缓存失效、重新加载权限策略
+// 实例启动前发送的广播不会补发
+func Broadcast(topic string, data interface{}) (err error) {
+	if topic == "" {
+		return gerror.New("topic is empty")
+	}
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+	mqMsg, err := q.SendMsg(BroadcastTopic(topic), gconv.String(data))
+	ProducerLog(ctx, BroadcastTopic(topic), mqMsg, err)
+	return
+}
+
+// Subscribe 订阅广播，当前实例收到主题的广播后调用 handler，同一主题可以订阅多次
+// 每个实例使用独立的消费者组 broadcast-<InstanceId>，返回后发送的广播都会收到
+func Subscribe(topic string, handler BroadcastHandler) (err error) {
+	if topic == "" {
+		return gerror.New("topic is empty")
+	}
+	if handler == nil {
+		return gerror.New("handler is nil")
+	}
+
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	if s, ok := subscriptions.topics[topic]; ok {
+		s.handlers = append(s.handlers, handler)
+		return
+	}
+
+	c, err := InstanceConsumer()
+	if err != nil {
+		return
+	}
+	var (
+		group   = "broadcast-" + InstanceId()
+		options = &ConsumeOptions{Group: group}
+		s       = &subscription{since: time.Now(), handlers: []BroadcastHandler{handler}}
+	)
+	if joiner, ok := c.(groupJoiner); ok {
+		if err = joiner.joinGroup(BroadcastTopic(topic), group); err != nil {
+			return
+		}
+	}
+
+	if subscriptions.cancel == nil {
+		subscriptions.ctx, subscriptions.cancel = context.WithCancel(ctx)
+		subscriptions.wg = &sync.WaitGroup{}
+	}
+	subscriptions.topics[topic] = s
+	subscriptions.wg.Add(1)
+	go func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if err := c.ListenReceiveMsgDo(ctx, BroadcastTopic(topic), options, func(list []MqMsg) {
+			receiveBroadcast(ctx, topic, group, s, list)
+		}); err != nil {
+			Logger().Warningf(ctx, "queue broadcast listen err:%+v, topic:%v", err, topic)
+		}
+
+		// 停止监听后删除当前实例的消费者组，之后的广播不再为其保存
+		if leaver, ok := c.(groupLeaver); ok {
+			if err := leaver.leaveGroup(BroadcastTopic(topic), group); err != nil {
+				Logger().Warningf(ctx, "queue broadcast leave group err:%+v, topic:%v", err, topic)
+			}
+		}
+	}(subscriptions.ctx, subscriptions.wg)
+	return
+}
+
+// receiveBroadcast 依次调用订阅的 handler，跳过开始订阅前发送的广播
+func receiveBroadcast(ctx context.Context, topic string, group string, s *subscription, list []MqMsg) {
+	ctx = context.WithoutCancel(ctx)
+
+	subscriptions.Lock()
+	handlers := s.handlers
+	subscriptions.Unlock()
+
+	for _, mqMsg := range list {
+		if mqMsg.Timestamp.Before(s.since) {
+			continue
+		}
+		for _, handler := range handlers {
+			start := time.Now()
+			err := handler(ctx, mqMsg)
+			collector.recordHandled(BroadcastTopic(topic), group, 1, time.Since(start), err)
+			ConsumerLog(ctx, BroadcastTopic(topic), mqMsg, err)
+		}
+	}
+}
+
+// StopSubscriptions 停止当前实例的广播监听，等待监听返回并删除当前实例的消费者组，服务关闭时调用
+// 最多等待 shutdownTimeout 秒
+func StopSubscriptions(ctx context.Context) (err error) {
+	wg := resetSubscriptions()
+	if wg == nil {
+		return
+	}
+
+	timeout := time.Duration(getConfig().ShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		err = gerror.Newf("queue broadcast stop timeout after %v", timeout)
+	}
+	return
+}
+
+// resetSubscriptions 停止所有广播监听并清空订阅，返回正在停止的监听
+func resetSubscriptions() (wg *sync.WaitGroup) {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	if subscriptions.cancel != nil {
+		subscriptions.cancel()
+		subscriptions.cancel = nil
+	}
+	wg, subscriptions.wg = subscriptions.wg, nil
+	subscriptions.topics = make(map[string]*subscription)
+	return
+}
@@ -2,7 +2,18 @@ package zqueue
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
 )
 
 // Consumer 消费者接口，实现该接口即可加入到消费队列中
@@ -11,43 +22,263 @@ type Consumer interface {
 	Handle(ctx context.Context, mqMsg MqMsg) (err error) // 处理消息的方法
 }
 
+// RetryPolicy 消费失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多处理次数，包含第一次处理，小于等于1时不重试
+	Backoff     time.Duration // 第一次重试的间隔，之后每次翻倍
+	MaxBackoff  time.Duration // 重试间隔上限，0 为不限制
+	Jitter      float64       // 随机抖动比例（0-1），避免大量消息同时重试
+}
+
+// RetryConsumer 需要失败重试的消费者实现该接口
+// 未实现时处理失败的消息直接进入死信队列
+type RetryConsumer interface {
+	RetryPolicy() *RetryPolicy
+}
+
+// DefaultConsumerGroup 默认消费者组
+const DefaultConsumerGroup = "default"
+
+// ConsumeOptions 消费选项
+type ConsumeOptions struct {
+	Group     string // 消费者组，不同消费者组各自消费主题的全部消息，默认为 DefaultConsumerGroup
+	Workers   int    // 并发处理的协程数量，默认 1，大于 1 时不保证处理顺序
+	BatchSize int    // 每批最多处理的消息数量，实现 BatchConsumer 时有效，默认 100
+}
+
+// OptionsConsumer 需要并发或批量处理的消费者实现该接口
+type OptionsConsumer interface {
+	ConsumeOptions() *ConsumeOptions
+}
+
+// BatchConsumer 批量处理消息的消费者实现该接口，实现后不再调用 Handle
+// 返回错误时整批消息按重试策略分别重试，返回 *BatchError 时只重试其中失败的消息
+type BatchConsumer interface {
+	HandleBatch(ctx context.Context, list []MqMsg) (err error)
+}
+
+// BatchError 批量处理中部分消息失败，未记录的消息视为处理成功
+type BatchError struct {
+	Errors map[string]error // 处理失败的消息ID和失败原因
+}
+
+// Add 记录处理失败的消息
+func (e *BatchError) Add(msgId string, err error) {
+	if e.Errors == nil {
+		e.Errors = make(map[string]error)
+	}
+	e.Errors[msgId] = err
+}
+
+// Err 没有失败的消息时返回 nil
+func (e *BatchError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error 失败数量和消息ID最小的失败原因
+func (e *BatchError) Error() string {
+	if len(e.Errors) == 0 {
+		return "no message failed"
+	}
+	msgId := slices.Min(slices.Collect(maps.Keys(e.Errors)))
+	return fmt.Sprintf("%d messages failed, msgId:%v, err:%v", len(e.Errors), msgId, e.Errors[msgId])
+}
+
+// unrecoverableError 重试也无法处理成功的错误
+type unrecoverableError struct {
+	error
+}
+
+func (e unrecoverableError) Unwrap() error {
+	return e.error
+}
+
+// Unrecoverable 标记重试也无法处理成功的错误，如消息无法解析，处理失败时不再重试，直接进入死信队列
+func Unrecoverable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return unrecoverableError{err}
+}
+
+// normalize 填充默认值
+func (o *ConsumeOptions) normalize() ConsumeOptions {
+	var opts ConsumeOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	return opts
+}
+
+// consumeOptions 消费者的消费选项
+func consumeOptions(job Consumer) *ConsumeOptions {
+	var opts ConsumeOptions
+	if oc, ok := job.(OptionsConsumer); ok && oc.ConsumeOptions() != nil {
+		opts = *oc.ConsumeOptions()
+	}
+	if opts.Group == "" {
+		opts.Group = DefaultConsumerGroup
+	}
+	if _, ok := job.(BatchConsumer); !ok {
+		opts.BatchSize = 1
+	} else if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &opts
+}
+
+// consumerGroupKey 消费者组在存储中的名称，默认消费者组使用组群名称，其他消费者组加上组群名称前缀，避免共用存储的组群互相影响
+func consumerGroupKey(groupName string, group string) string {
+	if group == "" || group == DefaultConsumerGroup {
+		return groupName
+	}
+	return groupName + ":" + group
+}
+
+// parseConsumerGroup 存储中的名称对应的消费者组，其他组群的消费者组返回 false
+func parseConsumerGroup(groupName string, key string) (string, bool) {
+	if key == groupName {
+		return DefaultConsumerGroup, true
+	}
+	if group, ok := strings.CutPrefix(key, groupName+":"); ok {
+		return group, true
+	}
+	return "", false
+}
+
+// Delay 第 attempts 次失败后的重试间隔
+func (p *RetryPolicy) Delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		jitter := min(p.Jitter, 1)
+		delay = time.Duration(float64(delay) * (1 - jitter + 2*jitter*rand.Float64()))
+	}
+	return delay
+}
+
+// defaultShutdownTimeout 关闭时等待消息处理完成的默认时间
+const defaultShutdownTimeout = 30 * time.Second
+
+// groupJoiner 需要在消费前登记消费者组的驱动实现该接口
+type groupJoiner interface {
+	joinGroup(topic string, group string) error
+}
+
+// groupLeaver 不再消费的消费者组需要删除登记和保存的消息时，驱动实现该接口
+type groupLeaver interface {
+	leaveGroup(topic string, group string) error
+}
+
 // consumerManager 消费者管理
 type consumerManager struct {
 	sync.Mutex
-	list map[string]Consumer // 维护的消费者列表
+	list   map[string]Consumer // 维护的消费者列表
+	wg     *sync.WaitGroup     // 正在监听的消费者，每次启动使用新的计数，超时停止后仍可以重新启动
+	cancel context.CancelFunc  // 停止监听
 }
 
 var consumers = &consumerManager{
 	list: make(map[string]Consumer),
 }
 
-// RegisterConsumer 注册任务到消费者队列
+// RegisterConsumer 注册任务到消费者队列，同一主题的每个消费者组只能注册一个消费者
 func RegisterConsumer(cs Consumer) {
 	consumers.Lock()
 	defer consumers.Unlock()
-	topic := cs.GetTopic()
-	if _, ok := consumers.list[topic]; ok {
-		Logger().Warningf(ctx, "queue.RegisterConsumer topic:%v duplicate registration.", topic)
+	var (
+		topic = cs.GetTopic()
+		group = consumeOptions(cs).Group
+		key   = topic + "@" + group
+	)
+	if _, ok := consumers.list[key]; ok {
+		Logger().Warningf(ctx, "queue.RegisterConsumer topic:%v group:%v duplicate registration.", topic, group)
 		return
 	}
-	Logger().Infof(ctx, "queue.RegisterConsumer topic:%v register success.", topic)
-	consumers.list[topic] = cs
+	Logger().Infof(ctx, "queue.RegisterConsumer topic:%v group:%v register success.", topic, group)
+	consumers.list[key] = cs
 }
 
-// StartConsumersListener 启动所有已注册的消费者监听
+// StartConsumersListener 启动所有已注册的消费者监听，ctx 取消或调用 StopConsumersListener 后停止
 func StartConsumersListener(ctx context.Context) {
+	consumers.Lock()
+	defer consumers.Unlock()
+
+	// 先登记全部消费者组，避免先启动的消费者组删除其他消费者组还没有读取的数据
+	if c, err := InstanceConsumer(); err == nil {
+		if joiner, ok := c.(groupJoiner); ok {
+			for _, job := range consumers.list {
+				if err = joiner.joinGroup(job.GetTopic(), consumeOptions(job).Group); err != nil {
+					Logger().Warningf(ctx, "queue.StartConsumersListener join group err:%+v, topic:%v", err, job.GetTopic())
+				}
+			}
+		}
+	}
+
+	ctx, consumers.cancel = context.WithCancel(ctx)
+	wg := &sync.WaitGroup{}
+	consumers.wg = wg
 	for _, c := range consumers.list {
+		wg.Add(1)
 		go func(c Consumer) {
+			defer wg.Done()
 			consumerListen(ctx, c)
 		}(c)
 	}
 }
 
-// consumerListen 消费者监听
+// StopConsumersListener 停止拉取消息，等待正在处理的消息完成并提交
+// 超过配置的 shutdownTimeout 后不再等待，未提交的消息会在下次启动后重新消费
+func StopConsumersListener(ctx context.Context) (err error) {
+	consumers.Lock()
+	cancel, wg := consumers.cancel, consumers.wg
+	consumers.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	timeout := time.Duration(getConfig().ShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		Logger().Debug(ctx, "queue consumers drained.")
+	case <-time.After(timeout):
+		err = gerror.Newf("queue consumers drain timeout after %v", timeout)
+	}
+	return
+}
+
+// consumerListen 消费者监听，ctx 取消后返回
 func consumerListen(ctx context.Context, job Consumer) {
 	var (
 		topic  = job.GetTopic()
 		c, err = InstanceConsumer()
+		// 正在处理的消息不随监听一起取消
+		handleCtx = context.WithoutCancel(ctx)
 	)
 
 	if err != nil {
@@ -55,17 +286,133 @@ func consumerListen(ctx context.Context, job Consumer) {
 		return
 	}
 
-	if listenErr := c.ListenReceiveMsgDo(topic, func(mqMsg MqMsg) {
-		err = job.Handle(ctx, mqMsg)
+	options := consumeOptions(job)
+	if listenErr := c.ListenReceiveMsgDo(ctx, topic, options, func(list []MqMsg) {
+		consumerHandle(handleCtx, job, options.Group, list)
+	}); listenErr != nil {
+		Logger().Fatalf(ctx, "消费队列：%s 监听失败, err:%+v", topic, listenErr)
+	}
+}
+
+// consumerHandle 处理一批消息，跳过其他消费者组重试的消息，实现 Responder 时由 Reply 处理 Call 请求，实现 IdempotentConsumer 时跳过已处理的消息
+func consumerHandle(ctx context.Context, job Consumer, group string, list []MqMsg) {
+	topic := job.GetTopic()
+
+	var own []MqMsg
+	for _, mqMsg := range list {
+		if target := mqMsg.Header(HeaderGroup); target == "" || target == group {
+			own = append(own, mqMsg)
+		}
+	}
+	if list = own; len(list) == 0 {
+		return
+	}
+
+	// Call 请求由 Reply 处理并响应
+	if r, ok := job.(Responder); ok {
+		var rest []MqMsg
+		for _, mqMsg := range list {
+			if isCall(mqMsg) {
+				consumerReply(ctx, r, topic, group, mqMsg)
+				continue
+			}
+			rest = append(rest, mqMsg)
+		}
+		if list = rest; len(list) == 0 {
+			return
+		}
+	}
 
-		// if err != nil {
-		//	// 遇到错误，重新加入到队列
-		//	//queue.Push(topic, mqMsg.Body)
-		// }
+	idempotent := idempotentOptions(job)
+	if idempotent != nil {
+		if list = idempotent.skipProcessed(ctx, topic, group, list); len(list) == 0 {
+			return
+		}
+	}
+
+	// 批量处理，部分失败时只重试失败的消息
+	if bc, ok := job.(BatchConsumer); ok {
+		var (
+			start     = time.Now()
+			err       = bc.HandleBatch(ctx, list)
+			cost      = time.Since(start)
+			batchErr  *BatchError
+			processed []MqMsg
+		)
+		errors.As(err, &batchErr)
+		for _, mqMsg := range list {
+			msgErr := err
+			if batchErr != nil {
+				msgErr = batchErr.Errors[mqMsg.MsgId]
+			}
+
+			// 记录消费队列日志
+			ConsumerLog(ctx, topic, mqMsg, msgErr)
+			if msgErr != nil {
+				consumerFail(ctx, job, group, mqMsg, msgErr)
+				continue
+			}
+			processed = append(processed, mqMsg)
+		}
+		collector.recordBatch(topic, group, len(processed), len(list)-len(processed), cost, err)
+		if len(processed) > 0 && idempotent != nil {
+			idempotent.markProcessed(ctx, topic, group, processed...)
+		}
+		return
+	}
+
+	for _, mqMsg := range list {
+		start := time.Now()
+		err := job.Handle(ctx, mqMsg)
+		collector.recordHandled(topic, group, 1, time.Since(start), err)
+		if err == nil && idempotent != nil {
+			idempotent.markProcessed(ctx, topic, group, mqMsg)
+		}
 
 		// 记录消费队列日志
 		ConsumerLog(ctx, topic, mqMsg, err)
-	}); listenErr != nil {
-		Logger().Fatalf(ctx, "消费队列：%s 监听失败, err:%+v", topic, listenErr)
+
+		// 遇到错误，按重试策略重新加入到队列，超过重试次数后进入死信队列
+		if err != nil {
+			consumerFail(ctx, job, group, mqMsg, err)
+		}
+	}
+}
+
+// consumerFail 处理失败的消息，重试和重放的消息只投递给该消费者组
+func consumerFail(ctx context.Context, job Consumer, group string, mqMsg MqMsg, handleErr error) {
+	attempts := mqMsg.Attempts() + 1
+	mqMsg.SetHeader(HeaderAttempts, gconv.String(attempts))
+	mqMsg.SetHeader(HeaderError, handleErr.Error())
+	mqMsg.SetHeader(HeaderGroup, group)
+
+	var policy *RetryPolicy
+	if rc, ok := job.(RetryConsumer); ok {
+		policy = rc.RetryPolicy()
+	}
+
+	var unrecoverable unrecoverableError
+	if policy != nil && attempts < policy.MaxAttempts && !errors.As(handleErr, &unrecoverable) {
+		delay := policy.Delay(attempts)
+		q, err := InstanceProducer()
+		if err == nil {
+			_, err = q.SendMqMsg(mqMsg, time.Now().Add(delay))
+		}
+		if err == nil {
+			Logger().Infof(ctx, "消费 [%s] 失败，%v 后第 %d 次重试, msgId:%v", mqMsg.Topic, delay, attempts, mqMsg.MsgId)
+			return
+		}
+		Logger().Warningf(ctx, "消费 [%s] 重试失败, msgId:%v, err:%+v", mqMsg.Topic, mqMsg.MsgId, err)
+	}
+
+	mqMsg.SetHeader(HeaderFailedAt, gtime.Now().String())
+	dl, err := InstanceDeadLetter()
+	if err == nil {
+		err = dl.SendDeadLetter(mqMsg)
+	}
+	if err != nil {
+		Logger().Errorf(ctx, "消费 [%s] 写入死信队列失败, body:%+v, err:%+v", mqMsg.Topic, string(mqMsg.Body), err)
+		return
 	}
+	Logger().Warningf(ctx, "消费 [%s] 失败 %d 次，已进入死信队列, msgId:%v", mqMsg.Topic, attempts, mqMsg.MsgId)
 }
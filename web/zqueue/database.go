@@ -0,0 +1,607 @@
+package zqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/internal/model/do"
+	"github.com/denghuo98/zzframe/internal/model/entity"
+)
+
+// DatabaseConfig 数据库队列配置
+type DatabaseConfig struct {
+	BatchSize         int   `json:"batchSize"`         // 每次读取的消息数量，默认 100
+	PollInterval      int64 `json:"pollInterval"`      // 没有可读取的消息时的轮询间隔（毫秒），默认 1000
+	VisibilityTimeout int64 `json:"visibilityTimeout"` // 读取后超过该时间未确认的消息重新投递（秒），默认 60
+}
+
+// normalize 填充默认值
+func (c *DatabaseConfig) normalize() DatabaseConfig {
+	var cfg DatabaseConfig
+	if c != nil {
+		cfg = *c
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 1000
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 60
+	}
+	return cfg
+}
+
+// broadcastGroupExpire 广播消费者组有超过该时间仍未确认的消息时，视为实例已异常退出，删除该消费者组
+const broadcastGroupExpire = 10 * time.Minute
+
+// DatabaseMq 基于数据库表的消息队列，使用默认数据库分组的 zz_sys_queue_message 表
+// 每个消费者组保存一份消息，读取后在可见性超时时间内对其他消费者不可见，确认后删除，超时未确认时重新投递
+// 不依赖 redis 或本地磁盘，适用于只部署单个程序和数据库的场景
+type DatabaseMq struct {
+	groupName string
+	config    DatabaseConfig
+}
+
+func RegisterDatabaseMqProducer(groupName string, config *DatabaseConfig) (client MqProducer, err error) {
+	return NewDatabaseMq(groupName, config), nil
+}
+
+func RegisterDatabaseMqConsumer(groupName string, config *DatabaseConfig) (client MqConsumer, err error) {
+	return NewDatabaseMq(groupName, config), nil
+}
+
+// NewDatabaseMq 创建数据库队列，共用数据库的多个组群互不影响
+func NewDatabaseMq(groupName string, config *DatabaseConfig) *DatabaseMq {
+	return &DatabaseMq{groupName: groupName, config: config.normalize()}
+}
+
+// joinGroup 登记主题的消费者组，之后生产的消息会为该消费者组保存一份
+func (q *DatabaseMq) joinGroup(topic string, group string) (err error) {
+	_, err = dao.SysQueueGroup.Ctx(ctx).Data(do.SysQueueGroup{
+		Topic:         topic,
+		ConsumerGroup: consumerGroupKey(q.groupName, group),
+		CreatedAt:     gtime.Now(),
+	}).InsertIgnore()
+	return
+}
+
+// leaveGroup 删除主题的消费者组和为其保存的消息
+func (q *DatabaseMq) leaveGroup(topic string, group string) error {
+	return q.removeGroup(ctx, topic, consumerGroupKey(q.groupName, group))
+}
+
+// removeGroup 删除消费者组的登记和为其保存的消息
+func (q *DatabaseMq) removeGroup(ctx context.Context, topic string, key string) error {
+	return dao.SysQueueMessage.Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+		if _, err := tx.Model(dao.SysQueueGroup.Table()).Ctx(ctx).
+			Where(dao.SysQueueGroup.Columns().Topic, topic).
+			Where(dao.SysQueueGroup.Columns().ConsumerGroup, key).
+			Delete(); err != nil {
+			return err
+		}
+		_, err := tx.Model(dao.SysQueueMessage.Table()).Ctx(ctx).
+			Where(dao.SysQueueMessage.Columns().Topic, topic).
+			Where(dao.SysQueueMessage.Columns().ConsumerGroup, key).
+			Delete()
+		return err
+	})
+}
+
+// expireGroups 删除广播主题中已退出实例的消费者组，并重新登记当前实例被其他实例误删的消费者组
+// 运行中的实例在轮询间隔内确认广播，有超过 broadcastGroupExpire 仍未确认的消息时视为实例已退出
+func (q *DatabaseMq) expireGroups(ctx context.Context, topic string, group string) error {
+	if err := q.joinGroup(topic, group); err != nil {
+		return err
+	}
+
+	cols := dao.SysQueueMessage.Columns()
+	array, err := dao.SysQueueMessage.Ctx(ctx).
+		Fields(cols.ConsumerGroup).
+		Where(cols.Topic, topic).
+		WhereLike(cols.ConsumerGroup, q.groupName+":%").
+		WhereNot(cols.ConsumerGroup, consumerGroupKey(q.groupName, group)).
+		WhereLT(cols.CreatedAt, gtime.Now().Add(-broadcastGroupExpire)).
+		Group(cols.ConsumerGroup).
+		Array()
+	if err != nil {
+		return err
+	}
+	for _, v := range array {
+		if err = q.removeGroup(ctx, topic, v.String()); err != nil {
+			return err
+		}
+		Logger().Infof(ctx, "database.expireGroups removed topic：%v, group:%v .", topic, v.String())
+	}
+	return nil
+}
+
+// ListenReceiveMsgDo 消费数据，处理完成后确认消息，消费者异常退出时未确认的消息在可见性超时后重新投递
+// ctx 取消后处理完已读取的消息并返回，最多等待一次轮询间隔
+func (q *DatabaseMq) ListenReceiveMsgDo(ctx context.Context, topic string, options *ConsumeOptions, receiveDo func(list []MqMsg)) (err error) {
+	if topic == "" {
+		return gerror.New("database.ListenReceiveMsgDo topic is empty")
+	}
+
+	var group string
+	if options != nil {
+		group = options.Group
+	}
+	if err = q.joinGroup(topic, group); err != nil {
+		return
+	}
+
+	d := newDispatcher(options, receiveDo)
+	defer d.Close()
+
+	var (
+		key      = consumerGroupKey(q.groupName, group)
+		interval = time.Duration(q.config.PollInterval) * time.Millisecond
+		// 读取时不使用 ctx，避免取消时已锁定的消息没有返回
+		readCtx = context.WithoutCancel(ctx)
+		// 广播主题的每个实例有独立的消费者组，定期清理已退出实例的消费者组
+		expireAt time.Time
+	)
+	for ctx.Err() == nil {
+		if strings.HasPrefix(topic, BroadcastTopicPrefix) && time.Now().After(expireAt) {
+			expireAt = time.Now().Add(broadcastGroupExpire / 10)
+			if err := q.expireGroups(readCtx, topic, group); err != nil {
+				Logger().Warningf(ctx, "database.ListenReceiveMsgDo expire groups err:%+v, topic：%v .", err, topic)
+			}
+		}
+
+		rows, err := q.receive(readCtx, topic, key)
+		if err != nil {
+			Logger().Warningf(ctx, "database.ListenReceiveMsgDo receive err:%+v, topic：%v .", err, topic)
+		} else {
+			q.dispatch(topic, rows, d)
+		}
+
+		// 还有可读取的消息时继续读取
+		if err == nil && len(rows) >= q.config.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+	return nil
+}
+
+// receive 读取消费者组一批可见的消息，设置为不可见并生成读取凭证，确认时需要凭证一致
+// MySQL、PostgreSQL 使用 SELECT ... FOR UPDATE SKIP LOCKED 跳过其他实例正在读取的消息
+// SQLite 写入是串行的，更新时再次判断可见时间，同一条消息只会被一个实例更新
+func (q *DatabaseMq) receive(ctx context.Context, topic string, key string) (rows []*entity.SysQueueMessage, err error) {
+	var (
+		cols    = dao.SysQueueMessage.Columns()
+		now     = time.Now().UnixMilli()
+		receipt = newMsgId()
+		claimed int64
+	)
+	err = dao.SysQueueMessage.Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+		sql := fmt.Sprintf(
+			"SELECT %s FROM %s WHERE %s=? AND %s=? AND %s<=? ORDER BY %s LIMIT %d",
+			cols.Id, dao.SysQueueMessage.Table(), cols.Topic, cols.ConsumerGroup, cols.VisibleAt, cols.Id, q.config.BatchSize,
+		)
+		if skipLocked(tx.GetDB()) {
+			sql += " FOR UPDATE SKIP LOCKED"
+		}
+		result, err := tx.GetAll(sql, topic, key, now)
+		if err != nil || result.IsEmpty() {
+			return err
+		}
+
+		res, err := tx.Model(dao.SysQueueMessage.Table()).Ctx(ctx).
+			WhereIn(cols.Id, result.Array(cols.Id)).
+			WhereLTE(cols.VisibleAt, now).
+			Data(g.Map{
+				cols.VisibleAt:  now + q.config.VisibilityTimeout*1000,
+				cols.Receipt:    receipt,
+				cols.Deliveries: &gdb.Counter{Field: cols.Deliveries, Value: 1},
+			}).
+			Update()
+		if err != nil {
+			return err
+		}
+		claimed, err = res.RowsAffected()
+		return err
+	})
+	if err != nil || claimed == 0 {
+		return
+	}
+
+	err = dao.SysQueueMessage.Ctx(ctx).Where(cols.Receipt, receipt).OrderAsc(cols.Id).Scan(&rows)
+	return
+}
+
+// skipLocked 数据库是否支持 SKIP LOCKED
+func skipLocked(db gdb.DB) bool {
+	switch db.GetConfig().Type {
+	case "mysql", "mariadb", "pgsql":
+		return true
+	}
+	return false
+}
+
+// dispatch 分发读取到的消息，处理完成后确认，无法解析的消息直接确认
+func (q *DatabaseMq) dispatch(topic string, rows []*entity.SysQueueMessage, d *dispatcher) {
+	for _, row := range rows {
+		var mqMsg MqMsg
+		if err := json.Unmarshal([]byte(row.Body), &mqMsg); err != nil {
+			Logger().Warningf(ctx, "database.ListenReceiveMsgDo Unmarshal err:%+v, topic：%v, id:%v .", err, topic, row.Id)
+			q.ack(topic, row)
+			continue
+		}
+		d.Dispatch(mqMsg, func() {
+			q.ack(topic, row)
+		})
+	}
+	d.Flush()
+}
+
+// ack 确认消息，可见性超时后被其他消费者重新读取的消息凭证已改变，不会被删除
+func (q *DatabaseMq) ack(topic string, row *entity.SysQueueMessage) {
+	cols := dao.SysQueueMessage.Columns()
+	if _, err := dao.SysQueueMessage.Ctx(ctx).
+		Where(cols.Id, row.Id).
+		Where(cols.Receipt, row.Receipt).
+		Delete(); err != nil {
+		Logger().Warningf(ctx, "database.ListenReceiveMsgDo ack err:%+v, topic：%v, id:%v .", err, topic, row.Id)
+	}
+}
+
+// consumerGroups 主题已登记的消费者组，没有登记时只投递给默认消费者组，广播主题没有登记时不投递
+func (q *DatabaseMq) consumerGroups(ctx context.Context, topic string) (keys []string, err error) {
+	cols := dao.SysQueueGroup.Columns()
+	array, err := dao.SysQueueGroup.Ctx(ctx).
+		Fields(cols.ConsumerGroup).
+		Where(cols.Topic, topic).
+		Where(dao.SysQueueGroup.Ctx(ctx).Builder().
+			Where(cols.ConsumerGroup, q.groupName).
+			WhereOrLike(cols.ConsumerGroup, q.groupName+":%")).
+		Array()
+	if err != nil {
+		return
+	}
+	for _, v := range array {
+		keys = append(keys, v.String())
+	}
+	// 没有实例订阅的广播不需要保存
+	if len(keys) == 0 && !strings.HasPrefix(topic, BroadcastTopicPrefix) {
+		keys = append(keys, consumerGroupKey(q.groupName, DefaultConsumerGroup))
+	}
+	return
+}
+
+// inspectTopics 各主题保留的消息数量和消费者组的待处理数量，只包含当前组群的消费者组
+func (q *DatabaseMq) inspectTopics(ctx context.Context) (list []*TopicStats, err error) {
+	type groupCount struct {
+		Topic         string
+		ConsumerGroup string
+		Total         int64
+		LastId        int64
+	}
+	var (
+		cols     = dao.SysQueueMessage.Columns()
+		counts   []*groupCount
+		inflight []*groupCount
+		now      = time.Now().UnixMilli()
+	)
+	model := func() *gdb.Model {
+		return dao.SysQueueMessage.Ctx(ctx).
+			Fields(cols.Topic, cols.ConsumerGroup, "COUNT(1) AS total", "MAX("+cols.Id+") AS last_id").
+			Where(dao.SysQueueMessage.Ctx(ctx).Builder().
+				Where(cols.ConsumerGroup, q.groupName).
+				WhereOrLike(cols.ConsumerGroup, q.groupName+":%")).
+			Group(cols.Topic, cols.ConsumerGroup)
+	}
+	if err = model().Scan(&counts); err != nil {
+		return
+	}
+	if err = model().WhereGT(cols.Deliveries, 0).WhereGT(cols.VisibleAt, now).Scan(&inflight); err != nil {
+		return
+	}
+
+	var registered []*entity.SysQueueGroup
+	if err = dao.SysQueueGroup.Ctx(ctx).
+		Where(dao.SysQueueGroup.Ctx(ctx).Builder().
+			Where(dao.SysQueueGroup.Columns().ConsumerGroup, q.groupName).
+			WhereOrLike(dao.SysQueueGroup.Columns().ConsumerGroup, q.groupName+":%")).
+		Scan(&registered); err != nil {
+		return
+	}
+
+	var (
+		topics = make(map[string]*TopicStats)
+		lastId = make(map[string]int64)
+	)
+	group := func(topic string, key string) *GroupStats {
+		name, ok := parseConsumerGroup(q.groupName, key)
+		if !ok || IsDeadLetterTopic(topic) {
+			return nil
+		}
+		t, ok := topics[topic]
+		if !ok {
+			t = &TopicStats{Topic: topic}
+			topics[topic] = t
+			list = append(list, t)
+		}
+		for _, g := range t.Groups {
+			if g.Group == name {
+				return g
+			}
+		}
+		g := &GroupStats{Group: name}
+		t.Groups = append(t.Groups, g)
+		return g
+	}
+
+	for _, row := range registered {
+		group(row.Topic, row.ConsumerGroup)
+	}
+	for _, c := range counts {
+		if g := group(c.Topic, c.ConsumerGroup); g != nil {
+			g.Lag += c.Total
+			topics[c.Topic].Length += c.Total
+			lastId[c.Topic] = max(lastId[c.Topic], c.LastId)
+		}
+	}
+	for _, c := range inflight {
+		if g := group(c.Topic, c.ConsumerGroup); g != nil {
+			g.Lag -= c.Total
+			g.Pending += c.Total
+		}
+	}
+	for topic, id := range lastId {
+		topics[topic].Position = strconv.FormatInt(id, 10)
+	}
+	return list, nil
+}
+
+// peek 读取消费者组当前可以读取的消息，不包含已读取未确认和未到投递时间的消息
+func (q *DatabaseMq) peek(ctx context.Context, topic string, group string, limit int) (list []MqMsg, err error) {
+	var (
+		cols = dao.SysQueueMessage.Columns()
+		rows []*entity.SysQueueMessage
+	)
+	if err = dao.SysQueueMessage.Ctx(ctx).
+		Where(cols.Topic, topic).
+		Where(cols.ConsumerGroup, consumerGroupKey(q.groupName, group)).
+		WhereLTE(cols.VisibleAt, time.Now().UnixMilli()).
+		OrderAsc(cols.Id).
+		Limit(limit).
+		Scan(&rows); err != nil {
+		return
+	}
+	return q.unmarshal(ctx, topic, rows), nil
+}
+
+// unmarshal 解析消息，跳过无法解析的消息
+func (q *DatabaseMq) unmarshal(ctx context.Context, topic string, rows []*entity.SysQueueMessage) (list []MqMsg) {
+	for _, row := range rows {
+		var mqMsg MqMsg
+		if err := json.Unmarshal([]byte(row.Body), &mqMsg); err != nil {
+			Logger().Warningf(ctx, "database Unmarshal err:%+v, topic：%v, id:%v .", err, topic, row.Id)
+			continue
+		}
+		list = append(list, mqMsg)
+	}
+	return
+}
+
+// SendMsg 按字符串类型生产数据
+func (q *DatabaseMq) SendMsg(topic string, body string) (mqMsg MqMsg, err error) {
+	return q.SendByteMsg(topic, []byte(body))
+}
+
+// SendByteMsg 生产数据
+func (q *DatabaseMq) SendByteMsg(topic string, body []byte) (mqMsg MqMsg, err error) {
+	return q.SendMqMsg(MqMsg{Topic: topic, Body: body}, time.Time{})
+}
+
+// SendDelayMsg 生产延迟数据，delaySecond 秒后投递
+func (q *DatabaseMq) SendDelayMsg(topic string, body string, delaySecond int64) (mqMsg MqMsg, err error) {
+	return q.SendScheduleMsg(topic, body, time.Now().Add(time.Duration(delaySecond)*time.Second))
+}
+
+// SendScheduleMsg 生产定时数据，到达指定时间后投递，时间已过时立即投递
+func (q *DatabaseMq) SendScheduleMsg(topic string, body string, at time.Time) (mqMsg MqMsg, err error) {
+	return q.SendMqMsg(MqMsg{Topic: topic, Body: []byte(body)}, at)
+}
+
+// SendMqMsg 生产完整的消息，保留消息ID和消息头，at 晚于当前时间时延迟投递
+// 为主题已登记的每个消费者组保存一份，重试的消息只保存给处理失败的消费者组
+func (q *DatabaseMq) SendMqMsg(mqMsg MqMsg, at time.Time) (msg MqMsg, err error) {
+	if mqMsg.Topic == "" {
+		return mqMsg, gerror.New("DatabaseMq topic is empty")
+	}
+
+	mqMsg.RunType = SendMsg
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+	if mqMsg.Timestamp.IsZero() {
+		mqMsg.Timestamp = time.Now()
+	}
+
+	mqMsgJson, err := json.Marshal(mqMsg)
+	if err != nil {
+		return mqMsg, gerror.New(fmt.Sprint("queue database 生产者解析json消息失败:", err))
+	}
+
+	var keys []string
+	if group := mqMsg.Header(HeaderGroup); group != "" {
+		keys = []string{consumerGroupKey(q.groupName, group)}
+	} else if keys, err = q.consumerGroups(ctx, mqMsg.Topic); err != nil {
+		return mqMsg, gerror.New(fmt.Sprint("queue database 生产者读取消费者组失败:", err))
+	}
+
+	if len(keys) == 0 {
+		collector.recordProduced(mqMsg.Topic)
+		return mqMsg, nil
+	}
+
+	var (
+		now  = gtime.Now()
+		list = make([]do.SysQueueMessage, 0, len(keys))
+	)
+	for _, key := range keys {
+		list = append(list, do.SysQueueMessage{
+			Topic:         mqMsg.Topic,
+			ConsumerGroup: key,
+			MsgId:         mqMsg.MsgId,
+			Body:          string(mqMsgJson),
+			VisibleAt:     max(at.UnixMilli(), 0),
+			Deliveries:    0,
+			CreatedAt:     now,
+		})
+	}
+	if _, err = dao.SysQueueMessage.Ctx(ctx).Data(list).Insert(); err != nil {
+		return mqMsg, gerror.New(fmt.Sprint("queue database 生产者添加消息失败:", err))
+	}
+	collector.recordProduced(mqMsg.Topic)
+	return mqMsg, nil
+}
+
+// CancelDelayMsg 取消尚未投递的延迟数据
+func (q *DatabaseMq) CancelDelayMsg(topic string, msgId string) (err error) {
+	if topic == "" {
+		return gerror.New("DatabaseMq topic is empty")
+	}
+
+	cols := dao.SysQueueMessage.Columns()
+	res, err := dao.SysQueueMessage.Ctx(ctx).
+		Where(cols.Topic, topic).
+		Where(cols.MsgId, msgId).
+		Where(cols.Deliveries, 0).
+		WhereGT(cols.VisibleAt, time.Now().UnixMilli()).
+		Where(dao.SysQueueMessage.Ctx(ctx).Builder().
+			Where(cols.ConsumerGroup, q.groupName).
+			WhereOrLike(cols.ConsumerGroup, q.groupName+":%")).
+		Delete()
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue database 取消延迟消息失败:", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return gerror.Newf("延迟消息 %v 不存在或已投递", msgId)
+	}
+	return nil
+}
+
+// SendDeadLetter 写入死信队列，死信保存在死信主题中，消息ID相同的死信会被替换
+func (q *DatabaseMq) SendDeadLetter(mqMsg MqMsg) (err error) {
+	if mqMsg.Topic == "" {
+		return gerror.New("DatabaseMq topic is empty")
+	}
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+
+	data, err := json.Marshal(mqMsg)
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue database 死信解析json消息失败:", err))
+	}
+
+	var (
+		cols  = dao.SysQueueMessage.Columns()
+		topic = DeadLetterTopic(mqMsg.Topic)
+	)
+	if err = dao.SysQueueMessage.Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+		if _, err := q.deadLetters(tx.Model(dao.SysQueueMessage.Table()).Ctx(ctx), mqMsg.Topic).
+			Where(cols.MsgId, mqMsg.MsgId).
+			Delete(); err != nil {
+			return err
+		}
+		_, err := tx.Model(dao.SysQueueMessage.Table()).Ctx(ctx).Data(do.SysQueueMessage{
+			Topic:         topic,
+			ConsumerGroup: q.groupName,
+			MsgId:         mqMsg.MsgId,
+			Body:          string(data),
+			VisibleAt:     0,
+			Deliveries:    0,
+			CreatedAt:     gtime.Now(),
+		}).Insert()
+		return err
+	}); err != nil {
+		return gerror.New(fmt.Sprint("queue database 写入死信失败:", err))
+	}
+	return nil
+}
+
+// deadLetters 原始主题的死信条件
+func (q *DatabaseMq) deadLetters(m *gdb.Model, topic string) *gdb.Model {
+	cols := dao.SysQueueMessage.Columns()
+	return m.Where(cols.Topic, DeadLetterTopic(topic)).Where(cols.ConsumerGroup, q.groupName)
+}
+
+// DeadLetterTopics 存在死信的原始主题
+func (q *DatabaseMq) DeadLetterTopics() (topics []string, err error) {
+	cols := dao.SysQueueMessage.Columns()
+	array, err := dao.SysQueueMessage.Ctx(ctx).
+		Fields(cols.Topic).
+		Distinct().
+		Where(cols.ConsumerGroup, q.groupName).
+		WhereLike(cols.Topic, "%"+DeadLetterSuffix).
+		Array()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range array {
+		topics = append(topics, strings.TrimSuffix(v.String(), DeadLetterSuffix))
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// ListDeadLetters 死信列表，按进入死信队列的时间排序
+func (q *DatabaseMq) ListDeadLetters(topic string) (list []MqMsg, err error) {
+	var rows []*entity.SysQueueMessage
+	if err = q.deadLetters(dao.SysQueueMessage.Ctx(ctx), topic).
+		OrderAsc(dao.SysQueueMessage.Columns().Id).
+		Scan(&rows); err != nil {
+		return nil, err
+	}
+	return q.unmarshal(ctx, topic, rows), nil
+}
+
+// GetDeadLetter 获取一条死信
+func (q *DatabaseMq) GetDeadLetter(topic string, msgId string) (mqMsg MqMsg, err error) {
+	var row *entity.SysQueueMessage
+	if err = q.deadLetters(dao.SysQueueMessage.Ctx(ctx), topic).
+		Where(dao.SysQueueMessage.Columns().MsgId, msgId).
+		Scan(&row); err != nil {
+		return
+	}
+	if row == nil {
+		return mqMsg, gerror.Newf("死信 %v 不存在", msgId)
+	}
+	err = json.Unmarshal([]byte(row.Body), &mqMsg)
+	return
+}
+
+// DeleteDeadLetters 删除死信，未指定消息ID时清空该主题的死信
+func (q *DatabaseMq) DeleteDeadLetters(topic string, msgIds ...string) (n int, err error) {
+	m := q.deadLetters(dao.SysQueueMessage.Ctx(ctx), topic)
+	if len(msgIds) > 0 {
+		m = m.WhereIn(dao.SysQueueMessage.Columns().MsgId, msgIds)
+	}
+	res, err := m.Delete()
+	if err != nil {
+		return
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,77 @@
+package zqueue
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+// 消息头
+const (
+	HeaderAttempts = "x-attempts"  // 已处理失败的次数
+	HeaderError    = "x-error"     // 最后一次处理失败的原因
+	HeaderFailedAt = "x-failed-at" // 进入死信队列的时间
+	HeaderGroup    = "x-group"     // 处理失败的消费者组，重试的消息只由该消费者组处理
+)
+
+// DeadLetterSuffix 死信主题后缀
+const DeadLetterSuffix = ".dlq"
+
+// MqDeadLetter 死信队列，保存重试后仍然处理失败的消息
+// 方法中的 topic 均为原始主题
+type MqDeadLetter interface {
+	SendDeadLetter(mqMsg MqMsg) (err error)
+	DeadLetterTopics() (topics []string, err error)
+	ListDeadLetters(topic string) (list []MqMsg, err error)
+	GetDeadLetter(topic string, msgId string) (mqMsg MqMsg, err error)
+	DeleteDeadLetters(topic string, msgIds ...string) (n int, err error)
+}
+
+// DeadLetterTopic 原始主题对应的死信主题
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// IsDeadLetterTopic 是否为死信主题
+func IsDeadLetterTopic(topic string) bool {
+	return strings.HasSuffix(topic, DeadLetterSuffix)
+}
+
+// InstanceDeadLetter 实例化死信队列
+func InstanceDeadLetter() (dl MqDeadLetter, err error) {
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+	dl, ok := q.(MqDeadLetter)
+	if !ok {
+		return nil, gerror.New("queue driver does not support dead letter")
+	}
+	return dl, nil
+}
+
+// ReplayDeadLetter 将死信重新投递到原始主题，重置失败次数
+func ReplayDeadLetter(topic string, msgId string) (err error) {
+	dl, err := InstanceDeadLetter()
+	if err != nil {
+		return
+	}
+	mqMsg, err := dl.GetDeadLetter(topic, msgId)
+	if err != nil {
+		return
+	}
+
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+	delete(mqMsg.Headers, HeaderAttempts)
+	delete(mqMsg.Headers, HeaderError)
+	delete(mqMsg.Headers, HeaderFailedAt)
+	if _, err = q.SendMqMsg(mqMsg, time.Time{}); err != nil {
+		return
+	}
+	_, err = dl.DeleteDeadLetters(topic, msgId)
+	return
+}
@@ -1,9 +1,11 @@
 package zqueue
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,9 +18,19 @@ import (
 type DiskProducerMq struct {
 	config    *disk.Config
 	producers map[string]*disk.Queue
+	delays    map[string]*disk.Delay
+	deads     map[string]*disk.Store
+	cancel    context.CancelFunc // 停止延迟消息投递
+	closed    bool
 	sync.Mutex
 }
 
+const (
+	delayBatchSize = 100         // 每次最多投递的延迟消息数量
+	delayInterval  = time.Second // 检查到期延迟消息的间隔
+	deadLetterFile = "dead.log"  // 死信存储文件
+)
+
 type DiskConsumerMq struct {
 	config *disk.Config
 }
@@ -29,46 +41,163 @@ func RegisterDiskMqConsumer(config *disk.Config) (client MqConsumer, err error)
 	}, nil
 }
 
-// ListenReceiveMsgDo 消费数据
-func (q *DiskConsumerMq) ListenReceiveMsgDo(topic string, receiveDo func(mqMsg MqMsg)) (err error) {
+// ListenReceiveMsgDo 消费数据，按读取顺序提交已处理的位置
+// ctx 取消后处理完已读取的消息、提交位置并关闭队列
+func (q *DiskConsumerMq) ListenReceiveMsgDo(ctx context.Context, topic string, options *ConsumeOptions, receiveDo func(list []MqMsg)) (err error) {
 	if topic == "" {
 		return gerror.New("disk.ListenReceiveMsgDo topic is empty")
 	}
 
-	var (
-		queue = NewDiskQueue(topic, q.config)
-		sleep = time.Second
-	)
+	// 确保生产者已初始化，恢复该主题未投递的延迟消息
+	if _, err = NewProducer(q.config.GroupName); err != nil {
+		return
+	}
 
-	go func() {
-		for {
-			if index, offset, data, err := queue.Read(); err == nil {
-				var mqMsg MqMsg
-				if err = json.Unmarshal(data, &mqMsg); err != nil {
-					Logger().Warningf(ctx, "disk.ListenReceiveMsgDo Unmarshal err:%+v, topic：%v, data:%+v .", err, topic, string(data))
-					continue
-				}
-				if mqMsg.MsgId != "" {
-					receiveDo(mqMsg)
-					queue.Commit(index, offset)
-					sleep = time.Millisecond * 10
-				}
-			} else {
-				sleep = time.Second
+	// 每个消费者组在主题目录下有独立的读取位置
+	conf := *q.config
+	if options != nil && options.Group != DefaultConsumerGroup {
+		conf.ConsumerGroup = options.Group
+	}
+	queue := NewDiskQueue(topic, &conf)
+	if queue == nil {
+		return gerror.New("queue disk 消费者初始化失败")
+	}
+	defer queue.Close()
+
+	d := newDispatcher(options, receiveDo)
+	defer d.Close()
+
+	recovery := queue.Recovery()
+	for ctx.Err() == nil {
+		index, offset, data, err := queue.Read()
+
+		// 截断了崩溃时不完整的记录或隔离了损坏的记录
+		if stats := queue.Recovery(); stats.RecoveredAt != recovery.RecoveredAt {
+			Logger().Warningf(ctx, "disk.ListenReceiveMsgDo recovered topic：%v, truncated:%v bytes, corrupted:%v bytes .", topic, stats.TruncatedBytes-recovery.TruncatedBytes, stats.CorruptedBytes-recovery.CorruptedBytes)
+			recovery = stats
+		}
+		if err != nil {
+			// 没有新消息时处理未凑满的一批
+			d.Flush()
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
 			}
+			continue
+		}
+
+		var mqMsg MqMsg
+		if err = json.Unmarshal(data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "disk.ListenReceiveMsgDo Unmarshal err:%+v, topic：%v, data:%+v .", err, topic, string(data))
+			continue
+		}
+		if mqMsg.MsgId != "" {
+			d.Dispatch(mqMsg, func() {
+				queue.Commit(index, offset)
+			})
+		}
+	}
+	return nil
+}
+
+// joinGroup 登记主题的消费者组
+func (q *DiskConsumerMq) joinGroup(topic string, group string) error {
+	conf := *q.config
+	if group != DefaultConsumerGroup {
+		conf.ConsumerGroup = group
+	}
+	dir := diskQueuePath(topic, &conf)
+	if err := gfile.Mkdir(dir); err != nil {
+		return gerror.Wrap(err, "queue disk 创建消费者目录失败")
+	}
+	return disk.Join(&disk.Config{Path: dir, ConsumerGroup: conf.ConsumerGroup})
+}
+
+// leaveGroup 删除主题的消费者组读取位置，不再为其保留数据文件
+func (q *DiskConsumerMq) leaveGroup(topic string, group string) error {
+	conf := *q.config
+	if group != DefaultConsumerGroup {
+		conf.ConsumerGroup = group
+	}
+	return disk.Leave(&disk.Config{Path: diskQueuePath(topic, &conf), ConsumerGroup: conf.ConsumerGroup})
+}
 
-			time.Sleep(sleep)
+// inspectTopics 读取各主题的数据文件和消费者组位置，不会修改队列文件
+func (q *DiskConsumerMq) inspectTopics(ctx context.Context) (list []*TopicStats, err error) {
+	dirs, err := filepath.Glob(filepath.Join(q.config.Path, q.config.GroupName, "*"))
+	if err != nil {
+		return nil, err
+	}
+	recoveries := disk.Recoveries()
+	for _, dir := range dirs {
+		topic := filepath.Base(dir)
+		if !gfile.IsDir(dir) || IsDeadLetterTopic(topic) {
+			continue
 		}
-	}()
 
-	select {}
+		info, err := disk.Inspect(dir)
+		if err != nil {
+			return nil, gerror.Wrapf(err, "queue disk 读取主题 %v 失败", topic)
+		}
+		t := &TopicStats{
+			Topic:    topic,
+			Position: fmt.Sprintf("%d:%d", info.Index, info.Offset),
+			Segments: info.Segments,
+			Bytes:    info.Bytes,
+		}
+		if recovery, ok := recoveries[dir]; ok {
+			t.Recovery = &recovery
+		}
+		for _, g := range info.Groups {
+			group := g.Group
+			if group == "" {
+				group = DefaultConsumerGroup
+			}
+			t.Groups = append(t.Groups, &GroupStats{
+				Group:     group,
+				Committed: fmt.Sprintf("%d:%d", g.Index, g.Offset),
+				Lag:       g.Lag,
+				LagBytes:  g.LagBytes,
+			})
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// peek 读取消费者组尚未提交的消息
+func (q *DiskConsumerMq) peek(ctx context.Context, topic string, group string, limit int) (list []MqMsg, err error) {
+	if group == DefaultConsumerGroup {
+		group = ""
+	}
+	records, err := disk.Peek(diskQueuePath(topic, q.config), group, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range records {
+		var mqMsg MqMsg
+		if err = json.Unmarshal(data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "disk.peek Unmarshal err:%+v, topic：%v, data:%+v .", err, topic, string(data))
+			continue
+		}
+		list = append(list, mqMsg)
+	}
+	return list, nil
 }
 
 func RegisterDiskMqProducer(config *disk.Config) (client MqProducer, err error) {
-	return &DiskProducerMq{
+	producer := &DiskProducerMq{
 		config:    config,
 		producers: make(map[string]*disk.Queue),
-	}, nil
+		delays:    make(map[string]*disk.Delay),
+		deads:     make(map[string]*disk.Store),
+	}
+
+	// 投递重启前和其他进程添加的延迟消息
+	var deliverCtx context.Context
+	deliverCtx, producer.cancel = context.WithCancel(ctx)
+	go producer.deliverDelay(deliverCtx)
+	return producer, nil
 }
 
 // SendMsg 按字符串类型生产数据
@@ -82,50 +211,296 @@ func (d *DiskProducerMq) SendByteMsg(topic string, body []byte) (mqMsg MqMsg, er
 		return mqMsg, gerror.New("DiskMq topic is empty")
 	}
 
-	mqMsg = MqMsg{
-		RunType:   SendMsg,
-		Topic:     topic,
-		MsgId:     getRandMsgId(),
-		Body:      body,
-		Timestamp: time.Now(),
+	return d.SendMqMsg(MqMsg{Topic: topic, Body: body}, time.Time{})
+}
+
+// SendDelayMsg 生产延迟数据，delaySecond 秒后投递
+func (d *DiskProducerMq) SendDelayMsg(topic string, body string, delaySecond int64) (mqMsg MqMsg, err error) {
+	return d.SendScheduleMsg(topic, body, time.Now().Add(time.Duration(delaySecond)*time.Second))
+}
+
+// SendScheduleMsg 生产定时数据，到达指定时间后投递，时间已过时立即投递
+func (d *DiskProducerMq) SendScheduleMsg(topic string, body string, at time.Time) (mqMsg MqMsg, err error) {
+	return d.SendMqMsg(MqMsg{Topic: topic, Body: []byte(body)}, at)
+}
+
+// SendMqMsg 生产完整的消息，保留消息ID和消息头，at 晚于当前时间时延迟投递
+func (d *DiskProducerMq) SendMqMsg(mqMsg MqMsg, at time.Time) (msg MqMsg, err error) {
+	if mqMsg.Topic == "" {
+		return mqMsg, gerror.New("DiskMq topic is empty")
+	}
+
+	mqMsg.RunType = SendMsg
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+	if mqMsg.Timestamp.IsZero() {
+		mqMsg.Timestamp = time.Now()
 	}
 
 	mqMsgJson, err := json.Marshal(mqMsg)
 	if err != nil {
-		return mqMsg, gerror.New(fmt.Sprint("queue redis 生产者解析json消息失败:", err))
+		return mqMsg, gerror.New(fmt.Sprint("queue disk 生产者解析json消息失败:", err))
+	}
+
+	// 延迟投递
+	if at.After(time.Now()) {
+		delay, err := d.getDelay(mqMsg.Topic)
+		if err != nil {
+			return mqMsg, err
+		}
+		if err = delay.Add(mqMsg.MsgId, at, mqMsgJson); err != nil {
+			return mqMsg, gerror.New(fmt.Sprint("queue disk 生产者添加延迟消息失败:", err))
+		}
+		collector.recordProduced(mqMsg.Topic)
+		return mqMsg, nil
 	}
 
-	queue := d.getProducer(topic)
+	queue := d.getProducer(mqMsg.Topic)
+	if queue == nil {
+		return mqMsg, gerror.New("queue disk 生产者初始化失败")
+	}
 	if err = queue.Write(mqMsgJson); err != nil {
 		return mqMsg, gerror.New(fmt.Sprint("queue disk 生产者添加消息失败:", err))
 	}
+	collector.recordProduced(mqMsg.Topic)
+	return mqMsg, nil
+}
+
+// CancelDelayMsg 取消尚未投递的延迟数据
+func (d *DiskProducerMq) CancelDelayMsg(topic string, msgId string) (err error) {
+	if topic == "" {
+		return gerror.New("DiskMq topic is empty")
+	}
+
+	delay, err := d.getDelay(topic)
+	if err != nil {
+		return err
+	}
+	ok, err := delay.Remove(msgId)
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue disk 取消延迟消息失败:", err))
+	}
+	if !ok {
+		return gerror.Newf("延迟消息 %v 不存在或已投递", msgId)
+	}
+	return nil
+}
+
+// getDelay 获取主题的延迟消息存储
+func (d *DiskProducerMq) getDelay(topic string) (*disk.Delay, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if delay, ok := d.delays[topic]; ok {
+		return delay, nil
+	}
+	if d.closed {
+		return nil, gerror.New("queue disk 生产者已关闭")
+	}
+
+	dir := diskQueuePath(topic, d.config)
+	if err := gfile.Mkdir(dir); err != nil {
+		return nil, gerror.Wrap(err, "queue disk 创建延迟消息目录失败")
+	}
+	delay, err := disk.NewDelay(dir)
+	if err != nil {
+		return nil, gerror.Wrap(err, "queue disk 加载延迟消息失败")
+	}
+	d.delays[topic] = delay
+	return delay, nil
+}
+
+// deliverDelay 将到期的延迟消息写入队列，每个主题同一时间只由一个进程投递，该进程退出后由其他进程接管
+func (d *DiskProducerMq) deliverDelay(ctx context.Context) {
+	for {
+		delivered := 0
+		files, _ := filepath.Glob(filepath.Join(d.config.Path, d.config.GroupName, "*", "delay.log"))
+		for _, file := range files {
+			topic := filepath.Base(filepath.Dir(file))
+			delay, err := d.getDelay(topic)
+			if err != nil {
+				Logger().Warningf(ctx, "disk.deliverDelay err:%+v, topic：%v .", err, topic)
+				continue
+			}
+			n, err := delay.Deliver(time.Now(), delayBatchSize, func(item *disk.DelayItem) error {
+				queue := d.getProducer(topic)
+				if queue == nil {
+					return gerror.New("queue disk 生产者初始化失败")
+				}
+				return queue.Write(item.Data)
+			})
+			if err != nil {
+				Logger().Warningf(ctx, "disk.deliverDelay write err:%+v, topic：%v .", err, topic)
+			}
+			delivered = max(delivered, n)
+		}
+
+		// 还有到期的消息时继续投递
+		if delivered < delayBatchSize {
+			select {
+			case <-ctx.Done():
+			case <-time.After(delayInterval):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// close 停止延迟消息投递，关闭队列和存储
+func (d *DiskProducerMq) close() {
+	d.cancel()
+
+	d.Lock()
+	defer d.Unlock()
+	d.closed = true
+	for _, delay := range d.delays {
+		delay.Close()
+	}
+	for _, store := range d.deads {
+		store.Close()
+	}
+	for _, queue := range d.producers {
+		queue.Close()
+	}
+}
+
+// SendDeadLetter 写入死信队列
+func (d *DiskProducerMq) SendDeadLetter(mqMsg MqMsg) (err error) {
+	if mqMsg.Topic == "" {
+		return gerror.New("DiskMq topic is empty")
+	}
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+
+	data, err := json.Marshal(mqMsg)
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue disk 死信解析json消息失败:", err))
+	}
+	store, err := d.getDeadLetter(mqMsg.Topic)
+	if err != nil {
+		return err
+	}
+	if err = store.Put(&disk.Record{Id: mqMsg.MsgId, Time: time.Now().UnixMilli(), Data: data}); err != nil {
+		return gerror.New(fmt.Sprint("queue disk 写入死信失败:", err))
+	}
+	return nil
+}
+
+// DeadLetterTopics 存在死信的原始主题
+func (d *DiskProducerMq) DeadLetterTopics() (topics []string, err error) {
+	files, err := filepath.Glob(filepath.Join(d.config.Path, d.config.GroupName, "*"+DeadLetterSuffix, deadLetterFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		topic := strings.TrimSuffix(filepath.Base(filepath.Dir(file)), DeadLetterSuffix)
+		store, err := d.getDeadLetter(topic)
+		if err != nil {
+			return nil, err
+		}
+		if store.Len() > 0 {
+			topics = append(topics, topic)
+		}
+	}
 	return
 }
 
-func (d *DiskProducerMq) SendDelayMsg(topic string, body string, delaySecond int64) (mqMsg MqMsg, err error) {
-	err = gerror.New("implement me")
+// ListDeadLetters 死信列表，按进入死信队列的时间排序
+func (d *DiskProducerMq) ListDeadLetters(topic string) (list []MqMsg, err error) {
+	store, err := d.getDeadLetter(topic)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range store.List() {
+		var mqMsg MqMsg
+		if err = json.Unmarshal(record.Data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "disk.ListDeadLetters Unmarshal err:%+v, topic：%v, msgId:%v .", err, topic, record.Id)
+			continue
+		}
+		list = append(list, mqMsg)
+	}
+	return list, nil
+}
+
+// GetDeadLetter 获取一条死信
+func (d *DiskProducerMq) GetDeadLetter(topic string, msgId string) (mqMsg MqMsg, err error) {
+	store, err := d.getDeadLetter(topic)
+	if err != nil {
+		return
+	}
+	record := store.Get(msgId)
+	if record == nil {
+		return mqMsg, gerror.Newf("死信 %v 不存在", msgId)
+	}
+	err = json.Unmarshal(record.Data, &mqMsg)
 	return
 }
 
+// DeleteDeadLetters 删除死信，未指定消息ID时清空该主题的死信
+func (d *DiskProducerMq) DeleteDeadLetters(topic string, msgIds ...string) (n int, err error) {
+	store, err := d.getDeadLetter(topic)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgIds) == 0 {
+		for _, record := range store.List() {
+			msgIds = append(msgIds, record.Id)
+		}
+	}
+	return store.Delete(msgIds...)
+}
+
+// getDeadLetter 获取主题的死信存储
+func (d *DiskProducerMq) getDeadLetter(topic string) (*disk.Store, error) {
+	if topic == "" {
+		return nil, gerror.New("DiskMq topic is empty")
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if store, ok := d.deads[topic]; ok {
+		return store, nil
+	}
+
+	dir := diskQueuePath(DeadLetterTopic(topic), d.config)
+	if err := gfile.Mkdir(dir); err != nil {
+		return nil, gerror.Wrap(err, "queue disk 创建死信目录失败")
+	}
+	store, err := disk.OpenStore(dir, deadLetterFile)
+	if err != nil {
+		return nil, gerror.Wrap(err, "queue disk 加载死信失败")
+	}
+	d.deads[topic] = store
+	return store, nil
+}
+
 func (d *DiskProducerMq) getProducer(topic string) *disk.Queue {
+	d.Lock()
+	defer d.Unlock()
 	queue, ok := d.producers[topic]
-	if ok {
+	if ok || d.closed {
 		return queue
 	}
 	queue = NewDiskQueue(topic, d.config)
-	d.Lock()
-	defer d.Unlock()
-	d.producers[topic] = queue
+	if queue != nil {
+		d.producers[topic] = queue
+	}
 	return queue
 }
 
 func NewDiskQueue(topic string, config *disk.Config) *disk.Queue {
 	conf := &disk.Config{
-		Path:         fmt.Sprintf(config.Path + "/" + config.GroupName + "/" + topic),
-		BatchSize:    config.BatchSize,
-		BatchTime:    config.BatchTime * time.Second,
-		SegmentSize:  config.SegmentSize,
-		SegmentLimit: config.SegmentLimit,
+		Path:          diskQueuePath(topic, config),
+		BatchSize:     config.BatchSize,
+		BatchTime:     config.BatchTime * time.Second,
+		SegmentSize:   config.SegmentSize,
+		SegmentLimit:  config.SegmentLimit,
+		ConsumerGroup: config.ConsumerGroup,
 	}
 
 	if !gfile.Exists(conf.Path) {
@@ -143,9 +518,7 @@ func NewDiskQueue(topic string, config *disk.Config) *disk.Queue {
 	return queue
 }
 
-func getRandMsgId() string {
-	rand.NewSource(time.Now().UnixNano())
-	radium := rand.Intn(999) + 1
-	timeCode := time.Now().UnixNano()
-	return fmt.Sprintf("%d%.4d", timeCode, radium)
+// diskQueuePath 主题的数据目录
+func diskQueuePath(topic string, config *disk.Config) string {
+	return fmt.Sprintf("%s/%s/%s", config.Path, config.GroupName, topic)
 }
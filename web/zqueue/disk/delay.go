@@ -0,0 +1,119 @@
+package disk
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	delayFile      = "delay.log"   // delayed message log
+	delayOwnerFile = "delay.owner" // lock file held by the process delivering the delayed messages
+)
+
+// DelayItem a scheduled message
+type DelayItem struct {
+	Id   string
+	Due  int64 // due time in milliseconds
+	Data []byte
+}
+
+// Delay durable store of scheduled messages shared by every process using the path
+// any process adds and cancels messages through the log, only the process owning the path delivers them
+type Delay struct {
+	sync.Mutex
+	dir    string
+	store  *Store
+	owner  *os.File
+	closed bool
+}
+
+func NewDelay(dir string) (d *Delay, err error) {
+	store, err := OpenStore(dir, delayFile)
+	if err != nil {
+		return
+	}
+	return &Delay{dir: dir, store: store}, nil
+}
+
+// Add schedule a message
+func (d *Delay) Add(id string, due time.Time, data []byte) error {
+	ok, err := d.store.Add(&Record{Id: id, Time: due.UnixMilli(), Data: data})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("delay message already exists")
+	}
+	return nil
+}
+
+// Remove cancel a scheduled message, returns false if it does not exist or has been delivered
+func (d *Delay) Remove(id string) (bool, error) {
+	n, err := d.store.Delete(id)
+	return n > 0, err
+}
+
+// Deliver at most limit messages due at now in order of due time, a message is removed once deliver returns nil
+// it returns 0 unless this process owns the path, another process takes over when the owner exits
+func (d *Delay) Deliver(now time.Time, limit int, deliver func(item *DelayItem) error) (n int, err error) {
+	if !d.own() {
+		return 0, nil
+	}
+
+	ms := now.UnixMilli()
+	n, err1 := d.store.Take(func(record *Record) bool {
+		if record.Time > ms || limit <= 0 {
+			return false
+		}
+		if err = deliver(&DelayItem{Id: record.Id, Due: record.Time, Data: record.Data}); err != nil {
+			return false
+		}
+		limit--
+		return true
+	})
+	if err == nil {
+		err = err1
+	}
+	return
+}
+
+// Len number of scheduled messages
+func (d *Delay) Len() int {
+	return d.store.Len()
+}
+
+// Close delay store, releases the ownership of the path
+func (d *Delay) Close() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.closed = true
+	if d.owner != nil {
+		_ = d.owner.Close()
+		d.owner = nil
+	}
+	d.store.Close()
+}
+
+// own returns true when this process owns the path, the lock is held until the delay store is closed or the process exits
+func (d *Delay) own() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.owner != nil || d.closed {
+		return d.owner != nil
+	}
+	f, err := os.OpenFile(path.Join(d.dir, delayOwnerFile), os.O_CREATE|os.O_RDWR, filePerm)
+	if err != nil {
+		return false
+	}
+	if ok, err := tryLockSegment(f); err != nil || !ok {
+		_ = f.Close()
+		return false
+	}
+	d.owner = f
+	return true
+}
@@ -11,44 +11,72 @@ import (
 
 const (
 	filePerm  = 0600     // 数据写入权限
-	indexFile = ".index" // 消息索引文件
+	indexFile = ".index" // 消息索引文件，其他消费者组为 .index.<消费者组>
+	lockFile  = ".lock"  // 创建、重新打开和跳过分片时持有的目录锁
 )
 
 type Config struct {
-	GroupName    string        // 组群名称
-	Path         string        // 数据存放路径
-	BatchSize    int64         // 每N条消息同步一次，batchSize和batchTime满足其一就会同步一次
-	BatchTime    time.Duration // 每N秒消息同步一次
-	SegmentSize  int64         // 每个topic分片数据文件最大字节
-	SegmentLimit int64         // 每个topic最大分片数据文件数量
+	GroupName     string        // 组群名称
+	ConsumerGroup string        // 消费者组，每个消费者组有独立的读取位置，为空时使用默认消费者组
+	Path          string        // 数据存放路径
+	BatchSize     int64         // 每N条消息同步一次，batchSize和batchTime满足其一就会同步一次
+	BatchTime     time.Duration // 每N秒消息同步一次
+	SegmentSize   int64         // 每个topic分片数据文件最大字节
+	SegmentLimit  int64         // 每个topic最大分片数据文件数量
 }
 
 type Queue struct {
 	sync.RWMutex
-	close  bool
-	ticker *time.Ticker
-	wg     *sync.WaitGroup
-	ctx    context.Context
-	cancel context.CancelFunc
-	writer *writer
-	reader *reader
+	close    bool
+	restored bool // reader checkpoint is restored on the first read, so writers do not join any consumer group
+	ticker   *time.Ticker
+	wg       *sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	writer   *writer
+	reader   *reader
 }
 
 func New(config *Config) (queue *Queue, err error) {
 	if _, err = os.Stat(config.Path); err != nil {
 		return
 	}
+	if !validGroup(config.ConsumerGroup) {
+		return nil, errors.New("invalid consumer group")
+	}
 	queue = &Queue{close: false, wg: &sync.WaitGroup{}, writer: &writer{config: config}, reader: &reader{config: config}}
 	queue.ticker = time.NewTicker(config.BatchTime)
 	queue.ctx, queue.cancel = context.WithCancel(context.TODO())
-	err = queue.reader.restore()
-	if err != nil {
-		return
-	}
 	go queue.sync()
 	return
 }
 
+// Join register a consumer group before reading, segments are kept until every registered group has passed them
+func Join(config *Config) error {
+	if !validGroup(config.ConsumerGroup) {
+		return errors.New("invalid consumer group")
+	}
+	r := &reader{config: config}
+	if _, err := os.Stat(r.checkpointFile()); err == nil {
+		return nil
+	}
+	r.sync()
+	return nil
+}
+
+// Leave remove the checkpoint of a consumer group that is no longer read, segments are no longer kept for it
+// a group reading again after leaving starts from the oldest segment left
+func Leave(config *Config) error {
+	if !validGroup(config.ConsumerGroup) {
+		return errors.New("invalid consumer group")
+	}
+	r := &reader{config: config}
+	if err := os.Remove(r.checkpointFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // Write data
 func (q *Queue) Write(data []byte) error {
 	if q.close {
@@ -66,28 +94,64 @@ func (q *Queue) Read() (int64, int64, []byte, error) {
 	if q.close {
 		return 0, 0, nil, errors.New("closed")
 	}
+	if err := q.restore(); err != nil {
+		return 0, 0, nil, err
+	}
 
 	q.RLock()
 	defer q.RUnlock()
 
 	index, offset, data, err := q.reader.read()
+	// skip the corrupt records unless the segment is still being written
+	if err == errorCorrupt && q.reader.quarantine() == nil {
+		index, offset, data, err = q.reader.read()
+	}
 	if err == io.EOF && (q.writer.file == nil || q.reader.file.Name() != q.writer.file.Name()) {
 		_ = q.reader.safeRotate()
+		// continue with the next segment without waiting for the next read
+		if q.reader.file == nil {
+			return q.reader.read()
+		}
 	}
 	return index, offset, data, err
 }
 
-// Commit index and offset
+// Commit index and offset, safe to call concurrently with Read
 func (q *Queue) Commit(index int64, offset int64) {
 	if q.close {
 		return
 	}
 
+	q.Lock()
+	defer q.Unlock()
+
 	ck := &q.reader.checkpoint
 	ck.Index, ck.Offset = index, offset
 	q.reader.sync()
 }
 
+// Recovery stats of the queue path
+func (q *Queue) Recovery() RecoveryStats {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	return *recoveryStats(q.reader.config.Path)
+}
+
+// restore the checkpoint of the consumer group
+func (q *Queue) restore() error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.restored {
+		return nil
+	}
+	if err := q.reader.restore(); err != nil {
+		return err
+	}
+	q.restored = true
+	return nil
+}
+
 // Close Queue
 func (q *Queue) Close() {
 	if q.close {
@@ -109,10 +173,20 @@ func (q *Queue) sync() {
 		select {
 		case <-q.ticker.C:
 			q.Lock()
-			q.writer.sync()
+			q.writer.tick()
 			q.Unlock()
 		case <-q.ctx.Done():
 			return
 		}
 	}
 }
+
+// validGroup consumer group is used in the checkpoint file name
+func validGroup(group string) bool {
+	for _, c := range group {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
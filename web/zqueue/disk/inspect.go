@@ -0,0 +1,281 @@
+package disk
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Info segments and consumer group positions of a queue path
+type Info struct {
+	Segments int64        // number of segments
+	Bytes    int64        // total size of segments
+	Index    int64        // newest segment, the writer position
+	Offset   int64        // size of the newest segment
+	Groups   []*GroupInfo // consumer groups joined
+}
+
+// GroupInfo position of a consumer group
+type GroupInfo struct {
+	Group    string // empty for the default consumer group
+	Index    int64  // committed segment
+	Offset   int64  // committed offset
+	Lag      int64  // records not committed yet
+	LagBytes int64  // bytes not committed yet
+}
+
+type segmentInfo struct {
+	file    string
+	index   int64
+	size    int64
+	records int64 // counted on demand, -1 before
+}
+
+// segmentCount records counted from the start of a segment, kept between Inspect calls
+// segments only grow by appending, a truncated tail or quarantined bytes start after the last complete record counted
+type segmentCount struct {
+	end     int64           // end of the last complete record counted
+	records int64           // records before end
+	marks   map[int64]int64 // committed offset -> records before it
+}
+
+const maxCountMarks = 16 // committed offsets remembered per segment, about one per consumer group
+
+var (
+	countMu sync.Mutex
+	counts  = make(map[string]*segmentCount) // segment -> records counted
+)
+
+// Inspect a queue path, read only, safe to call from another process
+func Inspect(dir string) (*Info, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	forgetCounts(dir, segments)
+
+	info := &Info{}
+	for _, s := range segments {
+		info.Segments++
+		info.Bytes += s.size
+		info.Index, info.Offset = s.index, s.size
+	}
+
+	checkpoints, err := filepath.Glob(filepath.Join(dir, indexFile+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(checkpoints)
+	for _, file := range checkpoints {
+		group := strings.TrimPrefix(strings.TrimPrefix(filepath.Base(file), indexFile), ".")
+		ck, err := readCheckpoint(file)
+		if err != nil {
+			return nil, err
+		}
+
+		g := &GroupInfo{Group: group, Index: ck.Index, Offset: ck.Offset}
+		for _, s := range segments {
+			switch {
+			case s.index > ck.Index:
+				g.Lag += s.count()
+				g.LagBytes += s.size
+			case s.index == ck.Index && s.size > ck.Offset:
+				g.Lag += s.count() - recordsBefore(s.file, ck.Offset)
+				g.LagBytes += s.size - ck.Offset
+			}
+		}
+		info.Groups = append(info.Groups, g)
+	}
+	return info, nil
+}
+
+// Peek records after the committed position of a consumer group without committing, read only
+func Peek(dir string, group string, limit int) (list [][]byte, err error) {
+	ck, err := readCheckpoint((&reader{config: &Config{Path: dir, ConsumerGroup: group}}).checkpointFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range segments {
+		if len(list) >= limit {
+			break
+		}
+		var offset int64
+		switch {
+		case s.index < ck.Index:
+			continue
+		case s.index == ck.Index:
+			offset = ck.Offset
+		}
+		if err = eachRecord(s.file, offset, func(data []byte) bool {
+			list = append(list, data)
+			return len(list) < limit
+		}); err != nil {
+			return
+		}
+	}
+	return list, nil
+}
+
+func listSegments(dir string) ([]*segmentInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.data"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	segments := make([]*segmentInfo, 0, len(files))
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			// removed after all consumer groups passed it
+			continue
+		}
+		segments = append(segments, &segmentInfo{file: file, index: segmentIndex(file), size: stat.Size(), records: -1})
+	}
+	return segments, nil
+}
+
+// count complete records of the segment
+func (s *segmentInfo) count() int64 {
+	if s.records < 0 {
+		s.records = recordsBefore(s.file, s.size)
+	}
+	return s.records
+}
+
+// recordsBefore complete records of a segment before offset, only the bytes not counted by a previous call are read
+func recordsBefore(file string, offset int64) int64 {
+	countMu.Lock()
+	defer countMu.Unlock()
+
+	c := counts[file]
+	if c == nil {
+		c = &segmentCount{marks: make(map[int64]int64)}
+		counts[file] = c
+	}
+	if n, ok := c.marks[offset]; ok {
+		return n
+	}
+
+	// continue from the nearest position counted before
+	var from, n int64
+	if offset >= c.end {
+		from, n = c.end, c.records
+	} else {
+		for o, m := range c.marks {
+			if o <= offset && o > from {
+				from, n = o, m
+			}
+		}
+	}
+	end, m := scanRecords(file, from, offset)
+	n += m
+
+	if end >= c.end {
+		c.end, c.records = end, n
+	} else {
+		if len(c.marks) >= maxCountMarks {
+			clear(c.marks)
+		}
+		c.marks[end] = n
+	}
+	return n
+}
+
+// forgetCounts drop the counts of removed segments and of segments shrunk by recovery
+func forgetCounts(dir string, segments []*segmentInfo) {
+	sizes := make(map[string]int64, len(segments))
+	for _, s := range segments {
+		sizes[s.file] = s.size
+	}
+
+	countMu.Lock()
+	defer countMu.Unlock()
+	for file, c := range counts {
+		if filepath.Dir(file) != filepath.Clean(dir) {
+			continue
+		}
+		if size, ok := sizes[file]; !ok || size < c.end {
+			delete(counts, file)
+		}
+	}
+}
+
+// scanRecords count complete records between from and to, returns the end of the last one
+func scanRecords(file string, from int64, to int64) (end int64, n int64) {
+	end = from
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(from, io.SeekStart); err != nil {
+		return
+	}
+	r := bufio.NewReader(f)
+	for end < to {
+		_, size, err := readRecord(r)
+		if err != nil || end+size > to {
+			return
+		}
+		end += size
+		n++
+	}
+	return
+}
+
+// readCheckpoint read again when the checkpoint is being written by the consumer
+func readCheckpoint(file string) (ck checkpoint, err error) {
+	for i := 0; i < 3; i++ {
+		var data []byte
+		if data, err = os.ReadFile(file); err != nil {
+			return
+		}
+		if err = json.Unmarshal(data, &ck); err == nil {
+			return
+		}
+	}
+	return
+}
+
+// eachRecord call fn with every complete record from offset until fn returns false
+// an incomplete or corrupt record ends the segment, it is handled by the consumer
+func eachRecord(file string, offset int64, fn func(data []byte) bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		data, _, err := readRecord(r)
+		if err == io.EOF || err == errorCorrupt {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(data) {
+			return nil
+		}
+	}
+}
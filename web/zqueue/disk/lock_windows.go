@@ -0,0 +1,27 @@
+//go:build windows
+
+package disk
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// the locked byte is far beyond the end of a segment, so readers of other handles are not blocked by the lock
+const lockOffsetHigh = 0x7fffffff
+
+// lockSegment hold an exclusive lock on a segment being written, released when the file is closed
+func lockSegment(file *os.File) error {
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{OffsetHigh: lockOffsetHigh})
+}
+
+// tryLockSegment returns false when the segment is being written
+func tryLockSegment(file *os.File) (bool, error) {
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{OffsetHigh: lockOffsetHigh})
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
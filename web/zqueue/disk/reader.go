@@ -2,10 +2,10 @@ package disk
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -37,15 +37,31 @@ func (r *reader) read() (int64, int64, []byte, error) {
 		return r.index, r.offset, nil, err
 	}
 
-	// read a line
-	data, err := r.reader.ReadBytes('\n')
+	data, size, err := readRecord(r.reader)
 	if err != nil {
+		// read the record again from the offset next time
+		r.rewind()
 		return r.index, r.offset, nil, err
 	}
-	data = bytes.TrimRight(data, "\n")
 
-	r.offset += int64(len(data)) + 1
-	return r.index, r.offset, data, err
+	r.offset += size
+	return r.index, r.offset, data, nil
+}
+
+// rewind to the offset, discard the bytes of an incomplete record
+func (r *reader) rewind() {
+	if _, err := r.file.Seek(r.offset, io.SeekStart); err == nil {
+		r.reader.Reset(r.file)
+	}
+}
+
+// quarantine the rest of the current segment after a corrupt record
+func (r *reader) quarantine() error {
+	if err := quarantineSegment(r.file.Name(), r.offset); err != nil {
+		return err
+	}
+	r.rewind()
+	return nil
 }
 
 // check a new segment
@@ -68,7 +84,7 @@ func (r *reader) open(file string) (err error) {
 	}
 
 	// get file index
-	r.index = r.getIndex(file)
+	r.index = segmentIndex(file)
 
 	// seek read offset
 	if _, err = r.file.Seek(r.offset, 0); err != nil {
@@ -81,14 +97,50 @@ func (r *reader) open(file string) (err error) {
 
 // safeRotate to next segment
 func (r *reader) safeRotate() error {
+	// writers of any process do not open a segment while it is passed
+	lock, err := lockPath(r.config.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
 	// if there is no next file, it is not cleared
-	if _, err := r.next(); err == errorQueueEmpty {
+	if _, err = r.next(); err == errorQueueEmpty {
 		return nil
 	}
+	if ok, err := r.passed(); err != nil || !ok {
+		return err
+	}
 
 	return r.rotate()
 }
 
+// passed returns true when no writer of any process holds the current segment and every record of it has been read
+func (r *reader) passed() (bool, error) {
+	f, ok, err := lockedSegment(r.file.Name())
+	if err != nil || !ok {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() <= r.offset {
+		return true, nil
+	}
+
+	// records flushed after the last read are read first, an incomplete record left by a crashed writer is truncated
+	if _, err = f.Seek(r.offset, io.SeekStart); err != nil {
+		return false, err
+	}
+	if _, _, err = readRecord(bufio.NewReader(f)); err != io.EOF {
+		return false, nil
+	}
+	return true, truncateSegment(r.file.Name(), r.offset, info.Size())
+}
+
 // rotate to next segment
 func (r *reader) rotate() error {
 	if r.file == nil {
@@ -116,15 +168,15 @@ func (r *reader) close() {
 
 // sync index and offset
 func (r *reader) sync() {
-	name := path.Join(r.config.Path, indexFile)
+	name := r.checkpointFile()
 	if data, err := json.Marshal(&r.checkpoint); err == nil {
 		_ = os.WriteFile(name, data, filePerm)
 	}
 }
 
-// restore index and offset
+// restore index and offset, an uninitialized consumer group starts from the oldest segment
 func (r *reader) restore() (err error) {
-	name := path.Join(r.config.Path, indexFile)
+	name := r.checkpointFile()
 
 	// uninitialized
 	if _, err1 := os.Stat(name); err1 != nil {
@@ -135,6 +187,11 @@ func (r *reader) restore() (err error) {
 
 	_ = json.Unmarshal(data, &r.checkpoint)
 	r.index, r.offset = r.checkpoint.Index, r.checkpoint.Offset
+
+	// segments left by a crash may end with an incomplete record
+	if err = recoverSegments(r.config.Path, r.index); err != nil {
+		return
+	}
 	if r.index == 0 {
 		return
 	}
@@ -153,10 +210,12 @@ func (r *reader) next() (string, error) {
 	}
 	sort.Strings(files)
 
+	committed := r.committed()
 	for _, file := range files {
-		index := r.getIndex(file)
-		if index < r.checkpoint.Index {
-			_ = os.Remove(file) // remove expired segment
+		index := segmentIndex(file)
+		if index < committed {
+			_ = os.Remove(file) // remove segment which every consumer group has passed
+			forgetSegment(file)
 		}
 
 		if index > r.index {
@@ -167,8 +226,39 @@ func (r *reader) next() (string, error) {
 	return "", errorQueueEmpty
 }
 
-// get segment index
-func (r *reader) getIndex(filename string) int64 {
+// checkpointFile checkpoint file of the consumer group
+func (r *reader) checkpointFile() string {
+	if r.config.ConsumerGroup == "" {
+		return path.Join(r.config.Path, indexFile)
+	}
+	return path.Join(r.config.Path, indexFile+"."+r.config.ConsumerGroup)
+}
+
+// committed the min committed segment index of all consumer groups
+func (r *reader) committed() int64 {
+	files, err := filepath.Glob(filepath.Join(r.config.Path, indexFile+"*"))
+	if err != nil {
+		return 0
+	}
+
+	committed := r.checkpoint.Index
+	for _, file := range files {
+		var ck checkpoint
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0
+		}
+		// being written by another consumer group
+		if err = json.Unmarshal(data, &ck); err != nil {
+			return 0
+		}
+		committed = min(committed, ck.Index)
+	}
+	return committed
+}
+
+// segmentIndex index of a segment file
+func segmentIndex(filename string) int64 {
 	base := filepath.Base(filename)
 	name := base[0 : len(base)-len(path.Ext(filename))]
 	index, _ := strconv.ParseInt(name, 10, 64)
@@ -0,0 +1,95 @@
+package disk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// record layout: magic(2) version(1) length(4) crc32c(4) data(length)
+// crc32c covers version, length and data, all integers are big endian
+const (
+	recordVersion    = 1
+	recordHeaderSize = 11
+	maxRecordSize    = 1 << 30
+)
+
+var (
+	recordMagic = [2]byte{'Z', 'Q'}
+	crcTable    = crc32.MakeTable(crc32.Castagnoli)
+
+	errorCorrupt = errors.New("corrupt record")
+)
+
+// encodeRecord frame data with header and checksum
+func encodeRecord(data []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(data))
+	record[0], record[1] = recordMagic[0], recordMagic[1]
+	record[2] = recordVersion
+	binary.BigEndian.PutUint32(record[3:7], uint32(len(data)))
+	copy(record[recordHeaderSize:], data)
+	binary.BigEndian.PutUint32(record[7:11], recordChecksum(record))
+	return record
+}
+
+func recordChecksum(record []byte) uint32 {
+	sum := crc32.Update(0, crcTable, record[2:7])
+	return crc32.Update(sum, crcTable, record[recordHeaderSize:])
+}
+
+// readRecord read a record, returns the data and the bytes consumed
+// io.EOF means no complete record yet, the caller should seek back before reading again
+// segments written before framing was introduced are newline separated json and still readable
+func readRecord(r *bufio.Reader) ([]byte, int64, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if first[0] == '{' {
+		return readLine(r)
+	}
+	if first[0] != recordMagic[0] {
+		return nil, 0, errorCorrupt
+	}
+
+	header, err := r.Peek(recordHeaderSize)
+	if err != nil {
+		return nil, 0, incomplete(err)
+	}
+	// a record of an unknown version can not be located, so it is treated as corrupt
+	if header[1] != recordMagic[1] || header[2] != recordVersion {
+		return nil, 0, errorCorrupt
+	}
+	length := binary.BigEndian.Uint32(header[3:7])
+	if length > maxRecordSize {
+		return nil, 0, errorCorrupt
+	}
+
+	record := make([]byte, recordHeaderSize+int(length))
+	if _, err = io.ReadFull(r, record); err != nil {
+		return nil, 0, incomplete(err)
+	}
+	if binary.BigEndian.Uint32(record[7:11]) != recordChecksum(record) {
+		return nil, 0, errorCorrupt
+	}
+	return record[recordHeaderSize:], int64(len(record)), nil
+}
+
+// readLine read a legacy record
+func readLine(r *bufio.Reader) ([]byte, int64, error) {
+	data, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, 0, incomplete(err)
+	}
+	return bytes.TrimRight(data, "\n"), int64(len(data)), nil
+}
+
+func incomplete(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
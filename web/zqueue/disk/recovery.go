@@ -0,0 +1,235 @@
+package disk
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const corruptExt = ".corrupt" // quarantined bytes of a segment, named <index>.corrupt
+
+// RecoveryStats verification and recovery of the segments in a queue path since startup
+type RecoveryStats struct {
+	Segments       int64     `json:"segments"`       // segments verified
+	Records        int64     `json:"records"`        // records verified
+	Truncated      int64     `json:"truncated"`      // segments with an incomplete tail truncated
+	TruncatedBytes int64     `json:"truncatedBytes"` // bytes truncated
+	Corrupted      int64     `json:"corrupted"`      // segments with corrupt records quarantined
+	CorruptedBytes int64     `json:"corruptedBytes"` // bytes moved to .corrupt files
+	RecoveredAt    time.Time `json:"recoveredAt"`    // last time a segment was truncated or quarantined
+}
+
+var (
+	recoveryMu sync.Mutex
+	recoveries = make(map[string]*RecoveryStats) // queue path -> stats
+	verified   = make(map[string]bool)           // segments verified since startup
+)
+
+// Recoveries recovery stats of every queue path opened since startup
+func Recoveries() map[string]RecoveryStats {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+
+	stats := make(map[string]RecoveryStats, len(recoveries))
+	for dir, s := range recoveries {
+		stats[dir] = *s
+	}
+	return stats
+}
+
+// recoveryStats stats of a queue path, must be called with recoveryMu held
+func recoveryStats(dir string) *RecoveryStats {
+	dir = filepath.Clean(dir)
+	s, ok := recoveries[dir]
+	if !ok {
+		s = &RecoveryStats{}
+		recoveries[dir] = s
+	}
+	return s
+}
+
+// recoverSegments verify segments from index, truncate incomplete tails left by a crash and quarantine corrupt records
+// segments locked by a writer of any process are skipped, their tail is not complete yet
+func recoverSegments(dir string, index int64) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.data"))
+	if err != nil {
+		return err
+	}
+
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+
+	stats := recoveryStats(dir)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if segmentIndex(file) < index || verified[file] {
+			continue
+		}
+		ok, err := recoverSegment(file, stats)
+		if err != nil {
+			return err
+		}
+		verified[file] = ok
+	}
+	return nil
+}
+
+// recoverSegment returns false when the segment is being written and was not verified
+func recoverSegment(file string, stats *RecoveryStats) (bool, error) {
+	f, ok, err := lockedSegment(file)
+	if err != nil || !ok {
+		return false, err
+	}
+	// the lock is held until the segment is repaired
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		r      = bufio.NewReader(f)
+		offset int64
+	)
+	for {
+		_, size, err := readRecord(r)
+		if err == nil {
+			offset += size
+			stats.Records++
+			continue
+		}
+
+		stats.Segments++
+		switch err {
+		case io.EOF:
+			if offset == info.Size() {
+				return true, nil
+			}
+			return true, truncate(file, offset, info.Size(), stats)
+		case errorCorrupt:
+			return true, quarantine(file, offset, stats)
+		default:
+			return false, err
+		}
+	}
+}
+
+// truncateSegment truncate the incomplete tail of a segment locked by the caller
+func truncateSegment(file string, offset int64, size int64) error {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	return truncate(file, offset, size, recoveryStats(filepath.Dir(file)))
+}
+
+// truncate must be called with recoveryMu held
+func truncate(file string, offset int64, size int64, stats *RecoveryStats) error {
+	if err := os.Truncate(file, offset); err != nil {
+		return err
+	}
+	stats.Truncated++
+	stats.TruncatedBytes += size - offset
+	stats.RecoveredAt = time.Now()
+	return nil
+}
+
+// quarantineSegment move the bytes from offset to the end of a segment not being written to its .corrupt file
+func quarantineSegment(file string, offset int64) error {
+	f, ok, err := lockedSegment(file)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errorCorrupt
+	}
+	defer f.Close()
+
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	return quarantine(file, offset, recoveryStats(filepath.Dir(file)))
+}
+
+// quarantine must be called with recoveryMu held
+func quarantine(file string, offset int64, stats *RecoveryStats) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// already quarantined by another consumer group
+	if info.Size() <= offset {
+		return nil
+	}
+
+	name := strings.TrimSuffix(file, filepath.Ext(file)) + corruptExt
+	out, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err == nil {
+		_, err = io.Copy(out, f)
+	}
+	if err1 := out.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Truncate(file, offset); err != nil {
+		return err
+	}
+
+	stats.Corrupted++
+	stats.CorruptedBytes += info.Size() - offset
+	stats.RecoveredAt = time.Now()
+	return nil
+}
+
+// lockedSegment open a segment and lock it, returns false when it is locked by a writer of any process
+func lockedSegment(file string) (*os.File, bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := tryLockSegment(f)
+	if err != nil || !ok {
+		_ = f.Close()
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// lockPath lock the queue path, released when the file is closed
+// writers hold it while opening a segment and readers while passing one, so a segment is never passed before its writer locks it
+func lockPath(dir string) (*os.File, error) {
+	return lockName(filepath.Join(dir, lockFile))
+}
+
+// lockName lock a lock file, released when the file is closed
+func lockName(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, filePerm)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockSegment(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// forgetSegment a removed segment
+func forgetSegment(file string) {
+	recoveryMu.Lock()
+	delete(verified, filepath.Clean(file))
+	recoveryMu.Unlock()
+}
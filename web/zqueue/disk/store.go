@@ -0,0 +1,336 @@
+package disk
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+)
+
+const (
+	storeOpPut     = "add"
+	storeOpDel     = "del"
+	compactGarbage = 1024    // compact the log when garbage records exceed this and the live records
+	lockExt        = ".lock" // lock file of a log, held by the process appending or compacting it
+)
+
+// Record a keyed record of Store
+type Record struct {
+	Id   string `json:"id"`
+	Time int64  `json:"time"` // milliseconds
+	Data []byte `json:"data"`
+}
+
+type storeRecord struct {
+	Op   string `json:"op"`
+	Id   string `json:"id"`
+	Time int64  `json:"time,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Store durable keyed records, appended to a log file and replayed on restart
+// processes sharing the log hold its lock file while reading, appending or compacting and replay the records of the others first
+type Store struct {
+	sync.Mutex
+	path    string
+	closed  bool
+	info    os.FileInfo // the log replayed, replaced when any process compacts it
+	offset  int64       // bytes of the log replayed
+	items   map[string]*Record
+	garbage int
+}
+
+func OpenStore(dir, name string) (s *Store, err error) {
+	if _, err = os.Stat(dir); err != nil {
+		return
+	}
+	s = &Store{path: path.Join(dir, name), items: make(map[string]*Record)}
+	if err = s.update(func() error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put add or replace a record
+func (s *Store) Put(record *Record) error {
+	return s.update(func() error {
+		return s.append(&storeRecord{Op: storeOpPut, Id: record.Id, Time: record.Time, Data: record.Data})
+	})
+}
+
+// Add a record, returns false when a record with the same id exists
+func (s *Store) Add(record *Record) (ok bool, err error) {
+	err = s.update(func() error {
+		if _, ok = s.items[record.Id]; ok {
+			ok = false
+			return nil
+		}
+		ok = true
+		return s.append(&storeRecord{Op: storeOpPut, Id: record.Id, Time: record.Time, Data: record.Data})
+	})
+	return
+}
+
+// Delete records, returns the number of deleted records
+func (s *Store) Delete(ids ...string) (n int, err error) {
+	err = s.update(func() error {
+		for _, id := range ids {
+			ok, err := s.delete(id)
+			if err != nil {
+				return err
+			}
+			if ok {
+				n++
+			}
+		}
+		return s.tidy()
+	})
+	return
+}
+
+// Take delete the records ordered by time while fn accepts them, no other process changes the store meanwhile
+// fn returns false to keep the record and stop, e.g. it is not due yet or failed to be handled
+func (s *Store) Take(fn func(record *Record) bool) (n int, err error) {
+	err = s.update(func() error {
+		for _, record := range s.list() {
+			if !fn(record) {
+				break
+			}
+			if _, err := s.delete(record.Id); err != nil {
+				return err
+			}
+			n++
+		}
+		return s.tidy()
+	})
+	return
+}
+
+// Get a record
+func (s *Store) Get(id string) *Record {
+	s.Lock()
+	defer s.Unlock()
+	s.view()
+	return s.items[id]
+}
+
+// List records ordered by time
+func (s *Store) List() []*Record {
+	s.Lock()
+	defer s.Unlock()
+	s.view()
+	return s.list()
+}
+
+// Len number of records
+func (s *Store) Len() int {
+	s.Lock()
+	defer s.Unlock()
+	s.view()
+	return len(s.items)
+}
+
+// Close store
+func (s *Store) Close() {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+}
+
+// update run fn with the records of every process replayed, holding the lock file of the log
+func (s *Store) update(fn func() error) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return errors.New("closed")
+	}
+	lock, err := lockName(s.path + lockExt)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err = s.refresh(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// view replay the records appended by every process before reading, the records replayed last are read when the log can not be locked
+// must be called with the store lock held
+func (s *Store) view() {
+	if s.closed {
+		return
+	}
+	lock, err := lockName(s.path + lockExt)
+	if err != nil {
+		return
+	}
+	defer lock.Close()
+	_ = s.refresh()
+}
+
+// list records ordered by time, must be called with the store lock held
+func (s *Store) list() []*Record {
+	list := make([]*Record, 0, len(s.items))
+	for _, record := range s.items {
+		list = append(list, record)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Time == list[j].Time {
+			return list[i].Id < list[j].Id
+		}
+		return list[i].Time < list[j].Time
+	})
+	return list
+}
+
+// delete a record, must be called with the lock file held
+func (s *Store) delete(id string) (bool, error) {
+	if _, ok := s.items[id]; !ok {
+		return false, nil
+	}
+	if err := s.append(&storeRecord{Op: storeOpDel, Id: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// tidy compact the log when it is mostly garbage, must be called with the lock file held
+func (s *Store) tidy() error {
+	if s.garbage > compactGarbage && s.garbage > len(s.items) {
+		return s.compact()
+	}
+	return nil
+}
+
+// append a record, sync to disk and replay it, must be called with the lock file held
+func (s *Store) append(record *storeRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(data, '\n')); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return s.refresh()
+}
+
+// refresh replay the records appended since the last refresh, the whole log after it was compacted
+// must be called with the lock file held
+func (s *Store) refresh() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		s.reset(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if s.info == nil || !os.SameFile(s.info, info) || info.Size() < s.offset {
+		s.reset(info)
+	}
+	if info.Size() == s.offset {
+		return nil
+	}
+	if _, err = file.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a record partially written by a crashed process, records appended later start on a new line
+			if len(line) > 0 {
+				return os.Truncate(s.path, s.offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.offset += int64(len(line))
+		s.apply(line)
+	}
+}
+
+// reset replay the log from the beginning
+func (s *Store) reset(info os.FileInfo) {
+	s.info, s.offset, s.items, s.garbage = info, 0, make(map[string]*Record), 0
+}
+
+// apply a record of the log
+func (s *Store) apply(line []byte) {
+	var record storeRecord
+	// skip a corrupt record
+	if err := json.Unmarshal(line, &record); err != nil {
+		return
+	}
+	switch record.Op {
+	case storeOpPut:
+		if _, ok := s.items[record.Id]; ok {
+			s.garbage++
+		}
+		s.items[record.Id] = &Record{Id: record.Id, Time: record.Time, Data: record.Data}
+	case storeOpDel:
+		delete(s.items, record.Id)
+		s.garbage += 2
+	}
+}
+
+// compact rewrite the log with live records only, other processes replay the new log on their next refresh
+// must be called with the lock file held
+func (s *Store) compact() error {
+	tmp := s.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, item := range s.items {
+		data, err := json.Marshal(&storeRecord{Op: storeOpPut, Id: item.Id, Time: item.Time, Data: item.Data})
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		_, _ = w.Write(append(data, '\n'))
+	}
+	if err = w.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	return s.refresh()
+}
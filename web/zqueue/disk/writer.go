@@ -11,17 +11,19 @@ import (
 )
 
 type writer struct {
-	file   *os.File
-	size   int64
-	count  int64
-	writer *bufio.Writer
-	config *Config
+	file    *os.File
+	last    string // segment released while idle, appended again while it is the newest
+	size    int64
+	count   int64
+	written bool // records written since the last tick
+	writer  *bufio.Writer
+	config  *Config
 }
 
 // write data
 func (w *writer) write(data []byte) error {
-	// append newline
-	data = append(data, "\n"...)
+	// frame with header and checksum
+	data = encodeRecord(data)
 	size := int64(len(data))
 
 	// close current segment for rotate
@@ -31,7 +33,7 @@ func (w *writer) write(data []byte) error {
 
 	// create a new segment
 	if w.file == nil {
-		if err := w.open(); err != nil {
+		if err := w.open(size); err != nil {
 			return err
 		}
 	}
@@ -42,6 +44,7 @@ func (w *writer) write(data []byte) error {
 	}
 
 	w.size += size
+	w.written = true
 
 	// sync data to disk
 	w.count++
@@ -52,22 +55,93 @@ func (w *writer) write(data []byte) error {
 	return nil
 }
 
-// create a new segment
-func (w *writer) open() (err error) {
+// open the released segment while it is the newest and has room for size bytes, otherwise create a new segment
+func (w *writer) open(size int64) (err error) {
+	// readers do not pass a segment while it is opened
+	lock, err := lockPath(w.config.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	newest := w.newest()
+	last := w.last
+	w.last = ""
+	if last != "" && segmentIndex(last) == newest {
+		if ok, err := w.reopen(last, size); err != nil || ok {
+			return err
+		}
+	}
+
 	if w.segmentNum() >= w.config.SegmentLimit {
 		return errors.New("segment num exceeds the limit")
 	}
 
-	name := path.Join(w.config.Path, fmt.Sprintf("%013d.data", time.Now().UnixNano()/1e6))
-	if w.file, err = os.OpenFile(name, os.O_CREATE|os.O_WRONLY, filePerm); err != nil {
+	// segments created in the same millisecond take the next index, an existing segment is never overwritten
+	name := path.Join(w.config.Path, fmt.Sprintf("%013d.data", max(time.Now().UnixNano()/1e6, newest+1)))
+	if w.file, err = os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePerm); err != nil {
+		return err
+	}
+	// readers of any process do not recover or pass a segment while it is locked
+	if err = lockSegment(w.file); err != nil {
+		_ = w.file.Close()
+		w.file = nil
 		return err
 	}
 
 	w.size = 0
+	w.buffer()
+	return nil
+}
+
+// reopen a released segment, returns false when it is full
+func (w *writer) reopen(name string, size int64) (bool, error) {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return false, nil
+	}
+	if err = lockSegment(file); err != nil {
+		_ = file.Close()
+		return false, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.Size()+size > w.config.SegmentSize {
+		_ = file.Close()
+		return false, err
+	}
+
+	w.file, w.size = file, info.Size()
+	w.buffer()
+	return true, nil
+}
+
+// buffer the records of the opened segment
+func (w *writer) buffer() {
 	// disable auto flush
 	w.writer = bufio.NewWriterSize(w.file, int(w.config.SegmentSize))
 	w.writer.Reset(w.file)
-	return err
+}
+
+// tick sync the buffered records, release the segment when nothing was written since the last tick
+func (w *writer) tick() {
+	if !w.written {
+		w.release()
+		return
+	}
+	w.written = false
+	w.sync()
+}
+
+// release the segment so readers of any process can pass it when newer segments are written
+func (w *writer) release() {
+	if w.file == nil {
+		return
+	}
+	name := w.file.Name()
+	w.close()
+	if w.file == nil {
+		w.last = name
+	}
 }
 
 // sync data to disk
@@ -100,3 +174,12 @@ func (w *writer) segmentNum() int64 {
 	segments, _ := filepath.Glob(path.Join(w.config.Path, "*.data"))
 	return int64(len(segments))
 }
+
+// newest index of the segments
+func (w *writer) newest() (index int64) {
+	segments, _ := filepath.Glob(path.Join(w.config.Path, "*.data"))
+	for _, file := range segments {
+		index = max(index, segmentIndex(file))
+	}
+	return
+}
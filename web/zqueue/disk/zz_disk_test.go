@@ -0,0 +1,596 @@
+package disk
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/test/gtest"
+)
+
+// testConfig 每条消息同步一次的队列配置
+func testConfig(dir string, group string) *Config {
+	return &Config{
+		GroupName:     "test",
+		ConsumerGroup: group,
+		Path:          dir,
+		BatchSize:     1,
+		BatchTime:     time.Second,
+		SegmentSize:   1 << 20,
+		SegmentLimit:  100,
+	}
+}
+
+// testWrite 写入消息后关闭队列
+func testWrite(t *gtest.T, dir string, list ...string) {
+	q, err := New(testConfig(dir, ""))
+	t.AssertNil(err)
+	defer q.Close()
+	for _, data := range list {
+		t.AssertNil(q.Write([]byte(data)))
+	}
+}
+
+// testReadAll 读取并确认全部已写入的消息
+func testReadAll(q *Queue) (list []string) {
+	for {
+		index, offset, data, err := q.Read()
+		if err != nil {
+			return
+		}
+		q.Commit(index, offset)
+		list = append(list, string(data))
+	}
+}
+
+// testSegments 队列目录中的分片文件
+func testSegments(t *gtest.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.data"))
+	t.AssertNil(err)
+	return files
+}
+
+func TestRecord(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		record := encodeRecord([]byte("hello"))
+		t.Assert(len(record), recordHeaderSize+5)
+
+		data, size, err := readRecord(bufio.NewReader(bytes.NewReader(record)))
+		t.AssertNil(err)
+		t.Assert(string(data), "hello")
+		t.Assert(size, len(record))
+
+		// 旧版本按行分隔的消息仍然可以读取
+		data, size, err = readRecord(bufio.NewReader(bytes.NewReader([]byte("{\"a\":1}\n"))))
+		t.AssertNil(err)
+		t.Assert(string(data), "{\"a\":1}")
+		t.Assert(size, 8)
+
+		// 不完整的消息等待写入完成
+		_, _, err = readRecord(bufio.NewReader(bytes.NewReader(record[:recordHeaderSize+2])))
+		t.Assert(err.Error(), "EOF")
+
+		// 校验和、魔数或版本错误
+		for _, i := range []int{0, 2, 7, recordHeaderSize} {
+			corrupt := bytes.Clone(record)
+			corrupt[i] ^= 0xff
+			_, _, err = readRecord(bufio.NewReader(bytes.NewReader(corrupt)))
+			t.Assert(err, errorCorrupt)
+		}
+	})
+}
+
+func TestWriter_Rotate(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+
+		// 每条消息一个分片，同一毫秒内创建的分片不会覆盖已有分片
+		config := testConfig(dir, "")
+		config.SegmentSize = recordHeaderSize + 1
+		q, err := New(config)
+		t.AssertNil(err)
+		defer q.Close()
+		list := []string{"1", "2", "3", "4", "5"}
+		for _, data := range list {
+			t.AssertNil(q.Write([]byte(data)))
+		}
+		t.Assert(len(testSegments(t, dir)), len(list))
+		t.Assert(testReadAll(q), list)
+	})
+}
+
+func TestWriter_Release(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		w1, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer w1.Close()
+		w2, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+
+		// 空闲时释放的分片仍是最新分片时继续写入
+		t.AssertNil(w1.Write([]byte("1")))
+		w1.writer.release()
+		t.AssertNil(w1.Write([]byte("2")))
+		t.Assert(len(testSegments(t, dir)), 1)
+
+		// 其他生产者创建了更新的分片后，读取者可能已经跳过释放的分片，写入新的分片
+		t.AssertNil(w2.Write([]byte("3")))
+		w1.writer.release()
+		t.AssertNil(w1.Write([]byte("4")))
+		t.Assert(len(testSegments(t, dir)), 3)
+		w2.Close()
+
+		q, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer q.Close()
+		t.Assert(testReadAll(q), []string{"1", "2", "3", "4"})
+	})
+}
+
+func TestReader_WaitWriter(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		w1, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		w2, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer w2.Close()
+		q, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer q.Close()
+
+		// 两个生产者各自写入一个分片
+		t.AssertNil(w1.Write([]byte("1")))
+		t.AssertNil(w2.Write([]byte("2")))
+		t.Assert(len(testSegments(t, dir)), 2)
+
+		// 旧分片仍被生产者持有时不跳到新的分片
+		t.Assert(testReadAll(q), []string{"1"})
+		t.AssertNil(w1.Write([]byte("3")))
+		t.Assert(testReadAll(q), []string{"3"})
+
+		// 生产者释放分片后读完剩余的消息再读新的分片
+		t.AssertNil(w1.Write([]byte("4")))
+		w1.Close()
+		t.Assert(testReadAll(q), []string{"4", "2"})
+	})
+}
+
+func TestRecovery_TruncateTail(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		testWrite(t, dir, "1", "2", "3")
+
+		// 崩溃时留下不完整的消息
+		segments := testSegments(t, dir)
+		t.Assert(len(segments), 1)
+		f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, filePerm)
+		t.AssertNil(err)
+		_, err = f.Write(encodeRecord([]byte("4"))[:5])
+		t.AssertNil(err)
+		t.AssertNil(f.Close())
+
+		q, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer q.Close()
+		t.Assert(testReadAll(q), []string{"1", "2", "3"})
+
+		stat, err := os.Stat(segments[0])
+		t.AssertNil(err)
+		t.Assert(stat.Size(), 3*(recordHeaderSize+1))
+
+		stats := q.Recovery()
+		t.Assert(stats.Segments, 1)
+		t.Assert(stats.Records, 3)
+		t.Assert(stats.Truncated, 1)
+		t.Assert(stats.TruncatedBytes, 5)
+		t.Assert(stats.Corrupted, 0)
+		t.Assert(Recoveries()[filepath.Clean(dir)], stats)
+	})
+}
+
+func TestRecovery_Quarantine(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		testWrite(t, dir, "1", "2", "3")
+
+		// 第二条消息损坏，之后的内容无法定位，全部隔离
+		segments := testSegments(t, dir)
+		data, err := os.ReadFile(segments[0])
+		t.AssertNil(err)
+		size := recordHeaderSize + 1
+		data[2*size-1] ^= 0xff
+		t.AssertNil(os.WriteFile(segments[0], data, filePerm))
+
+		q, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer q.Close()
+		t.Assert(testReadAll(q), []string{"1"})
+
+		corrupt, err := os.ReadFile(segments[0][:len(segments[0])-len(".data")] + corruptExt)
+		t.AssertNil(err)
+		t.Assert(corrupt, data[size:])
+
+		stats := q.Recovery()
+		t.Assert(stats.Records, 1)
+		t.Assert(stats.Corrupted, 1)
+		t.Assert(stats.CorruptedBytes, 2*size)
+		t.Assert(stats.Truncated, 0)
+		t.AssertNE(stats.RecoveredAt.IsZero(), true)
+
+		// 之后写入的消息正常消费
+		t.AssertNil(q.Write([]byte("4")))
+		t.Assert(testReadAll(q), []string{"4"})
+	})
+}
+
+func TestRecovery_SkipSegmentBeingWritten(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+
+		// 生产者持有分片的锁，消费者所在的进程看不到生产者的内存状态
+		producer, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		t.AssertNil(producer.Write([]byte("1")))
+		segments := testSegments(t, dir)
+		t.Assert(len(segments), 1)
+
+		// 模拟生产者正在写入的半条消息
+		f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, filePerm)
+		t.AssertNil(err)
+		partial := encodeRecord([]byte("2"))
+		_, err = f.Write(partial[:5])
+		t.AssertNil(err)
+
+		consumer, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer consumer.Close()
+		t.Assert(testReadAll(consumer), []string{"1"})
+		t.Assert(consumer.Recovery().Truncated, 0)
+
+		stat, err := os.Stat(segments[0])
+		t.AssertNil(err)
+		t.Assert(stat.Size(), recordHeaderSize+1+5)
+
+		// 写入完成后消费者读到完整的消息
+		_, err = f.Write(partial[5:])
+		t.AssertNil(err)
+		t.AssertNil(f.Close())
+		t.Assert(testReadAll(consumer), []string{"2"})
+
+		// 写入期间不隔离分片的内容，生产者关闭后才可以隔离
+		t.Assert(quarantineSegment(segments[0], 0), errorCorrupt)
+		producer.Close()
+		t.AssertNil(quarantineSegment(segments[0], recordHeaderSize+1))
+		t.Assert(consumer.Recovery().Corrupted, 1)
+	})
+}
+
+func TestStore(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		s, err := OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		t.AssertNil(s.Put(&Record{Id: "b", Time: 2, Data: []byte("b")}))
+		t.AssertNil(s.Put(&Record{Id: "a", Time: 1, Data: []byte("a")}))
+		t.AssertNil(s.Put(&Record{Id: "c", Time: 3, Data: []byte("c")}))
+		t.AssertNil(s.Put(&Record{Id: "b", Time: 2, Data: []byte("b2")}))
+		n, err := s.Delete("c", "x")
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		s.Close()
+		t.AssertNE(s.Put(&Record{Id: "d"}), nil)
+
+		// 崩溃时写了一半的记录在重启时跳过
+		f, err := os.OpenFile(filepath.Join(dir, "test.log"), os.O_WRONLY|os.O_APPEND, filePerm)
+		t.AssertNil(err)
+		_, err = f.WriteString(`{"op":"add","id":"d"`)
+		t.AssertNil(err)
+		t.AssertNil(f.Close())
+
+		// 重启后按时间排序恢复
+		s, err = OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		defer s.Close()
+		list := s.List()
+		t.Assert(len(list), 2)
+		t.Assert(list[0].Id, "a")
+		t.Assert(list[1].Id, "b")
+		t.Assert(s.Get("b").Data, []byte("b2"))
+		t.Assert(s.Get("c"), nil)
+	})
+}
+
+func TestStore_Compact(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		s, err := OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		t.AssertNil(s.Put(&Record{Id: "keep", Time: 1}))
+		for i := 0; i <= compactGarbage/2; i++ {
+			t.AssertNil(s.Put(&Record{Id: "tmp", Time: 2}))
+			_, err = s.Delete("tmp")
+			t.AssertNil(err)
+		}
+		t.Assert(s.garbage < compactGarbage, true)
+
+		// 压缩后只保留有效的记录，重启后仍然可以恢复
+		data, err := os.ReadFile(filepath.Join(dir, "test.log"))
+		t.AssertNil(err)
+		t.Assert(bytes.Count(data, []byte("\n")) < compactGarbage, true)
+		t.AssertNil(s.Put(&Record{Id: "new", Time: 3}))
+		s.Close()
+
+		s, err = OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		defer s.Close()
+		t.Assert(s.Len(), 2)
+		t.AssertNE(s.Get("keep"), nil)
+		t.AssertNE(s.Get("new"), nil)
+	})
+}
+
+// testDeliver 投递到期的延迟消息，返回投递的消息ID
+func testDeliver(t *gtest.T, d *Delay, now time.Time, limit int) (ids []string) {
+	_, err := d.Deliver(now, limit, func(item *DelayItem) error {
+		ids = append(ids, item.Id)
+		return nil
+	})
+	t.AssertNil(err)
+	return
+}
+
+func TestDelay(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			dir = t.TempDir()
+			now = time.Now()
+		)
+		d, err := NewDelay(dir)
+		t.AssertNil(err)
+		t.AssertNil(d.Add("late", now.Add(time.Hour), []byte("late")))
+		t.AssertNil(d.Add("second", now.Add(2*time.Second), []byte("second")))
+		t.AssertNil(d.Add("first", now.Add(time.Second), []byte("first")))
+		t.AssertNil(d.Add("canceled", now.Add(time.Second), []byte("canceled")))
+		t.AssertNE(d.Add("first", now, nil), nil)
+
+		// 取消后不再投递，不存在的消息不能取消
+		ok, err := d.Remove("canceled")
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		ok, err = d.Remove("canceled")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		// 未到期时不投递
+		t.Assert(len(testDeliver(t, d, now, 10)), 0)
+
+		// 投递失败时保留，之后重新投递
+		n, err := d.Deliver(now.Add(3*time.Second), 10, func(item *DelayItem) error {
+			return errors.New("write failed")
+		})
+		t.AssertNE(err, nil)
+		t.Assert(n, 0)
+
+		// 按到期时间投递，每次最多 limit 条，已投递的消息不能取消
+		t.Assert(testDeliver(t, d, now.Add(3*time.Second), 1), []string{"first"})
+		ok, err = d.Remove("first")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+		d.Close()
+
+		// 重启后恢复未投递的延迟消息
+		d, err = NewDelay(dir)
+		t.AssertNil(err)
+		defer d.Close()
+		t.Assert(d.Len(), 2)
+		items := testDeliver(t, d, now.Add(3*time.Second), 10)
+		t.Assert(items, []string{"second"})
+		t.Assert(testDeliver(t, d, now.Add(2*time.Hour), 10), []string{"late"})
+		t.Assert(d.Len(), 0)
+	})
+}
+
+func TestDelay_Processes(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			dir = t.TempDir()
+			now = time.Now()
+		)
+		// 两个实例模拟同一主题的两个进程
+		owner, err := NewDelay(dir)
+		t.AssertNil(err)
+		other, err := NewDelay(dir)
+		t.AssertNil(err)
+		defer other.Close()
+
+		// 其他进程添加和取消的消息由持有主题的进程投递，每条消息只投递一次
+		t.AssertNil(owner.Add("a", now, []byte("a")))
+		t.AssertNil(other.Add("b", now.Add(time.Millisecond), []byte("b")))
+		t.AssertNil(other.Add("c", now.Add(2*time.Millisecond), []byte("c")))
+		t.AssertNE(owner.Add("b", now, nil), nil)
+		ok, err := other.Remove("a")
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		t.Assert(testDeliver(t, owner, now.Add(time.Second), 1), []string{"b"})
+		t.Assert(len(testDeliver(t, other, now.Add(time.Second), 10)), 0)
+
+		// 已投递的消息在其他进程中不能取消
+		ok, err = other.Remove("b")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		// 持有主题的进程退出后由其他进程接管
+		owner.Close()
+		t.Assert(testDeliver(t, other, now.Add(time.Second), 10), []string{"c"})
+	})
+}
+
+func TestStore_Processes(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		s1, err := OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		defer s1.Close()
+		s2, err := OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		defer s2.Close()
+
+		// 压缩替换日志文件后，其他进程追加的记录不会丢失
+		t.AssertNil(s2.Put(&Record{Id: "keep", Time: 1}))
+		for i := 0; i <= compactGarbage/2; i++ {
+			t.AssertNil(s1.Put(&Record{Id: "tmp", Time: 2}))
+			_, err = s1.Delete("tmp")
+			t.AssertNil(err)
+		}
+		t.AssertNil(s2.Put(&Record{Id: "new", Time: 3}))
+		ok, err := s1.Add(&Record{Id: "new", Time: 4})
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		s3, err := OpenStore(dir, "test.log")
+		t.AssertNil(err)
+		defer s3.Close()
+		t.Assert(s3.Len(), 2)
+		t.AssertNE(s3.Get("keep"), nil)
+		t.AssertNE(s3.Get("new"), nil)
+	})
+}
+
+func TestConsumerGroups(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		t.AssertNil(Join(testConfig(dir, "")))
+		t.AssertNil(Join(testConfig(dir, "audit")))
+		t.AssertNE(Join(testConfig(dir, "../audit")), nil)
+
+		// 每条消息一个分片，分片文件名精确到毫秒
+		conf := testConfig(dir, "")
+		conf.SegmentSize = recordHeaderSize + 1
+		q, err := New(conf)
+		t.AssertNil(err)
+		for _, data := range []string{"1", "2", "3"} {
+			t.AssertNil(q.Write([]byte(data)))
+			time.Sleep(2 * time.Millisecond)
+		}
+		q.Close()
+		t.Assert(len(testSegments(t, dir)), 3)
+
+		// 其他消费者组还没有读取时保留分片
+		def, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer def.Close()
+		t.Assert(testReadAll(def), []string{"1", "2", "3"})
+		t.Assert(len(testSegments(t, dir)), 3)
+
+		// 所有消费者组都读过的分片才删除，每个消费者组都消费全部消息
+		audit, err := New(testConfig(dir, "audit"))
+		t.AssertNil(err)
+		defer audit.Close()
+		t.Assert(testReadAll(audit), []string{"1", "2", "3"})
+		segments := testSegments(t, dir)
+		t.Assert(len(segments) < 3, true)
+		t.Assert(segmentIndex(segments[len(segments)-1]), def.reader.checkpoint.Index)
+
+		// 已提交的消息不再消费
+		t.Assert(len(testReadAll(def)), 0)
+		t.Assert(len(testReadAll(audit)), 0)
+
+		info, err := Inspect(dir)
+		t.AssertNil(err)
+		t.Assert(len(info.Groups), 2)
+		t.Assert(info.Groups[0].Group, "")
+		t.Assert(info.Groups[1].Group, "audit")
+		for _, g := range info.Groups {
+			t.Assert(g.Lag, 0)
+		}
+	})
+}
+
+func TestConsumerGroups_Leave(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		t.AssertNil(Join(testConfig(dir, "")))
+		t.AssertNil(Join(testConfig(dir, "removed")))
+
+		conf := testConfig(dir, "")
+		conf.SegmentSize = recordHeaderSize + 1
+		q, err := New(conf)
+		t.AssertNil(err)
+		for _, data := range []string{"1", "2", "3"} {
+			t.AssertNil(q.Write([]byte(data)))
+		}
+		q.Close()
+
+		// 不再使用的消费者组没有读取，分片一直保留
+		def, err := New(testConfig(dir, ""))
+		t.AssertNil(err)
+		defer def.Close()
+		t.Assert(testReadAll(def), []string{"1", "2", "3"})
+		t.Assert(len(testSegments(t, dir)), 3)
+
+		// 删除消费者组后不再为其保留分片
+		t.AssertNil(Leave(testConfig(dir, "removed")))
+		t.AssertNil(Leave(testConfig(dir, "removed")))
+		t.AssertNE(Leave(testConfig(dir, "../removed")), nil)
+		t.Assert(len(testReadAll(def)), 0)
+		t.Assert(len(testSegments(t, dir)), 1)
+
+		info, err := Inspect(dir)
+		t.AssertNil(err)
+		t.Assert(len(info.Groups), 1)
+		t.Assert(info.Groups[0].Group, "")
+	})
+}
+
+func TestInspect(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		dir := t.TempDir()
+		t.AssertNil(Join(testConfig(dir, "")))
+		t.AssertNil(Join(testConfig(dir, "audit")))
+		testWrite(t, dir, "1", "2", "3")
+
+		info, err := Inspect(dir)
+		t.AssertNil(err)
+		t.Assert(info.Segments, 1)
+		t.Assert(info.Bytes, 3*(recordHeaderSize+1))
+		t.Assert(info.Offset, info.Bytes)
+		for _, g := range info.Groups {
+			t.Assert(g.Lag, 3)
+			t.Assert(g.LagBytes, info.Bytes)
+		}
+		t.Assert(counts[testSegments(t, dir)[0]].records, 3)
+
+		// 已统计的记录数量在之后的调用中复用，只读取新写入的数据
+		q, err := New(testConfig(dir, "audit"))
+		t.AssertNil(err)
+		index, offset, _, err := q.Read()
+		t.AssertNil(err)
+		q.Commit(index, offset)
+		q.Close()
+		testWrite(t, dir, "4", "5")
+
+		info, err = Inspect(dir)
+		t.AssertNil(err)
+		t.Assert(info.Bytes, 5*(recordHeaderSize+1))
+		t.Assert(info.Groups[0].Lag, 5)
+		t.Assert(info.Groups[1].Group, "audit")
+		t.Assert(info.Groups[1].Lag, 4)
+		t.Assert(info.Groups[1].LagBytes, 4*(recordHeaderSize+1))
+
+		// 恢复时截断的分片重新统计
+		segments := testSegments(t, dir)
+		t.Assert(len(segments), 2)
+		t.AssertNil(os.Truncate(segments[1], recordHeaderSize+1))
+		info, err = Inspect(dir)
+		t.AssertNil(err)
+		t.Assert(info.Segments, 2)
+		t.Assert(info.Groups[0].Lag, 4)
+		t.Assert(info.Groups[1].Lag, 3)
+	})
+}
@@ -0,0 +1,100 @@
+package zqueue
+
+import (
+	"sync"
+)
+
+// dispatchBatch 一批待处理的消息
+type dispatchBatch struct {
+	seq     uint64
+	list    []MqMsg
+	commits []func()
+}
+
+// dispatcher 将驱动读取的消息分批交给多个协程处理，并按读取顺序提交
+// 驱动调用 Dispatch 添加消息，没有更多消息时调用 Flush，停止消费时调用 Close
+type dispatcher struct {
+	options   ConsumeOptions
+	receiveDo func(list []MqMsg)
+	jobs      chan *dispatchBatch
+	wg        sync.WaitGroup
+	batch     *dispatchBatch // 正在凑批的消息，只由驱动的读取协程访问
+	seq       uint64
+
+	mu   sync.Mutex
+	next uint64                    // 下一批需要提交的序号
+	done map[uint64]*dispatchBatch // 已处理完成但前面的批次还没完成
+}
+
+func newDispatcher(options *ConsumeOptions, receiveDo func(list []MqMsg)) *dispatcher {
+	d := &dispatcher{
+		options:   options.normalize(),
+		receiveDo: receiveDo,
+		done:      make(map[uint64]*dispatchBatch),
+	}
+	d.jobs = make(chan *dispatchBatch)
+	for i := 0; i < d.options.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch 添加一条消息，commit 在该消息和之前的消息都处理完成后调用
+// 所有协程都在处理时阻塞，避免读取过多的消息
+func (d *dispatcher) Dispatch(mqMsg MqMsg, commit func()) {
+	if d.batch == nil {
+		d.batch = &dispatchBatch{seq: d.seq}
+		d.seq++
+	}
+	d.batch.list = append(d.batch.list, mqMsg)
+	if commit != nil {
+		d.batch.commits = append(d.batch.commits, commit)
+	}
+	if len(d.batch.list) >= d.options.BatchSize {
+		d.Flush()
+	}
+}
+
+// Flush 立即处理未凑满的一批消息
+func (d *dispatcher) Flush() {
+	if d.batch == nil {
+		return
+	}
+	d.jobs <- d.batch
+	d.batch = nil
+}
+
+// Close 处理剩余的消息，等待全部处理完成并提交
+func (d *dispatcher) Close() {
+	d.Flush()
+	close(d.jobs)
+	d.wg.Wait()
+}
+
+func (d *dispatcher) work() {
+	defer d.wg.Done()
+	for batch := range d.jobs {
+		d.receiveDo(batch.list)
+		d.commit(batch)
+	}
+}
+
+// commit 按顺序提交已处理完成的批次
+func (d *dispatcher) commit(batch *dispatchBatch) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.done[batch.seq] = batch
+	for {
+		b, ok := d.done[d.next]
+		if !ok {
+			return
+		}
+		for _, commit := range b.commits {
+			commit()
+		}
+		delete(d.done, d.next)
+		d.next++
+	}
+}
@@ -0,0 +1,232 @@
+package zqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/internal/model/do"
+	"github.com/denghuo98/zzframe/web/zcache"
+)
+
+// IdempotentConsumer 需要按消息ID去重的消费者实现该接口
+// 处理成功的消息ID在 TTL 内再次投递时直接跳过，不再调用 Handle 或 HandleBatch
+type IdempotentConsumer interface {
+	Idempotent() *Idempotent
+}
+
+// Idempotent 消费去重选项
+type Idempotent struct {
+	TTL   time.Duration  // 记录已处理消息ID的时长，默认 24 小时，需要大于消息可能被重复投递的时间
+	Store ProcessedStore // 已处理消息ID的存储，默认为 CacheProcessedStore
+}
+
+// ProcessedStore 已处理消息的存储，key 由主题、消费者组和消息ID组成
+type ProcessedStore interface {
+	// Processed 返回 keys 中已处理的 key
+	Processed(ctx context.Context, keys []string) (processed map[string]bool, err error)
+	// MarkProcessed 记录处理成功的 key，ttl 后过期
+	MarkProcessed(ctx context.Context, keys []string, ttl time.Duration) (err error)
+}
+
+// defaultProcessedTTL 默认记录已处理消息ID的时长
+const defaultProcessedTTL = 24 * time.Hour
+
+// idempotentOptions 消费者的去重选项，未实现 IdempotentConsumer 时返回 nil
+func idempotentOptions(job Consumer) *Idempotent {
+	ic, ok := job.(IdempotentConsumer)
+	if !ok || ic.Idempotent() == nil {
+		return nil
+	}
+	opts := *ic.Idempotent()
+	if opts.TTL <= 0 {
+		opts.TTL = defaultProcessedTTL
+	}
+	if opts.Store == nil {
+		opts.Store = CacheProcessedStore{}
+	}
+	return &opts
+}
+
+// processedKey 已处理消息的 key，不同消费者组分别去重
+func processedKey(topic string, group string, msgId string) string {
+	return fmt.Sprintf("zqueue:processed:%s:%s:%s", topic, group, msgId)
+}
+
+// skipProcessed 过滤已处理的消息和同一批中重复的消息，没有消息ID的消息不去重
+// 读取存储失败时不过滤，保证消息至少处理一次
+func (o *Idempotent) skipProcessed(ctx context.Context, topic string, group string, list []MqMsg) []MqMsg {
+	keys := make([]string, 0, len(list))
+	for _, mqMsg := range list {
+		if mqMsg.MsgId != "" {
+			keys = append(keys, processedKey(topic, group, mqMsg.MsgId))
+		}
+	}
+	if len(keys) == 0 {
+		return list
+	}
+
+	processed, err := o.Store.Processed(ctx, keys)
+	if err != nil {
+		Logger().Warningf(ctx, "消费 [%s] 读取已处理消息失败, err:%+v", topic, err)
+		processed = nil
+	}
+
+	var (
+		seen = make(map[string]bool, len(keys))
+		out  = make([]MqMsg, 0, len(list))
+	)
+	for _, mqMsg := range list {
+		if mqMsg.MsgId != "" {
+			key := processedKey(topic, group, mqMsg.MsgId)
+			if processed[key] || seen[key] {
+				Logger().Debugf(ctx, "消费 [%s] 跳过已处理的消息, msgId:%v", topic, mqMsg.MsgId)
+				continue
+			}
+			seen[key] = true
+		}
+		out = append(out, mqMsg)
+	}
+	return out
+}
+
+// markProcessed 记录处理成功的消息，记录失败时消息重复投递后会再次处理
+func (o *Idempotent) markProcessed(ctx context.Context, topic string, group string, list ...MqMsg) {
+	keys := make([]string, 0, len(list))
+	for _, mqMsg := range list {
+		if mqMsg.MsgId != "" {
+			keys = append(keys, processedKey(topic, group, mqMsg.MsgId))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := o.Store.MarkProcessed(ctx, keys, o.TTL); err != nil {
+		Logger().Warningf(ctx, "消费 [%s] 记录已处理消息失败, err:%+v", topic, err)
+	}
+}
+
+// CacheProcessedStore 使用 zcache 记录已处理的消息，需要先加载系统配置初始化缓存
+// 使用内存缓存时重启后记录丢失，需要跨重启去重时请使用 redis、file 缓存或 DBProcessedStore
+type CacheProcessedStore struct{}
+
+// Processed 返回 keys 中已处理的 key
+func (s CacheProcessedStore) Processed(ctx context.Context, keys []string) (processed map[string]bool, err error) {
+	cache, err := s.instance(ctx)
+	if err != nil {
+		return
+	}
+	processed = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		ok, err := cache.Contains(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		processed[key] = ok
+	}
+	return
+}
+
+// MarkProcessed 记录处理成功的 key
+func (s CacheProcessedStore) MarkProcessed(ctx context.Context, keys []string, ttl time.Duration) (err error) {
+	cache, err := s.instance(ctx)
+	if err != nil {
+		return
+	}
+	data := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		data[key] = 1
+	}
+	return cache.SetMap(ctx, data, ttl)
+}
+
+// instance 缓存未初始化时返回错误，不中断消费
+func (s CacheProcessedStore) instance(ctx context.Context) (cache *gcache.Cache, err error) {
+	err = g.Try(ctx, func(ctx context.Context) {
+		cache = zcache.Instance()
+	})
+	return
+}
+
+// DBProcessedStore 使用 zz_sys_queue_processed 表记录已处理的消息，过期的记录定期删除
+type DBProcessedStore struct{}
+
+// processedCleanInterval 删除过期记录的间隔
+const processedCleanInterval = 10 * time.Minute
+
+// processedCleaner 上次删除过期记录的时间
+var processedCleaner struct {
+	sync.Mutex
+	cleanedAt time.Time
+}
+
+// Processed 返回 keys 中已处理的 key
+func (s DBProcessedStore) Processed(ctx context.Context, keys []string) (processed map[string]bool, err error) {
+	cols := dao.SysQueueProcessed.Columns()
+	array, err := dao.SysQueueProcessed.Ctx(ctx).
+		Fields(cols.MsgKey).
+		WhereIn(cols.MsgKey, keys).
+		WhereGT(cols.ExpiredAt, gtime.Now()).
+		Array()
+	if err != nil {
+		return
+	}
+	processed = make(map[string]bool, len(array))
+	for _, v := range array {
+		processed[v.String()] = true
+	}
+	return
+}
+
+// MarkProcessed 记录处理成功的 key，已存在时更新过期时间
+func (s DBProcessedStore) MarkProcessed(ctx context.Context, keys []string, ttl time.Duration) (err error) {
+	var (
+		cols = dao.SysQueueProcessed.Columns()
+		now  = gtime.Now()
+		list = make([]do.SysQueueProcessed, 0, len(keys))
+	)
+	for _, key := range keys {
+		list = append(list, do.SysQueueProcessed{MsgKey: key, ExpiredAt: now.Add(ttl), CreatedAt: now})
+	}
+
+	if err = dao.SysQueueProcessed.Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+		if _, err := tx.Model(dao.SysQueueProcessed.Table()).Ctx(ctx).WhereIn(cols.MsgKey, keys).Delete(); err != nil {
+			return err
+		}
+		_, err := tx.Model(dao.SysQueueProcessed.Table()).Ctx(ctx).Data(list).Insert()
+		return err
+	}); err != nil {
+		return
+	}
+
+	s.clean(ctx)
+	return
+}
+
+// clean 删除过期的记录
+func (s DBProcessedStore) clean(ctx context.Context) {
+	processedCleaner.Lock()
+	if time.Since(processedCleaner.cleanedAt) < processedCleanInterval {
+		processedCleaner.Unlock()
+		return
+	}
+	processedCleaner.cleanedAt = time.Now()
+	processedCleaner.Unlock()
+
+	cols := dao.SysQueueProcessed.Columns()
+	res, err := dao.SysQueueProcessed.Ctx(ctx).WhereLTE(cols.ExpiredAt, gtime.Now()).Delete()
+	if err != nil {
+		Logger().Warningf(ctx, "queue processed clean err:%+v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		Logger().Debugf(ctx, "queue processed clean %d expired records.", n)
+	}
+}
@@ -0,0 +1,380 @@
+package zqueue
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+// MemoryMq 基于内存的消息队列，消息只保存在当前进程中，重启后丢失
+// 不读写磁盘和外部服务，适用于单元测试和不需要持久化的单进程部署，生产者和消费者共用同一个实例
+type MemoryMq struct {
+	sync.Mutex
+	topics map[string]*memoryTopic
+	delays map[string]*time.Timer // 主题/消息ID -> 延迟投递的定时器
+	deads  map[string][]MqMsg     // 原始主题 -> 死信，按进入死信队列的顺序
+}
+
+// memoryTopic 主题中还没有被所有消费者组提交的消息
+type memoryTopic struct {
+	list   []MqMsg
+	base   int64                   // list[0] 的位置
+	groups map[string]*memoryGroup // 消费者组的读取和提交位置
+	notify chan struct{}           // 写入消息时关闭并替换，唤醒等待的消费者
+}
+
+type memoryGroup struct {
+	read      int64 // 下一条读取的位置
+	committed int64 // 下一条未提交的位置
+}
+
+// memoryReadSize 消费者每次最多读取的消息数量
+const memoryReadSize = 100
+
+// memoryMqs 各组群的内存队列
+var memoryMqs = struct {
+	sync.Mutex
+	m map[string]*MemoryMq
+}{m: make(map[string]*MemoryMq)}
+
+// RegisterMemoryMq 获取组群的内存队列，同一组群的生产者和消费者返回同一个实例
+func RegisterMemoryMq(groupName string) (*MemoryMq, error) {
+	memoryMqs.Lock()
+	defer memoryMqs.Unlock()
+	mq, ok := memoryMqs.m[groupName]
+	if !ok {
+		mq = NewMemoryMq()
+		memoryMqs.m[groupName] = mq
+	}
+	return mq, nil
+}
+
+// NewMemoryMq 创建独立的内存队列
+func NewMemoryMq() *MemoryMq {
+	return &MemoryMq{
+		topics: make(map[string]*memoryTopic),
+		delays: make(map[string]*time.Timer),
+		deads:  make(map[string][]MqMsg),
+	}
+}
+
+// resetMemoryMq 丢弃各组群的内存队列和未投递的延迟消息
+func resetMemoryMq() {
+	memoryMqs.Lock()
+	defer memoryMqs.Unlock()
+	for _, mq := range memoryMqs.m {
+		mq.Lock()
+		for _, timer := range mq.delays {
+			timer.Stop()
+		}
+		mq.Unlock()
+	}
+	memoryMqs.m = make(map[string]*MemoryMq)
+}
+
+// topic 必须持有锁
+func (m *MemoryMq) topic(name string) *memoryTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string]*memoryGroup), notify: make(chan struct{})}
+		m.topics[name] = t
+	}
+	return t
+}
+
+// group 必须持有锁，新的消费者组从保留的第一条消息开始消费
+func (m *MemoryMq) group(topic string, group string) *memoryGroup {
+	t := m.topic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memoryGroup{read: t.base, committed: t.base}
+		t.groups[group] = g
+	}
+	return g
+}
+
+// joinGroup 登记主题的消费者组，避免先启动的消费者组提交后删除其他消费者组还没有读取的消息
+func (m *MemoryMq) joinGroup(topic string, group string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.group(topic, group)
+	return nil
+}
+
+// ListenReceiveMsgDo 消费数据，按读取顺序提交已处理的位置
+// ctx 取消后处理完已读取的消息并返回，再次监听时从未提交的位置开始读取
+func (m *MemoryMq) ListenReceiveMsgDo(ctx context.Context, topic string, options *ConsumeOptions, receiveDo func(list []MqMsg)) (err error) {
+	if topic == "" {
+		return gerror.New("memory.ListenReceiveMsgDo topic is empty")
+	}
+	group := DefaultConsumerGroup
+	if options != nil && options.Group != "" {
+		group = options.Group
+	}
+
+	d := newDispatcher(options, receiveDo)
+	defer d.Close()
+
+	m.Lock()
+	g := m.group(topic, group)
+	g.read = g.committed
+	m.Unlock()
+
+	for ctx.Err() == nil {
+		m.Lock()
+		t := m.topic(topic)
+		start := g.read
+		list := slices.Clone(t.list[start-t.base : min(int64(len(t.list)), start-t.base+memoryReadSize)])
+		g.read += int64(len(list))
+		notify := t.notify
+		m.Unlock()
+
+		if len(list) == 0 {
+			// 没有新消息时处理未凑满的一批
+			d.Flush()
+			select {
+			case <-ctx.Done():
+			case <-notify:
+			}
+			continue
+		}
+
+		for i, mqMsg := range list {
+			offset := start + int64(i) + 1
+			d.Dispatch(cloneMqMsg(mqMsg), func() {
+				m.commit(topic, group, offset)
+			})
+		}
+	}
+	return nil
+}
+
+// commit 提交消费者组的位置，删除所有消费者组都已提交的消息
+func (m *MemoryMq) commit(topic string, group string, offset int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	t := m.topic(topic)
+	g := m.group(topic, group)
+	g.committed = max(g.committed, offset)
+
+	low := g.committed
+	for _, g := range t.groups {
+		low = min(low, g.committed)
+	}
+	if n := low - t.base; n > 0 {
+		t.list = slices.Clone(t.list[n:])
+		t.base = low
+	}
+}
+
+// publish 写入消息并唤醒等待的消费者
+func (m *MemoryMq) publish(mqMsg MqMsg) {
+	m.Lock()
+	defer m.Unlock()
+
+	t := m.topic(mqMsg.Topic)
+	t.list = append(t.list, cloneMqMsg(mqMsg))
+	close(t.notify)
+	t.notify = make(chan struct{})
+}
+
+// cloneMqMsg 复制消息体和消息头，避免生产者和各消费者组修改同一条消息
+func cloneMqMsg(mqMsg MqMsg) MqMsg {
+	mqMsg.Body = slices.Clone(mqMsg.Body)
+	mqMsg.Headers = maps.Clone(mqMsg.Headers)
+	return mqMsg
+}
+
+// inspectTopics 各主题保留的消息数量和消费者组位置
+func (m *MemoryMq) inspectTopics(ctx context.Context) (list []*TopicStats, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for name, t := range m.topics {
+		next := t.base + int64(len(t.list))
+		stats := &TopicStats{Topic: name, Position: strconv.FormatInt(next, 10), Length: int64(len(t.list))}
+		for group, g := range t.groups {
+			stats.Groups = append(stats.Groups, &GroupStats{
+				Group:     group,
+				Committed: strconv.FormatInt(g.committed, 10),
+				Lag:       next - g.committed,
+			})
+		}
+		list = append(list, stats)
+	}
+	return list, nil
+}
+
+// peek 读取消费者组尚未提交的消息
+func (m *MemoryMq) peek(ctx context.Context, topic string, group string, limit int) (list []MqMsg, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	t, ok := m.topics[topic]
+	if !ok {
+		return nil, nil
+	}
+	start := t.base
+	if g, ok := t.groups[group]; ok {
+		start = g.committed
+	}
+	for _, mqMsg := range t.list[start-t.base : min(int64(len(t.list)), start-t.base+int64(limit))] {
+		list = append(list, cloneMqMsg(mqMsg))
+	}
+	return list, nil
+}
+
+// SendMsg 按字符串类型生产数据
+func (m *MemoryMq) SendMsg(topic string, body string) (mqMsg MqMsg, err error) {
+	return m.SendByteMsg(topic, []byte(body))
+}
+
+// SendByteMsg 生产数据
+func (m *MemoryMq) SendByteMsg(topic string, body []byte) (mqMsg MqMsg, err error) {
+	return m.SendMqMsg(MqMsg{Topic: topic, Body: body}, time.Time{})
+}
+
+// SendDelayMsg 生产延迟数据，delaySecond 秒后投递
+func (m *MemoryMq) SendDelayMsg(topic string, body string, delaySecond int64) (mqMsg MqMsg, err error) {
+	return m.SendScheduleMsg(topic, body, time.Now().Add(time.Duration(delaySecond)*time.Second))
+}
+
+// SendScheduleMsg 生产定时数据，到达指定时间后投递，时间已过时立即投递
+func (m *MemoryMq) SendScheduleMsg(topic string, body string, at time.Time) (mqMsg MqMsg, err error) {
+	return m.SendMqMsg(MqMsg{Topic: topic, Body: []byte(body)}, at)
+}
+
+// SendMqMsg 生产完整的消息，保留消息ID和消息头，at 晚于当前时间时延迟投递
+func (m *MemoryMq) SendMqMsg(mqMsg MqMsg, at time.Time) (msg MqMsg, err error) {
+	if mqMsg.Topic == "" {
+		return mqMsg, gerror.New("MemoryMq topic is empty")
+	}
+
+	mqMsg.RunType = SendMsg
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+	if mqMsg.Timestamp.IsZero() {
+		mqMsg.Timestamp = time.Now()
+	}
+
+	// 延迟投递
+	if at.After(time.Now()) {
+		var (
+			key     = mqMsg.Topic + "/" + mqMsg.MsgId
+			delayed = cloneMqMsg(mqMsg)
+		)
+		m.Lock()
+		if timer, ok := m.delays[key]; ok {
+			timer.Stop()
+		}
+		var timer *time.Timer
+		timer = time.AfterFunc(time.Until(at), func() {
+			m.Lock()
+			if m.delays[key] == timer {
+				delete(m.delays, key)
+			}
+			m.Unlock()
+			m.publish(delayed)
+		})
+		m.delays[key] = timer
+		m.Unlock()
+		collector.recordProduced(mqMsg.Topic)
+		return mqMsg, nil
+	}
+
+	m.publish(mqMsg)
+	collector.recordProduced(mqMsg.Topic)
+	return mqMsg, nil
+}
+
+// CancelDelayMsg 取消尚未投递的延迟数据
+func (m *MemoryMq) CancelDelayMsg(topic string, msgId string) (err error) {
+	if topic == "" {
+		return gerror.New("MemoryMq topic is empty")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	key := topic + "/" + msgId
+	timer, ok := m.delays[key]
+	if !ok || !timer.Stop() {
+		return gerror.Newf("延迟消息 %v 不存在或已投递", msgId)
+	}
+	delete(m.delays, key)
+	return nil
+}
+
+// SendDeadLetter 写入死信队列，消息ID相同的死信会被替换
+func (m *MemoryMq) SendDeadLetter(mqMsg MqMsg) (err error) {
+	if mqMsg.Topic == "" {
+		return gerror.New("MemoryMq topic is empty")
+	}
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	list := slices.DeleteFunc(m.deads[mqMsg.Topic], func(item MqMsg) bool {
+		return item.MsgId == mqMsg.MsgId
+	})
+	m.deads[mqMsg.Topic] = append(list, cloneMqMsg(mqMsg))
+	return nil
+}
+
+// DeadLetterTopics 存在死信的原始主题
+func (m *MemoryMq) DeadLetterTopics() (topics []string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for topic, list := range m.deads {
+		if len(list) > 0 {
+			topics = append(topics, topic)
+		}
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// ListDeadLetters 死信列表，按进入死信队列的顺序排序
+func (m *MemoryMq) ListDeadLetters(topic string) (list []MqMsg, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, mqMsg := range m.deads[topic] {
+		list = append(list, cloneMqMsg(mqMsg))
+	}
+	return list, nil
+}
+
+// GetDeadLetter 获取一条死信
+func (m *MemoryMq) GetDeadLetter(topic string, msgId string) (mqMsg MqMsg, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, item := range m.deads[topic] {
+		if item.MsgId == msgId {
+			return cloneMqMsg(item), nil
+		}
+	}
+	return mqMsg, gerror.Newf("死信 %v 不存在", msgId)
+}
+
+// DeleteDeadLetters 删除死信，未指定消息ID时清空该主题的死信
+func (m *MemoryMq) DeleteDeadLetters(topic string, msgIds ...string) (n int, err error) {
+	m.Lock()
+	defer m.Unlock()
+	list := m.deads[topic]
+	if len(msgIds) == 0 {
+		delete(m.deads, topic)
+		return len(list), nil
+	}
+	m.deads[topic] = slices.DeleteFunc(list, func(item MqMsg) bool {
+		return slices.Contains(msgIds, item.MsgId)
+	})
+	return len(list) - len(m.deads[topic]), nil
+}
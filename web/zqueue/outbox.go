@@ -0,0 +1,250 @@
+package zqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/internal/model/do"
+	"github.com/denghuo98/zzframe/internal/model/entity"
+)
+
+// OutboxConfig 事务发件箱配置
+type OutboxConfig struct {
+	Interval    int64 `json:"interval"`    // 没有待发送消息时的轮询间隔（毫秒），默认 1000
+	BatchSize   int   `json:"batchSize"`   // 每次转发的消息数量，默认 100
+	LockTimeout int64 `json:"lockTimeout"` // 转发中的消息超过该时间未完成时由其他实例接管（秒），默认 60
+	Retention   int64 `json:"retention"`   // 已发送消息的保留时间（秒），默认 7 天
+}
+
+const (
+	outboxStatusPending = 0 // 待发送
+	outboxStatusSent    = 1 // 已发送
+
+	outboxMaxBackoff    = time.Minute // 转发失败后的最长等待时间
+	outboxCleanInterval = time.Minute // 清理已发送消息的间隔
+	outboxErrorLength   = 1000        // 失败原因的最大长度
+)
+
+// normalize 填充默认值
+func (c *OutboxConfig) normalize() OutboxConfig {
+	var cfg OutboxConfig
+	if c != nil {
+		cfg = *c
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 1000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 60
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 86400
+	}
+	return cfg
+}
+
+// PushTx 在事务中写入发件箱，事务提交后由 queue 命令启动的转发协程推送到队列
+// 事务回滚时消息不会推送；推送失败会一直重试，重试时消息ID保持不变，消费者可以据此去重
+func PushTx(tx gdb.TX, topic string, data interface{}) (err error) {
+	if topic == "" {
+		return gerror.New("topic is empty")
+	}
+	now := gtime.Now()
+	_, err = tx.Model(dao.SysOutbox.Table()).Ctx(tx.GetCtx()).Data(do.SysOutbox{
+		Topic:     topic,
+		MsgId:     newMsgId(),
+		Body:      gconv.String(data),
+		Status:    outboxStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}).Insert()
+	return
+}
+
+// outboxRelay 发件箱转发协程
+var outboxRelay struct {
+	sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartOutboxRelay 启动发件箱转发，按写入顺序把已提交的消息推送到队列
+// 多个实例同时转发时，每条消息同一时间只由一个实例推送
+func StartOutboxRelay(ctx context.Context) {
+	outboxRelay.Lock()
+	defer outboxRelay.Unlock()
+	if outboxRelay.cancel != nil {
+		return
+	}
+
+	ctx, outboxRelay.cancel = context.WithCancel(ctx)
+	outboxRelay.wg.Add(1)
+	go func() {
+		defer outboxRelay.wg.Done()
+		relayOutboxLoop(ctx, getConfig().Outbox.normalize())
+	}()
+}
+
+// StopOutboxRelay 停止发件箱转发，等待正在推送的消息完成
+func StopOutboxRelay(ctx context.Context) {
+	outboxRelay.Lock()
+	cancel := outboxRelay.cancel
+	outboxRelay.cancel = nil
+	outboxRelay.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	outboxRelay.wg.Wait()
+	Logger().Debug(ctx, "queue outbox relay stopped.")
+}
+
+// relayOutboxLoop 转发到没有待发送的消息后按轮询间隔等待，失败时逐步延长等待时间
+func relayOutboxLoop(ctx context.Context, cfg OutboxConfig) {
+	var (
+		interval  = time.Duration(cfg.Interval) * time.Millisecond
+		backoff   time.Duration
+		cleanedAt time.Time
+	)
+	for {
+		wait := interval
+		n, err := relayOutbox(ctx, cfg)
+		switch {
+		case err != nil:
+			backoff = min(max(backoff*2, interval), outboxMaxBackoff)
+			wait = backoff
+			Logger().Warningf(ctx, "queue outbox relay err:%+v, retry after %v", err, wait)
+		case n >= cfg.BatchSize:
+			backoff, wait = 0, 0
+		default:
+			backoff = 0
+		}
+
+		if time.Since(cleanedAt) >= outboxCleanInterval {
+			if err = cleanOutbox(ctx, cfg); err != nil {
+				Logger().Warningf(ctx, "queue outbox clean err:%+v", err)
+			}
+			cleanedAt = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// relayOutbox 转发一批待发送的消息，返回读取到的消息数量
+// 推送失败时停止本批转发，避免后写入的消息先于失败的消息推送
+func relayOutbox(ctx context.Context, cfg OutboxConfig) (n int, err error) {
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+
+	var (
+		cols = dao.SysOutbox.Columns()
+		list []*entity.SysOutbox
+	)
+	if err = dao.SysOutbox.Ctx(ctx).
+		Where(cols.Status, outboxStatusPending).
+		Where(outboxUnlocked(ctx, cfg)).
+		OrderAsc(cols.Id).
+		Limit(cfg.BatchSize).
+		Scan(&list); err != nil {
+		return
+	}
+
+	for _, row := range list {
+		if ctx.Err() != nil {
+			return
+		}
+		n++
+
+		ok, err := claimOutbox(ctx, cfg, row.Id)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			// 已被其他实例转发
+			continue
+		}
+
+		mqMsg := MqMsg{Topic: row.Topic, MsgId: row.MsgId, Body: []byte(row.Body)}
+		if row.CreatedAt != nil {
+			mqMsg.Timestamp = row.CreatedAt.Time
+		}
+		mqMsg, err = q.SendMqMsg(mqMsg, time.Time{})
+		ProducerLog(ctx, row.Topic, mqMsg, err)
+		if err != nil {
+			if _, err1 := dao.SysOutbox.Ctx(ctx).Where(cols.Id, row.Id).Data(g.Map{
+				cols.Attempts:  &gdb.Counter{Field: cols.Attempts, Value: 1},
+				cols.LastError: gstr.SubStrRune(err.Error(), 0, outboxErrorLength),
+				cols.LockedAt:  nil,
+				cols.UpdatedAt: gtime.Now(),
+			}).Update(); err1 != nil {
+				Logger().Warningf(ctx, "queue outbox release err:%+v, id:%v", err1, row.Id)
+			}
+			return n, err
+		}
+
+		now := gtime.Now()
+		if _, err = dao.SysOutbox.Ctx(ctx).Where(cols.Id, row.Id).Data(g.Map{
+			cols.Status:    outboxStatusSent,
+			cols.SentAt:    now,
+			cols.LockedAt:  nil,
+			cols.UpdatedAt: now,
+		}).Update(); err != nil {
+			// 已经推送成功，超过锁定时间后会再次推送，消息ID不变
+			return n, err
+		}
+	}
+	return
+}
+
+// claimOutbox 锁定一条待发送的消息，返回 false 表示已被其他实例锁定或已发送
+func claimOutbox(ctx context.Context, cfg OutboxConfig, id int64) (ok bool, err error) {
+	cols := dao.SysOutbox.Columns()
+	res, err := dao.SysOutbox.Ctx(ctx).
+		Where(cols.Id, id).
+		Where(cols.Status, outboxStatusPending).
+		Where(outboxUnlocked(ctx, cfg)).
+		Data(do.SysOutbox{LockedAt: gtime.Now()}).
+		Update()
+	if err != nil {
+		return
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// outboxUnlocked 未锁定或锁定已超时的条件
+func outboxUnlocked(ctx context.Context, cfg OutboxConfig) *gdb.WhereBuilder {
+	cols := dao.SysOutbox.Columns()
+	return dao.SysOutbox.Ctx(ctx).Builder().
+		WhereNull(cols.LockedAt).
+		WhereOrLT(cols.LockedAt, gtime.Now().Add(-time.Duration(cfg.LockTimeout)*time.Second))
+}
+
+// cleanOutbox 删除超过保留时间的已发送消息
+func cleanOutbox(ctx context.Context, cfg OutboxConfig) (err error) {
+	cols := dao.SysOutbox.Columns()
+	_, err = dao.SysOutbox.Ctx(ctx).
+		Where(cols.Status, outboxStatusSent).
+		WhereLT(cols.SentAt, gtime.Now().Add(-time.Duration(cfg.Retention)*time.Second)).
+		Delete()
+	return
+}
@@ -1,6 +1,8 @@
 package zqueue
 
 import (
+	"time"
+
 	"github.com/gogf/gf/v2/util/gconv"
 )
 
@@ -20,6 +22,7 @@ func Push(topic string, data interface{}) (err error) {
 // rocketmq delay 传入 延迟级别。如：2代表延迟5秒
 // rocketmq reference delay level definition: 1s 5s 10s 30s 1m 2m 3m 4m 5m 6m 7m 8m 9m 10m 20m 30m 1h 2h
 // rocketmq delay level starts from 1. for example, if we set param level=1, then the delay time is 1s.
+// disk delay 传入 秒，消息持久化在主题目录下，重启后不会丢失
 func DelayPush(topic string, data interface{}, delay int64) (err error) {
 	q, err := InstanceProducer()
 	if err != nil {
@@ -29,3 +32,24 @@ func DelayPush(topic string, data interface{}, delay int64) (err error) {
 	ProducerLog(ctx, topic, mqMsg, err)
 	return
 }
+
+// SchedulePush 推送定时消息，在指定时间投递给消费者
+// 返回的 MsgId 可用于 CancelDelayPush 取消尚未投递的消息
+func SchedulePush(topic string, data interface{}, at time.Time) (mqMsg MqMsg, err error) {
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+	mqMsg, err = q.SendScheduleMsg(topic, gconv.String(data), at)
+	ProducerLog(ctx, topic, mqMsg, err)
+	return
+}
+
+// CancelDelayPush 取消尚未投递的延迟或定时消息
+func CancelDelayPush(topic string, msgId string) (err error) {
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+	return q.CancelDelayMsg(topic, msgId)
+}
@@ -1,14 +1,18 @@
 package zqueue
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/google/uuid"
 
 	"github.com/denghuo98/zzframe/web/zqueue/disk"
+	"github.com/denghuo98/zzframe/web/zqueue/redis"
 )
 
 // MqProducer 消息生产者
@@ -16,22 +20,28 @@ type MqProducer interface {
 	SendMsg(topic string, body string) (mqMsg MqMsg, err error)
 	SendByteMsg(topic string, body []byte) (mqMsg MqMsg, err error)
 	SendDelayMsg(topic string, body string, delay int64) (mqMsg MqMsg, err error)
+	SendScheduleMsg(topic string, body string, at time.Time) (mqMsg MqMsg, err error)
+	CancelDelayMsg(topic string, msgId string) (err error)
+	SendMqMsg(mqMsg MqMsg, at time.Time) (msg MqMsg, err error)
 }
 
 // MqConsumer 消息消费者
 type MqConsumer interface {
-	ListenReceiveMsgDo(topic string, receiveDo func(mqMsg MqMsg)) (err error)
+	// ListenReceiveMsgDo 持续消费数据，按 options 分批并发调用 receiveDo，处理完成后按读取顺序提交
+	// ctx 取消后不再拉取新消息，处理完已读取的消息并提交后返回
+	ListenReceiveMsgDo(ctx context.Context, topic string, options *ConsumeOptions, receiveDo func(list []MqMsg)) (err error)
 }
 
 // MqMsg 消息体
 type MqMsg struct {
-	RunType   int       `json:"run_type"`
-	Topic     string    `json:"topic"`
-	MsgId     string    `json:"msg_id"`
-	Offset    int64     `json:"offset"`
-	Partition int32     `json:"partition"`
-	Timestamp time.Time `json:"timestamp"`
-	Body      []byte    `json:"body"`
+	RunType   int               `json:"run_type"`
+	Topic     string            `json:"topic"`
+	MsgId     string            `json:"msg_id"`
+	Offset    int64             `json:"offset"`
+	Partition int32             `json:"partition"`
+	Timestamp time.Time         `json:"timestamp"`
+	Body      []byte            `json:"body"`
+	Headers   map[string]string `json:"headers,omitempty"`
 }
 
 const (
@@ -42,58 +52,103 @@ const (
 
 // Config 配置
 type Config struct {
-	Switch    bool   `json:"switch"`
-	Driver    string `json:"driver"`
-	GroupName string `json:"groupName"`
-	Disk      *disk.Config
+	Switch          bool   `json:"switch"`
+	Driver          string `json:"driver"` // 队列驱动：disk/redis/memory/database
+	GroupName       string `json:"groupName"`
+	ShutdownTimeout int64  `json:"shutdownTimeout"` // 关闭时等待消息处理完成的最长时间（秒）
+	InstanceId      string `json:"instanceId"`      // 实例ID，区分各实例的响应主题和广播消费者组，默认见 InstanceId
+	CallTimeout     int64  `json:"callTimeout"`     // Call 等待响应的默认超时时间（秒），默认 30
+	Disk            *disk.Config
+	Redis           *redis.Config
+	Database        *DatabaseConfig
+	Outbox          *OutboxConfig
 }
 
 var (
 	ctx                   = gctx.GetInitCtx()
-	mqProducerInstanceMap map[string]MqProducer
-	mqConsumerInstanceMap map[string]MqConsumer
+	mqProducerInstanceMap = make(map[string]MqProducer)
+	mqConsumerInstanceMap = make(map[string]MqConsumer)
 	mutex                 sync.Mutex
 	config                Config
+	configOnce            sync.Once
 )
 
-func init() {
-	mqProducerInstanceMap = make(map[string]MqProducer)
-	mqConsumerInstanceMap = make(map[string]MqConsumer)
-	configContent := g.Cfg().MustGet(ctx, "queue")
-	if configContent != nil {
-		if err := configContent.Struct(&config); err != nil {
+// defaultConfig 没有队列配置时使用的默认配置
+func defaultConfig() Config {
+	return Config{
+		Switch:          true,
+		Driver:          "disk",
+		GroupName:       "default",
+		ShutdownTimeout: 30,
+		Disk: &disk.Config{
+			Path:         "./tmp/diskqueue",
+			BatchSize:    100,
+			BatchTime:    1,
+			SegmentSize:  10485760,
+			SegmentLimit: 3000,
+		},
+	}
+}
+
+// getConfig 首次使用队列时读取 queue 配置，导入包时不会读取配置或创建队列目录
+func getConfig() *Config {
+	configOnce.Do(func() {
+		configContent, err := g.Cfg().Get(ctx, "queue")
+		if err != nil || configContent == nil || configContent.IsNil() {
+			g.Log().Warningf(ctx, "消息队列配置为空，使用默认配置")
+			config = defaultConfig()
+			return
+		}
+		if err = configContent.Struct(&config); err != nil {
 			Logger().Warningf(ctx, "queue init err:%+v", err)
 		}
-	} else {
-		g.Log().Warningf(ctx, "消息队列配置为空，使用默认配置")
-		config = Config{
-			Switch:    true,
-			Driver:    "disk",
-			GroupName: "default",
-			Disk: &disk.Config{
-				Path:         "./tmp/diskqueue",
-				BatchSize:    100,
-				BatchTime:    1,
-				SegmentSize:  10485760,
-				SegmentLimit: 3000,
-			},
+	})
+	return &config
+}
+
+// SetConfig 设置队列配置，替代配置文件中的 queue 配置，已创建的实例会在下次使用时按新配置重新创建
+// 需要在启动消费者之前调用，如单元测试中使用 memory 驱动避免读写磁盘
+func SetConfig(c Config) {
+	configOnce.Do(func() {})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = c
+	closeProducers()
+	mqProducerInstanceMap = make(map[string]MqProducer)
+	mqConsumerInstanceMap = make(map[string]MqConsumer)
+	resetMemoryMq()
+	resetReplies()
+	resetSubscriptions()
+}
+
+// closeProducers 停止已创建的生产者的后台任务，如延迟消息投递，需要持有 mutex
+func closeProducers() {
+	for _, producer := range mqProducerInstanceMap {
+		if c, ok := producer.(interface{ close() }); ok {
+			c.close()
 		}
 	}
 }
 
 // InstanceConsumer 实例化消费者
 func InstanceConsumer() (mqClient MqConsumer, err error) {
-	return NewConsumer(config.GroupName)
+	return NewConsumer(getConfig().GroupName)
 }
 
 // InstanceProducer 实例化生产者
 func InstanceProducer() (mqClient MqProducer, err error) {
-	return NewProducer(config.GroupName)
+	return NewProducer(getConfig().GroupName)
 }
 
 // NewProducer 初始化生产者实例
 func NewProducer(groupName string) (mqClient MqProducer, err error) {
-	if item, ok := mqProducerInstanceMap[groupName]; ok {
+	config := getConfig()
+
+	mutex.Lock()
+	item, ok := mqProducerInstanceMap[groupName]
+	mutex.Unlock()
+	if ok {
 		return item, nil
 	}
 
@@ -107,6 +162,16 @@ func NewProducer(groupName string) (mqClient MqProducer, err error) {
 	case "disk":
 		config.Disk.GroupName = groupName
 		mqClient, err = RegisterDiskMqProducer(config.Disk)
+	case "redis":
+		if config.Redis == nil {
+			config.Redis = &redis.Config{}
+		}
+		config.Redis.GroupName = groupName
+		mqClient, err = RegisterRedisMqProducer(config.Redis)
+	case "memory":
+		mqClient, err = RegisterMemoryMq(groupName)
+	case "database":
+		mqClient, err = RegisterDatabaseMqProducer(groupName, config.Database)
 	default:
 		err = gerror.New("queue driver is not support")
 	}
@@ -123,6 +188,7 @@ func NewProducer(groupName string) (mqClient MqProducer, err error) {
 
 // NewConsumer 初始化消费者实例
 func NewConsumer(groupName string) (mqClient MqConsumer, err error) {
+	config := getConfig()
 	if groupName == "" {
 		err = gerror.New("mq groupName is empty.")
 		return
@@ -132,6 +198,16 @@ func NewConsumer(groupName string) (mqClient MqConsumer, err error) {
 	case "disk":
 		config.Disk.GroupName = groupName
 		mqClient, err = RegisterDiskMqConsumer(config.Disk)
+	case "redis":
+		if config.Redis == nil {
+			config.Redis = &redis.Config{}
+		}
+		config.Redis.GroupName = groupName
+		mqClient, err = RegisterRedisMqConsumer(config.Redis)
+	case "memory":
+		mqClient, err = RegisterMemoryMq(groupName)
+	case "database":
+		mqClient, err = RegisterDatabaseMqConsumer(groupName, config.Database)
 	default:
 		err = gerror.New("queue driver is not support")
 	}
@@ -146,7 +222,34 @@ func NewConsumer(groupName string) (mqClient MqConsumer, err error) {
 	return
 }
 
+// newMsgId 生成消息ID，使用 UUIDv7，按生成时间排序且不会重复，可以作为消费去重的依据
+func newMsgId() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
 // BodyString 返回消息体
 func (m *MqMsg) BodyString() string {
 	return string(m.Body)
 }
+
+// Header 获取消息头
+func (m *MqMsg) Header(key string) string {
+	return m.Headers[key]
+}
+
+// SetHeader 设置消息头
+func (m *MqMsg) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// Attempts 已处理失败的次数
+func (m *MqMsg) Attempts() int {
+	return gconv.Int(m.Header(HeaderAttempts))
+}
@@ -0,0 +1,411 @@
+package zqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/denghuo98/zzframe/web/zqueue/redis"
+)
+
+// RedisMq 基于 Redis Streams 的消息队列，同一个组群的多个实例通过消费者组共同消费
+type RedisMq struct {
+	stream *redis.Stream
+	cancel context.CancelFunc // 停止延迟消息投递
+	done   chan struct{}      // 延迟消息投递已返回
+}
+
+// redisDelays 各组群正在投递延迟消息的生产者，当前进程中每个组群最多一个
+var redisDelays = struct {
+	sync.Mutex
+	list map[string]*RedisMq
+}{list: make(map[string]*RedisMq)}
+
+func RegisterRedisMqProducer(config *redis.Config) (client MqProducer, err error) {
+	mq, err := newRedisMq(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 投递到期的延迟消息，多个实例同时投递时由 lua 脚本保证不会重复
+	mq.startDelay()
+	return mq, nil
+}
+
+func RegisterRedisMqConsumer(config *redis.Config) (client MqConsumer, err error) {
+	return newRedisMq(config)
+}
+
+// NewRedisMq 使用已有的 redis 客户端创建队列，不会启动延迟消息投递
+func NewRedisMq(client goredis.UniversalClient, config *redis.Config) *RedisMq {
+	return &RedisMq{stream: redis.New(client, config)}
+}
+
+func newRedisMq(config *redis.Config) (*RedisMq, error) {
+	if config == nil {
+		return nil, gerror.New("queue redis 配置为空")
+	}
+
+	r := g.Redis(config.Name)
+	if r == nil || r.GetAdapter() == nil {
+		return nil, gerror.Newf("queue redis 获取 redis 配置 %v 失败", config.Name)
+	}
+	client, ok := r.GetAdapter().Client().(goredis.UniversalClient)
+	if !ok {
+		return nil, gerror.New("queue redis 需要使用 github.com/gogf/gf/contrib/nosql/redis/v2 驱动")
+	}
+
+	conf := *config
+	conf.BlockTime = config.BlockTime * time.Second
+	conf.ClaimIdle = config.ClaimIdle * time.Second
+	return NewRedisMq(client, &conf), nil
+}
+
+// ListenReceiveMsgDo 消费数据，处理完成后确认消息，消费者异常退出时未确认的消息由其他消费者接管
+// ctx 取消后处理完已读取的消息并返回，最多等待一次读取的阻塞时间
+func (r *RedisMq) ListenReceiveMsgDo(ctx context.Context, topic string, options *ConsumeOptions, receiveDo func(list []MqMsg)) (err error) {
+	if topic == "" {
+		return gerror.New("redis.ListenReceiveMsgDo topic is empty")
+	}
+
+	// 确保生产者已初始化，投递该组群的延迟消息
+	if _, err = NewProducer(r.stream.Config().GroupName); err != nil {
+		return
+	}
+
+	d := newDispatcher(options, receiveDo)
+	defer d.Close()
+
+	// 每个消费者组使用各自的 redis 消费者组
+	stream := r.stream
+	if options != nil {
+		stream = r.stream.WithGroup(consumerGroupKey(r.stream.Config().GroupName, options.Group))
+	}
+
+	var (
+		start   string
+		claimAt time.Time
+		idle    = stream.Config().ClaimIdle
+		// 读取时不使用 ctx，避免取消时已分配给当前消费者的消息没有返回
+		readCtx = context.WithoutCancel(ctx)
+	)
+	for ctx.Err() == nil {
+		// 定期接管超时未确认的消息
+		if time.Since(claimAt) >= idle {
+			messages, next, err := stream.Claim(readCtx, topic, start)
+			if err != nil {
+				Logger().Warningf(ctx, "redis.ListenReceiveMsgDo claim err:%+v, topic：%v .", err, topic)
+				claimAt = time.Now()
+			} else {
+				r.dispatch(stream, topic, messages, d)
+				if start = next; start == "0-0" {
+					claimAt = time.Now()
+				}
+			}
+		}
+
+		messages, err := stream.Read(readCtx, topic)
+		if err != nil {
+			Logger().Warningf(ctx, "redis.ListenReceiveMsgDo read err:%+v, topic：%v .", err, topic)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		r.dispatch(stream, topic, messages, d)
+	}
+	return nil
+}
+
+// dispatch 分发读取到的消息，处理完成后确认，无法解析的消息直接确认
+func (r *RedisMq) dispatch(stream *redis.Stream, topic string, messages []redis.Message, d *dispatcher) {
+	ack := func(id string) {
+		if err := stream.Ack(ctx, topic, id); err != nil {
+			Logger().Warningf(ctx, "redis.ListenReceiveMsgDo ack err:%+v, topic：%v, id:%v .", err, topic, id)
+		}
+	}
+
+	for _, message := range messages {
+		var mqMsg MqMsg
+		if err := json.Unmarshal(message.Data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "redis.ListenReceiveMsgDo Unmarshal err:%+v, topic：%v, data:%+v .", err, topic, string(message.Data))
+			ack(message.Id)
+			continue
+		}
+		if mqMsg.MsgId == "" {
+			ack(message.Id)
+			continue
+		}
+		id := message.Id
+		d.Dispatch(mqMsg, func() {
+			ack(id)
+		})
+	}
+	d.Flush()
+}
+
+// inspectTopics 读取各主题的长度和消费者组位置，只包含当前组群的消费者组
+func (r *RedisMq) inspectTopics(ctx context.Context) (list []*TopicStats, err error) {
+	topics, err := r.stream.Topics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range topics {
+		info, err := r.stream.Info(ctx, topic)
+		if err != nil {
+			return nil, gerror.Wrapf(err, "queue redis 读取主题 %v 失败", topic)
+		}
+		t := &TopicStats{Topic: topic, Position: info.LastId, Length: info.Length}
+		for _, g := range info.Groups {
+			group, ok := r.consumerGroup(g.Name)
+			if !ok {
+				continue
+			}
+			t.Groups = append(t.Groups, &GroupStats{
+				Group:     group,
+				Committed: g.LastId,
+				Lag:       g.Lag,
+				Pending:   g.Pending,
+			})
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// peek 读取消费者组尚未读取的消息，不包含已读取未确认的消息
+func (r *RedisMq) peek(ctx context.Context, topic string, group string, limit int) (list []MqMsg, err error) {
+	info, err := r.stream.Info(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	var lastId string
+	for _, g := range info.Groups {
+		if name, ok := r.consumerGroup(g.Name); ok && name == group {
+			lastId = g.LastId
+		}
+	}
+
+	messages, err := r.stream.Range(ctx, topic, lastId, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		var mqMsg MqMsg
+		if err = json.Unmarshal(message.Data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "redis.peek Unmarshal err:%+v, topic：%v, id:%v .", err, topic, message.Id)
+			continue
+		}
+		list = append(list, mqMsg)
+	}
+	return list, nil
+}
+
+// consumerGroup redis 消费者组对应的消费者组名称，其他组群的消费者组返回 false
+func (r *RedisMq) consumerGroup(name string) (string, bool) {
+	return parseConsumerGroup(r.stream.Config().GroupName, name)
+}
+
+// SendMsg 按字符串类型生产数据
+func (r *RedisMq) SendMsg(topic string, body string) (mqMsg MqMsg, err error) {
+	return r.SendByteMsg(topic, []byte(body))
+}
+
+// SendByteMsg 生产数据
+func (r *RedisMq) SendByteMsg(topic string, body []byte) (mqMsg MqMsg, err error) {
+	if topic == "" {
+		return mqMsg, gerror.New("RedisMq topic is empty")
+	}
+
+	return r.SendMqMsg(MqMsg{Topic: topic, Body: body}, time.Time{})
+}
+
+// SendDelayMsg 生产延迟数据，delaySecond 秒后投递
+func (r *RedisMq) SendDelayMsg(topic string, body string, delaySecond int64) (mqMsg MqMsg, err error) {
+	return r.SendScheduleMsg(topic, body, time.Now().Add(time.Duration(delaySecond)*time.Second))
+}
+
+// SendScheduleMsg 生产定时数据，到达指定时间后投递，时间已过时立即投递
+func (r *RedisMq) SendScheduleMsg(topic string, body string, at time.Time) (mqMsg MqMsg, err error) {
+	return r.SendMqMsg(MqMsg{Topic: topic, Body: []byte(body)}, at)
+}
+
+// SendMqMsg 生产完整的消息，保留消息ID和消息头，at 晚于当前时间时延迟投递
+func (r *RedisMq) SendMqMsg(mqMsg MqMsg, at time.Time) (msg MqMsg, err error) {
+	if mqMsg.Topic == "" {
+		return mqMsg, gerror.New("RedisMq topic is empty")
+	}
+
+	mqMsg.RunType = SendMsg
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+	if mqMsg.Timestamp.IsZero() {
+		mqMsg.Timestamp = time.Now()
+	}
+
+	mqMsgJson, err := json.Marshal(mqMsg)
+	if err != nil {
+		return mqMsg, gerror.New(fmt.Sprint("queue redis 生产者解析json消息失败:", err))
+	}
+
+	// 延迟投递
+	if at.After(time.Now()) {
+		if err = r.stream.Schedule(ctx, mqMsg.Topic, mqMsg.MsgId, at, mqMsgJson); err != nil {
+			return mqMsg, gerror.New(fmt.Sprint("queue redis 生产者添加延迟消息失败:", err))
+		}
+		collector.recordProduced(mqMsg.Topic)
+		return mqMsg, nil
+	}
+
+	if _, err = r.stream.Add(ctx, mqMsg.Topic, mqMsgJson); err != nil {
+		return mqMsg, gerror.New(fmt.Sprint("queue redis 生产者添加消息失败:", err))
+	}
+	collector.recordProduced(mqMsg.Topic)
+	return mqMsg, nil
+}
+
+// CancelDelayMsg 取消尚未投递的延迟数据
+func (r *RedisMq) CancelDelayMsg(topic string, msgId string) (err error) {
+	if topic == "" {
+		return gerror.New("RedisMq topic is empty")
+	}
+
+	ok, err := r.stream.Cancel(ctx, topic, msgId)
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue redis 取消延迟消息失败:", err))
+	}
+	if !ok {
+		return gerror.Newf("延迟消息 %v 不存在或已投递", msgId)
+	}
+	return nil
+}
+
+// startDelay 开始投递组群的延迟消息，同一组群之前的投递会先停止
+func (r *RedisMq) startDelay() {
+	redisDelays.Lock()
+	defer redisDelays.Unlock()
+
+	if prev, ok := redisDelays.list[r.stream.Config().GroupName]; ok {
+		prev.stopDelay()
+	}
+
+	var deliverCtx context.Context
+	deliverCtx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	redisDelays.list[r.stream.Config().GroupName] = r
+	go r.deliverDelay(deliverCtx)
+}
+
+// stopDelay 停止投递并等待返回，需要持有 redisDelays 的锁
+func (r *RedisMq) stopDelay() {
+	r.cancel()
+	<-r.done
+	if redisDelays.list[r.stream.Config().GroupName] == r {
+		delete(redisDelays.list, r.stream.Config().GroupName)
+	}
+}
+
+// close 停止延迟消息投递
+func (r *RedisMq) close() {
+	redisDelays.Lock()
+	defer redisDelays.Unlock()
+
+	if r.cancel != nil {
+		r.stopDelay()
+	}
+}
+
+// deliverDelay 将到期的延迟消息写入队列，ctx 取消后返回
+func (r *RedisMq) deliverDelay(ctx context.Context) {
+	defer close(r.done)
+	for ctx.Err() == nil {
+		delivered := 0
+		topics, err := r.stream.DelayTopics(ctx)
+		if err != nil && ctx.Err() == nil {
+			Logger().Warningf(ctx, "redis.deliverDelay topics err:%+v .", err)
+		}
+		for _, topic := range topics {
+			n, err := r.stream.MoveDue(ctx, topic, time.Now(), delayBatchSize)
+			if err != nil {
+				Logger().Warningf(ctx, "redis.deliverDelay move err:%+v, topic：%v .", err, topic)
+				continue
+			}
+			delivered = max(delivered, n)
+		}
+
+		// 还有到期的消息时继续投递
+		if delivered < delayBatchSize {
+			select {
+			case <-ctx.Done():
+			case <-time.After(delayInterval):
+			}
+		}
+	}
+}
+
+// SendDeadLetter 写入死信队列
+func (r *RedisMq) SendDeadLetter(mqMsg MqMsg) (err error) {
+	if mqMsg.Topic == "" {
+		return gerror.New("RedisMq topic is empty")
+	}
+	if mqMsg.MsgId == "" {
+		mqMsg.MsgId = newMsgId()
+	}
+
+	data, err := json.Marshal(mqMsg)
+	if err != nil {
+		return gerror.New(fmt.Sprint("queue redis 死信解析json消息失败:", err))
+	}
+	if err = r.stream.PutDead(ctx, mqMsg.Topic, mqMsg.MsgId, data); err != nil {
+		return gerror.New(fmt.Sprint("queue redis 写入死信失败:", err))
+	}
+	return nil
+}
+
+// DeadLetterTopics 存在死信的原始主题
+func (r *RedisMq) DeadLetterTopics() (topics []string, err error) {
+	return r.stream.DeadTopics(ctx)
+}
+
+// ListDeadLetters 死信列表，按进入死信队列的时间排序
+func (r *RedisMq) ListDeadLetters(topic string) (list []MqMsg, err error) {
+	messages, err := r.stream.ListDead(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		var mqMsg MqMsg
+		if err = json.Unmarshal(message.Data, &mqMsg); err != nil {
+			Logger().Warningf(ctx, "redis.ListDeadLetters Unmarshal err:%+v, topic：%v, msgId:%v .", err, topic, message.Id)
+			continue
+		}
+		list = append(list, mqMsg)
+	}
+	return list, nil
+}
+
+// GetDeadLetter 获取一条死信
+func (r *RedisMq) GetDeadLetter(topic string, msgId string) (mqMsg MqMsg, err error) {
+	data, err := r.stream.GetDead(ctx, topic, msgId)
+	if err != nil {
+		return
+	}
+	if data == nil {
+		return mqMsg, gerror.Newf("死信 %v 不存在", msgId)
+	}
+	err = json.Unmarshal(data, &mqMsg)
+	return
+}
+
+// DeleteDeadLetters 删除死信，未指定消息ID时清空该主题的死信
+func (r *RedisMq) DeleteDeadLetters(topic string, msgIds ...string) (n int, err error) {
+	return r.stream.DeleteDead(ctx, topic, msgIds...)
+}
@@ -0,0 +1,423 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	dataField     = "data"          // field name of the message payload in a stream entry
+	streamTopics  = "topics"        // set of topics written or consumed
+	delayTopics   = "delay:topics"  // set of topics with scheduled messages
+	deadTopics    = "dlq:topics"    // set of topics with dead letters
+	defaultPrefix = "zqueue:"       // default key prefix
+	deadSuffix    = ".dlq"          // dead letter key suffix
+	startId       = "0-0"           // start id of XAUTOCLAIM
+	defaultBlock  = 2 * time.Second // default block time of XREADGROUP
+	defaultClaim  = time.Minute     // default idle time before a pending message is reclaimed
+)
+
+type Config struct {
+	GroupName string        // consumer group
+	Name      string        // redis configuration name, default is "default"
+	Consumer  string        // consumer name, default is hostname-pid
+	KeyPrefix string        // key prefix, default is "zqueue:"
+	MaxLen    int64         // approximate max length of each stream, 0 means no trimming
+	BatchSize int64         // max number of messages read at once
+	BlockTime time.Duration // block time of XREADGROUP
+	ClaimIdle time.Duration // pending messages idle longer than this are reclaimed from crashed consumers
+}
+
+// Message a stream entry or a keyed message
+type Message struct {
+	Id   string
+	Data []byte
+}
+
+// StreamInfo length, last id and consumer groups of a topic
+type StreamInfo struct {
+	Length int64
+	LastId string // last generated id, the writer position
+	Groups []GroupInfo
+}
+
+// GroupInfo position of a consumer group
+type GroupInfo struct {
+	Name    string
+	LastId  string // last delivered id
+	Pending int64  // delivered but not acknowledged
+	Lag     int64  // not delivered yet, -1 if redis can not determine it
+}
+
+// Stream queue operations on redis streams
+// keys of a topic share the same hash tag, so scripts also work on redis cluster
+type Stream struct {
+	client goredis.UniversalClient
+	config Config
+	groups sync.Map
+	topics sync.Map // topics added to the topics set
+}
+
+func New(client goredis.UniversalClient, config *Config) *Stream {
+	conf := *config
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = defaultPrefix
+	}
+	if conf.Consumer == "" {
+		host, _ := os.Hostname()
+		conf.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 100
+	}
+	if conf.BlockTime <= 0 {
+		conf.BlockTime = defaultBlock
+	}
+	if conf.ClaimIdle <= 0 {
+		conf.ClaimIdle = defaultClaim
+	}
+	return &Stream{client: client, config: conf}
+}
+
+// WithGroup returns a stream which reads as another consumer group
+func (s *Stream) WithGroup(group string) *Stream {
+	conf := s.config
+	conf.GroupName = group
+	return &Stream{client: s.client, config: conf}
+}
+
+// Config returns the effective configuration
+func (s *Stream) Config() Config {
+	return s.config
+}
+
+// Add append a message to the stream
+func (s *Stream) Add(ctx context.Context, topic string, data []byte) (string, error) {
+	args := &goredis.XAddArgs{
+		Stream: s.streamKey(topic),
+		Values: []any{dataField, data},
+	}
+	if s.config.MaxLen > 0 {
+		args.MaxLen, args.Approx = s.config.MaxLen, true
+	}
+	if err := s.addTopic(ctx, topic); err != nil {
+		return "", err
+	}
+	return s.client.XAdd(ctx, args).Result()
+}
+
+// Read new messages of the consumer group, blocks at most BlockTime
+func (s *Stream) Read(ctx context.Context, topic string) ([]Message, error) {
+	if err := s.ensureGroup(ctx, topic); err != nil {
+		return nil, err
+	}
+
+	streams, err := s.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    s.config.GroupName,
+		Consumer: s.config.Consumer,
+		Streams:  []string{s.streamKey(topic), ">"},
+		Count:    s.config.BatchSize,
+		Block:    s.config.BlockTime,
+	}).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, stream := range streams {
+		messages = append(messages, s.messages(stream.Messages)...)
+	}
+	return messages, nil
+}
+
+// Claim take over messages which are pending longer than ClaimIdle, returns the next start id
+func (s *Stream) Claim(ctx context.Context, topic string, start string) ([]Message, string, error) {
+	if err := s.ensureGroup(ctx, topic); err != nil {
+		return nil, start, err
+	}
+	if start == "" {
+		start = startId
+	}
+
+	list, next, err := s.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+		Stream:   s.streamKey(topic),
+		Group:    s.config.GroupName,
+		Consumer: s.config.Consumer,
+		MinIdle:  s.config.ClaimIdle,
+		Start:    start,
+		Count:    s.config.BatchSize,
+	}).Result()
+	if err != nil {
+		return nil, start, err
+	}
+	return s.messages(list), next, nil
+}
+
+// Ack acknowledge messages
+func (s *Stream) Ack(ctx context.Context, topic string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.XAck(ctx, s.streamKey(topic), s.config.GroupName, ids...).Err()
+}
+
+// Schedule a message to be appended to the stream at due time
+func (s *Stream) Schedule(ctx context.Context, topic string, id string, due time.Time, data []byte) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, s.delayDataKey(topic), id, data)
+		pipe.ZAdd(ctx, s.delayKey(topic), goredis.Z{Score: float64(due.UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.client.SAdd(ctx, s.config.KeyPrefix+delayTopics, topic).Err()
+}
+
+var cancelScript = goredis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// Cancel a scheduled message, returns false if it does not exist or has been delivered
+func (s *Stream) Cancel(ctx context.Context, topic string, id string) (bool, error) {
+	n, err := cancelScript.Run(ctx, s.client, []string{s.delayKey(topic), s.delayDataKey(topic)}, id).Int()
+	return n == 1, err
+}
+
+var moveScript = goredis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	local data = redis.call('HGET', KEYS[2], id)
+	if data then
+		if tonumber(ARGV[3]) > 0 then
+			redis.call('XADD', KEYS[3], 'MAXLEN', '~', ARGV[3], '*', 'data', data)
+		else
+			redis.call('XADD', KEYS[3], '*', 'data', data)
+		end
+	end
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+end
+return #ids
+`)
+
+// MoveDue atomically append due messages to the stream, safe to run on several workers
+func (s *Stream) MoveDue(ctx context.Context, topic string, now time.Time, limit int) (int, error) {
+	keys := []string{s.delayKey(topic), s.delayDataKey(topic), s.streamKey(topic)}
+	return moveScript.Run(ctx, s.client, keys, now.UnixMilli(), limit, s.config.MaxLen).Int()
+}
+
+// DelayTopics topics which have scheduled messages
+func (s *Stream) DelayTopics(ctx context.Context) ([]string, error) {
+	return s.client.SMembers(ctx, s.config.KeyPrefix+delayTopics).Result()
+}
+
+// Topics topics which have been written or consumed
+func (s *Stream) Topics(ctx context.Context) ([]string, error) {
+	return s.client.SMembers(ctx, s.config.KeyPrefix+streamTopics).Result()
+}
+
+// Info length and consumer groups of a topic, an empty info if the stream does not exist
+func (s *Stream) Info(ctx context.Context, topic string) (*StreamInfo, error) {
+	key := s.streamKey(topic)
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil || n == 0 {
+		return &StreamInfo{}, err
+	}
+
+	stream, err := s.client.XInfoStream(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.client.XInfoGroups(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StreamInfo{Length: stream.Length, LastId: stream.LastGeneratedID}
+	for _, group := range groups {
+		info.Groups = append(info.Groups, GroupInfo{
+			Name:    group.Name,
+			LastId:  group.LastDeliveredID,
+			Pending: group.Pending,
+			Lag:     group.Lag,
+		})
+	}
+	return info, nil
+}
+
+// Range at most limit messages after id, from the beginning of the stream if id is empty
+func (s *Stream) Range(ctx context.Context, topic string, id string, limit int64) ([]Message, error) {
+	start := "-"
+	if id != "" {
+		start = "(" + id
+	}
+	list, err := s.client.XRangeN(ctx, s.streamKey(topic), start, "+", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.messages(list), nil
+}
+
+// PutDead save a dead letter
+func (s *Stream) PutDead(ctx context.Context, topic string, id string, data []byte) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, s.deadKey(topic), id, data)
+		pipe.ZAdd(ctx, s.deadIndexKey(topic), goredis.Z{Score: float64(time.Now().UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.client.SAdd(ctx, s.config.KeyPrefix+deadTopics, topic).Err()
+}
+
+// DeadTopics topics which have dead letters
+func (s *Stream) DeadTopics(ctx context.Context) (topics []string, err error) {
+	members, err := s.client.SMembers(ctx, s.config.KeyPrefix+deadTopics).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range members {
+		n, err := s.client.HLen(ctx, s.deadKey(topic)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// ListDead dead letters ordered by the time they failed
+func (s *Stream) ListDead(ctx context.Context, topic string) ([]Message, error) {
+	ids, err := s.client.ZRange(ctx, s.deadIndexKey(topic), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	values, err := s.client.HMGet(ctx, s.deadKey(topic), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(ids))
+	for i, v := range values {
+		if data, ok := v.(string); ok {
+			messages = append(messages, Message{Id: ids[i], Data: []byte(data)})
+		}
+	}
+	return messages, nil
+}
+
+// GetDead get a dead letter, returns nil if it does not exist
+func (s *Stream) GetDead(ctx context.Context, topic string, id string) ([]byte, error) {
+	data, err := s.client.HGet(ctx, s.deadKey(topic), id).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// DeleteDead delete dead letters, all dead letters of the topic are deleted if ids is empty
+func (s *Stream) DeleteDead(ctx context.Context, topic string, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		n, err := s.client.HLen(ctx, s.deadKey(topic)).Result()
+		if err != nil {
+			return 0, err
+		}
+		return int(n), s.client.Del(ctx, s.deadKey(topic), s.deadIndexKey(topic)).Err()
+	}
+
+	var deleted *goredis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, s.deadKey(topic), ids...)
+		members := make([]any, len(ids))
+		for i, id := range ids {
+			members[i] = id
+		}
+		pipe.ZRem(ctx, s.deadIndexKey(topic), members...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted.Val()), nil
+}
+
+// ensureGroup create the consumer group, reading from the beginning of the stream
+func (s *Stream) ensureGroup(ctx context.Context, topic string) error {
+	if _, ok := s.groups.Load(topic); ok {
+		return nil
+	}
+	err := s.client.XGroupCreateMkStream(ctx, s.streamKey(topic), s.config.GroupName, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	if err = s.addTopic(ctx, topic); err != nil {
+		return err
+	}
+	s.groups.Store(topic, struct{}{})
+	return nil
+}
+
+// addTopic record the topic once, streams created before the topics set existed are added when consumed
+func (s *Stream) addTopic(ctx context.Context, topic string) error {
+	if _, ok := s.topics.Load(topic); ok {
+		return nil
+	}
+	if err := s.client.SAdd(ctx, s.config.KeyPrefix+streamTopics, topic).Err(); err != nil {
+		return err
+	}
+	s.topics.Store(topic, struct{}{})
+	return nil
+}
+
+func (s *Stream) messages(list []goredis.XMessage) []Message {
+	messages := make([]Message, 0, len(list))
+	for _, msg := range list {
+		var data []byte
+		switch v := msg.Values[dataField].(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		}
+		messages = append(messages, Message{Id: msg.ID, Data: data})
+	}
+	return messages
+}
+
+func (s *Stream) streamKey(topic string) string {
+	return s.config.KeyPrefix + "{" + topic + "}"
+}
+
+func (s *Stream) delayKey(topic string) string {
+	return s.streamKey(topic) + ":delay"
+}
+
+func (s *Stream) delayDataKey(topic string) string {
+	return s.streamKey(topic) + ":delay:data"
+}
+
+func (s *Stream) deadKey(topic string) string {
+	return s.config.KeyPrefix + "{" + topic + deadSuffix + "}"
+}
+
+func (s *Stream) deadIndexKey(topic string) string {
+	return s.deadKey(topic) + ":index"
+}
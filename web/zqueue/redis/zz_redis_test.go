@@ -0,0 +1,257 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/gogf/gf/v2/util/grand"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newTestStream 设置 ZQUEUE_REDIS_ADDR 时使用真实的 redis，否则使用 miniredis
+func newTestStream(t *testing.T, config *Config) *Stream {
+	addr := os.Getenv("ZQUEUE_REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(t).Addr()
+	}
+	client := goredis.NewClient(&goredis.Options{Addr: addr})
+	t.Cleanup(func() { _ = client.Close() })
+
+	conf := *config
+	conf.KeyPrefix = "zqueue-test:" + grand.S(8) + ":"
+	if conf.GroupName == "" {
+		conf.GroupName = "default"
+	}
+	return New(client, &conf)
+}
+
+func messageData(messages []Message) (list []string) {
+	for _, message := range messages {
+		list = append(list, string(message.Data))
+	}
+	return
+}
+
+func TestStream_ReadAck(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx = context.Background()
+			s   = newTestStream(t.T, &Config{Consumer: "c1", BlockTime: 100 * time.Millisecond})
+		)
+
+		_, err := s.Add(ctx, "order", []byte("1"))
+		t.AssertNil(err)
+		_, err = s.Add(ctx, "order", []byte("2"))
+		t.AssertNil(err)
+
+		messages, err := s.Read(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(messageData(messages), []string{"1", "2"})
+
+		// 已读取的消息不会再次读取
+		again, err := s.Read(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(len(again), 0)
+
+		t.AssertNil(s.Ack(ctx, "order", messages[0].Id, messages[1].Id))
+		pending, err := s.client.XPending(ctx, s.streamKey("order"), s.config.GroupName).Result()
+		t.AssertNil(err)
+		t.Assert(pending.Count, 0)
+	})
+}
+
+func TestStream_Claim(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx    = context.Background()
+			config = &Config{Consumer: "c1", BlockTime: 100 * time.Millisecond, ClaimIdle: 50 * time.Millisecond}
+			c1     = newTestStream(t.T, config)
+		)
+		config.Consumer, config.KeyPrefix, config.GroupName = "c2", c1.config.KeyPrefix, c1.config.GroupName
+		c2 := New(c1.client, config)
+
+		_, err := c1.Add(ctx, "order", []byte("1"))
+		t.AssertNil(err)
+		_, err = c1.Add(ctx, "order", []byte("2"))
+		t.AssertNil(err)
+
+		// c1 读取后只确认了第一条消息
+		messages, err := c1.Read(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(len(messages), 2)
+		t.AssertNil(c1.Ack(ctx, "order", messages[0].Id))
+
+		// 未超时的消息不会被接管
+		claimed, _, err := c2.Claim(ctx, "order", "")
+		t.AssertNil(err)
+		t.Assert(len(claimed), 0)
+
+		time.Sleep(100 * time.Millisecond)
+		claimed, next, err := c2.Claim(ctx, "order", "")
+		t.AssertNil(err)
+		t.Assert(messageData(claimed), []string{"2"})
+		t.Assert(next, startId)
+		t.AssertNil(c2.Ack(ctx, "order", claimed[0].Id))
+
+		time.Sleep(100 * time.Millisecond)
+		claimed, _, err = c1.Claim(ctx, "order", "")
+		t.AssertNil(err)
+		t.Assert(len(claimed), 0)
+	})
+}
+
+func TestStream_MaxLen(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx = context.Background()
+			s   = newTestStream(t.T, &Config{MaxLen: 10})
+		)
+
+		for i := 0; i < 1000; i++ {
+			_, err := s.Add(ctx, "order", []byte("1"))
+			t.AssertNil(err)
+		}
+
+		// 近似裁剪，保留的消息数量不少于 MaxLen
+		n, err := s.client.XLen(ctx, s.streamKey("order")).Result()
+		t.AssertNil(err)
+		t.AssertGE(n, int64(10))
+		t.AssertLT(n, int64(1000))
+	})
+}
+
+func TestStream_Delay(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx = context.Background()
+			s   = newTestStream(t.T, &Config{BlockTime: 100 * time.Millisecond})
+			now = time.Now()
+		)
+
+		t.AssertNil(s.Schedule(ctx, "order", "a", now.Add(time.Minute), []byte("a")))
+		t.AssertNil(s.Schedule(ctx, "order", "b", now.Add(2*time.Minute), []byte("b")))
+		t.AssertNil(s.Schedule(ctx, "order", "c", now.Add(3*time.Minute), []byte("c")))
+
+		topics, err := s.DelayTopics(ctx)
+		t.AssertNil(err)
+		t.Assert(topics, []string{"order"})
+
+		ok, err := s.Cancel(ctx, "order", "b")
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		ok, err = s.Cancel(ctx, "order", "b")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		// 未到期
+		n, err := s.MoveDue(ctx, "order", now, 100)
+		t.AssertNil(err)
+		t.Assert(n, 0)
+
+		n, err = s.MoveDue(ctx, "order", now.Add(5*time.Minute), 1)
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		n, err = s.MoveDue(ctx, "order", now.Add(5*time.Minute), 100)
+		t.AssertNil(err)
+		t.Assert(n, 1)
+
+		messages, err := s.Read(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(messageData(messages), []string{"a", "c"})
+
+		// 已投递的消息不能取消
+		ok, err = s.Cancel(ctx, "order", "a")
+		t.AssertNil(err)
+		t.Assert(ok, false)
+	})
+}
+
+func TestStream_Dead(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx = context.Background()
+			s   = newTestStream(t.T, &Config{})
+		)
+
+		t.AssertNil(s.PutDead(ctx, "order", "1", []byte("a")))
+		t.AssertNil(s.PutDead(ctx, "order", "2", []byte("b")))
+		t.AssertNil(s.PutDead(ctx, "user", "3", []byte("c")))
+
+		topics, err := s.DeadTopics(ctx)
+		t.AssertNil(err)
+		t.AssertIN("order", topics)
+		t.AssertIN("user", topics)
+
+		messages, err := s.ListDead(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(messageData(messages), []string{"a", "b"})
+
+		data, err := s.GetDead(ctx, "order", "2")
+		t.AssertNil(err)
+		t.Assert(string(data), "b")
+		data, err = s.GetDead(ctx, "order", "4")
+		t.AssertNil(err)
+		t.AssertNil(data)
+
+		n, err := s.DeleteDead(ctx, "order", "1", "4")
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		messages, err = s.ListDead(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(messageData(messages), []string{"b"})
+
+		// 清空
+		n, err = s.DeleteDead(ctx, "user")
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		topics, err = s.DeadTopics(ctx)
+		t.AssertNil(err)
+		t.Assert(topics, []string{"order"})
+	})
+}
+
+func TestStream_Info(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			ctx = context.Background()
+			s   = newTestStream(t.T, &Config{Consumer: "c1", BatchSize: 1, BlockTime: 100 * time.Millisecond})
+		)
+
+		info, err := s.Info(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(info.Length, 0)
+
+		for _, data := range []string{"1", "2", "3"} {
+			_, err = s.Add(ctx, "order", []byte(data))
+			t.AssertNil(err)
+		}
+		topics, err := s.Topics(ctx)
+		t.AssertNil(err)
+		t.Assert(topics, []string{"order"})
+
+		// 读取一条但未确认
+		messages, err := s.Read(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(messageData(messages), []string{"1"})
+
+		info, err = s.Info(ctx, "order")
+		t.AssertNil(err)
+		t.Assert(info.Length, 3)
+		t.Assert(len(info.Groups), 1)
+		t.Assert(info.Groups[0].Name, s.config.GroupName)
+		t.Assert(info.Groups[0].LastId, messages[0].Id)
+		t.Assert(info.Groups[0].Pending, 1)
+
+		// 消费者组尚未读取的消息
+		next, err := s.Range(ctx, "order", info.Groups[0].LastId, 10)
+		t.AssertNil(err)
+		t.Assert(messageData(next), []string{"2", "3"})
+		all, err := s.Range(ctx, "order", "", 2)
+		t.AssertNil(err)
+		t.Assert(messageData(all), []string{"1", "2"})
+	})
+}
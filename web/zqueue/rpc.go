@@ -0,0 +1,209 @@
+package zqueue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+// RPC 消息头
+const (
+	HeaderReplyTo       = "x-reply-to"       // 响应写入的主题
+	HeaderCorrelationId = "x-correlation-id" // 请求的关联ID，响应中原样返回
+	HeaderDeadline      = "x-deadline"       // 调用方等待的截止时间（毫秒时间戳），超过后不再处理
+)
+
+// ReplyTopicPrefix 响应主题前缀，每个实例使用各自的响应主题
+const ReplyTopicPrefix = "reply."
+
+// defaultCallTimeout 调用没有设置截止时间时的默认超时时间
+const defaultCallTimeout = 30 * time.Second
+
+// Responder 处理 Call 请求的消费者实现该接口，收到 Call 请求时调用 Reply 代替 Handle
+// 返回值作为响应发送给调用方，返回错误时调用方收到该错误，不会重试或进入死信队列
+type Responder interface {
+	Reply(ctx context.Context, mqMsg MqMsg) (resp interface{}, err error)
+}
+
+// InstanceId 当前实例的ID，用于区分响应主题和广播的消费者组
+// 未配置 instanceId 时，磁盘和内存队列只有一个进程使用，固定为 local，其他驱动为 主机名-进程ID
+func InstanceId() string {
+	if id := getConfig().InstanceId; id != "" {
+		return safeName(id)
+	}
+	switch getConfig().Driver {
+	case "disk", "memory":
+		return "local"
+	}
+	hostname, _ := os.Hostname()
+	return safeName(fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+}
+
+// safeName 替换字母、数字、- 和 _ 以外的字符，可以用于磁盘队列的目录和消费者组名称
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// rpcReplies 等待响应的调用，首次调用时启动当前实例的响应监听
+var rpcReplies = struct {
+	sync.Mutex
+	topic   string                // 正在监听的响应主题
+	cancel  context.CancelFunc    // 停止监听
+	waiting map[string]chan MqMsg // 关联ID -> 等待响应的调用
+}{waiting: make(map[string]chan MqMsg)}
+
+// Call 发送请求并等待响应，由实现 Responder 的消费者处理
+// ctx 没有截止时间时使用配置的 callTimeout，超时后返回错误，之后收到的响应会被丢弃
+func Call(ctx context.Context, topic string, req interface{}) (resp MqMsg, err error) {
+	if topic == "" {
+		return resp, gerror.New("topic is empty")
+	}
+
+	replyTopic, err := listenReplies()
+	if err != nil {
+		return
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := time.Duration(getConfig().CallTimeout) * time.Second
+		if timeout <= 0 {
+			timeout = defaultCallTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+
+	q, err := InstanceProducer()
+	if err != nil {
+		return
+	}
+
+	var (
+		correlationId = newMsgId()
+		ch            = make(chan MqMsg, 1)
+	)
+	rpcReplies.Lock()
+	rpcReplies.waiting[correlationId] = ch
+	rpcReplies.Unlock()
+	defer func() {
+		rpcReplies.Lock()
+		delete(rpcReplies.waiting, correlationId)
+		rpcReplies.Unlock()
+	}()
+
+	mqMsg := MqMsg{Topic: topic, MsgId: correlationId, Body: []byte(gconv.String(req))}
+	mqMsg.SetHeader(HeaderReplyTo, replyTopic)
+	mqMsg.SetHeader(HeaderCorrelationId, correlationId)
+	mqMsg.SetHeader(HeaderDeadline, strconv.FormatInt(deadline.UnixMilli(), 10))
+	mqMsg, err = q.SendMqMsg(mqMsg, time.Time{})
+	ProducerLog(ctx, topic, mqMsg, err)
+	if err != nil {
+		return
+	}
+
+	select {
+	case resp = <-ch:
+		if e := resp.Header(HeaderError); e != "" {
+			err = gerror.New(e)
+		}
+	case <-ctx.Done():
+		err = gerror.Wrapf(ctx.Err(), "queue call %s 等待响应超时", topic)
+	}
+	return
+}
+
+// listenReplies 启动当前实例的响应监听，返回响应主题
+func listenReplies() (topic string, err error) {
+	rpcReplies.Lock()
+	defer rpcReplies.Unlock()
+	if rpcReplies.cancel != nil {
+		return rpcReplies.topic, nil
+	}
+
+	c, err := InstanceConsumer()
+	if err != nil {
+		return
+	}
+	topic = ReplyTopicPrefix + InstanceId()
+	if joiner, ok := c.(groupJoiner); ok {
+		if err = joiner.joinGroup(topic, DefaultConsumerGroup); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	rpcReplies.topic, rpcReplies.cancel = topic, cancel
+	go func() {
+		if err := c.ListenReceiveMsgDo(ctx, topic, nil, receiveReplies); err != nil {
+			Logger().Warningf(ctx, "queue reply listen err:%+v, topic:%v", err, topic)
+		}
+	}()
+	return
+}
+
+// receiveReplies 将响应交给等待的调用，已超时或其他进程的响应直接丢弃
+func receiveReplies(list []MqMsg) {
+	rpcReplies.Lock()
+	defer rpcReplies.Unlock()
+	for _, mqMsg := range list {
+		if ch, ok := rpcReplies.waiting[mqMsg.Header(HeaderCorrelationId)]; ok {
+			ch <- mqMsg
+			delete(rpcReplies.waiting, mqMsg.Header(HeaderCorrelationId))
+		}
+	}
+}
+
+// resetReplies 停止响应监听，下次调用时按当前配置重新监听
+func resetReplies() {
+	rpcReplies.Lock()
+	defer rpcReplies.Unlock()
+	if rpcReplies.cancel != nil {
+		rpcReplies.cancel()
+		rpcReplies.cancel = nil
+	}
+}
+
+// isCall 是否为 Call 请求
+func isCall(mqMsg MqMsg) bool {
+	return mqMsg.Header(HeaderReplyTo) != "" && mqMsg.Header(HeaderCorrelationId) != ""
+}
+
+// consumerReply 处理 Call 请求并发送响应，调用方已超时的请求不再处理
+func consumerReply(ctx context.Context, r Responder, topic string, group string, mqMsg MqMsg) {
+	if deadline := gconv.Int64(mqMsg.Header(HeaderDeadline)); deadline > 0 && time.Now().UnixMilli() > deadline {
+		Logger().Warningf(ctx, "消费 [%s] 调用方已超时，跳过请求, msgId:%v", topic, mqMsg.MsgId)
+		return
+	}
+
+	start := time.Now()
+	resp, err := r.Reply(ctx, mqMsg)
+	collector.recordHandled(topic, group, 1, time.Since(start), err)
+	ConsumerLog(ctx, topic, mqMsg, err)
+
+	reply := MqMsg{Topic: mqMsg.Header(HeaderReplyTo), Body: []byte(gconv.String(resp))}
+	reply.SetHeader(HeaderCorrelationId, mqMsg.Header(HeaderCorrelationId))
+	if err != nil {
+		reply.Body = nil
+		reply.SetHeader(HeaderError, err.Error())
+	}
+
+	q, err := InstanceProducer()
+	if err == nil {
+		reply, err = q.SendMqMsg(reply, time.Time{})
+	}
+	ProducerLog(ctx, reply.Topic, reply, err)
+}
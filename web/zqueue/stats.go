@@ -0,0 +1,304 @@
+package zqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+
+	"github.com/denghuo98/zzframe/web/zqueue/disk"
+)
+
+// TopicStats 主题统计
+// 生产、消费数量和处理耗时为当前进程启动以来的统计，其余为队列存储的状态
+type TopicStats struct {
+	Topic    string        `json:"topic"`
+	Produced int64         `json:"produced"` // 生产成功的消息数量，包含重试和延迟消息
+	Position string        `json:"position"` // 写入位置，磁盘队列为 分片:偏移量，Redis 队列为最后一条消息的ID，内存队列为消息数量，数据库队列为最大的记录ID
+	Length   int64         `json:"length"`   // Redis、内存、数据库队列保留的消息数量，数据库队列每个消费者组各保存一份
+	Segments int64         `json:"segments"` // 磁盘队列的数据文件数量
+	Bytes    int64         `json:"bytes"`    // 磁盘队列的数据文件大小
+	Groups   []*GroupStats `json:"groups"`
+
+	// Recovery 磁盘队列当前进程启动以来的数据校验和恢复统计
+	Recovery *disk.RecoveryStats `json:"recovery,omitempty"`
+}
+
+// GroupStats 消费者组统计
+type GroupStats struct {
+	Group       string        `json:"group"`
+	Committed   string        `json:"committed"`   // 已提交的位置，磁盘队列为 分片:偏移量，Redis 队列为最后读取的消息ID
+	Lag         int64         `json:"lag"`         // 未消费的消息数量，Redis 队列需要 Redis 7 以上版本，无法计算时为 -1
+	LagBytes    int64         `json:"lagBytes"`    // 磁盘队列未消费的数据大小
+	Pending     int64         `json:"pending"`     // Redis、数据库队列已读取未确认的消息数量
+	Consumed    int64         `json:"consumed"`    // 处理成功的消息数量
+	Failed      int64         `json:"failed"`      // 处理失败的消息数量
+	LastError   string        `json:"lastError"`   // 最后一次处理失败的原因
+	LastErrorAt *time.Time    `json:"lastErrorAt"` // 最后一次处理失败的时间
+	Latency     *LatencyStats `json:"latency"`     // 处理耗时分布
+}
+
+// LatencyStats 处理耗时分布，批量处理时按每次调用统计
+type LatencyStats struct {
+	Count   int64            `json:"count"`   // 处理次数
+	Total   float64          `json:"total"`   // 总耗时（毫秒）
+	Max     float64          `json:"max"`     // 最长耗时（毫秒）
+	Buckets []*LatencyBucket `json:"buckets"` // 各耗时区间的处理次数
+}
+
+// LatencyBucket 耗时区间
+type LatencyBucket struct {
+	Le    string `json:"le"`    // 耗时上限，+Inf 为不限
+	Count int64  `json:"count"` // 耗时不超过上限且超过上一个区间的处理次数
+}
+
+// latencyBuckets 处理耗时区间的上限
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// inspector 读取主题存储状态的驱动实现该接口
+type inspector interface {
+	inspectTopics(ctx context.Context) ([]*TopicStats, error)
+	peek(ctx context.Context, topic string, group string, limit int) ([]MqMsg, error)
+}
+
+// groupCounter 消费者组在当前进程的计数
+type groupCounter struct {
+	consumed    int64
+	failed      int64
+	lastError   string
+	lastErrorAt time.Time
+	count       int64
+	total       time.Duration
+	max         time.Duration
+	buckets     []int64
+}
+
+// statsCollector 当前进程的生产和消费计数
+type statsCollector struct {
+	sync.Mutex
+	produced map[string]int64
+	groups   map[string]map[string]*groupCounter // topic -> group -> counter
+}
+
+var collector = &statsCollector{
+	produced: make(map[string]int64),
+	groups:   make(map[string]map[string]*groupCounter),
+}
+
+// recordProduced 记录生产成功的消息
+func (s *statsCollector) recordProduced(topic string) {
+	s.Lock()
+	defer s.Unlock()
+	s.produced[topic]++
+}
+
+// recordHandled 记录一次处理，n 为处理的消息数量
+func (s *statsCollector) recordHandled(topic string, group string, n int, cost time.Duration, err error) {
+	if err != nil {
+		s.recordBatch(topic, group, 0, n, cost, err)
+	} else {
+		s.recordBatch(topic, group, n, 0, cost, nil)
+	}
+}
+
+// recordBatch 记录一次批量处理，部分消息处理失败时 err 为失败原因
+func (s *statsCollector) recordBatch(topic string, group string, consumed int, failed int, cost time.Duration, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	c := s.group(topic, group)
+	c.consumed += int64(consumed)
+	c.failed += int64(failed)
+	if err != nil {
+		c.lastError, c.lastErrorAt = err.Error(), time.Now()
+	}
+
+	c.count++
+	c.total += cost
+	c.max = max(c.max, cost)
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return cost <= latencyBuckets[i] })
+	c.buckets[i]++
+}
+
+// group 必须持有锁
+func (s *statsCollector) group(topic string, group string) *groupCounter {
+	groups, ok := s.groups[topic]
+	if !ok {
+		groups = make(map[string]*groupCounter)
+		s.groups[topic] = groups
+	}
+	c, ok := groups[group]
+	if !ok {
+		c = &groupCounter{buckets: make([]int64, len(latencyBuckets)+1)}
+		groups[group] = c
+	}
+	return c
+}
+
+// fill 填充当前进程的计数
+func (s *statsCollector) fill(t *TopicStats) {
+	s.Lock()
+	defer s.Unlock()
+
+	t.Produced = s.produced[t.Topic]
+	for _, g := range t.Groups {
+		c, ok := s.groups[t.Topic][g.Group]
+		if !ok {
+			g.Latency = &LatencyStats{Buckets: latencyStatsBuckets(nil)}
+			continue
+		}
+		g.Consumed, g.Failed, g.LastError = c.consumed, c.failed, c.lastError
+		if !c.lastErrorAt.IsZero() {
+			at := c.lastErrorAt
+			g.LastErrorAt = &at
+		}
+		g.Latency = &LatencyStats{
+			Count:   c.count,
+			Total:   milliseconds(c.total),
+			Max:     milliseconds(c.max),
+			Buckets: latencyStatsBuckets(c.buckets),
+		}
+	}
+}
+
+func latencyStatsBuckets(counts []int64) []*LatencyBucket {
+	buckets := make([]*LatencyBucket, 0, len(latencyBuckets)+1)
+	for i := 0; i <= len(latencyBuckets); i++ {
+		b := &LatencyBucket{Le: "+Inf"}
+		if i < len(latencyBuckets) {
+			b.Le = latencyBuckets[i].String()
+		}
+		if counts != nil {
+			b.Count = counts[i]
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Stats 各主题和消费者组的统计，包含存储中的主题、已注册消费者的主题和当前进程生产过的主题
+func Stats(ctx context.Context) (list []*TopicStats, err error) {
+	c, err := InstanceConsumer()
+	if err != nil {
+		return
+	}
+	in, ok := c.(inspector)
+	if !ok {
+		return nil, gerror.New("queue driver does not support stats")
+	}
+	if list, err = in.inspectTopics(ctx); err != nil {
+		return
+	}
+
+	topics := make(map[string]*TopicStats, len(list))
+	for _, t := range list {
+		topics[t.Topic] = t
+	}
+	topic := func(name string) *TopicStats {
+		t, ok := topics[name]
+		if !ok {
+			t = &TopicStats{Topic: name}
+			topics[name] = t
+			list = append(list, t)
+		}
+		return t
+	}
+	addGroup := func(t *TopicStats, group string) {
+		for _, g := range t.Groups {
+			if g.Group == group {
+				return
+			}
+		}
+		t.Groups = append(t.Groups, &GroupStats{Group: group})
+	}
+
+	consumers.Lock()
+	for _, job := range consumers.list {
+		addGroup(topic(job.GetTopic()), consumeOptions(job).Group)
+	}
+	consumers.Unlock()
+
+	collector.Lock()
+	for name := range collector.produced {
+		topic(name)
+	}
+	for name, groups := range collector.groups {
+		for group := range groups {
+			addGroup(topic(name), group)
+		}
+	}
+	collector.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Topic < list[j].Topic })
+	for _, t := range list {
+		sort.Slice(t.Groups, func(i, j int) bool { return t.Groups[i].Group < t.Groups[j].Group })
+		collector.fill(t)
+	}
+	return
+}
+
+// Peek 查看消费者组接下来要处理的消息，不会提交消费位置
+func Peek(ctx context.Context, topic string, group string, limit int) (list []MqMsg, err error) {
+	if topic == "" {
+		return nil, gerror.New("topic is empty")
+	}
+	if group == "" {
+		group = DefaultConsumerGroup
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	c, err := InstanceConsumer()
+	if err != nil {
+		return
+	}
+	in, ok := c.(inspector)
+	if !ok {
+		return nil, gerror.New("queue driver does not support peek")
+	}
+	return in.peek(ctx, topic, group, limit)
+}
+
+// DropGroup 删除不再使用的消费者组，之后不再为其保留消息
+// 只能删除已经没有进程消费的消费者组，仍在消费时读取位置会被重新写入
+func DropGroup(ctx context.Context, topic string, group string) (err error) {
+	if topic == "" {
+		return gerror.New("topic is empty")
+	}
+	if group == "" {
+		return gerror.New("group is empty")
+	}
+
+	c, err := InstanceConsumer()
+	if err != nil {
+		return
+	}
+	leaver, ok := c.(groupLeaver)
+	if !ok {
+		return gerror.New("queue driver does not support dropping groups")
+	}
+	if err = leaver.leaveGroup(topic, group); err != nil {
+		return
+	}
+
+	collector.Lock()
+	delete(collector.groups[topic], group)
+	collector.Unlock()
+	return
+}
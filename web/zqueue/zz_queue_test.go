@@ -0,0 +1,1049 @@
+package zqueue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/gogf/gf/v2/util/gconv"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zqueue/disk"
+	"github.com/denghuo98/zzframe/web/zqueue/redis"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+
+	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
+)
+
+// testReceiver 收集消费到的消息，和 consumerHandle 一样跳过其他消费者组重试的消息
+type testReceiver struct {
+	sync.Mutex
+	group string
+	list  []MqMsg
+}
+
+func (r *testReceiver) receive(list []MqMsg) {
+	r.Lock()
+	defer r.Unlock()
+	for _, mqMsg := range list {
+		if target := mqMsg.Header(HeaderGroup); target == "" || target == r.group {
+			r.list = append(r.list, mqMsg)
+		}
+	}
+}
+
+func (r *testReceiver) bodies() (list []string) {
+	r.Lock()
+	defer r.Unlock()
+	for _, mqMsg := range r.list {
+		list = append(list, mqMsg.BodyString())
+	}
+	return
+}
+
+// listen 后台消费，返回停止消费并等待返回的函数
+func listen(c MqConsumer, topic string, group string, r *testReceiver) (stop func()) {
+	r.group = group
+	ctx, cancel := context.WithCancel(gctx.New())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.ListenReceiveMsgDo(ctx, topic, &ConsumeOptions{Group: group}, r.receive)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitBodies 等待消费到指定数量的消息
+func waitBodies(r *testReceiver, n int) []string {
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if len(r.bodies()) >= n {
+			break
+		}
+	}
+	return r.bodies()
+}
+
+// testQueue 生产、延迟、多个消费者组和死信的公共用例
+func testQueue(t *gtest.T, q MqProducer, c MqConsumer) {
+	joiner, ok := c.(groupJoiner)
+	t.Assert(ok, true)
+	t.AssertNil(joiner.joinGroup("order", DefaultConsumerGroup))
+	t.AssertNil(joiner.joinGroup("order", "audit"))
+
+	_, err := q.SendMsg("order", "1")
+	t.AssertNil(err)
+	_, err = q.SendMsg("order", "2")
+	t.AssertNil(err)
+
+	// 各消费者组分别消费全部消息
+	var r1, r2 testReceiver
+	stop1 := listen(c, "order", DefaultConsumerGroup, &r1)
+	stop2 := listen(c, "order", "audit", &r2)
+	t.Assert(waitBodies(&r1, 2), []string{"1", "2"})
+	t.Assert(waitBodies(&r2, 2), []string{"1", "2"})
+
+	// 重试的消息只投递给处理失败的消费者组
+	retry := MqMsg{Topic: "order", Body: []byte("3")}
+	retry.SetHeader(HeaderGroup, "audit")
+	_, err = q.SendMqMsg(retry, time.Time{})
+	t.AssertNil(err)
+	t.Assert(waitBodies(&r2, 3), []string{"1", "2", "3"})
+
+	// 延迟消息到期后投递，取消后不再投递
+	_, err = q.SendScheduleMsg("order", "4", time.Now().Add(200*time.Millisecond))
+	t.AssertNil(err)
+	canceled, err := q.SendDelayMsg("order", "5", 60)
+	t.AssertNil(err)
+	t.AssertNil(q.CancelDelayMsg("order", canceled.MsgId))
+	t.AssertNE(q.CancelDelayMsg("order", canceled.MsgId), nil)
+	t.Assert(waitBodies(&r1, 3), []string{"1", "2", "4"})
+
+	stop1()
+	stop2()
+	t.Assert(r1.bodies(), []string{"1", "2", "4"})
+
+	// 已确认的消息不会再次消费
+	var r3 testReceiver
+	stop3 := listen(c, "order", DefaultConsumerGroup, &r3)
+	time.Sleep(100 * time.Millisecond)
+	stop3()
+	t.Assert(len(r3.bodies()), 0)
+
+	// 死信
+	dl, ok := q.(MqDeadLetter)
+	t.Assert(ok, true)
+	t.AssertNil(dl.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m1", Body: []byte("a")}))
+	t.AssertNil(dl.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m2", Body: []byte("b")}))
+	t.AssertNil(dl.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m1", Body: []byte("c")}))
+	topics, err := dl.DeadLetterTopics()
+	t.AssertNil(err)
+	t.Assert(topics, []string{"order"})
+	list, err := dl.ListDeadLetters("order")
+	t.AssertNil(err)
+	t.Assert(len(list), 2)
+	mqMsg, err := dl.GetDeadLetter("order", "m1")
+	t.AssertNil(err)
+	t.Assert(mqMsg.BodyString(), "c")
+	n, err := dl.DeleteDeadLetters("order", "m1")
+	t.AssertNil(err)
+	t.Assert(n, 1)
+	_, err = dl.GetDeadLetter("order", "m1")
+	t.AssertNE(err, nil)
+	n, err = dl.DeleteDeadLetters("order")
+	t.AssertNil(err)
+	t.Assert(n, 1)
+}
+
+func TestMemoryMq(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		c, err := InstanceConsumer()
+		t.AssertNil(err)
+		t.Assert(q, c)
+		testQueue(t, q, c)
+
+		// 重新设置配置后丢弃内存中的消息
+		_, err = q.SendMsg("order", "6")
+		t.AssertNil(err)
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		list, err := Peek(gctx.New(), "order", DefaultConsumerGroup, 10)
+		t.AssertNil(err)
+		t.Assert(len(list), 0)
+	})
+}
+
+func TestDiskMq(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{
+			Driver:    "disk",
+			GroupName: "default",
+			Disk: &disk.Config{
+				Path:         t.T.TempDir(),
+				BatchSize:    1,
+				BatchTime:    1,
+				SegmentSize:  1 << 20,
+				SegmentLimit: 100,
+			},
+		})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		c, err := InstanceConsumer()
+		t.AssertNil(err)
+		testQueue(t, q, c)
+	})
+}
+
+func TestDiskMq_DeadLetterProcesses(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		conf := &disk.Config{
+			GroupName:    "default",
+			Path:         t.T.TempDir(),
+			BatchSize:    1,
+			BatchTime:    1,
+			SegmentSize:  1 << 20,
+			SegmentLimit: 100,
+		}
+		// 两个生产者模拟 queue 和 http 进程
+		queue, err := RegisterDiskMqProducer(conf)
+		t.AssertNil(err)
+		defer queue.(*DiskProducerMq).close()
+		http, err := RegisterDiskMqProducer(conf)
+		t.AssertNil(err)
+		defer http.(*DiskProducerMq).close()
+		writer, reader := queue.(MqDeadLetter), http.(MqDeadLetter)
+
+		// 读取时加载其他进程之后写入的死信
+		t.AssertNil(writer.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m1", Body: []byte("a")}))
+		list, err := reader.ListDeadLetters("order")
+		t.AssertNil(err)
+		t.Assert(len(list), 1)
+		t.AssertNil(writer.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m2", Body: []byte("b")}))
+		mqMsg, err := reader.GetDeadLetter("order", "m2")
+		t.AssertNil(err)
+		t.Assert(mqMsg.BodyString(), "b")
+
+		// 清空后其他进程写入的死信不会丢失
+		n, err := reader.DeleteDeadLetters("order")
+		t.AssertNil(err)
+		t.Assert(n, 2)
+		t.AssertNil(writer.SendDeadLetter(MqMsg{Topic: "order", MsgId: "m3", Body: []byte("c")}))
+		topics, err := reader.DeadLetterTopics()
+		t.AssertNil(err)
+		t.Assert(topics, []string{"order"})
+		list, err = reader.ListDeadLetters("order")
+		t.AssertNil(err)
+		t.Assert(len(list), 1)
+		t.Assert(list[0].MsgId, "m3")
+	})
+}
+
+func TestDiskMq_Stats(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		SetConfig(Config{
+			Driver:    "disk",
+			GroupName: "default",
+			Disk: &disk.Config{
+				Path:         t.T.TempDir(),
+				BatchSize:    1,
+				BatchTime:    1,
+				SegmentSize:  1 << 20,
+				SegmentLimit: 100,
+			},
+		})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		c, err := InstanceConsumer()
+		t.AssertNil(err)
+		t.AssertNil(c.(groupJoiner).joinGroup("stats", DefaultConsumerGroup))
+		for _, body := range []string{"1", "2", "3"} {
+			_, err = q.SendMsg("stats", body)
+			t.AssertNil(err)
+		}
+
+		topicStats := func() *TopicStats {
+			list, err := Stats(ctx)
+			t.AssertNil(err)
+			for _, s := range list {
+				if s.Topic == "stats" {
+					return s
+				}
+			}
+			return nil
+		}
+		s := topicStats()
+		t.AssertNE(s, nil)
+		t.Assert(s.Produced, 3)
+		t.Assert(s.Segments, 1)
+		t.Assert(s.Bytes > 0, true)
+		t.Assert(len(s.Groups), 1)
+		t.Assert(s.Groups[0].Group, DefaultConsumerGroup)
+		t.Assert(s.Groups[0].Lag, 3)
+		t.Assert(s.Groups[0].LagBytes, s.Bytes)
+
+		// 消费后积压清零，处理耗时按区间统计
+		var r testReceiver
+		stop := listen(c, "stats", DefaultConsumerGroup, &r)
+		t.Assert(waitBodies(&r, 3), []string{"1", "2", "3"})
+		stop()
+		collector.recordHandled("stats", DefaultConsumerGroup, 2, 3*time.Millisecond, nil)
+		collector.recordHandled("stats", DefaultConsumerGroup, 1, 2*time.Second, errors.New("timeout"))
+
+		s = topicStats()
+		t.Assert(s.Groups[0].Lag, 0)
+		t.Assert(s.Groups[0].LagBytes, 0)
+		t.Assert(s.Groups[0].Consumed, 2)
+		t.Assert(s.Groups[0].Failed, 1)
+		t.Assert(s.Groups[0].LastError, "timeout")
+		latency := s.Groups[0].Latency
+		t.Assert(latency.Count, 2)
+		t.Assert(latency.Max, 2000)
+		t.Assert(len(latency.Buckets), len(latencyBuckets)+1)
+		for _, b := range latency.Buckets {
+			switch b.Le {
+			case "5ms", "5s":
+				t.Assert(b.Count, 1)
+			default:
+				t.Assert(b.Count, 0)
+			}
+		}
+	})
+}
+
+func TestRedisMq_DeliverDelay(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		client := goredis.NewClient(&goredis.Options{Addr: miniredis.RunT(t.T).Addr()})
+		defer client.Close()
+		stopped := func(r *RedisMq) bool {
+			select {
+			case <-r.done:
+				return true
+			case <-time.After(time.Second):
+				return false
+			}
+		}
+
+		// 重新注册同一组群时停止之前的投递，每个组群只有一个投递
+		conf := &redis.Config{GroupName: "delay"}
+		first, second := NewRedisMq(client, conf), NewRedisMq(client, conf)
+		first.startDelay()
+		second.startDelay()
+		t.Assert(stopped(first), true)
+		redisDelays.Lock()
+		t.Assert(redisDelays.list["delay"] == second, true)
+		redisDelays.Unlock()
+
+		// 重新设置配置时停止投递
+		second.close()
+		t.Assert(stopped(second), true)
+		redisDelays.Lock()
+		t.Assert(len(redisDelays.list), 0)
+		redisDelays.Unlock()
+	})
+}
+
+func TestDatabaseMq(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		gdb.SetConfig(gdb.Config{
+			"default": gdb.ConfigGroup{
+				gdb.ConfigNode{Link: "sqlite::@file(" + filepath.Join(t.T.TempDir(), "queue.db") + ")"},
+			},
+		})
+		_, err := g.DB().Exec(ctx, `
+		CREATE TABLE zz_sys_queue_message (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			topic TEXT NOT NULL,
+			consumer_group TEXT NOT NULL DEFAULT '',
+			msg_id TEXT NOT NULL,
+			body TEXT,
+			visible_at INTEGER NOT NULL DEFAULT 0,
+			deliveries INTEGER NOT NULL DEFAULT 0,
+			receipt TEXT,
+			created_at TEXT
+		);`)
+		t.AssertNil(err)
+		_, err = g.DB().Exec(ctx, `
+		CREATE TABLE zz_sys_queue_group (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			topic TEXT NOT NULL,
+			consumer_group TEXT NOT NULL,
+			created_at TEXT,
+			UNIQUE (topic, consumer_group)
+		);`)
+		t.AssertNil(err)
+
+		SetConfig(Config{Driver: "database", GroupName: "default", Database: &DatabaseConfig{PollInterval: 20}})
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		c, err := InstanceConsumer()
+		t.AssertNil(err)
+		testQueue(t, q, c)
+
+		// 读取后未确认的消息在可见性超时前不会再次读取
+		mq := NewDatabaseMq("default", &DatabaseConfig{VisibilityTimeout: 1})
+		_, err = mq.SendMsg("order", "7")
+		t.AssertNil(err)
+		rows, err := mq.receive(ctx, "order", "default")
+		t.AssertNil(err)
+		t.Assert(len(rows), 1)
+		again, err := mq.receive(ctx, "order", "default")
+		t.AssertNil(err)
+		t.Assert(len(again), 0)
+
+		stats, err := Stats(ctx)
+		t.AssertNil(err)
+		for _, s := range stats {
+			if s.Topic == "order" {
+				t.Assert(len(s.Groups), 2)
+				t.Assert(s.Groups[1].Group, DefaultConsumerGroup)
+				t.Assert(s.Groups[1].Pending, 1)
+			}
+		}
+
+		time.Sleep(1100 * time.Millisecond)
+		again, err = mq.receive(ctx, "order", "default")
+		t.AssertNil(err)
+		t.Assert(len(again), 1)
+		t.Assert(again[0].Deliveries, 2)
+
+		// 旧的读取凭证不能确认消息
+		count := func() int {
+			n, err := g.DB().Model("zz_sys_queue_message").Where("topic", "order").Where("consumer_group", "default").Count()
+			t.AssertNil(err)
+			return n
+		}
+		mq.ack("order", rows[0])
+		t.Assert(count(), 1)
+		mq.ack("order", again[0])
+		t.Assert(count(), 0)
+
+		// 停止广播订阅后删除当前实例的消费者组，之后的广播不再保存
+		topic := BroadcastTopic("cache")
+		rowCount := func(table string) int {
+			n, err := g.DB().Model(table).Where("topic", topic).Count()
+			t.AssertNil(err)
+			return n
+		}
+		var r testReceiver
+		t.AssertNil(Subscribe("cache", func(ctx context.Context, mqMsg MqMsg) error {
+			r.receive([]MqMsg{mqMsg})
+			return nil
+		}))
+		t.AssertNil(Broadcast("cache", "k1"))
+		t.Assert(waitBodies(&r, 1), []string{"k1"})
+		t.Assert(rowCount("zz_sys_queue_group"), 1)
+		t.AssertNil(StopSubscriptions(ctx))
+		t.Assert(rowCount("zz_sys_queue_group"), 0)
+		t.AssertNil(Broadcast("cache", "k2"))
+		t.Assert(rowCount("zz_sys_queue_message"), 0)
+
+		// 异常退出的实例的消费者组在广播超时未确认后由其他实例删除
+		t.AssertNil(mq.joinGroup(topic, "broadcast-dead"))
+		_, err = mq.SendMsg(topic, "k3")
+		t.AssertNil(err)
+		_, err = g.DB().Model("zz_sys_queue_message").Where("topic", topic).
+			Data(g.Map{"created_at": gtime.Now().Add(-broadcastGroupExpire - time.Minute)}).Update()
+		t.AssertNil(err)
+		t.AssertNil(mq.expireGroups(ctx, topic, "broadcast-live"))
+		list, err := g.DB().Model("zz_sys_queue_group").Where("topic", topic).Array("consumer_group")
+		t.AssertNil(err)
+		t.Assert(list, []string{"default:broadcast-live"})
+		t.Assert(rowCount("zz_sys_queue_message"), 0)
+	})
+}
+
+// testResponder 处理 Call 请求
+type testResponder struct{}
+
+func (r *testResponder) GetTopic() string { return "export" }
+
+func (r *testResponder) Handle(ctx context.Context, mqMsg MqMsg) error { return nil }
+
+func (r *testResponder) Reply(ctx context.Context, mqMsg MqMsg) (interface{}, error) {
+	if mqMsg.BodyString() == "fail" {
+		return nil, errors.New("export failed")
+	}
+	return "file:" + mqMsg.BodyString(), nil
+}
+
+func TestCall(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		ctx, cancel := context.WithCancel(gctx.New())
+		defer cancel()
+		go consumerListen(ctx, &testResponder{})
+
+		resp, err := Call(ctx, "export", "a")
+		t.AssertNil(err)
+		t.Assert(resp.BodyString(), "file:a")
+
+		_, err = Call(ctx, "export", "fail")
+		t.AssertNE(err, nil)
+		t.Assert(err.Error(), "export failed")
+
+		// 没有消费者处理时超时
+		timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer timeoutCancel()
+		_, err = Call(timeoutCtx, "import", "a")
+		t.AssertNE(err, nil)
+	})
+}
+
+func TestBroadcast(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		// 订阅前发送的广播不会收到
+		t.AssertNil(Broadcast("cache", "old"))
+		time.Sleep(10 * time.Millisecond)
+
+		var r1, r2 testReceiver
+		subscribe := func(r *testReceiver) BroadcastHandler {
+			return func(ctx context.Context, mqMsg MqMsg) error {
+				r.receive([]MqMsg{mqMsg})
+				return nil
+			}
+		}
+		t.AssertNil(Subscribe("cache", subscribe(&r1)))
+		t.AssertNil(Subscribe("cache", subscribe(&r2)))
+
+		t.AssertNil(Broadcast("cache", "k1"))
+		t.Assert(waitBodies(&r1, 1), []string{"k1"})
+		t.Assert(waitBodies(&r2, 1), []string{"k1"})
+
+		// 广播不影响主题的普通消费者组
+		list, err := Peek(gctx.New(), "cache", DefaultConsumerGroup, 10)
+		t.AssertNil(err)
+		t.Assert(len(list), 0)
+	})
+}
+
+// testFailConsumer 处理总是失败，记录每次处理时的失败次数
+type testFailConsumer struct {
+	sync.Mutex
+	topic    string
+	policy   *RetryPolicy
+	attempts []int
+}
+
+func (c *testFailConsumer) GetTopic() string { return c.topic }
+
+func (c *testFailConsumer) Handle(ctx context.Context, mqMsg MqMsg) error {
+	c.Lock()
+	defer c.Unlock()
+	c.attempts = append(c.attempts, mqMsg.Attempts())
+	return errors.New("handle failed")
+}
+
+func (c *testFailConsumer) RetryPolicy() *RetryPolicy { return c.policy }
+
+// waitDeadLetters 等待主题出现指定数量的死信
+func waitDeadLetters(t *gtest.T, topic string, n int) (list []MqMsg) {
+	dl, err := InstanceDeadLetter()
+	t.AssertNil(err)
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if list, err = dl.ListDeadLetters(topic); err != nil || len(list) >= n {
+			break
+		}
+	}
+	t.AssertNil(err)
+	return list
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		// 每次失败后间隔翻倍，不超过上限
+		p := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+		t.Assert(p.Delay(1), 100*time.Millisecond)
+		t.Assert(p.Delay(2), 200*time.Millisecond)
+		t.Assert(p.Delay(4), 800*time.Millisecond)
+		t.Assert(p.Delay(5), time.Second)
+		t.Assert(p.Delay(100), time.Second)
+
+		// 不限制上限
+		p = &RetryPolicy{Backoff: 100 * time.Millisecond}
+		t.Assert(p.Delay(5), 1600*time.Millisecond)
+
+		// 抖动在 ±Jitter 范围内，超过 1 时按 1 计算
+		p = &RetryPolicy{Backoff: time.Second, Jitter: 0.2}
+		for i := 0; i < 100; i++ {
+			d := p.Delay(1)
+			t.Assert(d >= 800*time.Millisecond && d <= 1200*time.Millisecond, true)
+		}
+		p = &RetryPolicy{Backoff: time.Second, Jitter: 3}
+		for i := 0; i < 100; i++ {
+			d := p.Delay(1)
+			t.Assert(d >= 0 && d <= 2*time.Second, true)
+		}
+	})
+}
+
+func TestConsumerFail(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+
+		ctx, cancel := context.WithCancel(gctx.New())
+		defer cancel()
+		retry := &testFailConsumer{topic: "retry", policy: &RetryPolicy{MaxAttempts: 3, Backoff: 20 * time.Millisecond}}
+		noRetry := &testFailConsumer{topic: "no-retry"}
+		go consumerListen(ctx, retry)
+		go consumerListen(ctx, noRetry)
+
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		sent, err := q.SendMsg("retry", "a")
+		t.AssertNil(err)
+		_, err = q.SendMsg("no-retry", "b")
+		t.AssertNil(err)
+
+		// 处理 MaxAttempts 次后进入死信队列，重试的消息带有失败次数
+		list := waitDeadLetters(t, "retry", 1)
+		t.Assert(len(list), 1)
+		t.Assert(list[0].MsgId, sent.MsgId)
+		t.Assert(list[0].BodyString(), "a")
+		t.Assert(list[0].Attempts(), 3)
+		t.Assert(list[0].Header(HeaderError), "handle failed")
+		t.Assert(list[0].Header(HeaderGroup), DefaultConsumerGroup)
+		t.AssertNE(list[0].Header(HeaderFailedAt), "")
+		retry.Lock()
+		t.Assert(retry.attempts, []int{0, 1, 2})
+		retry.Unlock()
+
+		// 没有重试策略时直接进入死信队列
+		list = waitDeadLetters(t, "no-retry", 1)
+		t.Assert(len(list), 1)
+		t.Assert(list[0].Attempts(), 1)
+		noRetry.Lock()
+		t.Assert(noRetry.attempts, []int{0})
+		noRetry.Unlock()
+
+		// 重放后重置失败次数，再次按重试策略处理
+		t.AssertNil(ReplayDeadLetter("no-retry", list[0].MsgId))
+		t.Assert(len(waitDeadLetters(t, "no-retry", 1)), 1)
+		noRetry.Lock()
+		t.Assert(noRetry.attempts, []int{0, 0})
+		noRetry.Unlock()
+	})
+}
+
+// testSlowConsumer 处理时等待 release 关闭
+type testSlowConsumer struct {
+	topic   string
+	started chan struct{}
+	release chan struct{}
+	handled chan string
+}
+
+func newTestSlowConsumer(topic string) *testSlowConsumer {
+	return &testSlowConsumer{
+		topic:   topic,
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+		handled: make(chan string, 1),
+	}
+}
+
+func (c *testSlowConsumer) GetTopic() string { return c.topic }
+
+func (c *testSlowConsumer) Handle(ctx context.Context, mqMsg MqMsg) error {
+	c.started <- struct{}{}
+	<-c.release
+	c.handled <- mqMsg.BodyString()
+	return nil
+}
+
+func TestStopConsumersListener(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default", ShutdownTimeout: 1})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+		ctx := gctx.New()
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+
+		// 停止时等待正在处理的消息完成并提交
+		drain := newTestSlowConsumer("drain")
+		RegisterConsumer(drain)
+		StartConsumersListener(ctx)
+		_, err = q.SendMsg("drain", "a")
+		t.AssertNil(err)
+		<-drain.started
+		time.AfterFunc(100*time.Millisecond, func() { close(drain.release) })
+		t.AssertNil(StopConsumersListener(ctx))
+		t.Assert(<-drain.handled, "a")
+		list, err := Peek(ctx, "drain", DefaultConsumerGroup, 10)
+		t.AssertNil(err)
+		t.Assert(len(list), 0)
+
+		// 超过 shutdownTimeout 后不再等待，未提交的消息保留
+		consumers.Lock()
+		delete(consumers.list, "drain@"+DefaultConsumerGroup)
+		consumers.Unlock()
+		stuck := newTestSlowConsumer("stuck")
+		RegisterConsumer(stuck)
+		defer func() {
+			consumers.Lock()
+			delete(consumers.list, "stuck@"+DefaultConsumerGroup)
+			consumers.Unlock()
+		}()
+		StartConsumersListener(ctx)
+		_, err = q.SendMsg("stuck", "b")
+		t.AssertNil(err)
+		<-stuck.started
+
+		start := time.Now()
+		t.AssertNE(StopConsumersListener(ctx), nil)
+		t.Assert(time.Since(start) >= time.Second && time.Since(start) < 2*time.Second, true)
+		list, err = Peek(ctx, "stuck", DefaultConsumerGroup, 10)
+		t.AssertNil(err)
+		t.Assert(len(list), 1)
+
+		close(stuck.release)
+		t.Assert(<-stuck.handled, "b")
+	})
+}
+
+func TestDispatcher_CommitInOrder(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			mu       sync.Mutex
+			handled  []string
+			commits  []int
+			released = make(chan struct{})
+		)
+		// 先读取的消息后处理完成
+		d := newDispatcher(&ConsumeOptions{Workers: 3}, func(list []MqMsg) {
+			if list[0].BodyString() == "0" {
+				<-released
+			}
+			mu.Lock()
+			handled = append(handled, list[0].BodyString())
+			mu.Unlock()
+			if list[0].BodyString() == "2" {
+				close(released)
+			}
+		})
+		for i := 0; i < 3; i++ {
+			d.Dispatch(MqMsg{Body: []byte(gconv.String(i))}, func() {
+				mu.Lock()
+				commits = append(commits, i)
+				mu.Unlock()
+			})
+		}
+		d.Close()
+
+		t.Assert(handled[len(handled)-1], "0")
+		t.Assert(commits, []int{0, 1, 2})
+	})
+}
+
+func TestDispatcher_Batch(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			sizes   []int
+			commits int
+		)
+		d := newDispatcher(&ConsumeOptions{BatchSize: 2}, func(list []MqMsg) {
+			sizes = append(sizes, len(list))
+		})
+		for i := 0; i < 5; i++ {
+			d.Dispatch(MqMsg{}, func() { commits++ })
+		}
+		// 没有更多消息时处理未凑满的一批
+		d.Flush()
+		d.Close()
+		t.Assert(sizes, []int{2, 2, 1})
+		t.Assert(commits, 5)
+	})
+}
+
+// testBatchConsumer 批量处理消息
+type testBatchConsumer struct {
+	sizes  []int
+	err    error
+	policy *RetryPolicy
+}
+
+func (c *testBatchConsumer) GetTopic() string { return "batch" }
+
+func (c *testBatchConsumer) RetryPolicy() *RetryPolicy { return c.policy }
+
+func (c *testBatchConsumer) Handle(ctx context.Context, mqMsg MqMsg) error {
+	return errors.New("handle should not be called")
+}
+
+func (c *testBatchConsumer) HandleBatch(ctx context.Context, list []MqMsg) error {
+	c.sizes = append(c.sizes, len(list))
+	return c.err
+}
+
+func TestConsumerHandle_Batch(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+		ctx := gctx.New()
+
+		c := &testBatchConsumer{}
+		t.Assert(consumeOptions(c).BatchSize, 100)
+		list := []MqMsg{{Topic: "batch", MsgId: "1"}, {Topic: "batch", MsgId: "2"}, {Topic: "batch", MsgId: "3"}}
+		consumerHandle(ctx, c, DefaultConsumerGroup, list)
+		t.Assert(c.sizes, []int{3})
+		t.Assert(len(waitDeadLetters(t, "batch", 0)), 0)
+
+		// 整批失败时每条消息分别进入死信队列
+		c.err = errors.New("batch failed")
+		consumerHandle(ctx, c, DefaultConsumerGroup, list)
+		t.Assert(c.sizes, []int{3, 3})
+		t.Assert(len(waitDeadLetters(t, "batch", 3)), 3)
+		dl, err := InstanceDeadLetter()
+		t.AssertNil(err)
+		_, err = dl.DeleteDeadLetters("batch")
+		t.AssertNil(err)
+
+		// 部分失败时只重试失败的消息，无法恢复的消息直接进入死信队列
+		var batchErr BatchError
+		t.AssertNil(batchErr.Err())
+		batchErr.Add("2", errors.New("write failed"))
+		batchErr.Add("3", Unrecoverable(errors.New("bad json")))
+		c.err, c.policy = batchErr.Err(), &RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}
+		consumerHandle(ctx, c, DefaultConsumerGroup, list)
+		dead := waitDeadLetters(t, "batch", 1)
+		t.Assert(len(dead), 1)
+		t.Assert(dead[0].MsgId, "3")
+		t.Assert(dead[0].Header(HeaderError), "bad json")
+		q, err := InstanceProducer()
+		t.AssertNil(err)
+		t.AssertNil(q.CancelDelayMsg("batch", "2"))
+		t.AssertNE(q.CancelDelayMsg("batch", "1"), nil)
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		gdb.SetConfig(gdb.Config{
+			"default": gdb.ConfigGroup{
+				gdb.ConfigNode{Link: "sqlite::@file(" + filepath.Join(t.T.TempDir(), "outbox.db") + ")"},
+			},
+		})
+		_, err := g.DB().Exec(ctx, `
+		CREATE TABLE zz_sys_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			topic TEXT NOT NULL,
+			msg_id TEXT NOT NULL,
+			body TEXT,
+			status INTEGER NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			locked_at TEXT,
+			sent_at TEXT,
+			created_at TEXT,
+			updated_at TEXT
+		);`)
+		t.AssertNil(err)
+
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+		cfg := (&OutboxConfig{LockTimeout: 1, Retention: 60}).normalize()
+		count := func(where g.Map) int {
+			n, err := g.DB().Model("zz_sys_outbox").Where(where).Count()
+			t.AssertNil(err)
+			return n
+		}
+
+		// 事务回滚时不写入发件箱
+		err = g.DB().Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+			t.AssertNil(PushTx(tx, "outbox", "x"))
+			return errors.New("rollback")
+		})
+		t.AssertNE(err, nil)
+		t.Assert(count(nil), 0)
+		t.AssertNE(g.DB().Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+			return PushTx(tx, "", "x")
+		}), nil)
+
+		// 事务提交后按写入顺序转发
+		for _, list := range [][]string{{"1"}, {"2", "3"}} {
+			t.AssertNil(g.DB().Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+				for _, body := range list {
+					if err := PushTx(tx, "outbox", body); err != nil {
+						return err
+					}
+				}
+				return nil
+			}))
+		}
+		n, err := relayOutbox(ctx, cfg)
+		t.AssertNil(err)
+		t.Assert(n, 3)
+		t.Assert(count(g.Map{"status": outboxStatusSent}), 3)
+		list, err := Peek(ctx, "outbox", DefaultConsumerGroup, 10)
+		t.AssertNil(err)
+		t.Assert(len(list), 3)
+		for i, body := range []string{"1", "2", "3"} {
+			t.Assert(list[i].BodyString(), body)
+			msgId, err := g.DB().Model("zz_sys_outbox").Where("body", body).Value("msg_id")
+			t.AssertNil(err)
+			t.Assert(list[i].MsgId, msgId.String())
+		}
+
+		// 已被其他实例锁定的消息不转发，锁定超时后由其他实例接管
+		t.AssertNil(g.DB().Transaction(ctx, func(ctx context.Context, tx gdb.TX) error {
+			return PushTx(tx, "outbox", "4")
+		}))
+		id, err := g.DB().Model("zz_sys_outbox").Where("body", "4").Value("id")
+		t.AssertNil(err)
+		ok, err := claimOutbox(ctx, cfg, id.Int64())
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		ok, err = claimOutbox(ctx, cfg, id.Int64())
+		t.AssertNil(err)
+		t.Assert(ok, false)
+		n, err = relayOutbox(ctx, cfg)
+		t.AssertNil(err)
+		t.Assert(n, 0)
+
+		_, err = g.DB().Model("zz_sys_outbox").Where("id", id).Data(g.Map{"locked_at": gtime.Now().Add(-2 * time.Second)}).Update()
+		t.AssertNil(err)
+		n, err = relayOutbox(ctx, cfg)
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		t.Assert(count(g.Map{"status": outboxStatusSent}), 4)
+		ok, err = claimOutbox(ctx, cfg, id.Int64())
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		// 清理超过保留时间的已发送消息
+		_, err = g.DB().Model("zz_sys_outbox").WhereIn("body", g.Slice{"1", "2"}).Data(g.Map{"sent_at": gtime.Now().Add(-2 * time.Minute)}).Update()
+		t.AssertNil(err)
+		t.AssertNil(cleanOutbox(ctx, cfg))
+		t.Assert(count(nil), 2)
+		t.Assert(count(g.Map{"body": "1"}), 0)
+	})
+}
+
+// testIdempotentConsumer 按消息ID去重，处理消息体为 fail 的消息时失败
+type testIdempotentConsumer struct {
+	group   string
+	opts    *Idempotent
+	handled []string
+}
+
+func (c *testIdempotentConsumer) GetTopic() string { return "idempotent" }
+
+func (c *testIdempotentConsumer) Handle(ctx context.Context, mqMsg MqMsg) error {
+	c.handled = append(c.handled, mqMsg.MsgId)
+	if mqMsg.BodyString() == "fail" {
+		return errors.New("handle failed")
+	}
+	return nil
+}
+
+func (c *testIdempotentConsumer) Idempotent() *Idempotent { return c.opts }
+
+func TestIdempotent(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		SetConfig(Config{Driver: "memory", GroupName: "default"})
+		defer SetConfig(Config{Driver: "memory", GroupName: "default"})
+		ctx := gctx.New()
+		gdb.SetConfig(gdb.Config{
+			"default": gdb.ConfigGroup{
+				gdb.ConfigNode{Link: "sqlite::@file(" + filepath.Join(t.T.TempDir(), "cache.db") + ")"},
+			},
+		})
+		zcache.SetAdapter(ctx, &webSchema.CacheConfig{Adapter: "memory"})
+
+		c := &testIdempotentConsumer{opts: &Idempotent{TTL: 200 * time.Millisecond}}
+		t.Assert(idempotentOptions(c).Store, CacheProcessedStore{})
+		t.Assert(idempotentOptions(&testFailConsumer{}), nil)
+
+		// 同一批中重复的消息只处理一次，没有消息ID的消息不去重
+		consumerHandle(ctx, c, DefaultConsumerGroup, []MqMsg{
+			{Topic: "idempotent", MsgId: "a"},
+			{Topic: "idempotent", MsgId: "a"},
+			{Topic: "idempotent", MsgId: "b"},
+			{Topic: "idempotent"},
+			{Topic: "idempotent"},
+			{Topic: "idempotent", MsgId: "f", Body: []byte("fail")},
+		})
+		t.Assert(c.handled, []string{"a", "b", "", "", "f"})
+
+		// 已处理的消息再次投递时跳过，处理失败的消息再次处理，其他消费者组分别去重
+		c.handled = nil
+		consumerHandle(ctx, c, DefaultConsumerGroup, []MqMsg{
+			{Topic: "idempotent", MsgId: "a"},
+			{Topic: "idempotent", MsgId: "c"},
+			{Topic: "idempotent", MsgId: "f", Body: []byte("fail")},
+		})
+		t.Assert(c.handled, []string{"c", "f"})
+		c.handled = nil
+		consumerHandle(ctx, c, "audit", []MqMsg{{Topic: "idempotent", MsgId: "a"}})
+		t.Assert(c.handled, []string{"a"})
+
+		// 超过 TTL 后不再去重
+		time.Sleep(300 * time.Millisecond)
+		c.handled = nil
+		consumerHandle(ctx, c, DefaultConsumerGroup, []MqMsg{{Topic: "idempotent", MsgId: "a"}})
+		t.Assert(c.handled, []string{"a"})
+	})
+}
+
+func TestDBProcessedStore(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		gdb.SetConfig(gdb.Config{
+			"default": gdb.ConfigGroup{
+				gdb.ConfigNode{Link: "sqlite::@file(" + filepath.Join(t.T.TempDir(), "processed.db") + ")"},
+			},
+		})
+		_, err := g.DB().Exec(ctx, `
+		CREATE TABLE zz_sys_queue_processed (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			msg_key TEXT NOT NULL UNIQUE,
+			expired_at TEXT NOT NULL,
+			created_at TEXT
+		);`)
+		t.AssertNil(err)
+
+		var (
+			s    = DBProcessedStore{}
+			o    = &Idempotent{TTL: time.Hour, Store: s}
+			keys = []string{processedKey("t", "g", "a"), processedKey("t", "g", "b")}
+		)
+		processed, err := s.Processed(ctx, keys)
+		t.AssertNil(err)
+		t.Assert(len(processed), 0)
+
+		// 重复记录时更新过期时间
+		t.AssertNil(s.MarkProcessed(ctx, keys[:1], time.Hour))
+		t.AssertNil(s.MarkProcessed(ctx, keys[:1], time.Hour))
+		processed, err = s.Processed(ctx, keys)
+		t.AssertNil(err)
+		t.Assert(processed, map[string]bool{keys[0]: true})
+		list := o.skipProcessed(ctx, "t", "g", []MqMsg{{MsgId: "a"}, {MsgId: "b"}, {MsgId: "b"}})
+		t.Assert(len(list), 1)
+		t.Assert(list[0].MsgId, "b")
+
+		// 过期的记录不再去重，并在下次记录时删除
+		_, err = g.DB().Model("zz_sys_queue_processed").Where("msg_key", keys[0]).Data(g.Map{"expired_at": gtime.Now().Add(-time.Second)}).Update()
+		t.AssertNil(err)
+		processed, err = s.Processed(ctx, keys)
+		t.AssertNil(err)
+		t.Assert(len(processed), 0)
+
+		processedCleaner.Lock()
+		processedCleaner.cleanedAt = time.Time{}
+		processedCleaner.Unlock()
+		o.markProcessed(ctx, "t", "g", MqMsg{MsgId: "b"})
+		n, err := g.DB().Model("zz_sys_queue_processed").Count()
+		t.AssertNil(err)
+		t.Assert(n, 1)
+		processed, err = s.Processed(ctx, keys)
+		t.AssertNil(err)
+		t.Assert(processed, map[string]bool{keys[1]: true})
+	})
+}
@@ -0,0 +1,103 @@
+package zratelimit
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// memorySweepInterval 清理过期状态的间隔（毫秒）
+const memorySweepInterval = 60 * 1000
+
+// memoryStore 进程内的限流状态，只在当前实例有效
+type memoryStore struct {
+	sync.Mutex
+	buckets   map[string]*memoryBucket
+	windows   map[string]*memoryWindow
+	lastSweep int64
+}
+
+type memoryBucket struct {
+	tokens   float64
+	last     int64 // 上次补充令牌的时间
+	expireAt int64 // 令牌补满的时间，之后和不存在相同
+}
+
+type memoryWindow struct {
+	times    []int64 // 窗口内请求的时间，升序
+	expireAt int64   // 最晚一次请求离开窗口的时间
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		buckets: make(map[string]*memoryBucket),
+		windows: make(map[string]*memoryWindow),
+	}
+}
+
+func (s *memoryStore) takeToken(ctx context.Context, key string, rate float64, burst int, now int64) (bool, float64, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	if now > b.last {
+		b.tokens = math.Min(float64(burst), b.tokens+float64(now-b.last)*rate)
+		b.last = now
+	}
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.expireAt = now + int64(math.Ceil((float64(burst)-b.tokens)/rate))
+	return allowed, b.tokens, nil
+}
+
+func (s *memoryStore) slideWindow(ctx context.Context, key string, limit int, window int64, now int64) (bool, int, int64, int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.sweep(now)
+
+	w, ok := s.windows[key]
+	if !ok {
+		w = &memoryWindow{}
+		s.windows[key] = w
+	}
+	start := sort.Search(len(w.times), func(i int) bool {
+		return w.times[i] > now-window
+	})
+	w.times = w.times[start:]
+
+	allowed := len(w.times) < limit
+	if allowed {
+		w.times = append(w.times, now)
+		w.expireAt = now + window
+	}
+	if len(w.times) == 0 {
+		return allowed, 0, now, now, nil
+	}
+	return allowed, len(w.times), w.times[0], w.times[len(w.times)-1], nil
+}
+
+// sweep 定期删除已过期的状态，避免大量 key 一直占用内存
+func (s *memoryStore) sweep(now int64) {
+	if now-s.lastSweep < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.expireAt <= now {
+			delete(s.buckets, key)
+		}
+	}
+	for key, w := range s.windows {
+		if w.expireAt <= now {
+			delete(s.windows, key)
+		}
+	}
+}
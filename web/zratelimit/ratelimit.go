@@ -0,0 +1,135 @@
+package zratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zcache/layered"
+)
+
+// 限流算法
+const (
+	// AlgorithmTokenBucket 令牌桶，按固定速率补充令牌，允许突发 Burst 个请求
+	AlgorithmTokenBucket = "tokenBucket"
+	// AlgorithmSlidingWindow 滑动窗口，任意 Period 时间内最多 Limit 个请求
+	AlgorithmSlidingWindow = "slidingWindow"
+)
+
+// Rule 限流规则
+type Rule struct {
+	Algorithm string        // 限流算法，默认 AlgorithmSlidingWindow
+	Limit     int           // Period 内允许的请求数
+	Period    time.Duration // 统计周期
+	Burst     int           // 令牌桶容量，为 0 时与 Limit 一致
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed    bool          // 是否允许本次请求
+	Limit      int           // 配额，令牌桶为桶容量
+	Remaining  int           // 剩余配额
+	Reset      time.Duration // 配额完全恢复的剩余时间
+	RetryAfter time.Duration // 被限流时到下次允许请求的时间
+}
+
+// store 限流状态的存储，时间均为毫秒
+type store interface {
+	// takeToken 按 rate（每毫秒补充的令牌数）补充令牌后取出一个，返回是否取出和剩余令牌数
+	takeToken(ctx context.Context, key string, rate float64, burst int, now int64) (allowed bool, tokens float64, err error)
+	// slideWindow 窗口内请求数小于 limit 时记录本次请求，返回窗口内请求数和最早、最晚一次请求的时间
+	slideWindow(ctx context.Context, key string, limit int, window int64, now int64) (allowed bool, count int, oldest, newest int64, err error)
+}
+
+// Allow 对 key 执行一次限流检查，允许时消耗一个配额
+func Allow(ctx context.Context, key string, rule *Rule) (*Result, error) {
+	if rule.Limit <= 0 || rule.Period <= 0 {
+		return nil, gerror.Newf("限流规则无效：limit=%d period=%s", rule.Limit, rule.Period)
+	}
+	var (
+		s   = getStore()
+		now = time.Now().UnixMilli()
+	)
+	switch rule.Algorithm {
+	case AlgorithmTokenBucket:
+		burst := rule.Burst
+		if burst <= 0 {
+			burst = rule.Limit
+		}
+		rate := float64(rule.Limit) / float64(rule.Period.Milliseconds())
+		allowed, tokens, err := s.takeToken(ctx, key, rate, burst, now)
+		if err != nil {
+			return nil, gerror.Wrapf(err, "限流 %s 失败", key)
+		}
+		res := &Result{
+			Allowed:   allowed,
+			Limit:     burst,
+			Remaining: int(math.Floor(tokens)),
+			Reset:     tokenDuration(float64(burst)-tokens, rate),
+		}
+		if !allowed {
+			res.RetryAfter = tokenDuration(1-tokens, rate)
+		}
+		return res, nil
+
+	case AlgorithmSlidingWindow, "":
+		window := rule.Period.Milliseconds()
+		allowed, count, oldest, newest, err := s.slideWindow(ctx, key, rule.Limit, window, now)
+		if err != nil {
+			return nil, gerror.Wrapf(err, "限流 %s 失败", key)
+		}
+		res := &Result{
+			Allowed:   allowed,
+			Limit:     rule.Limit,
+			Remaining: max(rule.Limit-count, 0),
+			Reset:     time.Duration(max(newest+window-now, 0)) * time.Millisecond,
+		}
+		if !allowed {
+			res.RetryAfter = time.Duration(max(oldest+window-now, 0)) * time.Millisecond
+		}
+		return res, nil
+
+	default:
+		return nil, gerror.Newf("不支持的限流算法：%s", rule.Algorithm)
+	}
+}
+
+// tokenDuration 补充 n 个令牌需要的时间
+func tokenDuration(n, rate float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(n/rate)) * time.Millisecond
+}
+
+// current 和当前缓存适配器对应的存储，缓存适配器变化时重新创建
+var current struct {
+	sync.Mutex
+	adapter gcache.Adapter
+	store   store
+}
+
+// getStore redis 和二级缓存使用 Redis，多个实例共享配额，其他使用进程内的存储
+func getStore() store {
+	current.Lock()
+	defer current.Unlock()
+	adapter := zcache.Adapter()
+	if current.store != nil && current.adapter == adapter {
+		return current.store
+	}
+
+	switch adapter.(type) {
+	case *gcache.AdapterRedis, *layered.AdapterLayered:
+		current.store = newRedisStore(g.Redis())
+	default:
+		current.store = newMemoryStore()
+	}
+	current.adapter = adapter
+	return current.store
+}
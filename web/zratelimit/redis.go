@@ -0,0 +1,94 @@
+package zratelimit
+
+import (
+	"context"
+
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+const (
+	redisBucketPrefix = "zratelimit:bucket:"
+	redisWindowPrefix = "zratelimit:window:"
+
+	// 补充令牌后取出一个，令牌数以字符串返回，避免被转换为整数
+	// 状态在令牌补满后过期，过期和不存在相同
+	redisTokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate)
+	last = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', last)
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil((burst - tokens) / rate)))
+return {allowed, tostring(tokens)}`
+
+	// 有序集合保存窗口内每次请求的时间，先删除离开窗口的请求再计数
+	redisSlidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+return {allowed, count, oldest[2] or tostring(now), newest[2] or tostring(now)}`
+)
+
+// redisStore 使用 Redis 脚本保存限流状态，多个实例共享配额
+// 时间使用各实例的本地时间，实例间的时钟偏差会影响配额的恢复时间
+type redisStore struct {
+	redis *gredis.Redis
+}
+
+func newRedisStore(redis *gredis.Redis) *redisStore {
+	return &redisStore{redis: redis}
+}
+
+func (s *redisStore) takeToken(ctx context.Context, key string, rate float64, burst int, now int64) (bool, float64, error) {
+	v, err := s.redis.Eval(ctx, redisTokenBucketScript, 1, []string{redisBucketPrefix + key}, []interface{}{rate, burst, now})
+	if err != nil {
+		return false, 0, err
+	}
+	values := v.Strings()
+	if len(values) != 2 {
+		return false, 0, gerror.Newf("令牌桶脚本返回值无效：%v", values)
+	}
+	return values[0] == "1", gconv.Float64(values[1]), nil
+}
+
+func (s *redisStore) slideWindow(ctx context.Context, key string, limit int, window int64, now int64) (bool, int, int64, int64, error) {
+	member := gconv.String(now) + ":" + guid.S()
+	v, err := s.redis.Eval(ctx, redisSlidingWindowScript, 1, []string{redisWindowPrefix + key}, []interface{}{limit, window, now, member})
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	values := v.Strings()
+	if len(values) != 4 {
+		return false, 0, 0, 0, gerror.Newf("滑动窗口脚本返回值无效：%v", values)
+	}
+	return values[0] == "1", gconv.Int(values[1]), gconv.Int64(values[2]), gconv.Int64(values[3]), nil
+}
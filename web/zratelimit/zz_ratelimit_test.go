@@ -0,0 +1,97 @@
+package zratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// useStore 测试期间使用指定的存储
+func useStore(t *gtest.T, s store) {
+	current.Lock()
+	current.adapter, current.store = nil, s
+	current.Unlock()
+	t.T.Cleanup(func() {
+		current.Lock()
+		current.store = nil
+		current.Unlock()
+	})
+}
+
+// testAllow 各存储的公共用例
+func testAllow(t *gtest.T) {
+	ctx := context.Background()
+
+	// 滑动窗口：窗口内最多 limit 个请求，最早的请求离开窗口后恢复
+	window := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 2, Period: 500 * time.Millisecond}
+	for i := 1; i >= 0; i-- {
+		res, err := Allow(ctx, "sw", window)
+		t.AssertNil(err)
+		t.Assert(res.Allowed, true)
+		t.Assert(res.Remaining, i)
+	}
+	res, err := Allow(ctx, "sw", window)
+	t.AssertNil(err)
+	t.Assert(res.Allowed, false)
+	t.Assert(res.Limit, 2)
+	t.AssertGT(res.RetryAfter, 0)
+	t.AssertLE(res.RetryAfter, 500*time.Millisecond)
+	t.AssertGE(res.Reset, res.RetryAfter)
+
+	// 其他 key 不受影响
+	res, err = Allow(ctx, "sw2", window)
+	t.AssertNil(err)
+	t.Assert(res.Allowed, true)
+
+	time.Sleep(600 * time.Millisecond)
+	res, err = Allow(ctx, "sw", window)
+	t.AssertNil(err)
+	t.Assert(res.Allowed, true)
+	t.Assert(res.Remaining, 1)
+
+	// 令牌桶：允许突发 burst 个请求，之后按 limit/period 的速率恢复
+	bucket := &Rule{Algorithm: AlgorithmTokenBucket, Limit: 10, Period: time.Second, Burst: 3}
+	for i := 2; i >= 0; i-- {
+		res, err = Allow(ctx, "tb", bucket)
+		t.AssertNil(err)
+		t.Assert(res.Allowed, true)
+		t.Assert(res.Remaining, i)
+	}
+	res, err = Allow(ctx, "tb", bucket)
+	t.AssertNil(err)
+	t.Assert(res.Allowed, false)
+	t.Assert(res.Limit, 3)
+	t.AssertGT(res.RetryAfter, 0)
+	t.AssertLE(res.RetryAfter, 100*time.Millisecond)
+	t.AssertLE(res.Reset, 300*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	res, err = Allow(ctx, "tb", bucket)
+	t.AssertNil(err)
+	t.Assert(res.Allowed, true)
+
+	_, err = Allow(ctx, "bad", &Rule{Algorithm: "fixed", Limit: 1, Period: time.Second})
+	t.AssertNE(err, nil)
+	_, err = Allow(ctx, "bad", &Rule{Limit: 0, Period: time.Second})
+	t.AssertNE(err, nil)
+}
+
+func TestAllow(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		useStore(t, newMemoryStore())
+		testAllow(t)
+	})
+	gtest.C(t, func(t *gtest.T) {
+		redis, err := gredis.New(&gredis.Config{Address: miniredis.RunT(t.T).Addr()})
+		t.AssertNil(err)
+		defer redis.Close(context.Background())
+		useStore(t, newRedisStore(redis))
+		testAllow(t)
+	})
+}
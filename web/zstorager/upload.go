@@ -19,6 +19,7 @@ import (
 	"github.com/denghuo98/zzframe/internal/model/entity"
 	"github.com/denghuo98/zzframe/web/zcache"
 	"github.com/denghuo98/zzframe/web/zcontext"
+	"github.com/denghuo98/zzframe/web/zlock"
 	"github.com/denghuo98/zzframe/web/zutils"
 	"github.com/denghuo98/zzframe/web/zvalidate"
 	"github.com/denghuo98/zzframe/zconsts"
@@ -365,6 +366,17 @@ func DelMultipartProgress(ctx context.Context, in *MultipartProgress) (err error
 
 // UploadPart 上传分片
 func UploadPart(ctx context.Context, in *UploadPartParams) (res *UploadPartModel, err error) {
+	// 同一个上传的分片依次处理，避免并发更新进度时丢失分片或重复合并
+	lease, err := zlock.Lock(ctx, fmt.Sprintf("%v:%v", zconsts.LockMultipartUpload, in.UploadId), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := lease.Unlock(ctx); unlockErr != nil {
+			g.Log().Warningf(ctx, "unlock multipart upload %v err:%+v", in.UploadId, unlockErr)
+		}
+	}()
+
 	in.mp, err = GetMultipartProgress(ctx, in.UploadId)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,130 @@
+package ztoken
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zcache/layered"
+	"github.com/denghuo98/zzframe/web/zlock"
+	"github.com/denghuo98/zzframe/zconsts"
+)
+
+// 令牌族索引，记录令牌族ID和它的过期时间，写入时清理已过期的令牌族
+// redis 和二级缓存使用有序集合，单条命令完成更新；其他适配器在缓存中保存 ID -> 过期时间，更新时加锁
+const (
+	// 清理已过期的成员后加入，索引在最晚过期的成员过期后删除
+	redisIndexAddScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+redis.call('EXPIREAT', KEYS[1], tonumber(last[2]))
+return 1`
+)
+
+// indexLockTTL 更新索引时锁的有效期
+const indexLockTTL = 10 * time.Second
+
+// indexAdd 将令牌族加入索引，已存在时更新过期时间
+func indexAdd(ctx g.Ctx, key, familyId string, expireAt int64) error {
+	now := gtime.Timestamp()
+	if useRedisIndex() {
+		_, err := g.Redis().Eval(ctx, redisIndexAddScript, 1, []string{key}, []interface{}{familyId, expireAt, now})
+		return err
+	}
+	return updateIndex(ctx, key, func(members map[string]int64) {
+		members[familyId] = expireAt
+	})
+}
+
+// indexRemove 从索引中删除令牌族
+func indexRemove(ctx g.Ctx, key, familyId string) error {
+	if useRedisIndex() {
+		_, err := g.Redis().Do(ctx, "ZREM", key, familyId)
+		return err
+	}
+	return updateIndex(ctx, key, func(members map[string]int64) {
+		delete(members, familyId)
+	})
+}
+
+// indexMembers 索引中未过期的令牌族
+func indexMembers(ctx g.Ctx, key string) ([]string, error) {
+	now := gtime.Timestamp()
+	if useRedisIndex() {
+		v, err := g.Redis().Do(ctx, "ZRANGEBYSCORE", key, fmt.Sprintf("(%d", now), "+inf")
+		if err != nil {
+			return nil, err
+		}
+		return v.Strings(), nil
+	}
+
+	members, err := getIndex(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(members))
+	for id, expireAt := range members {
+		if expireAt > now {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// updateIndex 持有索引的锁时读取、修改并写回，同时清理已过期的令牌族
+func updateIndex(ctx g.Ctx, key string, f func(members map[string]int64)) error {
+	lockKey := fmt.Sprintf("%v:%v", zconsts.LockTokenIndex, key)
+	return zlock.Do(ctx, lockKey, indexLockTTL, func(ctx context.Context) error {
+		members, err := getIndex(ctx, key)
+		if err != nil {
+			return err
+		}
+		f(members)
+
+		var (
+			now  = gtime.Timestamp()
+			last int64
+		)
+		for id, expireAt := range members {
+			if expireAt <= now {
+				delete(members, id)
+				continue
+			}
+			last = max(last, expireAt)
+		}
+		if len(members) == 0 {
+			_, err = zcache.Instance().Remove(ctx, key)
+			return err
+		}
+		return zcache.Instance().Set(ctx, key, members, time.Duration(last-now)*time.Second)
+	})
+}
+
+func getIndex(ctx context.Context, key string) (map[string]int64, error) {
+	members := make(map[string]int64)
+	v, err := zcache.Instance().Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil && !v.IsEmpty() {
+		// 无法解析的旧索引直接丢弃，令牌族本身仍然有效，下次轮换时重新加入
+		_ = v.Scan(&members)
+	}
+	return members, nil
+}
+
+// useRedisIndex redis 和二级缓存直接在 Redis 中保存索引
+func useRedisIndex() bool {
+	switch zcache.Adapter().(type) {
+	case *gcache.AdapterRedis, *layered.AdapterLayered:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,396 @@
+package ztoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/golang-jwt/jwt/v5"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// DefaultKid 未配置签名密钥时，使用 secretKey 生成的默认密钥ID
+// 未配置 keys 时，未携带 kid 的令牌也使用该密钥验签
+const DefaultKid = "default"
+
+// DefaultSecretKey 未配置 secretKey 时使用的默认密钥，配置了 keys 时不会用它生成默认密钥
+const DefaultSecretKey = "zzframe"
+
+// SigningKey 令牌签名密钥
+type SigningKey struct {
+	Kid       string            // 密钥ID，写入令牌头部的 kid
+	Method    jwt.SigningMethod // 签名算法
+	Private   interface{}       // 签名密钥，为空时只能用于验签
+	Public    interface{}       // 验签密钥
+	RetiredAt int64             // 停用时间，停用后在宽限期内仍可验签
+}
+
+// Keyring 令牌签名密钥环
+// 同一时刻只有一个密钥用于签名，其余密钥仅用于验签，已停用的密钥超过宽限期后不再可用
+type Keyring struct {
+	sync.RWMutex
+	active string                 // 当前签名密钥ID
+	grace  int64                  // 停用密钥的宽限期，单位秒
+	keys   map[string]*SigningKey // 全部密钥
+	legacy bool                   // 是否接受未携带 kid 的令牌，只在未配置 keys 时接受
+}
+
+// JWK 公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"           dc:"密钥类型：RSA/EC/OKP"`
+	Kid string `json:"kid"           dc:"密钥ID"`
+	Use string `json:"use"           dc:"用途，固定为sig"`
+	Alg string `json:"alg"           dc:"签名算法"`
+	N   string `json:"n,omitempty"   dc:"RSA模数"`
+	E   string `json:"e,omitempty"   dc:"RSA公钥指数"`
+	Crv string `json:"crv,omitempty" dc:"曲线名称"`
+	X   string `json:"x,omitempty"   dc:"公钥X坐标，Ed25519为公钥"`
+	Y   string `json:"y,omitempty"   dc:"公钥Y坐标"`
+}
+
+// JWKS 公钥集合
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+var keyring = &Keyring{keys: make(map[string]*SigningKey)}
+
+// GetKeyring 获取当前使用的密钥环
+func GetKeyring() *Keyring {
+	return keyring
+}
+
+// SetKeyring 替换当前使用的密钥环
+func SetKeyring(k *Keyring) {
+	keyring = k
+}
+
+// NewKeyring 根据令牌配置创建密钥环
+// 未配置 keys 时使用 secretKey 生成 HS256 默认密钥，保持与旧版本签发的令牌兼容
+// 配置了 keys 时只有显式配置的 secretKey 才会生成默认密钥，并且不再接受未携带 kid 的令牌
+func NewKeyring(c *webSchema.TokenConfig) (*Keyring, error) {
+	k := &Keyring{
+		grace:  c.KeyGrace,
+		keys:   make(map[string]*SigningKey),
+		legacy: len(c.Keys) == 0,
+	}
+
+	// 默认宽限期与访问令牌有效期一致，保证旧密钥签发的令牌在过期前可用
+	if k.grace <= 0 {
+		k.grace = c.Expires
+	}
+
+	// 公开的默认密钥可以被任何人用来伪造令牌，配置了 keys 时不使用
+	if c.SecretKey != "" && (k.legacy || c.SecretKey != DefaultSecretKey) {
+		k.keys[DefaultKid] = &SigningKey{
+			Kid:     DefaultKid,
+			Method:  jwt.SigningMethodHS256,
+			Private: []byte(c.SecretKey),
+			Public:  []byte(c.SecretKey),
+		}
+	}
+
+	for _, kc := range c.Keys {
+		key, err := parseSigningKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[key.Kid] = key
+	}
+
+	active := c.ActiveKid
+	if active == "" {
+		if len(c.Keys) > 0 {
+			active = c.Keys[0].Kid
+		} else {
+			active = DefaultKid
+		}
+	}
+
+	key, ok := k.keys[active]
+	if !ok {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 不存在", active)
+	}
+	if key.Private == nil {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 未配置私钥，不能用于签名", active)
+	}
+	if key.RetiredAt > 0 {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 已停用，不能用于签名", active)
+	}
+	k.active = active
+	return k, nil
+}
+
+// Add 添加一个密钥，已存在相同 kid 时覆盖
+func (k *Keyring) Add(key *SigningKey) error {
+	if key.Kid == "" {
+		return gerror.New("密钥ID不能为空")
+	}
+	if key.Method == nil || key.Public == nil {
+		return gerror.Newf("密钥 [%v] 缺少签名算法或验签密钥", key.Kid)
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	k.keys[key.Kid] = key
+	return nil
+}
+
+// Rotate 轮换签名密钥，原签名密钥转为停用状态，宽限期内仍可验签
+func (k *Keyring) Rotate(key *SigningKey) error {
+	if key.Private == nil {
+		return gerror.Newf("密钥 [%v] 未配置私钥，不能用于签名", key.Kid)
+	}
+	if err := k.Add(key); err != nil {
+		return err
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	if prev, ok := k.keys[k.active]; ok && prev.Kid != key.Kid {
+		prev.RetiredAt = gtime.Timestamp()
+	}
+	key.RetiredAt = 0
+	k.active = key.Kid
+	return nil
+}
+
+// Remove 删除密钥，不能删除当前签名密钥
+func (k *Keyring) Remove(kid string) error {
+	k.Lock()
+	defer k.Unlock()
+	if kid == k.active {
+		return gerror.Newf("密钥 [%v] 正在用于签名，不能删除", kid)
+	}
+	delete(k.keys, kid)
+	return nil
+}
+
+// Prune 清理已超过宽限期的停用密钥
+func (k *Keyring) Prune() {
+	k.Lock()
+	defer k.Unlock()
+	for kid, key := range k.keys {
+		if !k.usable(key) {
+			delete(k.keys, kid)
+		}
+	}
+}
+
+// Active 获取当前签名密钥
+func (k *Keyring) Active() (*SigningKey, error) {
+	k.RLock()
+	defer k.RUnlock()
+	key, ok := k.keys[k.active]
+	if !ok || key.Private == nil {
+		return nil, gerror.New("未配置令牌签名密钥")
+	}
+	return key, nil
+}
+
+// Lookup 根据 kid 获取可用于验签的密钥
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	k.RLock()
+	defer k.RUnlock()
+
+	if kid == "" {
+		if !k.legacy {
+			return nil, false
+		}
+		kid = DefaultKid
+	}
+
+	key, ok := k.keys[kid]
+	if !ok || !k.usable(key) {
+		return nil, false
+	}
+	return key, true
+}
+
+// JWKS 导出全部可用的非对称公钥，HMAC 密钥不会导出
+func (k *Keyring) JWKS() *JWKS {
+	k.RLock()
+	defer k.RUnlock()
+
+	set := &JWKS{Keys: make([]*JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		if !k.usable(key) {
+			continue
+		}
+		if jwk := toJWK(key); jwk != nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// usable 密钥是否仍可用于验签
+func (k *Keyring) usable(key *SigningKey) bool {
+	if key.RetiredAt <= 0 {
+		return true
+	}
+	return key.RetiredAt+k.grace > gtime.Timestamp()
+}
+
+// sign 使用当前签名密钥签发令牌
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	key, err := k.Active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.Private)
+}
+
+// keyFunc 根据令牌头部的 kid 选择验签密钥，签名算法必须与密钥一致
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.Lookup(kid)
+	if !ok {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 不存在或已停用", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, gerror.Newf("令牌签名算法 [%v] 与密钥 [%v] 不匹配", token.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// parseSigningKey 解析配置中的签名密钥
+func parseSigningKey(c *webSchema.TokenKeyConfig) (key *SigningKey, err error) {
+	if c.Kid == "" {
+		return nil, gerror.New("令牌签名密钥ID不能为空")
+	}
+
+	method := jwt.GetSigningMethod(c.Algorithm)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 不支持的签名算法：%v", c.Kid, c.Algorithm)
+	}
+
+	key = &SigningKey{Kid: c.Kid, Method: method}
+	if c.RetiredAt != nil {
+		key.RetiredAt = c.RetiredAt.Unix()
+	}
+
+	var (
+		privatePem = readPem(c.PrivateKey)
+		publicPem  = readPem(c.PublicKey)
+	)
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if c.Secret == "" {
+			return nil, gerror.Newf("令牌签名密钥 [%v] 未配置secret", c.Kid)
+		}
+		key.Private, key.Public = []byte(c.Secret), []byte(c.Secret)
+		return key, nil
+
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if len(privatePem) > 0 {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePem)
+			if err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 私钥失败", c.Kid)
+			}
+			key.Private, key.Public = private, &private.PublicKey
+		}
+		if len(publicPem) > 0 {
+			if key.Public, err = jwt.ParseRSAPublicKeyFromPEM(publicPem); err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 公钥失败", c.Kid)
+			}
+		}
+
+	case *jwt.SigningMethodECDSA:
+		if len(privatePem) > 0 {
+			private, err := jwt.ParseECPrivateKeyFromPEM(privatePem)
+			if err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 私钥失败", c.Kid)
+			}
+			key.Private, key.Public = private, &private.PublicKey
+		}
+		if len(publicPem) > 0 {
+			if key.Public, err = jwt.ParseECPublicKeyFromPEM(publicPem); err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 公钥失败", c.Kid)
+			}
+		}
+		// 曲线必须与算法一致，如 ES256 只能使用 P-256
+		if public, ok := key.Public.(*ecdsa.PublicKey); ok && public.Curve.Params().BitSize != method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil, gerror.Newf("令牌签名密钥 [%v] 的曲线 %v 与签名算法 %v 不匹配", c.Kid, public.Curve.Params().Name, c.Algorithm)
+		}
+
+	case *jwt.SigningMethodEd25519:
+		if len(privatePem) > 0 {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(privatePem)
+			if err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 私钥失败", c.Kid)
+			}
+			key.Private, key.Public = private, private.(crypto.Signer).Public()
+		}
+		if len(publicPem) > 0 {
+			if key.Public, err = jwt.ParseEdPublicKeyFromPEM(publicPem); err != nil {
+				return nil, gerror.Wrapf(err, "解析令牌签名密钥 [%v] 公钥失败", c.Kid)
+			}
+		}
+	}
+
+	if key.Public == nil {
+		return nil, gerror.Newf("令牌签名密钥 [%v] 未配置私钥或公钥", c.Kid)
+	}
+	return key, nil
+}
+
+// readPem 读取PEM内容，支持直接配置内容或文件路径
+func readPem(value string) []byte {
+	if value == "" {
+		return nil
+	}
+	if !strings.Contains(value, "-----BEGIN") && gfile.Exists(value) {
+		return gfile.GetBytes(value)
+	}
+	return []byte(value)
+}
+
+// toJWK 将非对称公钥转换为JWK
+func toJWK(key *SigningKey) *JWK {
+	jwk := &JWK{
+		Kid: key.Kid,
+		Use: "sig",
+		Alg: key.Method.Alg(),
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64Url(pub.N.Bytes())
+		jwk.E = base64Url(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64Url(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64Url(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64Url(pub)
+	default:
+		return nil
+	}
+	return jwk
+}
+
+func base64Url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
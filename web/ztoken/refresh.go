@@ -0,0 +1,257 @@
+package ztoken
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/grand"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zlock"
+	"github.com/denghuo98/zzframe/zconsts"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// Family 刷新令牌族
+// 一次登录以及之后每次轮换签发的令牌都属于同一族，族内始终只有一个有效的刷新令牌
+type Family struct {
+	Id           string              `json:"id"`  // 令牌族ID
+	Identity     *webSchema.Identity `json:"idt"` // 登录身份
+	TokenKey     string              `json:"tk"`  // 当前访问令牌缓存key
+	RefreshKey   string              `json:"rk"`  // 当前有效的刷新令牌缓存key
+	RefreshCount int64               `json:"rc"`  // 轮换次数
+	ExpireAt     int64               `json:"exp"` // 当前刷新令牌过期时间
+	LoginIp      string              `json:"ip"`  // 登录IP
+	UserAgent    string              `json:"ua"`  // 登录UA
+	CreatedAt    int64               `json:"ca"`  // 登录时间
+	LastSeen     int64               `json:"ls"`  // 最后活跃时间
+}
+
+var (
+	errorRefresh      = gerror.New("刷新令牌已失效，请重新登录！")
+	errorRefreshReuse = gerror.New("刷新令牌已被使用，为保障账号安全已注销全部登录，请重新登录！")
+)
+
+// familyLockTTL 令牌族锁的有效期，持有期间自动续期
+const familyLockTTL = 10 * time.Second
+
+// GetRefreshKey 刷新令牌缓存key
+func GetRefreshKey(authKey string) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheTokenRefresh, authKey)
+}
+
+// GetFamilyKey 刷新令牌族缓存key
+func GetFamilyKey(familyId string) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheTokenFamily, familyId)
+}
+
+// withFamily 持有令牌族的锁时执行 f，多实例部署时同一令牌族的轮换和注销也是串行的
+func withFamily(ctx g.Ctx, familyId string, f func() error) error {
+	key := fmt.Sprintf("%v:%v", zconsts.LockTokenFamily, familyId)
+	return zlock.Do(ctx, key, familyLockTTL, func(ctx context.Context) error {
+		return f()
+	})
+}
+
+// GetMemberKey 用户令牌族索引key
+func GetMemberKey(userId int64) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheTokenMember, userId)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对
+// 每次使用都会轮换刷新令牌，已轮换的刷新令牌再次出现时视为被盗用，注销该用户的全部登录
+func Refresh(ctx g.Ctx, refreshToken string) (pair *TokenPair, user *webSchema.Identity, err error) {
+	if refreshToken == "" {
+		return nil, nil, errorRefresh
+	}
+
+	refreshKey := GetRefreshKey(GetAuthKey(refreshToken))
+	familyId, err := zcache.Instance().Get(ctx, refreshKey)
+	if err != nil || familyId == nil || familyId.IsEmpty() {
+		g.Log().Debugf(ctx, "refreshKey get err:%+v", err)
+		return nil, nil, errorRefresh
+	}
+
+	var reuse *Family
+	err = withFamily(ctx, familyId.String(), func() (err error) {
+		// 加锁后读取令牌族，同一刷新令牌的并发请求只有一个能通过比较
+		fa, err := getFamily(ctx, familyId.String())
+		if err != nil || fa == nil {
+			g.Log().Debugf(ctx, "family get err:%+v", err)
+			return errorRefresh
+		}
+
+		// 刷新令牌已被轮换过，说明旧令牌被重复使用
+		if fa.RefreshKey != refreshKey {
+			reuse = fa
+			return errorRefreshReuse
+		}
+
+		if fa.ExpireAt < gtime.Now().Unix() {
+			return errorRefresh
+		}
+
+		// 轮换次数已达上限，0 和 -1 均为不限制
+		if config.MaxRefreshTimes > 0 && fa.RefreshCount >= config.MaxRefreshTimes {
+			_ = deleteFamily(ctx, fa)
+			return errorRefresh
+		}
+
+		// 旧的访问令牌随轮换一起失效
+		if _, err = zcache.Instance().Remove(ctx, fa.TokenKey); err != nil {
+			return err
+		}
+
+		fa.RefreshCount += 1
+		if pair, err = issue(ctx, fa); err != nil {
+			return err
+		}
+		user = fa.Identity
+		return nil
+	})
+
+	// 注销时需要逐个获取令牌族的锁，在释放当前令牌族的锁之后执行
+	if reuse != nil {
+		g.Log().Warningf(ctx, "refresh token reuse detected, userId:%v, family:%v", reuse.Identity.Id, reuse.Id)
+		if revokeErr := RevokeMember(ctx, reuse.Identity.Id); revokeErr != nil {
+			g.Log().Errorf(ctx, "revoke member sessions err:%+v", revokeErr)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+// RevokeMember 注销用户的全部登录会话
+func RevokeMember(ctx g.Ctx, userId int64) (err error) {
+	memberKey := GetMemberKey(userId)
+	families, err := indexMembers(ctx, memberKey)
+	if err != nil {
+		return
+	}
+	for _, familyId := range families {
+		if err = RevokeSession(ctx, familyId); err != nil {
+			return
+		}
+	}
+
+	_, err = zcache.Instance().Remove(ctx, memberKey)
+	return
+}
+
+// revokeMemberApp 注销用户在指定应用下的全部登录会话
+func revokeMemberApp(ctx g.Ctx, userId int64, app string) (err error) {
+	families, err := indexMembers(ctx, GetMemberKey(userId))
+	if err != nil {
+		return
+	}
+	for _, familyId := range families {
+		fa, err := getFamily(ctx, familyId)
+		if err != nil {
+			return err
+		}
+		if fa == nil || fa.Identity.App != app {
+			continue
+		}
+		if err = RevokeSession(ctx, familyId); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// RevokeSession 注销一个登录会话
+func RevokeSession(ctx g.Ctx, familyId string) (err error) {
+	return withFamily(ctx, familyId, func() error {
+		fa, err := getFamily(ctx, familyId)
+		if err != nil || fa == nil {
+			return nil
+		}
+		return deleteFamily(ctx, fa)
+	})
+}
+
+// saveFamily 为令牌族生成新的刷新令牌并保存
+func saveFamily(ctx g.Ctx, fa *Family, tokenKey string) (refreshToken string, err error) {
+	var (
+		now        = gtime.Now()
+		duration   = time.Second * gconv.Duration(config.RefreshExpires)
+		refreshKey string
+	)
+
+	refreshToken = grand.S(48)
+	refreshKey = GetRefreshKey(GetAuthKey(refreshToken))
+
+	fa.TokenKey = tokenKey
+	fa.RefreshKey = refreshKey
+	fa.ExpireAt = now.Unix() + config.RefreshExpires
+	fa.LastSeen = now.Unix()
+
+	// 已轮换的刷新令牌仍然指向令牌族，直到自然过期，用于识别重复使用
+	if err = zcache.Instance().Set(ctx, refreshKey, fa.Id, duration); err != nil {
+		return "", err
+	}
+
+	if err = zcache.Instance().Set(ctx, GetFamilyKey(fa.Id), fa, duration); err != nil {
+		return "", err
+	}
+
+	// 加入用户和全部会话的索引，索引中的过期时间随轮换更新
+	if err = indexAdd(ctx, GetMemberKey(fa.Identity.Id), fa.Id, fa.ExpireAt); err != nil {
+		return "", err
+	}
+	if err = indexAdd(ctx, zconsts.CacheTokenSession, fa.Id, fa.ExpireAt); err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// getFamily 获取令牌族
+func getFamily(ctx g.Ctx, familyId string) (fa *Family, err error) {
+	v, err := zcache.Instance().Get(ctx, GetFamilyKey(familyId))
+	if err != nil || v == nil || v.IsEmpty() {
+		return nil, err
+	}
+	if err = v.Scan(&fa); err != nil {
+		return nil, err
+	}
+	if fa == nil || fa.Identity == nil {
+		return nil, nil
+	}
+	return fa, nil
+}
+
+// deleteFamily 删除令牌族以及它当前的访问令牌
+func deleteFamily(ctx g.Ctx, fa *Family) (err error) {
+	_, err = zcache.Instance().Remove(ctx,
+		fa.TokenKey,
+		fa.RefreshKey,
+		GetFamilyKey(fa.Id),
+	)
+	if err != nil {
+		return
+	}
+
+	if err = indexRemove(ctx, GetMemberKey(fa.Identity.Id), fa.Id); err != nil {
+		return
+	}
+	if err = indexRemove(ctx, zconsts.CacheTokenSession, fa.Id); err != nil {
+		return
+	}
+
+	// 身份绑定已指向更新的登录时保留，避免注销旧会话时把新会话一起注销
+	if !config.MultiLogin {
+		bindKey := GetBindKey(fa.Identity.App, fa.Identity.Id)
+		v, _ := zcache.Instance().Get(ctx, bindKey)
+		if v != nil && v.String() == fa.TokenKey {
+			_, err = zcache.Instance().Remove(ctx, bindKey)
+		}
+	}
+	return
+}
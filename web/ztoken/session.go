@@ -0,0 +1,93 @@
+package ztoken
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zlock"
+	"github.com/denghuo98/zzframe/zconsts"
+)
+
+// sessionTouchInterval 会话最后活跃时间的更新间隔，单位秒，避免每个请求都写缓存
+const sessionTouchInterval = 60
+
+// GetSession 获取登录会话，会话即一次登录产生的令牌族
+func GetSession(ctx g.Ctx, id string) (*Family, error) {
+	return getFamily(ctx, id)
+}
+
+// GetSessions 获取全部有效的登录会话，按登录时间倒序
+func GetSessions(ctx g.Ctx) (list []*Family, err error) {
+	ids, err := indexMembers(ctx, zconsts.CacheTokenSession)
+	if err != nil {
+		return
+	}
+	return loadSessions(ctx, ids)
+}
+
+// GetMemberSessions 获取用户的全部有效登录会话，按登录时间倒序
+func GetMemberSessions(ctx g.Ctx, userId int64) (list []*Family, err error) {
+	ids, err := indexMembers(ctx, GetMemberKey(userId))
+	if err != nil {
+		return
+	}
+	return loadSessions(ctx, ids)
+}
+
+// loadSessions 批量读取令牌族，跳过已失效的令牌族
+func loadSessions(ctx g.Ctx, ids []string) (list []*Family, err error) {
+	list = make([]*Family, 0, len(ids))
+	for _, id := range ids {
+		fa, err := getFamily(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if fa == nil {
+			continue
+		}
+		list = append(list, fa)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt > list[j].CreatedAt
+	})
+	return
+}
+
+// touchSession 更新会话最后活跃时间
+func touchSession(ctx g.Ctx, familyId string) {
+	if familyId == "" {
+		return
+	}
+
+	now := gtime.Timestamp()
+	fa, _ := getFamily(ctx, familyId)
+	if fa == nil || now-fa.LastSeen < sessionTouchInterval {
+		return
+	}
+
+	// 令牌族正在轮换或注销时跳过，下个请求再更新
+	lease, err := zlock.TryLock(ctx, fmt.Sprintf("%v:%v", zconsts.LockTokenFamily, familyId), familyLockTTL)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = lease.Unlock(ctx)
+	}()
+
+	// 加锁后重新读取，避免覆盖并发轮换的结果
+	fa, _ = getFamily(ctx, familyId)
+	if fa == nil || fa.ExpireAt <= now {
+		return
+	}
+
+	fa.LastSeen = now
+	if err = zcache.Instance().Set(ctx, GetFamilyKey(fa.Id), fa, time.Duration(fa.ExpireAt-now)*time.Second); err != nil {
+		g.Log().Warningf(ctx, "touch session err:%+v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zlocation"
 	"github.com/denghuo98/zzframe/zconsts"
 	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
 	"github.com/gogf/gf/v2/crypto/gmd5"
@@ -14,20 +15,28 @@ import (
 	"github.com/gogf/gf/v2/os/gtime"
 	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const SecretKey = "zzframe"
-
 type Claims struct {
 	*webSchema.Identity
 	jwt.RegisteredClaims
 }
 
 type Token struct {
-	ExpireAt     int64 `json:"exp"` // token过期时间
-	RefreshAt    int64 `json:"ra"`  // 刷新时间
-	RefreshCount int64 `json:"rc"`  // 刷新次数
+	ExpireAt     int64  `json:"exp"` // token过期时间
+	RefreshAt    int64  `json:"ra"`  // 刷新时间
+	RefreshCount int64  `json:"rc"`  // 刷新次数
+	Family       string `json:"fa"`  // 所属刷新令牌族
+}
+
+// TokenPair 登录签发的令牌对
+type TokenPair struct {
+	AccessToken    string // 访问令牌
+	Expires        int64  // 访问令牌有效期，单位秒
+	RefreshToken   string // 刷新令牌
+	RefreshExpires int64  // 刷新令牌有效期，单位秒
 }
 
 var (
@@ -36,8 +45,15 @@ var (
 	errorMultiLogin = gerror.New("账号已在其他地方登录，如非本人操作请及时修改登录密码！")
 )
 
-func SetConfig(c *webSchema.TokenConfig) {
+// SetConfig 设置令牌配置，同时根据配置重建签名密钥环
+func SetConfig(c *webSchema.TokenConfig) error {
+	k, err := NewKeyring(c)
+	if err != nil {
+		return err
+	}
 	config = c
+	SetKeyring(k)
+	return nil
 }
 
 func GetConfig() *webSchema.TokenConfig {
@@ -73,9 +89,7 @@ func GetAuthorization(r *ghttp.Request) string {
 
 // parseToken 解析jwt令牌
 func parseToken(ctx g.Ctx, header string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(header, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(header, &Claims{}, GetKeyring().keyFunc)
 
 	if err != nil {
 		g.Log().Debugf(ctx, "parseToken err:%+v", err)
@@ -177,44 +191,7 @@ func ParseLoginUser(r *ghttp.Request) (user *webSchema.Identity, err error) {
 		}
 	}
 
-	// 自动刷新token有效期
-	refreshToken := func() {
-		// 未开启自动刷新
-		if !config.AutoRefresh {
-			return
-		}
-
-		// 刷新次数已达上限
-		if config.MaxRefreshTimes != -1 && token.RefreshCount >= config.MaxRefreshTimes {
-			return
-		}
-
-		// 未达到刷新间隔
-		if gtime.New(token.RefreshAt).Unix()+config.RefreshInterval > now.Unix() {
-			return
-		}
-
-		// 刷新有效期
-		token.ExpireAt = now.Unix() + config.Expires
-		token.RefreshAt = now.Unix()
-		token.RefreshCount += 1
-
-		duration := time.Second * gconv.Duration(config.Expires)
-
-		if err = zcache.Instance().Set(ctx, tokenKey, token, duration); err != nil {
-			return
-		}
-
-		if err = zcache.Instance().Set(ctx, bindKey, tokenKey, duration); err != nil {
-			return
-		}
-	}
-
-	g.Go(ctx, func(ctx g.Ctx) {
-		refreshToken()
-	}, func(ctx g.Ctx, exception error) {
-		g.Log().Errorf(ctx, "refreshToken err:%+v", exception)
-	})
+	touchSession(ctx, token.Family)
 
 	user = claims.Identity
 	return
@@ -243,11 +220,22 @@ func Logout(r *ghttp.Request) (err error) {
 		// 认证key
 		authKey = GetAuthKey(header)
 		// 登录token
-		tokenKey = GetTokenKey("web", authKey)
+		tokenKey = GetTokenKey(claims.App, authKey)
 		// 身份绑定
-		bindKey = GetBindKey("web", claims.Id)
+		bindKey = GetBindKey(claims.App, claims.Id)
 	)
 
+	// 连同刷新令牌一起注销
+	tk, _ := zcache.Instance().Get(ctx, tokenKey)
+	if tk != nil && !tk.IsEmpty() {
+		var token *Token
+		if err = tk.Scan(&token); err == nil && token != nil && token.Family != "" {
+			if err = RevokeSession(ctx, token.Family); err != nil {
+				return
+			}
+		}
+	}
+
 	// 删除token
 	if _, err = zcache.Instance().Remove(ctx, tokenKey); err != nil {
 		return
@@ -261,20 +249,51 @@ func Logout(r *ghttp.Request) (err error) {
 	return
 }
 
-// Login 登录
-func Login(ctx g.Ctx, user *webSchema.Identity) (string, int64, error) {
+// Login 登录，签发访问令牌和刷新令牌
+func Login(ctx g.Ctx, user *webSchema.Identity) (*TokenPair, error) {
+	fa := &Family{
+		Id:        guid.S(),
+		Identity:  user,
+		CreatedAt: gtime.Timestamp(),
+	}
+
+	if r := g.RequestFromCtx(ctx); r != nil {
+		fa.LoginIp = zlocation.GetClientIp(r)
+		fa.UserAgent = r.UserAgent()
+	}
+
+	// 不允许多端登录时注销同一应用下的其他登录，避免旧设备通过刷新令牌重新抢回身份绑定
+	if !config.MultiLogin {
+		if err := revokeMemberApp(ctx, user.Id, user.App); err != nil {
+			return nil, err
+		}
+	}
+
+	return issue(ctx, fa)
+}
+
+// issue 为令牌族签发新的访问令牌和刷新令牌
+func issue(ctx g.Ctx, fa *Family) (*TokenPair, error) {
+	var (
+		now  = gtime.Now()
+		user = fa.Identity
+	)
+
 	claims := Claims{
 		user,
-		jwt.RegisteredClaims{},
+		jwt.RegisteredClaims{
+			ID:        guid.S(),
+			IssuedAt:  jwt.NewNumericDate(now.Time),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Second * gconv.Duration(config.Expires)).Time),
+		},
 	}
 
-	header, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.SecretKey))
+	header, err := GetKeyring().sign(claims)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
 	var (
-		now = gtime.Now()
 		// 认证key
 		authKey = GetAuthKey(header)
 		// 登录token
@@ -288,15 +307,28 @@ func Login(ctx g.Ctx, user *webSchema.Identity) (string, int64, error) {
 	token := &Token{
 		ExpireAt:     now.Unix() + config.Expires,
 		RefreshAt:    now.Unix(),
-		RefreshCount: 0,
+		RefreshCount: fa.RefreshCount,
+		Family:       fa.Id,
 	}
 
 	if err = zcache.Instance().Set(ctx, tokenKey, token, duration); err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
 	if err = zcache.Instance().Set(ctx, bindKey, tokenKey, duration); err != nil {
-		return "", 0, err
+		return nil, err
+	}
+
+	refreshToken, err := saveFamily(ctx, fa, tokenKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pair := &TokenPair{
+		AccessToken:    header,
+		Expires:        config.Expires,
+		RefreshToken:   refreshToken,
+		RefreshExpires: config.RefreshExpires,
 	}
-	return header, config.Expires, nil
+	return pair, nil
 }
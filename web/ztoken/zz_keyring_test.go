@@ -0,0 +1,204 @@
+package ztoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/golang-jwt/jwt/v5"
+
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+// testPrivatePem 将私钥编码为 PKCS8 PEM
+func testPrivatePem(t *gtest.T, private interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	t.AssertNil(err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// testPublicPem 将公钥编码为 PKIX PEM
+func testPublicPem(t *gtest.T, public interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	t.AssertNil(err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// testVerify 使用密钥环签发并验证令牌，返回令牌头部的 kid
+func testVerify(t *gtest.T, k *Keyring) string {
+	header, err := k.sign(jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	t.AssertNil(err)
+	token, err := jwt.Parse(header, k.keyFunc)
+	t.AssertNil(err)
+	t.Assert(token.Valid, true)
+	return token.Header["kid"].(string)
+}
+
+func TestParseSigningKey(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		t.AssertNil(err)
+		p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		t.AssertNil(err)
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		t.AssertNil(err)
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		t.AssertNil(err)
+
+		k, err := NewKeyring(&webSchema.TokenConfig{
+			Expires:   600,
+			ActiveKid: "rs",
+			Keys: []*webSchema.TokenKeyConfig{
+				{Kid: "rs", Algorithm: "RS256", PrivateKey: testPrivatePem(t, rsaKey)},
+				{Kid: "es", Algorithm: "ES256", PrivateKey: testPrivatePem(t, p256)},
+				{Kid: "ed", Algorithm: "EdDSA", PrivateKey: testPrivatePem(t, edKey)},
+				{Kid: "es-pub", Algorithm: "ES256", PublicKey: testPublicPem(t, &p256.PublicKey)},
+			},
+		})
+		t.AssertNil(err)
+		for _, kid := range []string{"rs", "es", "ed"} {
+			t.AssertNil(k.Rotate(k.keys[kid]))
+			t.Assert(testVerify(t, k), kid)
+		}
+
+		// 只有公钥的密钥不能用于签名
+		t.AssertNE(k.Rotate(k.keys["es-pub"]), nil)
+
+		// 曲线与算法不一致
+		for _, c := range []*webSchema.TokenKeyConfig{
+			{Kid: "es", Algorithm: "ES256", PrivateKey: testPrivatePem(t, p384)},
+			{Kid: "es", Algorithm: "ES256", PublicKey: testPublicPem(t, &p384.PublicKey)},
+			{Kid: "es", Algorithm: "ES384", PrivateKey: testPrivatePem(t, p256)},
+		} {
+			_, err = parseSigningKey(c)
+			t.AssertNE(err, nil)
+		}
+		_, err = parseSigningKey(&webSchema.TokenKeyConfig{Kid: "es", Algorithm: "ES384", PrivateKey: testPrivatePem(t, p384)})
+		t.AssertNil(err)
+
+		_, err = parseSigningKey(&webSchema.TokenKeyConfig{Kid: "none", Algorithm: "none"})
+		t.AssertNE(err, nil)
+		_, err = parseSigningKey(&webSchema.TokenKeyConfig{Kid: "hs", Algorithm: "HS256"})
+		t.AssertNE(err, nil)
+	})
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		k, err := NewKeyring(&webSchema.TokenConfig{SecretKey: "old", Expires: 600})
+		t.AssertNil(err)
+		old, err := k.sign(jwt.RegisteredClaims{})
+		t.AssertNil(err)
+
+		// 未携带 kid 的令牌使用默认密钥验签
+		_, ok := k.Lookup("")
+		t.Assert(ok, true)
+
+		// 轮换后使用新密钥签名，旧密钥签发的令牌在宽限期内仍可验签
+		t.AssertNil(k.Rotate(&SigningKey{Kid: "k2", Method: jwt.SigningMethodHS256, Private: []byte("new"), Public: []byte("new")}))
+		t.Assert(testVerify(t, k), "k2")
+		_, err = jwt.Parse(old, k.keyFunc)
+		t.AssertNil(err)
+		t.AssertNE(k.Remove("k2"), nil)
+
+		// 超过宽限期后不能验签，Prune 删除
+		k.keys[DefaultKid].RetiredAt = gtime.Timestamp() - 601
+		_, err = jwt.Parse(old, k.keyFunc)
+		t.AssertNE(err, nil)
+		k.Prune()
+		_, ok = k.keys[DefaultKid]
+		t.Assert(ok, false)
+
+		// 签名算法与密钥不一致
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS384, jwt.RegisteredClaims{})
+		forged.Header["kid"] = "k2"
+		header, err := forged.SignedString([]byte("new"))
+		t.AssertNil(err)
+		_, err = jwt.Parse(header, k.keyFunc)
+		t.AssertNE(err, nil)
+	})
+}
+
+func TestKeyring_DefaultKey(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		t.AssertNil(err)
+		keys := []*webSchema.TokenKeyConfig{{Kid: "rs", Algorithm: "RS256", PrivateKey: testPrivatePem(t, rsaKey)}}
+
+		// 使用公开的默认密钥签发、未携带 kid 的令牌
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString([]byte(DefaultSecretKey))
+		t.AssertNil(err)
+
+		// 未配置 keys 时兼容旧令牌
+		k, err := NewKeyring(&webSchema.TokenConfig{SecretKey: DefaultSecretKey, Expires: 600})
+		t.AssertNil(err)
+		_, err = jwt.Parse(forged, k.keyFunc)
+		t.AssertNil(err)
+
+		// 配置了 keys 后不使用默认密钥，也不接受未携带 kid 的令牌
+		k, err = NewKeyring(&webSchema.TokenConfig{SecretKey: DefaultSecretKey, Expires: 600, Keys: keys})
+		t.AssertNil(err)
+		_, ok := k.keys[DefaultKid]
+		t.Assert(ok, false)
+		_, err = jwt.Parse(forged, k.keyFunc)
+		t.AssertNE(err, nil)
+		_, ok = k.Lookup("")
+		t.Assert(ok, false)
+		t.Assert(testVerify(t, k), "rs")
+
+		// 显式配置的 secretKey 仍可通过 kid 验签
+		k, err = NewKeyring(&webSchema.TokenConfig{SecretKey: "secret", Expires: 600, Keys: keys})
+		t.AssertNil(err)
+		_, ok = k.Lookup(DefaultKid)
+		t.Assert(ok, true)
+		_, ok = k.Lookup("")
+		t.Assert(ok, false)
+	})
+}
+
+func TestKeyring_JWKS(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		t.AssertNil(err)
+		p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		t.AssertNil(err)
+		edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+		t.AssertNil(err)
+
+		k, err := NewKeyring(&webSchema.TokenConfig{
+			SecretKey: "secret",
+			Expires:   600,
+			ActiveKid: DefaultKid,
+			Keys: []*webSchema.TokenKeyConfig{
+				{Kid: "rs", Algorithm: "RS256", PrivateKey: testPrivatePem(t, rsaKey)},
+				{Kid: "es", Algorithm: "ES256", PublicKey: testPublicPem(t, &p256.PublicKey)},
+				{Kid: "ed", Algorithm: "EdDSA", PublicKey: testPublicPem(t, edPublic)},
+				{Kid: "retired", Algorithm: "ES256", PublicKey: testPublicPem(t, &p256.PublicKey), RetiredAt: gtime.Now().Add(-time.Hour)},
+			},
+		})
+		t.AssertNil(err)
+
+		// HMAC 密钥和超过宽限期的密钥不导出，按 kid 排序
+		keys := k.JWKS().Keys
+		t.Assert(len(keys), 3)
+		t.Assert(keys[0].Kid, "ed")
+		t.Assert(keys[0].Kty, "OKP")
+		t.Assert(keys[0].Crv, "Ed25519")
+		t.Assert(keys[1].Kid, "es")
+		t.Assert(keys[1].Kty, "EC")
+		t.Assert(keys[1].Crv, "P-256")
+		t.Assert(len(keys[1].X), 43)
+		t.Assert(keys[2].Kid, "rs")
+		t.Assert(keys[2].Kty, "RSA")
+		t.Assert(keys[2].Alg, "RS256")
+		t.Assert(keys[2].E, "AQAB")
+	})
+}
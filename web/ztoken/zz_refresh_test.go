@@ -0,0 +1,138 @@
+package ztoken
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+
+	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
+)
+
+// setupTestToken 使用内存缓存和 HS256 密钥
+func setupTestToken(t *gtest.T, multiLogin bool) {
+	gdb.SetConfig(gdb.Config{"default": gdb.ConfigGroup{gdb.ConfigNode{Link: "sqlite::@file(:memory:)"}}})
+	zcache.SetAdapter(context.Background(), &webSchema.CacheConfig{Adapter: "memory"})
+	t.AssertNil(SetConfig(&webSchema.TokenConfig{
+		SecretKey:       "test",
+		Expires:         600,
+		RefreshExpires:  3600,
+		MaxRefreshTimes: -1,
+		MultiLogin:      multiLogin,
+	}))
+}
+
+func TestRefresh_Concurrent(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestToken(t, true)
+
+		pair, err := Login(ctx, &webSchema.Identity{Id: 1, App: "admin"})
+		t.AssertNil(err)
+
+		// 同一刷新令牌并发使用，只有一个请求轮换成功
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			success int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := Refresh(ctx, pair.RefreshToken); err == nil {
+					mu.Lock()
+					success++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		t.Assert(success, 1)
+	})
+}
+
+func TestRevokeSession_KeepNewerBind(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestToken(t, false)
+
+		user := &webSchema.Identity{Id: 2, App: "admin"}
+		_, err := Login(ctx, user)
+		t.AssertNil(err)
+		sessions, err := GetMemberSessions(ctx, user.Id)
+		t.AssertNil(err)
+		t.Assert(len(sessions), 1)
+		old := sessions[0]
+
+		_, err = Login(ctx, user)
+		t.AssertNil(err)
+
+		// 注销旧会话后，新登录的身份绑定仍然有效
+		t.AssertNil(RevokeSession(ctx, old.Id))
+		v, err := zcache.Instance().Get(ctx, GetBindKey(user.App, user.Id))
+		t.AssertNil(err)
+		t.AssertNE(v.String(), "")
+		t.AssertNE(v.String(), old.TokenKey)
+	})
+}
+
+func TestLogin_SingleLoginRevokesOldRefresh(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestToken(t, false)
+
+		user := &webSchema.Identity{Id: 3, App: "admin"}
+		old, err := Login(ctx, user)
+		t.AssertNil(err)
+		pair, err := Login(ctx, user)
+		t.AssertNil(err)
+
+		// 旧设备的刷新令牌随新登录一起失效，不能抢回身份绑定
+		_, _, err = Refresh(ctx, old.RefreshToken)
+		t.AssertNE(err, nil)
+		v, err := zcache.Instance().Get(ctx, GetBindKey(user.App, user.Id))
+		t.AssertNil(err)
+		t.Assert(v.String(), GetTokenKey(user.App, GetAuthKey(pair.AccessToken)))
+
+		sessions, err := GetMemberSessions(ctx, user.Id)
+		t.AssertNil(err)
+		t.Assert(len(sessions), 1)
+
+		// 其他应用的登录不受影响
+		_, err = Login(ctx, &webSchema.Identity{Id: 3, App: "api"})
+		t.AssertNil(err)
+		_, _, err = Refresh(ctx, pair.RefreshToken)
+		t.AssertNil(err)
+	})
+}
+
+func TestRefresh_MaxRefreshTimesUnset(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := context.Background()
+		setupTestToken(t, true)
+		config.MaxRefreshTimes = 0
+
+		// 未配置轮换次数时不限制
+		pair, err := Login(ctx, &webSchema.Identity{Id: 4, App: "admin"})
+		t.AssertNil(err)
+		for i := 0; i < 3; i++ {
+			pair, _, err = Refresh(ctx, pair.RefreshToken)
+			t.AssertNil(err)
+		}
+
+		// 达到上限后拒绝轮换
+		config.MaxRefreshTimes = 1
+		pair, err = Login(ctx, &webSchema.Identity{Id: 5, App: "admin"})
+		t.AssertNil(err)
+		pair, _, err = Refresh(ctx, pair.RefreshToken)
+		t.AssertNil(err)
+		_, _, err = Refresh(ctx, pair.RefreshToken)
+		t.AssertNE(err, nil)
+	})
+}
@@ -0,0 +1,83 @@
+package ztoken
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/zconsts"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// testSessions 各缓存适配器的公共用例
+func testSessions(t *gtest.T) {
+	ctx := context.Background()
+
+	// 并发登录不会丢失索引
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := Login(ctx, &webSchema.Identity{Id: int64(i%2 + 10), App: "admin"})
+			t.AssertNil(err)
+		}(i)
+	}
+	wg.Wait()
+
+	sessions, err := GetSessions(ctx)
+	t.AssertNil(err)
+	t.Assert(len(sessions), 20)
+	member, err := GetMemberSessions(ctx, 10)
+	t.AssertNil(err)
+	t.Assert(len(member), 10)
+
+	// 注销后从索引中删除
+	t.AssertNil(RevokeSession(ctx, member[0].Id))
+	ids, err := indexMembers(ctx, zconsts.CacheTokenSession)
+	t.AssertNil(err)
+	t.Assert(len(ids), 19)
+	t.AssertNil(RevokeMember(ctx, 10))
+	ids, err = indexMembers(ctx, zconsts.CacheTokenSession)
+	t.AssertNil(err)
+	t.Assert(len(ids), 10)
+	ids, err = indexMembers(ctx, GetMemberKey(10))
+	t.AssertNil(err)
+	t.Assert(len(ids), 0)
+
+	// 写入时清理已过期的令牌族
+	now := gtime.Timestamp()
+	t.AssertNil(indexAdd(ctx, "index", "old", now-1))
+	t.AssertNil(indexAdd(ctx, "index", "new", now+60))
+	ids, err = indexMembers(ctx, "index")
+	t.AssertNil(err)
+	t.Assert(ids, []string{"new"})
+}
+
+func TestSessions(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestToken(t, true)
+		testSessions(t)
+	})
+	gtest.C(t, func(t *gtest.T) {
+		gredis.SetConfig(&gredis.Config{Address: miniredis.RunT(t.T).Addr()})
+		defer gredis.RemoveConfig()
+		setupTestToken(t, true)
+		zcache.SetAdapter(context.Background(), &webSchema.CacheConfig{Adapter: "redis"})
+		testSessions(t)
+
+		// 已过期的成员从有序集合中删除
+		n, err := g.Redis().Do(context.Background(), "ZCARD", "index")
+		t.AssertNil(err)
+		t.Assert(n.Int(), 1)
+	})
+}
@@ -0,0 +1,124 @@
+package ztotp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/test/gtest"
+)
+
+// rfcSecret RFC 6238 附录 B 中 SHA1 使用的密钥 "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		// 附录 B 为 8 位验证码，取后 6 位
+		vectors := map[int64]string{
+			59:          "94287082",
+			1111111109:  "07081804",
+			1111111111:  "14050471",
+			1234567890:  "89005924",
+			2000000000:  "69279037",
+			20000000000: "65353130",
+		}
+		for unix, expect := range vectors {
+			code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+			t.AssertNil(err)
+			t.Assert(code, expect[len(expect)-Digits:])
+		}
+
+		// 密钥忽略大小写和首尾空格
+		code, err := Code(" "+strings.ToLower(rfcSecret)+" ", 1)
+		t.AssertNil(err)
+		t.Assert(code, "287082")
+
+		_, err = Code("not base32!", 1)
+		t.AssertNE(err, nil)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		var (
+			now     = time.Unix(1111111111, 0)
+			current = Step(now)
+		)
+		codeAt := func(step int64) string {
+			code, err := Code(rfcSecret, step)
+			t.AssertNil(err)
+			return code
+		}
+
+		// 前后 Skew 个时间步内有效，返回验证码所属的时间步
+		for i := -Skew; i <= Skew; i++ {
+			step, ok := Validate(rfcSecret, codeAt(current+int64(i)), now)
+			t.Assert(ok, true)
+			t.Assert(step, current+int64(i))
+		}
+		for _, i := range []int64{-Skew - 1, Skew + 1} {
+			_, ok := Validate(rfcSecret, codeAt(current+i), now)
+			t.Assert(ok, false)
+		}
+
+		// 时间步的边界
+		start := time.Unix(current*Period, 0)
+		step, ok := Validate(rfcSecret, codeAt(current-Skew), start)
+		t.Assert(ok, true)
+		t.Assert(step, current-Skew)
+		_, ok = Validate(rfcSecret, codeAt(current-Skew), start.Add(Skew*Period*time.Second))
+		t.Assert(ok, false)
+
+		// 同一个验证码在下一个时间步仍然有效，但返回原来的时间步，调用方据此拒绝重复使用
+		code := codeAt(current)
+		first, ok := Validate(rfcSecret, code, now)
+		t.Assert(ok, true)
+		again, ok := Validate(rfcSecret, code, now.Add(Period*time.Second))
+		t.Assert(ok, true)
+		t.Assert(again, first)
+		earlier, ok := Validate(rfcSecret, codeAt(current-1), now)
+		t.Assert(ok, true)
+		t.Assert(earlier < first, true)
+
+		// 格式错误的验证码和密钥
+		step, ok = Validate(rfcSecret, " "+code+" ", now)
+		t.Assert(ok, true)
+		t.Assert(step, current)
+		for _, c := range []string{"", code[:Digits-1], code + "0", "abcdef"} {
+			_, ok = Validate(rfcSecret, c, now)
+			t.Assert(ok, false)
+		}
+		_, ok = Validate("not base32!", code, now)
+		t.Assert(ok, false)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		secret := GenerateSecret()
+		t.Assert(len(secret), 32)
+		t.AssertNE(secret, GenerateSecret())
+		_, err := Code(secret, 1)
+		t.AssertNil(err)
+	})
+}
+
+func TestURI(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		uri, err := url.Parse(URI("ZZFrame", "admin", rfcSecret))
+		t.AssertNil(err)
+		t.Assert(uri.Scheme, "otpauth")
+		t.Assert(uri.Host, "totp")
+		t.Assert(uri.Path, "/ZZFrame:admin")
+		t.Assert(uri.Query().Get("secret"), rfcSecret)
+		t.Assert(uri.Query().Get("issuer"), "ZZFrame")
+		t.Assert(uri.Query().Get("digits"), Digits)
+		t.Assert(uri.Query().Get("period"), Period)
+
+		img, err := QrCode(uri.String())
+		t.AssertNil(err)
+		t.Assert(strings.HasPrefix(img, "data:image/png;base64,"), true)
+	})
+}
@@ -70,4 +70,5 @@ type ResetPwdReq struct {
 }
 
 type ResetPwdRes struct {
+	*adminSchema.MemberResetPasswordOutput
 }
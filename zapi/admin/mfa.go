@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
+)
+
+// MfaStatusReq 获取当前用户的双因素认证状态
+type MfaStatusReq struct {
+	g.Meta `path:"/mfa/status" method:"get" tags:"SYS-05-双因素认证" summary:"获取双因素认证状态"`
+}
+
+type MfaStatusRes struct {
+	*adminSchema.MfaStatusOutput
+}
+
+// MfaEnrollReq 绑定认证器
+type MfaEnrollReq struct {
+	g.Meta `path:"/mfa/enroll" method:"post" tags:"SYS-05-双因素认证" summary:"绑定认证器"`
+}
+
+type MfaEnrollRes struct {
+	*adminSchema.MfaEnrollOutput
+}
+
+// MfaActivateReq 验证并启用双因素认证
+type MfaActivateReq struct {
+	g.Meta `path:"/mfa/activate" method:"post" tags:"SYS-05-双因素认证" summary:"启用双因素认证"`
+	adminSchema.MfaCodeInput
+}
+
+type MfaActivateRes struct {
+	*adminSchema.MfaRecoveryCodesOutput
+}
+
+// MfaDisableReq 关闭双因素认证
+type MfaDisableReq struct {
+	g.Meta `path:"/mfa/disable" method:"post" tags:"SYS-05-双因素认证" summary:"关闭双因素认证"`
+	adminSchema.MfaCodeInput
+}
+
+type MfaDisableRes struct{}
+
+// MfaRecoveryCodesReq 重新生成恢复码
+type MfaRecoveryCodesReq struct {
+	g.Meta `path:"/mfa/recovery-codes" method:"post" tags:"SYS-05-双因素认证" summary:"重新生成恢复码"`
+	adminSchema.MfaCodeInput
+}
+
+type MfaRecoveryCodesRes struct {
+	*adminSchema.MfaRecoveryCodesOutput
+}
+
+// MfaResetReq 重置用户的双因素认证
+type MfaResetReq struct {
+	g.Meta `path:"/mfa/reset" method:"post" tags:"SYS-05-双因素认证" summary:"重置用户双因素认证"`
+	adminSchema.MfaResetInput
+}
+
+type MfaResetRes struct{}
@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
+	"github.com/denghuo98/zzframe/zschema/zform"
+)
+
+// SessionListReq 获取登录会话列表
+type SessionListReq struct {
+	g.Meta `path:"/session/list" method:"get" tags:"SYS-04-会话管理" summary:"获取登录会话列表"`
+	adminSchema.SessionListInput
+}
+
+type SessionListRes struct {
+	adminSchema.SessionListOutput
+	zform.PageRes
+}
+
+// SessionViewReq 获取登录会话详情
+type SessionViewReq struct {
+	g.Meta `path:"/session/view" method:"get" tags:"SYS-04-会话管理" summary:"获取登录会话详情"`
+	adminSchema.SessionViewInput
+}
+
+type SessionViewRes struct {
+	*adminSchema.SessionItem
+}
+
+// SessionKickReq 强制下线会话
+type SessionKickReq struct {
+	g.Meta `path:"/session/kick" method:"post" tags:"SYS-04-会话管理" summary:"强制下线会话"`
+	adminSchema.SessionKickInput
+}
+
+type SessionKickRes struct{}
+
+// SessionKickMemberReq 强制下线用户全部会话
+type SessionKickMemberReq struct {
+	g.Meta `path:"/session/kick-member" method:"post" tags:"SYS-04-会话管理" summary:"强制下线用户全部会话"`
+	adminSchema.SessionKickMemberInput
+}
+
+type SessionKickMemberRes struct{}
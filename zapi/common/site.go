@@ -3,6 +3,8 @@ package common
 import (
 	"github.com/gogf/gf/v2/frame/g"
 
+	"github.com/denghuo98/zzframe/web/ztoken"
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
 	commonSchema "github.com/denghuo98/zzframe/zschema/common"
 )
 
@@ -33,6 +35,61 @@ type SiteAccountLoginRes struct {
 	commonSchema.SiteLoginOutput
 }
 
+// SiteMfaSetupReq 登录时绑定认证器
+type SiteMfaSetupReq struct {
+	g.Meta `path:"/site/mfa/setup" method:"post" tags:"SYS-00-系统管理" summary:"登录时绑定认证器"`
+	commonSchema.SiteMfaSetupInput
+}
+
+type SiteMfaSetupRes struct {
+	*adminSchema.MfaEnrollOutput
+}
+
+// SiteMfaLoginReq 双因素认证登录
+type SiteMfaLoginReq struct {
+	g.Meta `path:"/site/mfa/login" method:"post" tags:"SYS-00-系统管理" summary:"双因素认证登录"`
+	commonSchema.SiteMfaLoginInput
+}
+
+type SiteMfaLoginRes struct {
+	commonSchema.SiteLoginOutput
+}
+
+// SitePasswordForgotReq 找回密码
+type SitePasswordForgotReq struct {
+	g.Meta `path:"/site/password/forgot" method:"post" tags:"SYS-00-系统管理" summary:"找回密码"`
+	commonSchema.SitePasswordForgotInput
+}
+
+type SitePasswordForgotRes struct{}
+
+// SitePasswordResetReq 重置密码
+type SitePasswordResetReq struct {
+	g.Meta `path:"/site/password/reset" method:"post" tags:"SYS-00-系统管理" summary:"通过重置令牌设置新密码"`
+	commonSchema.SitePasswordResetInput
+}
+
+type SitePasswordResetRes struct{}
+
+// SiteTokenRefreshReq 刷新登录令牌
+type SiteTokenRefreshReq struct {
+	g.Meta `path:"/site/token/refresh" method:"post" tags:"SYS-00-系统管理" summary:"刷新登录令牌"`
+	commonSchema.SiteTokenRefreshInput
+}
+
+type SiteTokenRefreshRes struct {
+	commonSchema.SiteLoginOutput
+}
+
+// SiteJwksReq 令牌签名公钥
+type SiteJwksReq struct {
+	g.Meta `path:"/site/.well-known/jwks.json" method:"get" tags:"SYS-00-系统管理" summary:"令牌签名公钥"`
+}
+
+type SiteJwksRes struct {
+	Keys []*ztoken.JWK `json:"keys" dc:"公钥列表"`
+}
+
 // SiteRoutesReq 获取路由信息
 type SiteRoutesReq struct {
 	g.Meta `path:"/site/routes" method:"get" tags:"SYS-00-系统管理" summary:"获取路由信息"`
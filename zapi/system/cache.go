@@ -0,0 +1,16 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+)
+
+// SysCacheStatsReq 获取缓存统计
+type SysCacheStatsReq struct {
+	g.Meta `path:"/cache/stats" method:"get" tags:"SYS-13-缓存" summary:"获取缓存统计"`
+}
+
+type SysCacheStatsRes struct {
+	*systemSchema.SysCacheStatsOutput
+}
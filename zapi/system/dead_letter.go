@@ -0,0 +1,56 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	"github.com/denghuo98/zzframe/zschema/zform"
+)
+
+// SysDeadLetterTopicsReq 获取存在死信的主题
+type SysDeadLetterTopicsReq struct {
+	g.Meta `path:"/system/dead-letter/topics" method:"get" tags:"SYS-11-死信队列" summary:"获取存在死信的主题"`
+}
+
+type SysDeadLetterTopicsRes struct {
+	*systemSchema.SysDeadLetterTopicsOutput
+}
+
+// SysDeadLetterListReq 获取死信列表
+type SysDeadLetterListReq struct {
+	g.Meta `path:"/system/dead-letter/list" method:"get" tags:"SYS-11-死信队列" summary:"获取死信列表"`
+	systemSchema.SysDeadLetterListInput
+}
+
+type SysDeadLetterListRes struct {
+	zform.PageRes
+	systemSchema.SysDeadLetterListOutput
+}
+
+// SysDeadLetterViewReq 获取死信详情
+type SysDeadLetterViewReq struct {
+	g.Meta `path:"/system/dead-letter/view" method:"get" tags:"SYS-11-死信队列" summary:"获取死信详情"`
+	systemSchema.SysDeadLetterViewInput
+}
+
+type SysDeadLetterViewRes struct {
+	*systemSchema.SysDeadLetterItem
+}
+
+// SysDeadLetterReplayReq 重新投递死信
+type SysDeadLetterReplayReq struct {
+	g.Meta `path:"/system/dead-letter/replay" method:"post" tags:"SYS-11-死信队列" summary:"重新投递死信"`
+	systemSchema.SysDeadLetterReplayInput
+}
+
+type SysDeadLetterReplayRes struct{}
+
+// SysDeadLetterPurgeReq 清除死信
+type SysDeadLetterPurgeReq struct {
+	g.Meta `path:"/system/dead-letter/purge" method:"delete" tags:"SYS-11-死信队列" summary:"清除死信"`
+	systemSchema.SysDeadLetterPurgeInput
+}
+
+type SysDeadLetterPurgeRes struct {
+	*systemSchema.SysDeadLetterPurgeOutput
+}
@@ -26,6 +26,14 @@ type SysLoginLogListRes struct {
 	systemSchema.SysLoginLogListOutput
 }
 
+// SysLoginUnlockReq 解除登录锁定
+type SysLoginUnlockReq struct {
+	g.Meta `path:"/system/login-log/unlock" method:"post" tags:"SYS-10-登录日志" summary:"解除登录锁定"`
+	systemSchema.SysLoginUnlockInput
+}
+
+type SysLoginUnlockRes struct{}
+
 // SysLoginLogExportReq 导出登录日志
 type SysLoginLogExportReq struct {
 	g.Meta `path:"/system/login-log/export" method:"get" tags:"SYS-10-登录日志" summary:"导出登录日志"`
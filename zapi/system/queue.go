@@ -0,0 +1,16 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+)
+
+// SysQueueStatsReq 获取消息队列统计
+type SysQueueStatsReq struct {
+	g.Meta `path:"/queue/stats" method:"get" tags:"SYS-12-消息队列" summary:"获取消息队列统计"`
+}
+
+type SysQueueStatsRes struct {
+	*systemSchema.SysQueueStatsOutput
+}
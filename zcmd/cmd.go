@@ -27,6 +27,10 @@ var (
 		>> 所有服务  [go run main.go]   热编译  [gf run main.go]
 		>> HTTP服务  [go run main.go http]
 		>> 消息队列  [go run main.go queue]
+		---------------------------------------------------------------------------------
+		消息队列
+		>> 积压情况  [go run main.go queue stats]
+		>> 查看消息  [go run main.go queue peek <topic>]
     `,
 	}
 
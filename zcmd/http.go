@@ -1,11 +1,16 @@
 package zcmd
 
 import (
+	"context"
+
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gcmd"
 
 	"github.com/denghuo98/zzframe/web/zcasbin"
+	"github.com/denghuo98/zzframe/web/zqueue"
+	"github.com/denghuo98/zzframe/web/zutils"
+	"github.com/denghuo98/zzframe/zconsts"
 	"github.com/denghuo98/zzframe/zcontroller"
 	"github.com/denghuo98/zzframe/zservice"
 )
@@ -23,6 +28,7 @@ var (
 				zservice.Middleware().Ctx,
 				zservice.Middleware().CORS,
 				zservice.Middleware().ResponseHandler,
+				zservice.Middleware().RateLimit, // 在 ResponseHandler 之后，限流响应不会被覆盖
 			}...)
 
 			// 注册路由，后台管理
@@ -33,6 +39,13 @@ var (
 			// 初始化 casbin
 			zcasbin.InitEnforcer(ctx)
 
+			// 服务关闭时停止广播监听，删除当前实例的消费者组
+			zutils.Event().Register(zconsts.EventServerClose, func(ctx context.Context, args ...interface{}) {
+				if err := zqueue.StopSubscriptions(ctx); err != nil {
+					zqueue.Logger().Warningf(ctx, "stop queue broadcast failed, err:%+v", err)
+				}
+			})
+
 			// 信号监听
 			SignalListen(ctx, SignalHandlerForOverall)
 
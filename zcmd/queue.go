@@ -1,10 +1,19 @@
 package zcmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gcmd"
 
 	"github.com/denghuo98/zzframe/web/zqueue"
+	"github.com/denghuo98/zzframe/web/zutils"
+	"github.com/denghuo98/zzframe/zconsts"
 
 	_ "github.com/denghuo98/zzframe/zqueues"
 )
@@ -26,8 +35,22 @@ var (
 				zqueue.Logger().Errorf(ctx, "start queue consumer failed, err:%+v", exception)
 			})
 
+			// 转发事务发件箱中已提交的消息
+			zqueue.StartOutboxRelay(ctx)
+
 			serverWg.Add(1)
 
+			// 服务关闭时停止拉取消息，等待正在处理的消息完成
+			zutils.Event().Register(zconsts.EventServerClose, func(ctx context.Context, args ...interface{}) {
+				zqueue.StopOutboxRelay(ctx)
+				if err := zqueue.StopConsumersListener(ctx); err != nil {
+					zqueue.Logger().Warningf(ctx, "stop queue consumer failed, err:%+v", err)
+				}
+				if err := zqueue.StopSubscriptions(ctx); err != nil {
+					zqueue.Logger().Warningf(ctx, "stop queue broadcast failed, err:%+v", err)
+				}
+			})
+
 			// 信号监听
 			SignalListen(ctx, SignalHandlerForOverall)
 
@@ -37,4 +60,103 @@ var (
 			return
 		},
 	}
+
+	QueueStats = &gcmd.Command{
+		Name:  "stats",
+		Usage: "queue stats [-j]",
+		Brief: "查看消息队列各主题和消费者组的积压情况",
+		Description: `
+		读取队列存储中的写入位置、各消费者组的提交位置和积压数量
+		生产、消费数量和处理耗时只在服务进程内统计，请通过 /admin/queue/stats 接口查看`,
+		Arguments: []gcmd.Argument{
+			{Name: "json", Short: "j", Brief: "以 JSON 格式输出", Orphan: true},
+		},
+		Func: func(ctx g.Ctx, parser *gcmd.Parser) (err error) {
+			list, err := zqueue.Stats(ctx)
+			if err != nil {
+				return
+			}
+			if parser.GetOpt("json") != nil {
+				return printJson(list)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "TOPIC\tGROUP\tPOSITION\tCOMMITTED\tLAG\tLAG BYTES\tPENDING\tSEGMENTS\tBYTES")
+			for _, t := range list {
+				if len(t.Groups) == 0 {
+					_, _ = fmt.Fprintf(w, "%s\t-\t%s\t-\t-\t-\t-\t%d\t%d\n", t.Topic, t.Position, t.Segments, t.Bytes)
+				}
+				for _, g := range t.Groups {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+						t.Topic, g.Group, t.Position, g.Committed, g.Lag, g.LagBytes, g.Pending, t.Segments, t.Bytes)
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	QueuePeek = &gcmd.Command{
+		Name:  "peek",
+		Usage: "queue peek <topic> [-g group] [-n limit]",
+		Brief: "查看主题中消费者组接下来要处理的消息，不会提交消费位置",
+		Arguments: []gcmd.Argument{
+			{Name: "topic", IsArg: true, Brief: "主题"},
+			{Name: "group", Short: "g", Brief: "消费者组，默认为 default"},
+			{Name: "limit", Short: "n", Default: "10", Brief: "最多查看的消息数量"},
+		},
+		Func: func(ctx g.Ctx, parser *gcmd.Parser) (err error) {
+			topic := parser.GetArg(3).String()
+			if topic == "" {
+				return gerror.New("请指定主题，例如：queue peek login_log")
+			}
+			list, err := zqueue.Peek(ctx, topic, parser.GetOpt("group").String(), parser.GetOpt("limit", 10).Int())
+			if err != nil {
+				return
+			}
+			for _, mqMsg := range list {
+				_, _ = fmt.Printf("%s  %s  %v\n%s\n\n", mqMsg.MsgId, mqMsg.Timestamp.Format("2006-01-02 15:04:05"), mqMsg.Headers, mqMsg.BodyString())
+			}
+			return
+		},
+	}
+
+	QueueDropGroup = &gcmd.Command{
+		Name:  "drop-group",
+		Usage: "queue drop-group <topic> -g group",
+		Brief: "删除不再使用的消费者组，之后不再为其保留消息",
+		Description: `
+		消费者组改名或下线后，磁盘队列仍会为其保留数据文件，数据库队列仍会为其保存消息
+		只能删除已经没有进程消费的消费者组`,
+		Arguments: []gcmd.Argument{
+			{Name: "topic", IsArg: true, Brief: "主题"},
+			{Name: "group", Short: "g", Brief: "消费者组"},
+		},
+		Func: func(ctx g.Ctx, parser *gcmd.Parser) (err error) {
+			topic, group := parser.GetArg(3).String(), parser.GetOpt("group").String()
+			if topic == "" || group == "" {
+				return gerror.New("请指定主题和消费者组，例如：queue drop-group login_log -g audit")
+			}
+			if err = zqueue.DropGroup(ctx, topic, group); err != nil {
+				return
+			}
+			_, err = fmt.Printf("消费者组 %s 已从主题 %s 删除\n", group, topic)
+			return
+		},
+	}
 )
+
+// printJson 格式化输出 JSON
+func printJson(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
+
+func init() {
+	if err := Queue.AddCommand(QueueStats, QueuePeek, QueueDropGroup); err != nil {
+		panic(err)
+	}
+}
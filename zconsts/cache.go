@@ -4,5 +4,27 @@ package zconsts
 const (
 	CacheToken           = "token"            // 登录token
 	CacheTokenBind       = "token_bind"       // 登录用户身份绑定
+	CacheTokenRefresh    = "token_refresh"    // 刷新令牌
+	CacheTokenFamily     = "token_family"     // 刷新令牌族
+	CacheTokenMember     = "token_member"     // 登录用户的令牌族索引
+	CacheTokenSession    = "token_session"    // 全部登录会话索引
+	CacheMfaTicket       = "mfa_ticket"       // 登录二次验证凭证
+	CacheLoginFail       = "login_fail"       // 登录失败次数
+	CacheLoginLock       = "login_lock"       // 登录锁定
 	CacheMultipartUpload = "multipart_upload" // 分片上传
+	CacheRoleMenu        = "role_menu"        // 角色绑定的菜单
+)
+
+// cache tag
+const (
+	CacheTagRole = "role" // 角色，角色修改或删除后失效
+)
+
+// lock
+const (
+	LockInitSuperAdmin  = "init_super_admin" // 初始化超级管理员
+	LockMultipartUpload = "multipart_upload" // 分片上传，按上传ID加锁
+	LockTokenFamily     = "token_family"     // 刷新令牌族，按令牌族ID加锁
+	LockTokenIndex      = "token_index"      // 令牌族索引，按索引key加锁
+	LockLoginFail       = "login_fail"       // 登录失败次数，按账号或IP加锁
 )
@@ -7,4 +7,5 @@ const (
 	ContextHTTPKey        CtxKey = "httpContext" // http上下文
 	ContextKeyCronArgsKey CtxKey = "cronArgs"    // 定时任务参数
 	ContextKeyCronSn      CtxKey = "cronSn"      // 定时任务序列号
+	ContextKeyLoginUser   CtxKey = "loginUser"   // 已解析的登录令牌身份
 )
@@ -1,6 +1,7 @@
 package zconsts
 
 import (
+	"github.com/gogf/gf/v2/errors/gcode"
 	"github.com/gogf/gf/v2/text/gstr"
 )
 
@@ -11,6 +12,16 @@ const (
 	ErrorRotaPointer = "指针转换异常"
 )
 
+// 业务错误码，属于安全可控错误，前端可根据错误码做交互处理
+var (
+	CodeCaptchaRequired = gcode.New(10001, "需要输入验证码", nil)
+	CodeLoginLocked     = gcode.New(10002, "登录已锁定", nil)
+	CodeTooManyRequests = gcode.New(10003, "请求过于频繁", nil)
+)
+
+// 业务错误码列表
+var businessCodeSlice = []gcode.Code{CodeCaptchaRequired, CodeLoginLocked, CodeTooManyRequests}
+
 // 需要隐藏真实错误的Wrap，开启访问日志后仍然会将真实错误记录
 var concealErrorSlice = []string{ErrorORM, ErrorRotaPointer}
 
@@ -28,3 +39,13 @@ func ErrorMessage(err error) (message string) {
 	}
 	return
 }
+
+// IsBusinessCode 是否为业务错误码
+func IsBusinessCode(code int) bool {
+	for _, c := range businessCodeSlice {
+		if c.Code() == code {
+			return true
+		}
+	}
+	return false
+}
@@ -8,4 +8,11 @@ const (
 	StatusDelete  int = 3  // 已删除
 )
 
+// 登录状态
+const (
+	LoginStatusSuccess int = 1 // 登录成功
+	LoginStatusFail    int = 2 // 登录失败
+	LoginStatusLocked  int = 4 // 登录锁定，和 StatusDelete 区分
+)
+
 var StatusSlice = []int{StatusALL, StatusEnabled, StatusDisable, StatusDelete}
@@ -26,7 +26,12 @@ func Admin(ctx g.Ctx, group *ghttp.RouterGroup) {
 			adminController.Menu,
 			adminController.Role,
 			adminController.Member,
+			adminController.Session,
+			adminController.Mfa,
 			systemController.SysLoginLog,
+			systemController.SysDeadLetter,
+			systemController.SysQueue,
+			systemController.SysCache,
 			commonController.Upload,
 		)
 	})
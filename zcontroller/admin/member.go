@@ -78,8 +78,9 @@ func (c *cMember) UpdatePwd(ctx g.Ctx, req *adminApi.UpdatePwdReq) (res *adminAp
 
 // ResetPwd 重置用户密码
 func (c *cMember) ResetPwd(ctx g.Ctx, req *adminApi.ResetPwdReq) (res *adminApi.ResetPwdRes, err error) {
-	if err = zservice.AdminMember().ResetPassword(ctx, &req.MemberResetPasswordInput); err != nil {
+	out, err := zservice.AdminMember().ResetPassword(ctx, &req.MemberResetPasswordInput)
+	if err != nil {
 		return nil, err
 	}
-	return &adminApi.ResetPwdRes{}, nil
+	return &adminApi.ResetPwdRes{MemberResetPasswordOutput: out}, nil
 }
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	"github.com/denghuo98/zzframe/web/zcontext"
+	adminApi "github.com/denghuo98/zzframe/zapi/admin"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+var Mfa = cMfa{}
+
+type cMfa struct{}
+
+// Status 获取当前用户的双因素认证状态
+func (c *cMfa) Status(ctx g.Ctx, req *adminApi.MfaStatusReq) (res *adminApi.MfaStatusRes, err error) {
+	out, err := zservice.AdminMfa().Status(ctx, zcontext.GetUserId(ctx))
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.MfaStatusRes)
+	res.MfaStatusOutput = out
+	return res, nil
+}
+
+// Enroll 绑定认证器
+func (c *cMfa) Enroll(ctx g.Ctx, req *adminApi.MfaEnrollReq) (res *adminApi.MfaEnrollRes, err error) {
+	out, err := zservice.AdminMfa().Enroll(ctx, zcontext.GetUserId(ctx))
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.MfaEnrollRes)
+	res.MfaEnrollOutput = out
+	return res, nil
+}
+
+// Activate 验证并启用双因素认证
+func (c *cMfa) Activate(ctx g.Ctx, req *adminApi.MfaActivateReq) (res *adminApi.MfaActivateRes, err error) {
+	// 只能操作自己的双因素认证
+	req.MfaCodeInput.Id = zcontext.GetUserId(ctx)
+	out, err := zservice.AdminMfa().Activate(ctx, &req.MfaCodeInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.MfaActivateRes)
+	res.MfaRecoveryCodesOutput = out
+	return res, nil
+}
+
+// Disable 关闭双因素认证
+func (c *cMfa) Disable(ctx g.Ctx, req *adminApi.MfaDisableReq) (res *adminApi.MfaDisableRes, err error) {
+	req.MfaCodeInput.Id = zcontext.GetUserId(ctx)
+	if err = zservice.AdminMfa().Disable(ctx, &req.MfaCodeInput); err != nil {
+		return nil, err
+	}
+	return &adminApi.MfaDisableRes{}, nil
+}
+
+// RecoveryCodes 重新生成恢复码
+func (c *cMfa) RecoveryCodes(ctx g.Ctx, req *adminApi.MfaRecoveryCodesReq) (res *adminApi.MfaRecoveryCodesRes, err error) {
+	req.MfaCodeInput.Id = zcontext.GetUserId(ctx)
+	out, err := zservice.AdminMfa().RecoveryCodes(ctx, &req.MfaCodeInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.MfaRecoveryCodesRes)
+	res.MfaRecoveryCodesOutput = out
+	return res, nil
+}
+
+// Reset 重置用户的双因素认证
+func (c *cMfa) Reset(ctx g.Ctx, req *adminApi.MfaResetReq) (res *adminApi.MfaResetRes, err error) {
+	if err = zservice.AdminMfa().Reset(ctx, &req.MfaResetInput); err != nil {
+		return nil, err
+	}
+	return &adminApi.MfaResetRes{}, nil
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	adminApi "github.com/denghuo98/zzframe/zapi/admin"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+var Session = cSession{}
+
+type cSession struct{}
+
+// List 获取登录会话列表
+func (c *cSession) List(ctx g.Ctx, req *adminApi.SessionListReq) (res *adminApi.SessionListRes, err error) {
+	out, totalCount, err := zservice.AdminSession().List(ctx, &req.SessionListInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.SessionListRes)
+	res.SessionListOutput = *out
+	res.PageRes.Pack(req, totalCount)
+	return res, nil
+}
+
+// View 获取登录会话详情
+func (c *cSession) View(ctx g.Ctx, req *adminApi.SessionViewReq) (res *adminApi.SessionViewRes, err error) {
+	out, err := zservice.AdminSession().View(ctx, &req.SessionViewInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(adminApi.SessionViewRes)
+	res.SessionItem = out
+	return res, nil
+}
+
+// Kick 强制下线会话
+func (c *cSession) Kick(ctx g.Ctx, req *adminApi.SessionKickReq) (res *adminApi.SessionKickRes, err error) {
+	if err = zservice.AdminSession().Kick(ctx, &req.SessionKickInput); err != nil {
+		return nil, err
+	}
+	return &adminApi.SessionKickRes{}, nil
+}
+
+// KickMember 强制下线用户全部会话
+func (c *cSession) KickMember(ctx g.Ctx, req *adminApi.SessionKickMemberReq) (res *adminApi.SessionKickMemberRes, err error) {
+	if err = zservice.AdminSession().KickMember(ctx, &req.SessionKickMemberInput); err != nil {
+		return nil, err
+	}
+	return &adminApi.SessionKickMemberRes{}, nil
+}
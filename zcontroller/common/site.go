@@ -3,9 +3,10 @@ package common
 import (
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
-	"github.com/gogf/gf/v2/util/gmode"
 
 	"github.com/denghuo98/zzframe/web/zcaptcha"
+	"github.com/denghuo98/zzframe/web/zresp"
+	"github.com/denghuo98/zzframe/web/ztoken"
 	commonApi "github.com/denghuo98/zzframe/zapi/common"
 	"github.com/denghuo98/zzframe/zservice"
 )
@@ -30,11 +31,7 @@ func (c *cSite) Captcha(ctx g.Ctx, req *commonApi.SiteCaptchaReq) (res *commonAp
 }
 
 func (c *cSite) AccountLogin(ctx g.Ctx, req *commonApi.SiteAccountLoginReq) (res *commonApi.SiteAccountLoginRes, err error) {
-	// 验证码(开发环境不验证)
-	if !gmode.IsDevelop() && !zcaptcha.Verify(req.Cid, req.Code) {
-		return nil, gerror.New("验证码错误")
-	}
-
+	// 验证码由登录保护按失败次数决定是否校验
 	out, err := zservice.CommonSite().AccountLogin(ctx, &req.SiteAccountLoginInput)
 	if err != nil {
 		return nil, err
@@ -44,6 +41,60 @@ func (c *cSite) AccountLogin(ctx g.Ctx, req *commonApi.SiteAccountLoginReq) (res
 	return
 }
 
+func (c *cSite) MfaSetup(ctx g.Ctx, req *commonApi.SiteMfaSetupReq) (res *commonApi.SiteMfaSetupRes, err error) {
+	out, err := zservice.CommonSite().MfaSetup(ctx, &req.SiteMfaSetupInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(commonApi.SiteMfaSetupRes)
+	res.MfaEnrollOutput = out
+	return
+}
+
+func (c *cSite) MfaLogin(ctx g.Ctx, req *commonApi.SiteMfaLoginReq) (res *commonApi.SiteMfaLoginRes, err error) {
+	out, err := zservice.CommonSite().MfaLogin(ctx, &req.SiteMfaLoginInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(commonApi.SiteMfaLoginRes)
+	res.SiteLoginOutput = *out
+	return
+}
+
+func (c *cSite) PasswordForgot(ctx g.Ctx, req *commonApi.SitePasswordForgotReq) (res *commonApi.SitePasswordForgotRes, err error) {
+	if err = zservice.CommonSite().PasswordForgot(ctx, &req.SitePasswordForgotInput); err != nil {
+		return nil, err
+	}
+	return &commonApi.SitePasswordForgotRes{}, nil
+}
+
+func (c *cSite) PasswordReset(ctx g.Ctx, req *commonApi.SitePasswordResetReq) (res *commonApi.SitePasswordResetRes, err error) {
+	if err = zservice.CommonSite().PasswordReset(ctx, &req.SitePasswordResetInput); err != nil {
+		return nil, err
+	}
+	return &commonApi.SitePasswordResetRes{}, nil
+}
+
+func (c *cSite) TokenRefresh(ctx g.Ctx, req *commonApi.SiteTokenRefreshReq) (res *commonApi.SiteTokenRefreshRes, err error) {
+	out, err := zservice.CommonSite().TokenRefresh(ctx, &req.SiteTokenRefreshInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(commonApi.SiteTokenRefreshRes)
+	res.SiteLoginOutput = *out
+	return
+}
+
+// Jwks 以标准 JWKS 格式输出令牌签名公钥，不经过统一响应包装
+func (c *cSite) Jwks(ctx g.Ctx, req *commonApi.SiteJwksReq) (res *commonApi.SiteJwksRes, err error) {
+	if !ztoken.GetConfig().Jwks {
+		return nil, gerror.New("JWKS接口未开放")
+	}
+	res = &commonApi.SiteJwksRes{Keys: ztoken.GetKeyring().JWKS().Keys}
+	zresp.CustomJson(g.RequestFromCtx(ctx), res)
+	return
+}
+
 func (c *cSite) Routes(ctx g.Ctx, req *commonApi.SiteRoutesReq) (res *commonApi.SiteRoutesRes, err error) {
 	out, err := zservice.CommonSite().GetRoutes(ctx)
 	if err != nil {
@@ -0,0 +1,21 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemApi "github.com/denghuo98/zzframe/zapi/system"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+type cSysCache struct{}
+
+var SysCache = cSysCache{}
+
+// Stats 获取缓存统计
+func (c *cSysCache) Stats(ctx g.Ctx, req *systemApi.SysCacheStatsReq) (res *systemApi.SysCacheStatsRes, err error) {
+	out, err := zservice.SysCache().Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &systemApi.SysCacheStatsRes{SysCacheStatsOutput: out}, nil
+}
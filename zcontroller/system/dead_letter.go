@@ -0,0 +1,57 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemApi "github.com/denghuo98/zzframe/zapi/system"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+type cSysDeadLetter struct{}
+
+var SysDeadLetter = cSysDeadLetter{}
+
+// Topics 获取存在死信的主题
+func (c *cSysDeadLetter) Topics(ctx g.Ctx, req *systemApi.SysDeadLetterTopicsReq) (res *systemApi.SysDeadLetterTopicsRes, err error) {
+	out, err := zservice.SysDeadLetter().Topics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &systemApi.SysDeadLetterTopicsRes{SysDeadLetterTopicsOutput: out}, nil
+}
+
+// List 获取死信列表
+func (c *cSysDeadLetter) List(ctx g.Ctx, req *systemApi.SysDeadLetterListReq) (res *systemApi.SysDeadLetterListRes, err error) {
+	out, totalCount, err := zservice.SysDeadLetter().List(ctx, &req.SysDeadLetterListInput)
+	if err != nil {
+		return nil, err
+	}
+	res = new(systemApi.SysDeadLetterListRes)
+	res.SysDeadLetterListOutput = *out
+	res.PageRes.Pack(req, totalCount)
+	return
+}
+
+// View 获取死信详情
+func (c *cSysDeadLetter) View(ctx g.Ctx, req *systemApi.SysDeadLetterViewReq) (res *systemApi.SysDeadLetterViewRes, err error) {
+	out, err := zservice.SysDeadLetter().View(ctx, &req.SysDeadLetterViewInput)
+	if err != nil {
+		return nil, err
+	}
+	return &systemApi.SysDeadLetterViewRes{SysDeadLetterItem: out}, nil
+}
+
+// Replay 重新投递死信
+func (c *cSysDeadLetter) Replay(ctx g.Ctx, req *systemApi.SysDeadLetterReplayReq) (res *systemApi.SysDeadLetterReplayRes, err error) {
+	err = zservice.SysDeadLetter().Replay(ctx, &req.SysDeadLetterReplayInput)
+	return
+}
+
+// Purge 清除死信
+func (c *cSysDeadLetter) Purge(ctx g.Ctx, req *systemApi.SysDeadLetterPurgeReq) (res *systemApi.SysDeadLetterPurgeRes, err error) {
+	out, err := zservice.SysDeadLetter().Purge(ctx, &req.SysDeadLetterPurgeInput)
+	if err != nil {
+		return nil, err
+	}
+	return &systemApi.SysDeadLetterPurgeRes{SysDeadLetterPurgeOutput: out}, nil
+}
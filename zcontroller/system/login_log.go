@@ -33,6 +33,12 @@ func (c *cSysLoginLog) List(ctx g.Ctx, req *systemApi.SysLoginLogListReq) (res *
 	return
 }
 
+// Unlock 解除账号或IP的登录锁定
+func (c *cSysLoginLog) Unlock(ctx g.Ctx, req *systemApi.SysLoginUnlockReq) (res *systemApi.SysLoginUnlockRes, err error) {
+	err = zservice.SysLoginProtect().Unlock(ctx, &req.SysLoginUnlockInput)
+	return
+}
+
 // Export 导出登录日志
 func (c *cSysLoginLog) Export(ctx g.Ctx, req *systemApi.SysLoginLogExportReq) (res *systemApi.SysLoginLogExportRes, err error) {
 	filePath, err := zservice.SysLoginLog().Export(ctx, &req.SysLoginLogExportInput)
@@ -0,0 +1,21 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	systemApi "github.com/denghuo98/zzframe/zapi/system"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+type cSysQueue struct{}
+
+var SysQueue = cSysQueue{}
+
+// Stats 获取消息队列统计
+func (c *cSysQueue) Stats(ctx g.Ctx, req *systemApi.SysQueueStatsReq) (res *systemApi.SysQueueStatsRes, err error) {
+	out, err := zservice.SysQueue().Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &systemApi.SysQueueStatsRes{SysQueueStatsOutput: out}, nil
+}
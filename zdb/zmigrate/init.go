@@ -3,6 +3,7 @@ package zmigrate
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/frame/g"
@@ -69,14 +70,20 @@ func getDefaultSQLiteConfig() gdb.ConfigGroup {
 func initTables(ctx g.Ctx, dbType string) error {
 	// 初始化管理员相关表
 	tables := map[string]string{
-		"zz_admin_member":      getCreateTableSQL("admin_member", dbType),
-		"zz_admin_member_role": getCreateTableSQL("admin_member_role", dbType),
-		"zz_admin_menu":        getCreateTableSQL("admin_menu", dbType),
-		"zz_admin_role":        getCreateTableSQL("admin_role", dbType),
-		"zz_admin_role_menu":   getCreateTableSQL("admin_role_menu", dbType),
-		"zz_admin_role_casbin": getCreateTableSQL("admin_role_casbin", dbType),
-		"zz_sys_login_log":     getCreateTableSQL("sys_login_log", dbType),
-		"zz_sys_attachment":    getCreateTableSQL("sys_attachment", dbType),
+		"zz_admin_member":                  getCreateTableSQL("admin_member", dbType),
+		"zz_admin_member_role":             getCreateTableSQL("admin_member_role", dbType),
+		"zz_admin_member_mfa":              getCreateTableSQL("admin_member_mfa", dbType),
+		"zz_admin_member_password_history": getCreateTableSQL("admin_member_password_history", dbType),
+		"zz_admin_menu":                    getCreateTableSQL("admin_menu", dbType),
+		"zz_admin_role":                    getCreateTableSQL("admin_role", dbType),
+		"zz_admin_role_menu":               getCreateTableSQL("admin_role_menu", dbType),
+		"zz_admin_role_casbin":             getCreateTableSQL("admin_role_casbin", dbType),
+		"zz_sys_login_log":                 getCreateTableSQL("sys_login_log", dbType),
+		"zz_sys_attachment":                getCreateTableSQL("sys_attachment", dbType),
+		"zz_sys_outbox":                    getCreateTableSQL("sys_outbox", dbType),
+		"zz_sys_queue_processed":           getCreateTableSQL("sys_queue_processed", dbType),
+		"zz_sys_queue_message":             getCreateTableSQL("sys_queue_message", dbType),
+		"zz_sys_queue_group":               getCreateTableSQL("sys_queue_group", dbType),
 	}
 
 	for tableName, sql := range tables {
@@ -91,9 +98,74 @@ func initTables(ctx g.Ctx, dbType string) error {
 		}
 	}
 
+	return initColumns(ctx, dbType)
+}
+
+// initColumns 为旧版本创建的表补充新增字段，或调整已有字段的类型
+func initColumns(ctx g.Ctx, dbType string) error {
+	columns := []struct {
+		table  string
+		column string
+		key    string
+		typ    string // 期望的字段类型，非空时表示调整已有字段
+	}{
+		{"zz_admin_role", "mfa_required", "admin_role.mfa_required", ""},
+		{"zz_admin_member", "password_hash", "admin_member.password_hash", "varchar(255)"},
+		{"zz_admin_member", "must_change_password", "admin_member.must_change_password", ""},
+	}
+
+	for _, c := range columns {
+		typ, ok, err := columnType(ctx, c.table, c.column)
+		if err != nil {
+			g.Log().Warningf(ctx, "检查表 %s 字段 %s 失败: %v", c.table, c.column, err)
+			continue
+		}
+		if c.typ == "" && ok {
+			// 字段已存在
+			continue
+		}
+		if c.typ != "" && (!ok || strings.EqualFold(typ, c.typ)) {
+			// 字段不存在或类型已经一致
+			continue
+		}
+
+		sql := getAlterColumnSQL(c.key, dbType)
+		if sql == "" {
+			continue
+		}
+
+		if _, err := g.DB().Exec(ctx, sql); err != nil {
+			return fmt.Errorf("表 %s 变更字段 %s 失败: %w", c.table, c.column, err)
+		}
+		g.Log().Infof(ctx, "表 %s 变更字段 %s 成功", c.table, c.column)
+	}
 	return nil
 }
 
+// getAlterColumnSQL 根据数据库类型获取变更字段 SQL
+func getAlterColumnSQL(columnKey, dbType string) string {
+	switch dbType {
+	case "mysql":
+		switch columnKey {
+		case "admin_role.mfa_required":
+			return addAdminRoleMfaRequiredColumnSQL
+		case "admin_member.password_hash":
+			return modifyAdminMemberPasswordHashColumnSQL
+		case "admin_member.must_change_password":
+			return addAdminMemberMustChangePasswordColumnSQL
+		}
+	case "sqlite":
+		// sqlite 不限制字段长度，只需要新增字段
+		switch columnKey {
+		case "admin_role.mfa_required":
+			return addAdminRoleMfaRequiredColumnSQLite
+		case "admin_member.must_change_password":
+			return addAdminMemberMustChangePasswordColumnSQLite
+		}
+	}
+	return ""
+}
+
 // getCreateTableSQL 根据数据库类型获取建表 SQL
 func getCreateTableSQL(tableKey, dbType string) string {
 	switch dbType {
@@ -103,6 +175,10 @@ func getCreateTableSQL(tableKey, dbType string) string {
 			return createAdminMemberTableSQL
 		case "admin_member_role":
 			return createAdminMemberRoleTableSQL
+		case "admin_member_mfa":
+			return createAdminMemberMfaTableSQL
+		case "admin_member_password_history":
+			return createAdminMemberPasswordHistoryTableSQL
 		case "admin_menu":
 			return createAdminMenuTableSql
 		case "admin_role":
@@ -115,6 +191,14 @@ func getCreateTableSQL(tableKey, dbType string) string {
 			return createSysLoginLogTableSQL
 		case "sys_attachment":
 			return createSysAttachmentTableSQL
+		case "sys_outbox":
+			return createSysOutboxTableSQL
+		case "sys_queue_processed":
+			return createSysQueueProcessedTableSQL
+		case "sys_queue_message":
+			return createSysQueueMessageTableSQL
+		case "sys_queue_group":
+			return createSysQueueGroupTableSQL
 		}
 	case "sqlite":
 		switch tableKey {
@@ -122,6 +206,10 @@ func getCreateTableSQL(tableKey, dbType string) string {
 			return createAdminMemberTableSQLite
 		case "admin_member_role":
 			return createAdminMemberRoleTableSQLite
+		case "admin_member_mfa":
+			return createAdminMemberMfaTableSQLite
+		case "admin_member_password_history":
+			return createAdminMemberPasswordHistoryTableSQLite
 		case "admin_menu":
 			return createAdminMenuTableSQLite
 		case "admin_role":
@@ -134,6 +222,14 @@ func getCreateTableSQL(tableKey, dbType string) string {
 			return createSysLoginLogTableSQLite
 		case "sys_attachment":
 			return createSysAttachmentTableSQLite
+		case "sys_outbox":
+			return createSysOutboxTableSQLite
+		case "sys_queue_processed":
+			return createSysQueueProcessedTableSQLite
+		case "sys_queue_message":
+			return createSysQueueMessageTableSQLite
+		case "sys_queue_group":
+			return createSysQueueGroupTableSQLite
 		}
 	}
 	return ""
@@ -166,6 +262,19 @@ func tableExists(ctx g.Ctx, tableName string) bool {
 	return count > 0
 }
 
+// columnType 获取字段类型，字段不存在时 ok 为 false
+func columnType(ctx g.Ctx, tableName, column string) (typ string, ok bool, err error) {
+	fields, err := g.DB().TableFields(ctx, tableName)
+	if err != nil {
+		return "", false, err
+	}
+	field, ok := fields[column]
+	if !ok {
+		return "", false, nil
+	}
+	return field.Type, true, nil
+}
+
 // createTable 创建表
 func createTable(ctx g.Ctx, sql, tableName string) error {
 
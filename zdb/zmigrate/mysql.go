@@ -9,9 +9,10 @@ CREATE TABLE zz_admin_member (
   dept_id bigint DEFAULT '0' COMMENT '部门ID',
   real_name varchar(32) DEFAULT '' COMMENT '真实姓名',
   username varchar(20) NOT NULL DEFAULT '' COMMENT '帐号',
-  password_hash char(32) NOT NULL DEFAULT '' COMMENT '密码',
+  password_hash varchar(255) NOT NULL DEFAULT '' COMMENT '密码',
   salt char(16) NOT NULL COMMENT '密码盐',
   password_reset_token varchar(150) DEFAULT '' COMMENT '密码重置令牌',
+  must_change_password tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否需要修改密码',
   avatar char(150) DEFAULT '' COMMENT '头像',
   sex tinyint(1) DEFAULT '3' COMMENT '性别',
   email varchar(60) DEFAULT '' COMMENT '邮箱',
@@ -72,6 +73,7 @@ CREATE TABLE zz_admin_role (
   remark varchar(255) DEFAULT NULL COMMENT '备注',
   sort int NOT NULL DEFAULT '0' COMMENT '排序',
   status tinyint(1) NOT NULL DEFAULT '1' COMMENT '角色状态',
+  mfa_required tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否强制双因素认证',
   created_at datetime DEFAULT NULL COMMENT '创建时间',
   updated_at datetime DEFAULT NULL COMMENT '修改时间',
   PRIMARY KEY (id) USING BTREE,
@@ -146,3 +148,100 @@ CREATE TABLE zz_sys_attachment (
   PRIMARY KEY (id) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统_附件管理';
 `
+
+var createAdminMemberMfaTableSQL = `
+CREATE TABLE zz_admin_member_mfa (
+  member_id bigint NOT NULL COMMENT '管理员ID',
+  secret varchar(64) NOT NULL DEFAULT '' COMMENT 'TOTP密钥',
+  recovery_codes text COMMENT '恢复码哈希',
+  last_step bigint NOT NULL DEFAULT '0' COMMENT '最后使用的时间步',
+  status tinyint(1) NOT NULL DEFAULT '2' COMMENT '状态',
+  enabled_at datetime DEFAULT NULL COMMENT '启用时间',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  updated_at datetime DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (member_id) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='管理员_双因素认证';
+`
+
+var createAdminMemberPasswordHistoryTableSQL = `
+CREATE TABLE zz_admin_member_password_history (
+  id bigint NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  member_id bigint NOT NULL COMMENT '管理员ID',
+  password_hash varchar(255) NOT NULL DEFAULT '' COMMENT '密码',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (id) USING BTREE,
+  KEY idx_member_id (member_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='管理员_历史密码';
+`
+
+var createSysOutboxTableSQL = `
+CREATE TABLE zz_sys_outbox (
+  id bigint NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  topic varchar(128) NOT NULL COMMENT '主题',
+  msg_id varchar(64) NOT NULL COMMENT '消息ID',
+  body longtext COMMENT '消息内容',
+  status tinyint(1) NOT NULL DEFAULT '0' COMMENT '状态',
+  attempts int NOT NULL DEFAULT '0' COMMENT '发送失败次数',
+  last_error varchar(1000) DEFAULT NULL COMMENT '最后一次发送失败的原因',
+  locked_at datetime DEFAULT NULL COMMENT '锁定时间',
+  sent_at datetime DEFAULT NULL COMMENT '发送时间',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  updated_at datetime DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (id) USING BTREE,
+  KEY idx_status (status,id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统_消息发件箱';
+`
+
+var createSysQueueProcessedTableSQL = `
+CREATE TABLE zz_sys_queue_processed (
+  id bigint NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  msg_key varchar(255) NOT NULL COMMENT '消息标识',
+  expired_at datetime NOT NULL COMMENT '过期时间',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (id) USING BTREE,
+  UNIQUE KEY uk_msg_key (msg_key),
+  KEY idx_expired_at (expired_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统_队列已处理消息';
+`
+
+var createSysQueueMessageTableSQL = `
+CREATE TABLE zz_sys_queue_message (
+  id bigint NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  topic varchar(128) NOT NULL COMMENT '主题',
+  consumer_group varchar(128) NOT NULL DEFAULT '' COMMENT '消费者组',
+  msg_id varchar(64) NOT NULL COMMENT '消息ID',
+  body longtext COMMENT '消息内容',
+  visible_at bigint NOT NULL DEFAULT '0' COMMENT '可见时间（毫秒时间戳）',
+  deliveries int NOT NULL DEFAULT '0' COMMENT '投递次数',
+  receipt varchar(64) DEFAULT NULL COMMENT '读取凭证',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (id) USING BTREE,
+  KEY idx_topic_group (topic,consumer_group,visible_at),
+  KEY idx_msg_id (msg_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统_队列消息';
+`
+
+var createSysQueueGroupTableSQL = `
+CREATE TABLE zz_sys_queue_group (
+  id bigint NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  topic varchar(128) NOT NULL COMMENT '主题',
+  consumer_group varchar(128) NOT NULL COMMENT '消费者组',
+  created_at datetime DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (id) USING BTREE,
+  UNIQUE KEY uk_topic_group (topic,consumer_group)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统_队列消费者组';
+`
+
+// mysql 新增字段语句
+
+var addAdminRoleMfaRequiredColumnSQL = `
+ALTER TABLE zz_admin_role ADD COLUMN mfa_required tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否强制双因素认证' AFTER status;
+`
+
+var modifyAdminMemberPasswordHashColumnSQL = `
+ALTER TABLE zz_admin_member MODIFY COLUMN password_hash varchar(255) NOT NULL DEFAULT '' COMMENT '密码';
+`
+
+var addAdminMemberMustChangePasswordColumnSQL = `
+ALTER TABLE zz_admin_member ADD COLUMN must_change_password tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否需要修改密码' AFTER password_reset_token;
+`
@@ -12,6 +12,7 @@ CREATE TABLE IF NOT EXISTS zz_admin_member (
   password_hash TEXT NOT NULL DEFAULT '',
   salt TEXT NOT NULL,
   password_reset_token TEXT DEFAULT '',
+  must_change_password INTEGER NOT NULL DEFAULT 0,
   avatar TEXT DEFAULT '',
   sex INTEGER DEFAULT 3,
   email TEXT DEFAULT '',
@@ -64,6 +65,7 @@ CREATE TABLE IF NOT EXISTS zz_admin_role (
   remark TEXT,
   sort INTEGER NOT NULL DEFAULT 0,
   status INTEGER NOT NULL DEFAULT 1,
+  mfa_required INTEGER NOT NULL DEFAULT 0,
   created_at TEXT,
   updated_at TEXT
 );
@@ -130,3 +132,84 @@ CREATE TABLE IF NOT EXISTS zz_sys_attachment (
   updated_at TEXT DEFAULT (datetime('now','localtime'))
 );
 `
+
+var createAdminMemberMfaTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_admin_member_mfa (
+  member_id INTEGER PRIMARY KEY,
+  secret TEXT NOT NULL DEFAULT '',
+  recovery_codes TEXT,
+  last_step INTEGER NOT NULL DEFAULT 0,
+  status INTEGER NOT NULL DEFAULT 2,
+  enabled_at TEXT,
+  created_at TEXT,
+  updated_at TEXT
+);
+`
+
+var createAdminMemberPasswordHistoryTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_admin_member_password_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  member_id INTEGER NOT NULL,
+  password_hash TEXT NOT NULL DEFAULT '',
+  created_at TEXT
+);
+`
+
+var createSysOutboxTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_sys_outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  topic TEXT NOT NULL,
+  msg_id TEXT NOT NULL,
+  body TEXT,
+  status INTEGER NOT NULL DEFAULT 0,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  locked_at TEXT,
+  sent_at TEXT,
+  created_at TEXT,
+  updated_at TEXT
+);
+`
+
+var createSysQueueProcessedTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_sys_queue_processed (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  msg_key TEXT NOT NULL UNIQUE,
+  expired_at TEXT NOT NULL,
+  created_at TEXT
+);
+`
+
+var createSysQueueMessageTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_sys_queue_message (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  topic TEXT NOT NULL,
+  consumer_group TEXT NOT NULL DEFAULT '',
+  msg_id TEXT NOT NULL,
+  body TEXT,
+  visible_at INTEGER NOT NULL DEFAULT 0,
+  deliveries INTEGER NOT NULL DEFAULT 0,
+  receipt TEXT,
+  created_at TEXT
+);
+`
+
+var createSysQueueGroupTableSQLite = `
+CREATE TABLE IF NOT EXISTS zz_sys_queue_group (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  topic TEXT NOT NULL,
+  consumer_group TEXT NOT NULL,
+  created_at TEXT,
+  UNIQUE (topic, consumer_group)
+);
+`
+
+// sqlite 新增字段语句
+
+var addAdminRoleMfaRequiredColumnSQLite = `
+ALTER TABLE zz_admin_role ADD COLUMN mfa_required INTEGER NOT NULL DEFAULT 0;
+`
+
+var addAdminMemberMustChangePasswordColumnSQLite = `
+ALTER TABLE zz_admin_member ADD COLUMN must_change_password INTEGER NOT NULL DEFAULT 0;
+`
@@ -3,6 +3,7 @@ package zqueues
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/denghuo98/zzframe/internal/model/entity"
 	"github.com/denghuo98/zzframe/web/zqueue"
@@ -24,11 +25,60 @@ func (q *qLoginLog) GetTopic() string {
 	return zconsts.QueueLoginLogTopic
 }
 
-// Handle 处理消息
+// RetryPolicy 写入失败时重试，避免数据库短暂不可用时丢失登录日志
+func (q *qLoginLog) RetryPolicy() *zqueue.RetryPolicy {
+	return &zqueue.RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Jitter:      0.2,
+	}
+}
+
+// ConsumeOptions 登录高峰时批量写入登录日志
+func (q *qLoginLog) ConsumeOptions() *zqueue.ConsumeOptions {
+	return &zqueue.ConsumeOptions{
+		Workers:   2,
+		BatchSize: 100,
+	}
+}
+
+// Idempotent 消息重复投递时跳过已写入的登录日志
+func (q *qLoginLog) Idempotent() *zqueue.Idempotent {
+	return &zqueue.Idempotent{
+		TTL: 24 * time.Hour,
+	}
+}
+
+// Handle 处理消息，无法解析的消息不再重试
 func (q *qLoginLog) Handle(ctx context.Context, mqMsg zqueue.MqMsg) (err error) {
 	var data entity.SysLoginLog
 	if err = json.Unmarshal(mqMsg.Body, &data); err != nil {
-		return err
+		return zqueue.Unrecoverable(err)
 	}
 	return zservice.SysLoginLog().RealWrite(ctx, data)
 }
+
+// HandleBatch 批量处理消息，无法解析的消息单独进入死信队列，其余消息照常写入
+func (q *qLoginLog) HandleBatch(ctx context.Context, list []zqueue.MqMsg) (err error) {
+	var batchErr zqueue.BatchError
+	logs := make([]entity.SysLoginLog, 0, len(list))
+	ids := make([]string, 0, len(list))
+	for _, mqMsg := range list {
+		var data entity.SysLoginLog
+		if err = json.Unmarshal(mqMsg.Body, &data); err != nil {
+			batchErr.Add(mqMsg.MsgId, zqueue.Unrecoverable(err))
+			continue
+		}
+		logs = append(logs, data)
+		ids = append(ids, mqMsg.MsgId)
+	}
+	if len(logs) > 0 {
+		if err = zservice.SysLoginLog().RealWriteBatch(ctx, logs); err != nil {
+			for _, id := range ids {
+				batchErr.Add(id, err)
+			}
+		}
+	}
+	return batchErr.Err()
+}
@@ -60,6 +60,22 @@ type MemberResetPasswordInput struct {
 	Id int64 `json:"id" v:"required|min:1#用户ID不能为空|用户ID不能小于1" dc:"用户ID"`
 }
 
+// MemberResetPasswordOutput 服务层-系统用户重置密码输出
+type MemberResetPasswordOutput struct {
+	Password string `json:"password" dc:"临时密码，用户下次登录时需要修改"`
+}
+
+// MemberForgotPasswordInput 服务层-找回密码入参
+type MemberForgotPasswordInput struct {
+	Account string `json:"account" v:"required#账号或邮箱不能为空" dc:"账号或邮箱"`
+}
+
+// MemberConfirmPasswordResetInput 服务层-通过重置令牌设置新密码入参
+type MemberConfirmPasswordResetInput struct {
+	Token    string `json:"token"    v:"required#重置令牌不能为空" dc:"密码重置令牌"`
+	Password string `json:"password" v:"required#新密码不能为空"  dc:"新密码"`
+}
+
 // MemberUpdateRoleInput 服务层-系统用户更新角色入参
 type MemberUpdateRoleInput struct {
 	Id      int64
@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// MfaStatusOutput 服务层-双因素认证状态输出
+type MfaStatusOutput struct {
+	Enabled       bool        `json:"enabled"       dc:"是否已启用"`
+	Required      bool        `json:"required"      dc:"所属角色是否强制启用"`
+	RecoveryCodes int         `json:"recoveryCodes" dc:"剩余恢复码数量"`
+	EnabledAt     *gtime.Time `json:"enabledAt"     dc:"启用时间"`
+}
+
+// MfaEnrollOutput 服务层-双因素认证绑定输出
+type MfaEnrollOutput struct {
+	Secret string `json:"secret" dc:"TOTP密钥，无法扫码时手动输入"`
+	Uri    string `json:"uri"    dc:"otpauth地址"`
+	QrCode string `json:"qrCode" dc:"二维码图片base64"`
+}
+
+// MfaCodeInput 服务层-双因素认证验证码入参
+type MfaCodeInput struct {
+	Id   int64
+	Code string `json:"code" v:"required#验证码不能为空" dc:"认证器验证码或恢复码"`
+}
+
+// MfaRecoveryCodesOutput 服务层-恢复码输出，只在生成时返回一次
+type MfaRecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recoveryCodes" dc:"恢复码"`
+}
+
+// MfaResetInput 服务层-重置用户双因素认证入参
+type MfaResetInput struct {
+	MemberId int64 `json:"memberId" v:"required|min:1#用户ID不能为空|用户ID不能小于1" dc:"用户ID"`
+}
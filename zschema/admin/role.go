@@ -37,12 +37,13 @@ type RoleListOutput struct {
 
 // RoleUpdateFields 角色允许更新的数据字段
 type RoleUpdateFields struct {
-	Id     int64  `json:"id"           dc:"角色ID"`
-	Name   string `json:"name"         dc:"角色名称"`
-	Key    string `json:"key"          dc:"角色权限字符串"`
-	Remark string `json:"remark"       dc:"备注"`
-	Sort   int    `json:"sort"         dc:"排序"`
-	Status int    `json:"status"       dc:"角色状态"`
+	Id          int64  `json:"id"           dc:"角色ID"`
+	Name        string `json:"name"         dc:"角色名称"`
+	Key         string `json:"key"          dc:"角色权限字符串"`
+	Remark      string `json:"remark"       dc:"备注"`
+	Sort        int    `json:"sort"         dc:"排序"`
+	Status      int    `json:"status"       dc:"角色状态"`
+	MfaRequired int    `json:"mfaRequired"  dc:"是否强制双因素认证"`
 }
 
 // RoleUpdateMenuInput 服务层-角色更新菜单入参
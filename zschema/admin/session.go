@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/zschema/zform"
+)
+
+// SessionListInput 服务层-登录会话列表入参
+type SessionListInput struct {
+	zform.PageReq
+	MemberId int64  `json:"memberId" dc:"用户ID"`
+	Username string `json:"username" dc:"账号"`
+	App      string `json:"app" dc:"应用"`
+	LoginIp  string `json:"loginIp" dc:"登录IP"`
+}
+
+// SessionItem 登录会话信息
+type SessionItem struct {
+	Id           string      `json:"id" dc:"会话ID"`
+	MemberId     int64       `json:"memberId" dc:"用户ID"`
+	Username     string      `json:"username" dc:"账号"`
+	RealName     string      `json:"realName" dc:"真实姓名"`
+	App          string      `json:"app" dc:"应用"`
+	TokenKey     string      `json:"tokenKey" dc:"令牌缓存key"`
+	LoginIp      string      `json:"loginIp" dc:"登录IP"`
+	UserAgent    string      `json:"userAgent" dc:"UA"`
+	Os           string      `json:"os" dc:"操作系统"`
+	Browser      string      `json:"browser" dc:"浏览器"`
+	RefreshCount int64       `json:"refreshCount" dc:"刷新次数"`
+	CreatedAt    *gtime.Time `json:"createdAt" dc:"登录时间"`
+	LastSeenAt   *gtime.Time `json:"lastSeenAt" dc:"最后活跃时间"`
+	ExpireAt     *gtime.Time `json:"expireAt" dc:"会话过期时间"`
+}
+
+// SessionListOutput 服务层-登录会话列表输出
+type SessionListOutput struct {
+	List []*SessionItem `json:"list" dc:"会话列表"`
+}
+
+// SessionViewInput 服务层-登录会话详情入参
+type SessionViewInput struct {
+	Id string `json:"id" v:"required#会话ID不能为空" dc:"会话ID"`
+}
+
+// SessionKickInput 服务层-强制下线会话入参
+type SessionKickInput struct {
+	Id string `json:"id" v:"required#会话ID不能为空" dc:"会话ID"`
+}
+
+// SessionKickMemberInput 服务层-强制下线用户全部会话入参
+type SessionKickMemberInput struct {
+	MemberId int64 `json:"memberId" v:"required|min:1#用户ID不能为空|用户ID不能小于1" dc:"用户ID"`
+}
@@ -8,12 +8,49 @@ type SiteAccountLoginInput struct {
 	Code     string `json:"code" dc:"验证码"`
 }
 
+// SiteTokenRefreshInput 服务层-刷新令牌入参
+type SiteTokenRefreshInput struct {
+	RefreshToken string `json:"refreshToken" v:"required#刷新令牌不能为空" dc:"刷新令牌"`
+}
+
+// SiteMfaSetupInput 服务层-登录时绑定双因素认证入参
+type SiteMfaSetupInput struct {
+	Ticket string `json:"ticket" v:"required#登录凭证不能为空" dc:"登录二次验证凭证"`
+}
+
+// SiteMfaLoginInput 服务层-双因素认证登录入参
+type SiteMfaLoginInput struct {
+	Ticket string `json:"ticket" v:"required#登录凭证不能为空" dc:"登录二次验证凭证"`
+	Code   string `json:"code"   v:"required#验证码不能为空"   dc:"认证器验证码或恢复码"`
+}
+
+// SitePasswordForgotInput 服务层-找回密码入参
+type SitePasswordForgotInput struct {
+	Account string `json:"account" v:"required#账号或邮箱不能为空" dc:"账号或邮箱"`
+	Cid     string `json:"cid"     v:"required#验证码ID不能为空"  dc:"验证码ID"`
+	Code    string `json:"code"    v:"required#验证码不能为空"    dc:"验证码"`
+}
+
+// SitePasswordResetInput 服务层-通过重置令牌设置新密码入参
+type SitePasswordResetInput struct {
+	Token    string `json:"token"    v:"required#重置令牌不能为空" dc:"密码重置令牌"`
+	Password string `json:"password" v:"required#新密码不能为空"  dc:"新密码，与登录密码相同的加密方式"`
+}
+
 // SiteLoginOutput 服务层-登录输出（不区分登录方式）
+// 需要双因素认证时只返回 mfaTicket，需要修改密码时只返回 resetToken，均不返回登录令牌
 type SiteLoginOutput struct {
-	Id       int64  `json:"id"              dc:"用户ID"`
-	Username string `json:"username"        dc:"用户名"`
-	Token    string `json:"token"           dc:"登录token"`
-	Expires  int64  `json:"expires"         dc:"登录有效期"`
+	Id             int64    `json:"id"                      dc:"用户ID"`
+	Username       string   `json:"username"                dc:"用户名"`
+	Token          string   `json:"token"                   dc:"登录token"`
+	Expires        int64    `json:"expires"                 dc:"登录有效期"`
+	RefreshToken   string   `json:"refreshToken"            dc:"刷新令牌"`
+	RefreshExpires int64    `json:"refreshExpires"          dc:"刷新令牌有效期"`
+	MfaTicket      string   `json:"mfaTicket,omitempty"     dc:"登录二次验证凭证"`
+	MfaSetup       bool     `json:"mfaSetup,omitempty"      dc:"是否需要先绑定认证器"`
+	RecoveryCodes  []string `json:"recoveryCodes,omitempty" dc:"恢复码，仅在登录时完成绑定后返回"`
+	MustChangePwd  bool     `json:"mustChangePwd,omitempty" dc:"是否需要先修改密码"`
+	ResetToken     string   `json:"resetToken,omitempty"    dc:"密码重置令牌，用于修改密码"`
 }
 
 // SiteRouteInfo 服务层-路由信息
@@ -0,0 +1,32 @@
+package system
+
+// SysCacheLayer 二级缓存中一层的命中统计
+type SysCacheLayer struct {
+	Hits    int64   `json:"hits"    dc:"命中次数"`
+	Misses  int64   `json:"misses"  dc:"未命中次数"`
+	HitRate float64 `json:"hitRate" dc:"命中率"`
+	Size    int     `json:"size"    dc:"缓存数量，只统计本地缓存"`
+}
+
+// SysCacheLock 分布式锁统计
+type SysCacheLock struct {
+	Backend   string `json:"backend"   dc:"锁的存储：redis、file、memory"`
+	Held      int64  `json:"held"      dc:"当前持有的锁数量"`
+	Acquired  int64  `json:"acquired"  dc:"获取成功的次数"`
+	Contended int64  `json:"contended" dc:"锁被占用的次数"`
+	Timeout   int64  `json:"timeout"   dc:"等待超时的次数"`
+	WaitTime  int64  `json:"waitTime"  dc:"等待的总时间（毫秒）"`
+	Expired   int64  `json:"expired"   dc:"持有期间续期失败、锁已过期的次数"`
+	Released  int64  `json:"released"  dc:"释放成功的次数"`
+}
+
+// SysCacheStatsOutput 缓存统计
+type SysCacheStatsOutput struct {
+	Adapter     string         `json:"adapter"     dc:"缓存适配器"`
+	L1          *SysCacheLayer `json:"l1"          dc:"本地缓存，适配器为 layered 时返回"`
+	L2          *SysCacheLayer `json:"l2"          dc:"Redis 缓存，适配器为 layered 时返回"`
+	Published   int64          `json:"published"   dc:"发布的失效通知数量"`
+	Received    int64          `json:"received"    dc:"收到其他实例的失效通知数量"`
+	Reconnected int64          `json:"reconnected" dc:"失效通知订阅重连次数，重连时清空本地缓存"`
+	Lock        *SysCacheLock  `json:"lock"        dc:"分布式锁统计"`
+}
@@ -0,0 +1,64 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/zschema/zform"
+)
+
+// SysDeadLetterTopicItem 死信主题
+type SysDeadLetterTopicItem struct {
+	Topic string `json:"topic" dc:"原始主题"`
+	Count int    `json:"count" dc:"死信数量"`
+}
+
+// SysDeadLetterTopicsOutput 死信主题列表
+type SysDeadLetterTopicsOutput struct {
+	List []*SysDeadLetterTopicItem `json:"list" dc:"列表"`
+}
+
+// SysDeadLetterListInput 死信列表入参
+type SysDeadLetterListInput struct {
+	zform.PageReq
+	Topic string `json:"topic" v:"required#主题不能为空" dc:"原始主题"`
+}
+
+// SysDeadLetterItem 死信
+type SysDeadLetterItem struct {
+	MsgId     string            `json:"msgId"     dc:"消息ID"`
+	Topic     string            `json:"topic"     dc:"原始主题"`
+	Body      string            `json:"body"      dc:"消息内容"`
+	Attempts  int               `json:"attempts"  dc:"处理失败次数"`
+	Error     string            `json:"error"     dc:"最后一次失败原因"`
+	CreatedAt *gtime.Time       `json:"createdAt" dc:"消息生产时间"`
+	FailedAt  string            `json:"failedAt"  dc:"进入死信队列时间"`
+	Headers   map[string]string `json:"headers"   dc:"消息头"`
+}
+
+// SysDeadLetterListOutput 死信列表
+type SysDeadLetterListOutput struct {
+	List []*SysDeadLetterItem `json:"list" dc:"列表"`
+}
+
+// SysDeadLetterViewInput 死信详情入参
+type SysDeadLetterViewInput struct {
+	Topic string `json:"topic" v:"required#主题不能为空"   dc:"原始主题"`
+	MsgId string `json:"msgId" v:"required#消息ID不能为空" dc:"消息ID"`
+}
+
+// SysDeadLetterReplayInput 重新投递死信入参
+type SysDeadLetterReplayInput struct {
+	Topic  string   `json:"topic"  v:"required#主题不能为空"   dc:"原始主题"`
+	MsgIds []string `json:"msgIds" v:"required#消息ID不能为空" dc:"消息ID"`
+}
+
+// SysDeadLetterPurgeInput 清除死信入参
+type SysDeadLetterPurgeInput struct {
+	Topic  string   `json:"topic"  v:"required#主题不能为空" dc:"原始主题"`
+	MsgIds []string `json:"msgIds" dc:"消息ID，为空时清空该主题的全部死信"`
+}
+
+// SysDeadLetterPurgeOutput 清除死信输出
+type SysDeadLetterPurgeOutput struct {
+	Count int `json:"count" dc:"清除数量"`
+}
@@ -13,6 +13,12 @@ type SysLoginLogPushInput struct {
 	Error    error
 }
 
+// SysLoginUnlockInput 解除登录锁定入参
+type SysLoginUnlockInput struct {
+	Username string `json:"username" dc:"账号"`
+	Ip       string `json:"ip"       dc:"登录IP"`
+}
+
 type SysLoginLogDeleteInput struct {
 	Id int64
 }
@@ -0,0 +1,61 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// SysQueueLatencyBucket 处理耗时区间
+type SysQueueLatencyBucket struct {
+	Le    string `json:"le"    dc:"耗时上限，+Inf 为不限"`
+	Count int64  `json:"count" dc:"耗时在该区间的处理次数"`
+}
+
+// SysQueueLatency 处理耗时分布
+type SysQueueLatency struct {
+	Count   int64                    `json:"count"   dc:"处理次数"`
+	Total   float64                  `json:"total"   dc:"总耗时（毫秒）"`
+	Max     float64                  `json:"max"     dc:"最长耗时（毫秒）"`
+	Buckets []*SysQueueLatencyBucket `json:"buckets" dc:"各耗时区间的处理次数"`
+}
+
+// SysQueueGroupItem 消费者组统计
+type SysQueueGroupItem struct {
+	Group       string           `json:"group"       dc:"消费者组"`
+	Committed   string           `json:"committed"   dc:"已提交的位置"`
+	Lag         int64            `json:"lag"         dc:"未消费的消息数量"`
+	LagBytes    int64            `json:"lagBytes"    dc:"磁盘队列未消费的数据大小"`
+	Pending     int64            `json:"pending"     dc:"Redis 队列已读取未确认的消息数量"`
+	Consumed    int64            `json:"consumed"    dc:"处理成功的消息数量"`
+	Failed      int64            `json:"failed"      dc:"处理失败的消息数量"`
+	LastError   string           `json:"lastError"   dc:"最后一次处理失败的原因"`
+	LastErrorAt *gtime.Time      `json:"lastErrorAt" dc:"最后一次处理失败的时间"`
+	Latency     *SysQueueLatency `json:"latency"     dc:"处理耗时分布"`
+}
+
+// SysQueueRecovery 磁盘队列数据校验和恢复统计
+type SysQueueRecovery struct {
+	Segments       int64       `json:"segments"       dc:"校验的分片数量"`
+	Records        int64       `json:"records"        dc:"校验的记录数量"`
+	Truncated      int64       `json:"truncated"      dc:"截断不完整记录的分片数量"`
+	TruncatedBytes int64       `json:"truncatedBytes" dc:"截断的数据大小"`
+	Corrupted      int64       `json:"corrupted"      dc:"隔离损坏记录的分片数量"`
+	CorruptedBytes int64       `json:"corruptedBytes" dc:"隔离的数据大小"`
+	RecoveredAt    *gtime.Time `json:"recoveredAt"    dc:"最后一次截断或隔离的时间"`
+}
+
+// SysQueueTopicItem 主题统计
+type SysQueueTopicItem struct {
+	Topic    string               `json:"topic"    dc:"主题"`
+	Produced int64                `json:"produced" dc:"生产成功的消息数量"`
+	Position string               `json:"position" dc:"写入位置"`
+	Length   int64                `json:"length"   dc:"Redis 队列保留的消息数量"`
+	Segments int64                `json:"segments" dc:"磁盘队列的数据文件数量"`
+	Bytes    int64                `json:"bytes"    dc:"磁盘队列的数据文件大小"`
+	Groups   []*SysQueueGroupItem `json:"groups"   dc:"消费者组"`
+	Recovery *SysQueueRecovery    `json:"recovery" dc:"磁盘队列数据校验和恢复统计"`
+}
+
+// SysQueueStatsOutput 消息队列统计
+type SysQueueStatsOutput struct {
+	List []*SysQueueTopicItem `json:"list" dc:"主题列表"`
+}
@@ -1,5 +1,7 @@
 package zweb
 
+import "github.com/gogf/gf/v2/os/gtime"
+
 type SystemConfig struct {
 	Mode string `json:"mode" dc:"系统模式"` // dev | test | prod
 }
@@ -14,18 +16,105 @@ type AnonymousConfig struct {
 }
 
 type CacheConfig struct {
-	Adapter string `json:"adapter" dc:"缓存适配器"`  // redis | file
-	FileDir string `json:"fileDir" dc:"文件缓存目录"` // 文件缓存目录，当adapter为file时必填
+	Adapter string `json:"adapter" dc:"缓存适配器"`   // redis | file | layered
+	FileDir string `json:"fileDir" dc:"文件缓存目录"`  // 文件缓存目录，当adapter为file时必填
+	L1Size  int    `json:"l1Size"  dc:"本地缓存容量"`  // adapter为layered时有效，默认10000
+	L1Ttl   int64  `json:"l1Ttl"   dc:"本地缓存有效期"` // adapter为layered时有效，单位毫秒，默认5000
 }
 
 // TokenConfig 登录令牌配置
 type TokenConfig struct {
 	SecretKey       string `json:"secretKey"`
-	Expires         int64  `json:"expires"`
-	AutoRefresh     bool   `json:"autoRefresh"`
-	RefreshInterval int64  `json:"refreshInterval"`
-	MaxRefreshTimes int64  `json:"maxRefreshTimes"`
+	Expires         int64  `json:"expires"`         // 访问令牌有效期，单位秒
+	RefreshExpires  int64  `json:"refreshExpires"`  // 刷新令牌有效期，单位秒，每次轮换后重新计算
+	MaxRefreshTimes int64  `json:"maxRefreshTimes"` // 单次登录最多轮换次数，0或-1为不限制
 	MultiLogin      bool   `json:"multiLogin"`
+
+	// 签名密钥，未配置时使用 secretKey 以 HS256 签名
+	ActiveKid string            `json:"activeKid"` // 当前签名密钥ID，为空时使用 keys 中的第一个
+	Keys      []*TokenKeyConfig `json:"keys"`      // 签名密钥列表
+	KeyGrace  int64             `json:"keyGrace"`  // 停用密钥的验签宽限期，单位秒，默认与 expires 一致
+	Jwks      bool              `json:"jwks"`      // 是否开放 JWKS 公钥接口
+}
+
+// TokenKeyConfig 令牌签名密钥配置
+type TokenKeyConfig struct {
+	Kid        string      `json:"kid"`        // 密钥ID
+	Algorithm  string      `json:"algorithm"`  // 签名算法：HS256/RS256/ES256/EdDSA 等
+	Secret     string      `json:"secret"`     // HMAC 密钥
+	PrivateKey string      `json:"privateKey"` // PEM 私钥内容或文件路径，不配置时仅用于验签
+	PublicKey  string      `json:"publicKey"`  // PEM 公钥内容或文件路径，配置私钥时可省略
+	RetiredAt  *gtime.Time `json:"retiredAt"`  // 停用时间，停用后仅在宽限期内验签
+}
+
+// MfaConfig 双因素认证配置
+type MfaConfig struct {
+	Issuer        string `json:"issuer"`        // 认证器App中显示的签发方名称
+	TicketExpires int64  `json:"ticketExpires"` // 登录二次验证凭证有效期，单位秒
+}
+
+// PasswordConfig 密码配置
+type PasswordConfig struct {
+	Algorithm   string `json:"algorithm"`   // 哈希算法：argon2id/bcrypt，默认 argon2id
+	Memory      uint32 `json:"memory"`      // argon2id 内存开销，单位KiB
+	Iterations  uint32 `json:"iterations"`  // argon2id 迭代次数
+	Parallelism uint8  `json:"parallelism"` // argon2id 并行度
+	MaxMemory   uint32 `json:"maxMemory"`   // argon2id 校验哈希时允许的最大内存开销，单位KiB
+	Cost        int    `json:"cost"`        // bcrypt 计算强度
+
+	// 密码策略
+	MinLength   int `json:"minLength"`   // 最小长度
+	MaxLength   int `json:"maxLength"`   // 最大长度
+	CharClasses int `json:"charClasses"` // 至少包含大写字母、小写字母、数字、特殊字符中的几种
+	History     int `json:"history"`     // 不能与最近几次使用过的密码相同（含当前密码），0为不限制
+
+	// 找回密码
+	ResetExpires int64  `json:"resetExpires"` // 重置令牌有效期，单位秒
+	ResetUrl     string `json:"resetUrl"`     // 重置密码页面地址，{token} 会被替换为重置令牌
+}
+
+// NotifyConfig 消息通知配置
+type NotifyConfig struct {
+	Driver string      `json:"driver"` // 通知驱动：smtp/log/file，为空时不发送通知
+	Smtp   *SmtpConfig `json:"smtp"`   // SMTP 邮件配置
+	File   string      `json:"file"`   // file 驱动的输出文件，每条消息一行 JSON
+}
+
+// SmtpConfig SMTP 邮件配置
+type SmtpConfig struct {
+	Host     string `json:"host"`     // 服务器地址
+	Port     int    `json:"port"`     // 服务器端口
+	Username string `json:"username"` // 登录账号
+	Password string `json:"password"` // 登录密码或授权码
+	From     string `json:"from"`     // 发件人，默认与 username 一致
+	Ssl      bool   `json:"ssl"`      // 是否使用 SSL 连接，通常为 465 端口
+}
+
+// LoginProtectConfig 登录防暴力破解配置
+// 按账号和客户端IP分别统计失败次数，次数配置为-1时关闭对应的限制
+type LoginProtectConfig struct {
+	CaptchaAttempts int   `json:"captchaAttempts"` // 失败多少次后需要输入验证码
+	LockAttempts    int   `json:"lockAttempts"`    // 失败多少次后锁定
+	LockDuration    int64 `json:"lockDuration"`    // 锁定时长，单位秒
+	Window          int64 `json:"window"`          // 失败次数统计周期，单位秒
+}
+
+// RateLimitConfig 接口限流配置
+type RateLimitConfig struct {
+	Enabled bool             `json:"enabled"` // 是否启用限流
+	Rules   []*RateLimitRule `json:"rules"`   // 限流规则，按顺序匹配，请求只使用第一条匹配的规则
+}
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	Pattern   string   `json:"pattern"`   // 路由地址，以 * 结尾时按前缀匹配，如 /admin/*
+	Methods   []string `json:"methods"`   // 请求方法，为空时不限制
+	Key       string   `json:"key"`       // 限流维度：ip/user/apiKey，默认 ip，无法获取用户或 apiKey 时按 ip
+	Header    string   `json:"header"`    // key 为 apiKey 时读取的请求头，默认 X-Api-Key
+	Algorithm string   `json:"algorithm"` // 限流算法：slidingWindow/tokenBucket，默认 slidingWindow
+	Limit     int      `json:"limit"`     // 统计周期内允许的请求数
+	Period    int64    `json:"period"`    // 统计周期，单位秒，默认 1
+	Burst     int      `json:"burst"`     // tokenBucket 的桶容量，即允许的突发请求数，默认与 limit 一致
 }
 
 // UploadConfig 上传配置
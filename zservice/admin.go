@@ -83,20 +83,81 @@ type IAdminMember interface {
 	UpdatePassword(ctx g.Ctx, in *admin.MemberUpdatePasswordInput) error
 
 	// ResetPassword 重置用户密码
-	// 将用户密码重置为默认密码
-	ResetPassword(ctx g.Ctx, in *admin.MemberResetPasswordInput) error
+	// 生成随机临时密码，用户下次登录时必须修改密码
+	ResetPassword(ctx g.Ctx, in *admin.MemberResetPasswordInput) (*admin.MemberResetPasswordOutput, error)
+
+	// ForgotPassword 找回密码
+	// 按账号或邮箱查找用户，生成一次性重置令牌并通过消息通知发送，用户不存在时同样返回成功
+	ForgotPassword(ctx g.Ctx, in *admin.MemberForgotPasswordInput) error
+
+	// IssuePasswordResetToken 生成一次性密码重置令牌，数据库中只保存令牌的哈希
+	IssuePasswordResetToken(ctx g.Ctx, id int64) (string, error)
+
+	// ConfirmPasswordReset 使用重置令牌设置新密码
+	// 令牌使用后失效，并注销用户的全部登录会话
+	ConfirmPasswordReset(ctx g.Ctx, in *admin.MemberConfirmPasswordResetInput) error
 
 	// Delete 删除成员
 	// 软删除用户，将用户状态设置为禁用
 	Delete(ctx g.Ctx, in *admin.MemberDeleteInput) error
 }
 
+// IAdminSession 登录会话管理接口
+// 查看在线会话，并支持强制下线
+type IAdminSession interface {
+	// List 获取登录会话列表
+	// 支持按用户、账号、应用、登录IP过滤，按登录时间倒序分页
+	List(ctx g.Ctx, in *admin.SessionListInput) (*admin.SessionListOutput, int, error)
+
+	// View 获取登录会话详情
+	View(ctx g.Ctx, in *admin.SessionViewInput) (*admin.SessionItem, error)
+
+	// Kick 强制下线一个会话
+	Kick(ctx g.Ctx, in *admin.SessionKickInput) error
+
+	// KickMember 强制下线用户的全部会话
+	KickMember(ctx g.Ctx, in *admin.SessionKickMemberInput) error
+}
+
+// IAdminMfa 双因素认证管理接口
+// 基于 RFC 6238 TOTP，支持恢复码和按角色强制启用
+type IAdminMfa interface {
+	// Status 获取用户的双因素认证状态
+	Status(ctx g.Ctx, memberId int64) (*admin.MfaStatusOutput, error)
+
+	// Enabled 用户是否已启用双因素认证
+	Enabled(ctx g.Ctx, memberId int64) (bool, error)
+
+	// Required 用户所属角色是否强制启用双因素认证
+	Required(ctx g.Ctx, memberId int64) (bool, error)
+
+	// Enroll 生成新的TOTP密钥，验证通过后才会启用
+	Enroll(ctx g.Ctx, memberId int64) (*admin.MfaEnrollOutput, error)
+
+	// Activate 验证认证器验证码并启用双因素认证，返回恢复码
+	Activate(ctx g.Ctx, in *admin.MfaCodeInput) (*admin.MfaRecoveryCodesOutput, error)
+
+	// Verify 校验认证器验证码或恢复码，恢复码使用后失效
+	Verify(ctx g.Ctx, in *admin.MfaCodeInput) error
+
+	// Disable 关闭双因素认证，角色强制启用时不能关闭
+	Disable(ctx g.Ctx, in *admin.MfaCodeInput) error
+
+	// RecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+	RecoveryCodes(ctx g.Ctx, in *admin.MfaCodeInput) (*admin.MfaRecoveryCodesOutput, error)
+
+	// Reset 管理员重置用户的双因素认证，用于用户丢失认证设备
+	Reset(ctx g.Ctx, in *admin.MfaResetInput) error
+}
+
 // AdminRole 角色管理单例实例
 // 通过接口提供服务，实现依赖倒置和单例访问
 var (
-	localAdminRole   IAdminRole
-	localAdminMenu   IAdminMenu
-	localAdminMember IAdminMember
+	localAdminRole    IAdminRole
+	localAdminMenu    IAdminMenu
+	localAdminMember  IAdminMember
+	localAdminSession IAdminSession
+	localAdminMfa     IAdminMfa
 )
 
 func AdminRole() IAdminRole {
@@ -135,3 +196,29 @@ func AdminMember() IAdminMember {
 func RegisterAdminMember(i IAdminMember) {
 	localAdminMember = i
 }
+
+// AdminSession 登录会话管理单例实例
+// 通过接口提供服务，实现依赖倒置和单例访问
+func AdminSession() IAdminSession {
+	if localAdminSession == nil {
+		panic("AdminSession is not initialized, please register it first")
+	}
+	return localAdminSession
+}
+
+func RegisterAdminSession(i IAdminSession) {
+	localAdminSession = i
+}
+
+// AdminMfa 双因素认证管理单例实例
+// 通过接口提供服务，实现依赖倒置和单例访问
+func AdminMfa() IAdminMfa {
+	if localAdminMfa == nil {
+		panic("AdminMfa is not initialized, please register it first")
+	}
+	return localAdminMfa
+}
+
+func RegisterAdminMfa(i IAdminMfa) {
+	localAdminMfa = i
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
 	commonSchema "github.com/denghuo98/zzframe/zschema/common"
 	"github.com/denghuo98/zzframe/zschema/zweb"
 )
@@ -12,6 +13,11 @@ type ICommonSite interface {
 	Ping(ctx g.Ctx) string
 	InitSuperAdmin(ctx g.Ctx) error
 	AccountLogin(ctx g.Ctx, in *commonSchema.SiteAccountLoginInput) (out *commonSchema.SiteLoginOutput, err error)
+	TokenRefresh(ctx g.Ctx, in *commonSchema.SiteTokenRefreshInput) (out *commonSchema.SiteLoginOutput, err error)
+	MfaSetup(ctx g.Ctx, in *commonSchema.SiteMfaSetupInput) (out *adminSchema.MfaEnrollOutput, err error)
+	MfaLogin(ctx g.Ctx, in *commonSchema.SiteMfaLoginInput) (out *commonSchema.SiteLoginOutput, err error)
+	PasswordForgot(ctx g.Ctx, in *commonSchema.SitePasswordForgotInput) error
+	PasswordReset(ctx g.Ctx, in *commonSchema.SitePasswordResetInput) error
 	BindUserContext(ctx g.Ctx, claims *zweb.Identity) error
 	GetRoutes(ctx g.Ctx) (out *commonSchema.SiteRoutesOutput, err error)
 }
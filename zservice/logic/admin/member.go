@@ -1,10 +1,16 @@
 package admin
 
 import (
-	"github.com/gogf/gf/v2/crypto/gmd5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
 	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/grand"
 	"github.com/samber/lo"
@@ -12,6 +18,8 @@ import (
 	"github.com/denghuo98/zzframe/internal/dao"
 	"github.com/denghuo98/zzframe/internal/model/entity"
 	"github.com/denghuo98/zzframe/web/zcontext"
+	"github.com/denghuo98/zzframe/web/znotify"
+	"github.com/denghuo98/zzframe/web/zpassword"
 	"github.com/denghuo98/zzframe/zconsts"
 	"github.com/denghuo98/zzframe/zdb/zgorm"
 	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
@@ -21,6 +29,9 @@ import (
 type sAdminMember struct {
 }
 
+// passwordResetInterval 找回密码重复发送的最小间隔（秒）
+const passwordResetInterval = 60
+
 func init() {
 	zservice.RegisterAdminMember(NewAdminMember())
 }
@@ -191,6 +202,20 @@ func (s *sAdminMember) Edit(ctx g.Ctx, in *adminSchema.MemberEditInput) (err err
 		}
 	}
 
+	// 修改密码时需要校验密码策略和历史密码
+	var mb *entity.AdminMember
+	if in.Id > 0 && in.Password != "" {
+		if err = dao.AdminMember.Ctx(ctx).WherePri(in.Id).Scan(&mb); err != nil {
+			return gerror.Wrap(err, zconsts.ErrorORM)
+		}
+		if mb == nil {
+			return gerror.New("用户不存在")
+		}
+		if in.PasswordHash, err = s.hashPassword(ctx, mb, in.Password); err != nil {
+			return err
+		}
+	}
+
 	tx, err := g.DB().Begin(ctx)
 	if err != nil {
 		return gerror.Wrap(err, zconsts.ErrorORM)
@@ -209,16 +234,11 @@ func (s *sAdminMember) Edit(ctx g.Ctx, in *adminSchema.MemberEditInput) (err err
 	if in.Id > 0 {
 		// TODO 超管账号禁止编辑
 
-		// 修改密码，需要使用到密码盐进行加密
-		if in.Password != "" {
-			salt, err := m.Fields(cols.Salt).WherePri(in.Id).Value()
-			if err != nil {
-				return gerror.Wrap(err, zconsts.ErrorORM)
+		// 修改密码，记录被替换的密码
+		if mb != nil {
+			if err = s.savePasswordHistory(tx, mb); err != nil {
+				return err
 			}
-			if salt.IsEmpty() {
-				return gerror.New("该用户没有设置密码盐，请联系管理员！")
-			}
-			in.PasswordHash = gmd5.MustEncryptString(in.Password + salt.String())
 		} else {
 			// 忽略密码字段
 			m = m.FieldsEx(cols.PasswordHash)
@@ -232,7 +252,9 @@ func (s *sAdminMember) Edit(ctx g.Ctx, in *adminSchema.MemberEditInput) (err err
 		var addIn adminSchema.MemberAddInput
 		addIn.MemberEditInput = in
 		addIn.Salt = grand.S(6)
-		addIn.PasswordHash = gmd5.MustEncryptString(in.Password + addIn.Salt)
+		if addIn.PasswordHash, err = s.hashPassword(ctx, nil, in.Password); err != nil {
+			return err
+		}
 		if id, err := m.Data(addIn).OmitEmpty().InsertAndGetId(); err != nil {
 			return gerror.Wrap(err, zconsts.ErrorORM)
 		} else {
@@ -300,49 +322,177 @@ func (s *sAdminMember) UpdatePassword(ctx g.Ctx, in *adminSchema.MemberUpdatePas
 		return gerror.New("超级管理员密码不能修改")
 	}
 
-	if gmd5.MustEncryptString(in.OldPassword+mb.Salt) != mb.PasswordHash {
+	ok, err := zpassword.Verify(in.OldPassword, mb.Salt, mb.PasswordHash)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return gerror.New("旧密码错误")
 	}
-	if gmd5.MustEncryptString(in.NewPassword+mb.Salt) == mb.PasswordHash {
+	if same, _ := zpassword.Verify(in.NewPassword, mb.Salt, mb.PasswordHash); same {
 		return gerror.New("新密码不能与旧密码相同")
 	}
 
-	update := g.Map{
-		dao.AdminMember.Columns().PasswordHash: gmd5.MustEncryptString(in.NewPassword + mb.Salt),
-	}
-	if _, err = dao.AdminMember.Ctx(ctx).WherePri(in.Id).Data(update).Update(); err != nil {
-		return gerror.Wrap(err, zconsts.ErrorORM)
+	hash, err := s.hashPassword(ctx, mb, in.NewPassword)
+	if err != nil {
+		return err
 	}
-	return nil
+	return s.updatePassword(ctx, mb, hash, false)
 }
 
-func (s *sAdminMember) ResetPassword(ctx g.Ctx, in *adminSchema.MemberResetPasswordInput) (err error) {
+func (s *sAdminMember) ResetPassword(ctx g.Ctx, in *adminSchema.MemberResetPasswordInput) (out *adminSchema.MemberResetPasswordOutput, err error) {
 	if in.Id <= 0 {
-		return gerror.New("用户ID不能为空")
+		return nil, gerror.New("用户ID不能为空")
 	}
 
 	var mb *entity.AdminMember
 	if err = dao.AdminMember.Ctx(ctx).WherePri(in.Id).Scan(&mb); err != nil {
-		return gerror.Wrap(err, zconsts.ErrorORM)
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
 	}
 	if mb == nil {
-		return gerror.New("用户不存在")
+		return nil, gerror.New("用户不存在")
 	}
 
 	// 验证是否是超级管理员，超级管理员不能重置密码
 	if s.VerifySuperAdmin(ctx, in.Id) {
-		return gerror.New("超级管理员密码不能重置")
+		return nil, gerror.New("超级管理员密码不能重置")
 	}
 
-	update := g.Map{
-		dao.AdminMember.Columns().PasswordHash: gmd5.MustEncryptString(zconsts.DefaultUserPassword + mb.Salt),
+	// 生成随机临时密码，用户下次登录时必须修改
+	password, err := zpassword.Generate()
+	if err != nil {
+		return nil, err
 	}
-	if _, err = dao.AdminMember.Ctx(ctx).WherePri(in.Id).Data(update).Update(); err != nil {
+	hash, err := zpassword.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.updatePassword(ctx, mb, hash, true); err != nil {
+		return nil, err
+	}
+
+	// 重置密码后注销用户的全部登录会话
+	if err = zservice.AdminSession().KickMember(ctx, &adminSchema.SessionKickMemberInput{MemberId: in.Id}); err != nil {
+		return nil, err
+	}
+	return &adminSchema.MemberResetPasswordOutput{Password: password}, nil
+}
+
+// ForgotPassword 找回密码，不论账号是否存在都返回成功，避免通过该接口探测账号
+func (s *sAdminMember) ForgotPassword(ctx g.Ctx, in *adminSchema.MemberForgotPasswordInput) (err error) {
+	if in.Account == "" {
+		return gerror.New("账号或邮箱不能为空")
+	}
+	// 未配置通知时无法发送重置令牌
+	if !znotify.Enabled() {
+		return gerror.New("未开启找回密码功能，请联系管理员重置密码")
+	}
+
+	cols := dao.AdminMember.Columns()
+	var mb *entity.AdminMember
+	if err = dao.AdminMember.Ctx(ctx).Where(cols.Username, in.Account).Scan(&mb); err != nil {
 		return gerror.Wrap(err, zconsts.ErrorORM)
 	}
+	if mb == nil {
+		if err = dao.AdminMember.Ctx(ctx).Where(cols.Email, in.Account).Scan(&mb); err != nil {
+			return gerror.Wrap(err, zconsts.ErrorORM)
+		}
+	}
+	if mb == nil || mb.Status != zconsts.StatusEnabled || mb.Email == "" {
+		g.Log().Infof(ctx, "forgot password skipped, account:%v not found or has no email", in.Account)
+		return nil
+	}
+	// 超级管理员的密码由配置文件维护
+	if s.VerifySuperAdmin(ctx, mb.Id) {
+		g.Log().Infof(ctx, "forgot password skipped, account:%v is super admin", in.Account)
+		return nil
+	}
+
+	// 限制重复发送的频率
+	conf := zpassword.GetConfig()
+	if _, expireAt := s.parseResetToken(mb.PasswordResetToken); expireAt-conf.ResetExpires+passwordResetInterval > gtime.Timestamp() {
+		g.Log().Infof(ctx, "forgot password skipped, account:%v requested too frequently", in.Account)
+		return nil
+	}
+
+	token, err := s.IssuePasswordResetToken(ctx, mb.Id)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("您的密码重置令牌为：%v", token)
+	if conf.ResetUrl != "" {
+		content = fmt.Sprintf("请访问以下链接重置密码：\n%v", strings.ReplaceAll(conf.ResetUrl, "{token}", url.QueryEscape(token)))
+	}
+	content = fmt.Sprintf("您正在重置账号 %v 的密码。%v\n链接%d分钟内有效且只能使用一次，如非本人操作请忽略。", mb.Username, content, conf.ResetExpires/60)
+
+	// 发送失败只记录日志，避免通过错误信息探测账号
+	if err = znotify.Send(ctx, &znotify.Message{To: mb.Email, Subject: "重置密码", Content: content}); err != nil {
+		g.Log().Errorf(ctx, "send password reset notify err:%+v", err)
+	}
 	return nil
 }
 
+// IssuePasswordResetToken 生成一次性密码重置令牌，数据库中保存令牌哈希和过期时间
+func (s *sAdminMember) IssuePasswordResetToken(ctx g.Ctx, id int64) (token string, err error) {
+	token = grand.S(32)
+	expireAt := gtime.Timestamp() + zpassword.GetConfig().ResetExpires
+	if _, err = dao.AdminMember.Ctx(ctx).WherePri(id).Data(g.Map{
+		dao.AdminMember.Columns().PasswordResetToken: fmt.Sprintf("%v:%v", s.hashResetToken(token), expireAt),
+	}).Update(); err != nil {
+		return "", gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return token, nil
+}
+
+// ConfirmPasswordReset 使用重置令牌设置新密码
+func (s *sAdminMember) ConfirmPasswordReset(ctx g.Ctx, in *adminSchema.MemberConfirmPasswordResetInput) (err error) {
+	if in.Token == "" {
+		return gerror.New("重置令牌不能为空")
+	}
+
+	cols := dao.AdminMember.Columns()
+	var mb *entity.AdminMember
+	if err = dao.AdminMember.Ctx(ctx).WhereLike(cols.PasswordResetToken, s.hashResetToken(in.Token)+":%").Scan(&mb); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if mb == nil {
+		return gerror.New("重置令牌无效或已过期")
+	}
+	if _, expireAt := s.parseResetToken(mb.PasswordResetToken); expireAt < gtime.Timestamp() {
+		return gerror.New("重置令牌无效或已过期")
+	}
+	if mb.Status != zconsts.StatusEnabled {
+		return gerror.New("账号已禁用")
+	}
+
+	hash, err := s.hashPassword(ctx, mb, in.Password)
+	if err != nil {
+		return err
+	}
+
+	err = dao.AdminMember.Transaction(ctx, func(ctx g.Ctx, tx gdb.TX) error {
+		// 令牌只能使用一次，并发请求时只有一个能成功
+		res, err := g.Model(dao.AdminMember.Table()).TX(tx).WherePri(mb.Id).
+			Where(cols.PasswordResetToken, mb.PasswordResetToken).
+			Data(g.Map{cols.PasswordResetToken: ""}).
+			Update()
+		if err != nil {
+			return gerror.Wrap(err, zconsts.ErrorORM)
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return gerror.New("重置令牌无效或已过期")
+		}
+		return s.updatePasswordTx(tx, mb, hash, false)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 重置密码后注销用户的全部登录会话
+	return zservice.AdminSession().KickMember(ctx, &adminSchema.SessionKickMemberInput{MemberId: mb.Id})
+}
+
 func (s *sAdminMember) Delete(ctx g.Ctx, in *adminSchema.MemberDeleteInput) (err error) {
 	if in.Id <= 0 {
 		return gerror.New("用户ID不能为空")
@@ -357,9 +507,126 @@ func (s *sAdminMember) Delete(ctx g.Ctx, in *adminSchema.MemberDeleteInput) (err
 	if _, err = dao.AdminMember.Ctx(ctx).WherePri(in.Id).Data(g.Map{cols.Status: zconsts.StatusDisable}).Update(); err != nil {
 		return gerror.Wrap(err, zconsts.ErrorORM)
 	}
+
+	// 删除后注销用户的全部登录会话
+	return zservice.AdminSession().KickMember(ctx, &adminSchema.SessionKickMemberInput{MemberId: in.Id})
+}
+
+// hashPassword 校验密码策略后生成密码哈希，修改已有用户的密码时同时校验历史密码
+func (s *sAdminMember) hashPassword(ctx g.Ctx, mb *entity.AdminMember, password string) (hash string, err error) {
+	if err = zpassword.CheckPolicy(password); err != nil {
+		return "", err
+	}
+	if mb != nil {
+		if err = s.checkPasswordHistory(ctx, mb, password); err != nil {
+			return "", err
+		}
+	}
+	return zpassword.Hash(password)
+}
+
+// checkPasswordHistory 新密码不能与最近几次使用过的密码相同
+func (s *sAdminMember) checkPasswordHistory(ctx g.Ctx, mb *entity.AdminMember, password string) (err error) {
+	history := zpassword.GetConfig().History
+	if history <= 0 {
+		return nil
+	}
+
+	// 当前密码加上历史记录中被替换的密码
+	hashes := []string{mb.PasswordHash}
+	if history > 1 {
+		cols := dao.AdminMemberPasswordHistory.Columns()
+		values, err := dao.AdminMemberPasswordHistory.Ctx(ctx).
+			Where(cols.MemberId, mb.Id).
+			OrderDesc(cols.Id).
+			Limit(history - 1).
+			Array(cols.PasswordHash)
+		if err != nil {
+			return gerror.Wrap(err, zconsts.ErrorORM)
+		}
+		hashes = append(hashes, gconv.Strings(values)...)
+	}
+
+	for _, hash := range hashes {
+		ok, err := zpassword.Verify(password, mb.Salt, hash)
+		if err != nil {
+			g.Log().Warningf(ctx, "verify password history err:%+v", err)
+			continue
+		}
+		if ok {
+			return gerror.Newf("新密码不能与最近%d次使用过的密码相同", history)
+		}
+	}
+	return nil
+}
+
+// savePasswordHistory 记录被替换的密码，只保留校验历史密码需要的条数
+func (s *sAdminMember) savePasswordHistory(tx gdb.TX, mb *entity.AdminMember) (err error) {
+	history := zpassword.GetConfig().History
+	if history <= 1 || mb.PasswordHash == "" {
+		return nil
+	}
+
+	cols := dao.AdminMemberPasswordHistory.Columns()
+	if _, err = g.Model(dao.AdminMemberPasswordHistory.Table()).TX(tx).Data(&entity.AdminMemberPasswordHistory{
+		MemberId:     mb.Id,
+		PasswordHash: mb.PasswordHash,
+	}).OmitEmpty().Insert(); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+
+	ids, err := g.Model(dao.AdminMemberPasswordHistory.Table()).TX(tx).Where(cols.MemberId, mb.Id).OrderDesc(cols.Id).Array(cols.Id)
+	if err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if len(ids) < history {
+		return nil
+	}
+	if _, err = g.Model(dao.AdminMemberPasswordHistory.Table()).TX(tx).WhereIn(cols.Id, ids[history-1:]).Delete(); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
 	return nil
 }
 
+// updatePassword 更新密码并记录被替换的密码，mustChange 表示用户下次登录时是否必须修改密码
+func (s *sAdminMember) updatePassword(ctx g.Ctx, mb *entity.AdminMember, hash string, mustChange bool) (err error) {
+	return dao.AdminMember.Transaction(ctx, func(ctx g.Ctx, tx gdb.TX) error {
+		return s.updatePasswordTx(tx, mb, hash, mustChange)
+	})
+}
+
+// updatePasswordTx 在事务中更新密码，修改密码后未使用的重置令牌同时失效
+func (s *sAdminMember) updatePasswordTx(tx gdb.TX, mb *entity.AdminMember, hash string, mustChange bool) (err error) {
+	if err = s.savePasswordHistory(tx, mb); err != nil {
+		return err
+	}
+	cols := dao.AdminMember.Columns()
+	update := g.Map{
+		cols.PasswordHash:       hash,
+		cols.PasswordResetToken: "",
+		cols.MustChangePassword: gconv.Int(mustChange),
+	}
+	if _, err = g.Model(dao.AdminMember.Table()).TX(tx).WherePri(mb.Id).Data(update).Update(); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return nil
+}
+
+// hashResetToken 重置令牌哈希
+func (s *sAdminMember) hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseResetToken 解析保存的重置令牌，格式为 哈希:过期时间
+func (s *sAdminMember) parseResetToken(value string) (hash string, expireAt int64) {
+	hash, expire, found := strings.Cut(value, ":")
+	if !found {
+		return "", 0
+	}
+	return hash, gconv.Int64(expire)
+}
+
 func (s *sAdminMember) updateRoles(tx gdb.TX, in *adminSchema.MemberUpdateRoleInput) (err error) {
 	// 校验用户是否存在
 	if ok, err := g.Model(dao.AdminMember.Table()).TX(tx).Where(dao.AdminMember.Columns().Id, in.Id).Exist(); err != nil {
@@ -14,7 +14,6 @@ import (
 	"github.com/denghuo98/zzframe/zconsts"
 	"github.com/denghuo98/zzframe/zdb/zgorm"
 	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
-	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
 	"github.com/denghuo98/zzframe/zservice"
 )
 
@@ -109,14 +108,15 @@ func (s *sMenu) List(ctx g.Ctx, in *adminSchema.MenuListInput) (*adminSchema.Men
 
 	// 如果不是超级管理员，需要根据权限来设置菜单的可见性
 	if !zservice.AdminMember().VerifySuperAdmin(ctx, user.Id) {
-		roleIds := lo.Map(user.Roles, func(role *webSchema.IdentityRole, _ int) int64 {
-			return role.Id
-		})
-		menuIds, err := dao.AdminRoleMenu.Ctx(ctx).WhereIn(dao.AdminRoleMenu.Columns().RoleId, roleIds).Array(dao.AdminRoleMenu.Columns().MenuId)
-		if err != nil {
-			return nil, gerror.Wrap(err, zconsts.ErrorORM)
+		menuIds := make([]int64, 0)
+		for _, role := range user.Roles {
+			ids, err := roleMenuIds(ctx, role.Id)
+			if err != nil {
+				return nil, err
+			}
+			menuIds = append(menuIds, ids...)
 		}
-		m = m.WhereIn(cols.Id, menuIds)
+		m = m.WhereIn(cols.Id, lo.Uniq(menuIds))
 	}
 
 	// 排序
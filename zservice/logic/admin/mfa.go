@@ -0,0 +1,341 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/grand"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/ztotp"
+	"github.com/denghuo98/zzframe/zconsts"
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+const (
+	recoveryCodeCount   = 10                                // 每次生成的恢复码数量
+	recoveryCodeCharset = "abcdefghjkmnpqrstuvwxyz23456789" // 恢复码字符集，去掉了容易混淆的字符
+)
+
+type sAdminMfa struct{}
+
+func init() {
+	zservice.RegisterAdminMfa(NewAdminMfa())
+}
+
+func NewAdminMfa() *sAdminMfa {
+	return &sAdminMfa{}
+}
+
+// Status 获取用户的双因素认证状态
+func (s *sAdminMfa) Status(ctx g.Ctx, memberId int64) (out *adminSchema.MfaStatusOutput, err error) {
+	mfa, err := s.get(ctx, memberId)
+	if err != nil {
+		return nil, err
+	}
+
+	out = new(adminSchema.MfaStatusOutput)
+	if out.Required, err = s.Required(ctx, memberId); err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Status == zconsts.StatusEnabled {
+		out.Enabled = true
+		out.EnabledAt = mfa.EnabledAt
+		out.RecoveryCodes = len(s.recoveryHashes(mfa))
+	}
+	return out, nil
+}
+
+// Enabled 用户是否已启用双因素认证
+func (s *sAdminMfa) Enabled(ctx g.Ctx, memberId int64) (bool, error) {
+	mfa, err := s.get(ctx, memberId)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Status == zconsts.StatusEnabled, nil
+}
+
+// Required 用户所属角色是否强制启用双因素认证
+func (s *sAdminMfa) Required(ctx g.Ctx, memberId int64) (bool, error) {
+	mrCols := dao.AdminMemberRole.Columns()
+	roleIds, err := dao.AdminMemberRole.Ctx(ctx).Where(mrCols.MemberId, memberId).Array(mrCols.RoleId)
+	if err != nil {
+		return false, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if len(roleIds) == 0 {
+		return false, nil
+	}
+
+	cols := dao.AdminRole.Columns()
+	count, err := dao.AdminRole.Ctx(ctx).WhereIn(cols.Id, roleIds).Where(cols.MfaRequired, 1).Count()
+	if err != nil {
+		return false, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return count > 0, nil
+}
+
+// Enroll 生成新的TOTP密钥，验证通过后才会启用
+func (s *sAdminMfa) Enroll(ctx g.Ctx, memberId int64) (out *adminSchema.MfaEnrollOutput, err error) {
+	var mb *entity.AdminMember
+	if err = dao.AdminMember.Ctx(ctx).WherePri(memberId).Scan(&mb); err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if mb == nil {
+		return nil, gerror.New("用户不存在")
+	}
+
+	mfa, err := s.get(ctx, memberId)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Status == zconsts.StatusEnabled {
+		return nil, gerror.New("已启用双因素认证，如需更换设备请先关闭")
+	}
+
+	conf, err := zservice.SystemConfig().GetMfaConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := ztotp.GenerateSecret()
+	cols := dao.AdminMemberMfa.Columns()
+	data := g.Map{
+		cols.MemberId:      memberId,
+		cols.Secret:        secret,
+		cols.RecoveryCodes: "",
+		cols.LastStep:      0,
+		cols.Status:        zconsts.StatusDisable,
+		cols.EnabledAt:     nil,
+	}
+	if mfa == nil {
+		data[cols.CreatedAt] = gtime.Now()
+		_, err = dao.AdminMemberMfa.Ctx(ctx).Data(data).Insert()
+	} else {
+		data[cols.UpdatedAt] = gtime.Now()
+		_, err = dao.AdminMemberMfa.Ctx(ctx).WherePri(memberId).Data(data).Update()
+	}
+	if err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+
+	out = new(adminSchema.MfaEnrollOutput)
+	out.Secret = secret
+	out.Uri = ztotp.URI(conf.Issuer, mb.Username, secret)
+	if out.QrCode, err = ztotp.QrCode(out.Uri); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Activate 验证认证器验证码并启用双因素认证，返回恢复码
+func (s *sAdminMfa) Activate(ctx g.Ctx, in *adminSchema.MfaCodeInput) (out *adminSchema.MfaRecoveryCodesOutput, err error) {
+	mfa, err := s.get(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || mfa.Secret == "" {
+		return nil, gerror.New("请先绑定认证器")
+	}
+	if mfa.Status == zconsts.StatusEnabled {
+		return nil, gerror.New("已启用双因素认证")
+	}
+
+	if err = s.verifyTotp(ctx, mfa, in.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes := s.generateRecoveryCodes()
+	cols := dao.AdminMemberMfa.Columns()
+	_, err = dao.AdminMemberMfa.Ctx(ctx).WherePri(in.Id).Data(g.Map{
+		cols.RecoveryCodes: gjson.MustEncodeString(hashes),
+		cols.Status:        zconsts.StatusEnabled,
+		cols.EnabledAt:     gtime.Now(),
+		cols.UpdatedAt:     gtime.Now(),
+	}).Update()
+	if err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return &adminSchema.MfaRecoveryCodesOutput{RecoveryCodes: codes}, nil
+}
+
+// Verify 校验认证器验证码或恢复码，恢复码使用后失效
+func (s *sAdminMfa) Verify(ctx g.Ctx, in *adminSchema.MfaCodeInput) (err error) {
+	mfa, err := s.getEnabled(ctx, in.Id)
+	if err != nil {
+		return err
+	}
+
+	// 认证器验证码为纯数字，其余按恢复码处理
+	code := strings.TrimSpace(in.Code)
+	if len(code) == ztotp.Digits && strings.Trim(code, "0123456789") == "" {
+		return s.verifyTotp(ctx, mfa, code)
+	}
+	return s.useRecoveryCode(ctx, mfa, code)
+}
+
+// Disable 关闭双因素认证，角色强制启用时不能关闭
+func (s *sAdminMfa) Disable(ctx g.Ctx, in *adminSchema.MfaCodeInput) (err error) {
+	required, err := s.Required(ctx, in.Id)
+	if err != nil {
+		return err
+	}
+	if required {
+		return gerror.New("所属角色要求启用双因素认证，不能关闭")
+	}
+
+	if err = s.Verify(ctx, in); err != nil {
+		return err
+	}
+
+	if _, err = dao.AdminMemberMfa.Ctx(ctx).WherePri(in.Id).Delete(); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return nil
+}
+
+// RecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+func (s *sAdminMfa) RecoveryCodes(ctx g.Ctx, in *adminSchema.MfaCodeInput) (out *adminSchema.MfaRecoveryCodesOutput, err error) {
+	if err = s.Verify(ctx, in); err != nil {
+		return nil, err
+	}
+
+	codes, hashes := s.generateRecoveryCodes()
+	cols := dao.AdminMemberMfa.Columns()
+	_, err = dao.AdminMemberMfa.Ctx(ctx).WherePri(in.Id).Data(g.Map{
+		cols.RecoveryCodes: gjson.MustEncodeString(hashes),
+		cols.UpdatedAt:     gtime.Now(),
+	}).Update()
+	if err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return &adminSchema.MfaRecoveryCodesOutput{RecoveryCodes: codes}, nil
+}
+
+// Reset 管理员重置用户的双因素认证
+func (s *sAdminMfa) Reset(ctx g.Ctx, in *adminSchema.MfaResetInput) (err error) {
+	if in.MemberId <= 0 {
+		return gerror.New("用户ID不能为空")
+	}
+	if _, err = dao.AdminMemberMfa.Ctx(ctx).WherePri(in.MemberId).Delete(); err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return nil
+}
+
+// get 获取用户的双因素认证记录
+func (s *sAdminMfa) get(ctx g.Ctx, memberId int64) (mfa *entity.AdminMemberMfa, err error) {
+	if memberId <= 0 {
+		return nil, gerror.New("用户ID不能为空")
+	}
+	if err = dao.AdminMemberMfa.Ctx(ctx).WherePri(memberId).Scan(&mfa); err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	return mfa, nil
+}
+
+// getEnabled 获取已启用的双因素认证记录
+func (s *sAdminMfa) getEnabled(ctx g.Ctx, memberId int64) (mfa *entity.AdminMemberMfa, err error) {
+	if mfa, err = s.get(ctx, memberId); err != nil {
+		return nil, err
+	}
+	if mfa == nil || mfa.Status != zconsts.StatusEnabled {
+		return nil, gerror.New("未启用双因素认证")
+	}
+	return mfa, nil
+}
+
+// verifyTotp 校验认证器验证码，同一个时间步的验证码只能使用一次
+func (s *sAdminMfa) verifyTotp(ctx g.Ctx, mfa *entity.AdminMemberMfa, code string) (err error) {
+	step, ok := ztotp.Validate(mfa.Secret, code, gtime.Now().Time)
+	if !ok || step <= mfa.LastStep {
+		return gerror.New("验证码错误")
+	}
+
+	// 以时间步做乐观锁，并发提交同一个验证码时只有一个能成功
+	cols := dao.AdminMemberMfa.Columns()
+	res, err := dao.AdminMemberMfa.Ctx(ctx).
+		WherePri(mfa.MemberId).
+		WhereLT(cols.LastStep, step).
+		Data(g.Map{cols.LastStep: step}).
+		Update()
+	if err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return gerror.New("验证码错误")
+	}
+	mfa.LastStep = step
+	return nil
+}
+
+// useRecoveryCode 使用恢复码，使用后立即失效
+func (s *sAdminMfa) useRecoveryCode(ctx g.Ctx, mfa *entity.AdminMemberMfa, code string) (err error) {
+	var (
+		hash   = s.hashRecoveryCode(code)
+		hashes = s.recoveryHashes(mfa)
+		remain = make([]string, 0, len(hashes))
+		found  bool
+	)
+
+	for _, h := range hashes {
+		if !found && subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			found = true
+			continue
+		}
+		remain = append(remain, h)
+	}
+	if !found {
+		return gerror.New("验证码错误")
+	}
+
+	// 以原恢复码内容做乐观锁，避免同一个恢复码被并发使用
+	cols := dao.AdminMemberMfa.Columns()
+	res, err := dao.AdminMemberMfa.Ctx(ctx).
+		WherePri(mfa.MemberId).
+		Where(cols.RecoveryCodes, mfa.RecoveryCodes).
+		Data(g.Map{cols.RecoveryCodes: gjson.MustEncodeString(remain)}).
+		Update()
+	if err != nil {
+		return gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return gerror.New("验证码错误")
+	}
+
+	g.Log().Infof(ctx, "mfa recovery code used, memberId:%v, remain:%v", mfa.MemberId, len(remain))
+	return nil
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文和哈希
+func (s *sAdminMfa) generateRecoveryCodes() (codes []string, hashes []string) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		code := grand.Str(recoveryCodeCharset, 5) + "-" + grand.Str(recoveryCodeCharset, 5)
+		codes = append(codes, code)
+		hashes = append(hashes, s.hashRecoveryCode(code))
+	}
+	return
+}
+
+// hashRecoveryCode 恢复码哈希，忽略大小写和分隔符
+func (s *sAdminMfa) hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// recoveryHashes 解析已保存的恢复码哈希
+func (s *sAdminMfa) recoveryHashes(mfa *entity.AdminMemberMfa) (hashes []string) {
+	if mfa.RecoveryCodes == "" {
+		return nil
+	}
+	_ = gjson.DecodeTo(mfa.RecoveryCodes, &hashes)
+	return
+}
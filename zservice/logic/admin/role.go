@@ -1,7 +1,9 @@
 package admin
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/errors/gerror"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/denghuo98/zzframe/internal/dao"
 	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/zcache"
 	"github.com/denghuo98/zzframe/web/zcasbin"
 	"github.com/denghuo98/zzframe/web/zcontext"
 	"github.com/denghuo98/zzframe/zconsts"
@@ -87,8 +90,9 @@ func (s *sAdminRole) Edit(ctx g.Ctx, in adminSchema.RoleEditInput) (role *entity
 	defer func() {
 		if err != nil {
 			tx.Rollback()
-		} else {
-			tx.Commit()
+		} else if err = tx.Commit(); err == nil {
+			// 提交后删除角色缓存，下次读取时重新加载绑定的菜单
+			err = s.flushCache(ctx, in.Id)
 		}
 	}()
 
@@ -139,7 +143,7 @@ func (s *sAdminRole) Delete(ctx g.Ctx, in adminSchema.RoleDeleteInput) (err erro
 	if _, err = dao.AdminRole.Ctx(ctx).WherePri(in.Id).Delete(); err != nil {
 		return gerror.Wrap(err, zconsts.ErrorORM)
 	}
-	return nil
+	return s.flushCache(ctx, in.Id)
 }
 
 func (s *sAdminRole) List(ctx g.Ctx, in adminSchema.RoleListInput) (out *adminSchema.RoleListOutput, totalCount int, err error) {
@@ -259,3 +263,33 @@ func (s *sAdminRole) embedMenus(ctx g.Ctx, list []*adminSchema.RoleListOutputIte
 	}
 	return
 }
+
+// flushCache 删除角色的全部缓存，包括绑定的菜单
+func (s *sAdminRole) flushCache(ctx g.Ctx, roleId int64) (err error) {
+	if err = zcache.Tags(roleTag(roleId)).Flush(ctx); err != nil {
+		return gerror.Wrap(err, "删除角色缓存失败")
+	}
+	return nil
+}
+
+// roleTag 角色的缓存标签
+func roleTag(roleId int64) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheTagRole, roleId)
+}
+
+// roleMenuIds 角色绑定的菜单ID，缓存到角色修改或删除为止
+func roleMenuIds(ctx g.Ctx, roleId int64) (menuIds []int64, err error) {
+	key := fmt.Sprintf("%v:%v", zconsts.CacheRoleMenu, roleId)
+	v, err := zcache.Tags(roleTag(roleId)).GetOrSetFunc(ctx, key, func(ctx context.Context) (interface{}, error) {
+		rmCols := dao.AdminRoleMenu.Columns()
+		ids, err := dao.AdminRoleMenu.Ctx(ctx).Where(rmCols.RoleId, roleId).Array(rmCols.MenuId)
+		if err != nil {
+			return nil, gerror.Wrap(err, zconsts.ErrorORM)
+		}
+		return gconv.Int64s(ids), nil
+	}, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return v.Int64s(), nil
+}
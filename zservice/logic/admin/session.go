@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/ztoken"
+	"github.com/denghuo98/zzframe/web/zutils"
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
+	"github.com/denghuo98/zzframe/zschema/zform"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+type sAdminSession struct{}
+
+func init() {
+	zservice.RegisterAdminSession(NewAdminSession())
+}
+
+func NewAdminSession() *sAdminSession {
+	return &sAdminSession{}
+}
+
+// List 获取登录会话列表
+func (s *sAdminSession) List(ctx g.Ctx, in *adminSchema.SessionListInput) (out *adminSchema.SessionListOutput, totalCount int, err error) {
+	var sessions []*ztoken.Family
+	if in.MemberId > 0 {
+		sessions, err = ztoken.GetMemberSessions(ctx, in.MemberId)
+	} else {
+		sessions, err = ztoken.GetSessions(ctx)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list := make([]*adminSchema.SessionItem, 0, len(sessions))
+	for _, fa := range sessions {
+		if in.Username != "" && fa.Identity.Username != in.Username {
+			continue
+		}
+		if in.App != "" && fa.Identity.App != in.App {
+			continue
+		}
+		if in.LoginIp != "" && fa.LoginIp != in.LoginIp {
+			continue
+		}
+		list = append(list, s.toItem(fa))
+	}
+
+	totalCount = len(list)
+	if in.Pagination {
+		_, perPage, offset := zform.CalPage(in.Page, in.PerPage)
+		if offset >= totalCount {
+			list = list[:0]
+		} else {
+			list = list[offset:min(offset+perPage, totalCount)]
+		}
+	}
+
+	out = new(adminSchema.SessionListOutput)
+	out.List = list
+	return
+}
+
+// View 获取登录会话详情
+func (s *sAdminSession) View(ctx g.Ctx, in *adminSchema.SessionViewInput) (out *adminSchema.SessionItem, err error) {
+	fa, err := ztoken.GetSession(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+	if fa == nil {
+		return nil, gerror.New("会话不存在或已失效")
+	}
+	return s.toItem(fa), nil
+}
+
+// Kick 强制下线一个会话
+func (s *sAdminSession) Kick(ctx g.Ctx, in *adminSchema.SessionKickInput) (err error) {
+	fa, err := ztoken.GetSession(ctx, in.Id)
+	if err != nil {
+		return err
+	}
+	if fa == nil {
+		return gerror.New("会话不存在或已失效")
+	}
+
+	if err = ztoken.RevokeSession(ctx, in.Id); err != nil {
+		return err
+	}
+	g.Log().Infof(ctx, "session kicked, session:%v, userId:%v", in.Id, fa.Identity.Id)
+	return nil
+}
+
+// KickMember 强制下线用户的全部会话
+func (s *sAdminSession) KickMember(ctx g.Ctx, in *adminSchema.SessionKickMemberInput) (err error) {
+	if in.MemberId <= 0 {
+		return gerror.New("用户ID不能为空")
+	}
+
+	if err = ztoken.RevokeMember(ctx, in.MemberId); err != nil {
+		return err
+	}
+	g.Log().Infof(ctx, "member sessions kicked, userId:%v", in.MemberId)
+	return nil
+}
+
+// toItem 转换会话信息
+func (s *sAdminSession) toItem(fa *ztoken.Family) *adminSchema.SessionItem {
+	return &adminSchema.SessionItem{
+		Id:           fa.Id,
+		MemberId:     fa.Identity.Id,
+		Username:     fa.Identity.Username,
+		RealName:     fa.Identity.RealName,
+		App:          fa.Identity.App,
+		TokenKey:     fa.TokenKey,
+		LoginIp:      fa.LoginIp,
+		UserAgent:    fa.UserAgent,
+		Os:           zutils.GetOs(fa.UserAgent),
+		Browser:      zutils.GetBrowser(fa.UserAgent),
+		RefreshCount: fa.RefreshCount,
+		CreatedAt:    gtime.NewFromTimeStamp(fa.CreatedAt),
+		LastSeenAt:   gtime.NewFromTimeStamp(fa.LastSeen),
+		ExpireAt:     gtime.NewFromTimeStamp(fa.ExpireAt),
+	}
+}
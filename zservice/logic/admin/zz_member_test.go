@@ -5,15 +5,25 @@ import (
 
 	"github.com/gogf/gf/v2/crypto/gmd5"
 	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gfile"
 	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/gogf/gf/v2/text/gregex"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/grand"
 
 	"github.com/denghuo98/zzframe/internal/dao"
 	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/znotify"
+	"github.com/denghuo98/zzframe/web/zpassword"
+	"github.com/denghuo98/zzframe/web/ztoken"
 	"github.com/denghuo98/zzframe/zconsts"
 	"github.com/denghuo98/zzframe/zschema/admin"
 	"github.com/denghuo98/zzframe/zschema/zform"
+	"github.com/denghuo98/zzframe/zschema/zweb"
 	"github.com/denghuo98/zzframe/zservice"
 
 	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
@@ -38,6 +48,7 @@ func setupTestDBForMember() {
 		remark TEXT,
 		sort INTEGER DEFAULT 0,
 		status INTEGER DEFAULT 0,
+		mfa_required INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -53,6 +64,7 @@ func setupTestDBForMember() {
 		password_hash VARCHAR(255) NOT NULL,
 		salt VARCHAR(255) NOT NULL,
 		password_reset_token VARCHAR(255),
+		must_change_password INTEGER DEFAULT 0,
 		avatar VARCHAR(255),
 		sex INTEGER DEFAULT 0,
 		email VARCHAR(255),
@@ -74,6 +86,16 @@ func setupTestDBForMember() {
 	);
 	`
 
+	// 创建历史密码表
+	createPasswordHistoryTableSQL := `
+	CREATE TABLE zz_admin_member_password_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		member_id INTEGER NOT NULL,
+		password_hash VARCHAR(255) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
 	ctx := gctx.New()
 	_, err := g.DB().Exec(ctx, createRoleTableSQL)
 	if err != nil {
@@ -90,6 +112,14 @@ func setupTestDBForMember() {
 		panic(err)
 	}
 
+	_, err = g.DB().Exec(ctx, createPasswordHistoryTableSQL)
+	if err != nil {
+		panic(err)
+	}
+
+	// 删除用户、重置密码时会注销登录会话，修改角色时会删除角色缓存
+	zcache.SetAdapter(ctx, &zweb.CacheConfig{Adapter: "memory"})
+
 	// 创建测试角色（因为member需要关联role）
 	testRole := admin.RoleEditInput{
 		AdminRole: entity.AdminRole{
@@ -108,6 +138,16 @@ func setupTestDBForMember() {
 
 	// 注册角色服务
 	zservice.RegisterAdminRole(roleService)
+
+	if err = ztoken.SetConfig(&zweb.TokenConfig{
+		SecretKey:       "zzframe",
+		Expires:         7200,
+		RefreshExpires:  604800,
+		MaxRefreshTimes: -1,
+		MultiLogin:      true,
+	}); err != nil {
+		panic(err)
+	}
 }
 
 // cleanupTestDBForMember 清理成员测试数据库
@@ -115,6 +155,7 @@ func cleanupTestDBForMember() {
 	ctx := gctx.New()
 	// 删除表（注意顺序，先删除有外键依赖的表）
 	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member_role")
+	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member_password_history")
 	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member")
 	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_role")
 }
@@ -791,17 +832,20 @@ func TestAdminMember_ResetPassword(t *testing.T) {
 				Id: userId,
 			}
 
-			err := s.ResetPassword(ctx, input)
+			out, err := s.ResetPassword(ctx, input)
 			t.AssertNil(err)
+			t.AssertNil(zpassword.CheckPolicy(out.Password))
 
-			// 验证密码是否被重置为默认密码
+			// 验证密码是否被重置为临时密码，并要求下次登录时修改
 			updatedRecord, err := dao.AdminMember.Ctx(ctx).Where("id", userId).One()
 			t.AssertNil(err)
+			t.Assert(updatedRecord["must_change_password"].Int(), 1)
 
 			// 获取用户的盐值
 			salt := updatedRecord["salt"].String()
-			expectedHash := gmd5.MustEncryptString("123456" + salt)
-			t.Assert(updatedRecord["password_hash"], expectedHash)
+			ok, err := zpassword.Verify(out.Password, salt, updatedRecord["password_hash"].String())
+			t.AssertNil(err)
+			t.Assert(ok, true)
 		})
 
 		// 测试重置密码 - 用户不存在
@@ -810,7 +854,7 @@ func TestAdminMember_ResetPassword(t *testing.T) {
 				Id: 99999, // 不存在的用户ID
 			}
 
-			err := s.ResetPassword(ctx, input)
+			_, err := s.ResetPassword(ctx, input)
 			t.AssertNE(err, nil)
 			t.Assert(err.Error(), "用户不存在")
 		})
@@ -821,9 +865,142 @@ func TestAdminMember_ResetPassword(t *testing.T) {
 				Id: 0,
 			}
 
-			err := s.ResetPassword(ctx, input)
+			_, err := s.ResetPassword(ctx, input)
 			t.AssertNE(err, nil)
 			t.Assert(err.Error(), "用户ID不能为空")
 		})
 	})
 }
+
+func TestAdminMember_PasswordPolicy(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestDBForMember()
+		defer cleanupTestDBForMember()
+
+		t.AssertNil(zpassword.SetConfig(&zweb.PasswordConfig{MinLength: 8, CharClasses: 3, History: 3}))
+		defer zpassword.SetConfig(nil)
+
+		ctx := gctx.New()
+		s := &sAdminMember{}
+
+		// 不符合密码策略
+		err := s.Edit(ctx, &admin.MemberEditInput{Username: "policyuser", Password: "Ab1", Status: 1})
+		t.AssertNE(err, nil)
+		err = s.Edit(ctx, &admin.MemberEditInput{Username: "policyuser", Password: "abcdefgh1", Status: 1})
+		t.AssertNE(err, nil)
+
+		err = s.Edit(ctx, &admin.MemberEditInput{Username: "policyuser", Password: "Password1", Status: 1})
+		t.AssertNil(err)
+
+		var mb *entity.AdminMember
+		t.AssertNil(dao.AdminMember.Ctx(ctx).Where("username", "policyuser").Scan(&mb))
+		t.Assert(mb.PasswordHash[:10], "$argon2id$")
+
+		// 修改密码，不能与最近3次使用过的密码相同
+		for i, password := range []string{"Password2", "Password3"} {
+			old := []string{"Password1", "Password2"}[i]
+			t.AssertNil(s.UpdatePassword(ctx, &admin.MemberUpdatePasswordInput{Id: mb.Id, OldPassword: old, NewPassword: password}))
+		}
+		err = s.UpdatePassword(ctx, &admin.MemberUpdatePasswordInput{Id: mb.Id, OldPassword: "Password3", NewPassword: "Password2"})
+		t.AssertNE(err, nil)
+		err = s.Edit(ctx, &admin.MemberEditInput{Id: mb.Id, Username: "policyuser", Password: "Password2", Status: 1})
+		t.AssertNE(err, nil)
+		err = s.UpdatePassword(ctx, &admin.MemberUpdatePasswordInput{Id: mb.Id, OldPassword: "Password3", NewPassword: "Password1"})
+		t.AssertNE(err, nil)
+		t.AssertNil(s.UpdatePassword(ctx, &admin.MemberUpdatePasswordInput{Id: mb.Id, OldPassword: "Password3", NewPassword: "Password4"}))
+		t.AssertNil(s.UpdatePassword(ctx, &admin.MemberUpdatePasswordInput{Id: mb.Id, OldPassword: "Password4", NewPassword: "Password1"}))
+
+		count, err := dao.AdminMemberPasswordHistory.Ctx(ctx).Where("member_id", mb.Id).Count()
+		t.AssertNil(err)
+		t.Assert(count, 2)
+
+		// 旧版本的 md5 哈希仍然可以校验
+		legacy := gmd5.MustEncryptString("Password9" + mb.Salt)
+		ok, err := zpassword.Verify("Password9", mb.Salt, legacy)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		t.Assert(zpassword.NeedsRehash(legacy), true)
+
+		// bcrypt
+		t.AssertNil(zpassword.SetConfig(&zweb.PasswordConfig{Algorithm: zpassword.AlgorithmBcrypt, Cost: 4}))
+		hash, err := zpassword.Hash("Password9")
+		t.AssertNil(err)
+		ok, err = zpassword.Verify("Password9", "", hash)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		t.Assert(zpassword.NeedsRehash(hash), false)
+		t.Assert(zpassword.NeedsRehash(mb.PasswordHash), true)
+	})
+}
+
+func TestAdminMember_ForgotPassword(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestDBForMember()
+		defer cleanupTestDBForMember()
+
+		// 使用文件通知，从文件中读取发送的重置令牌
+		file := gfile.Temp(grand.S(8), "notify.log")
+		defer gfile.Remove(gfile.Dir(file))
+		t.AssertNil(znotify.SetConfig(&zweb.NotifyConfig{Driver: znotify.DriverFile, File: file}))
+		defer znotify.SetConfig(nil)
+		t.AssertNil(zpassword.SetConfig(&zweb.PasswordConfig{ResetUrl: "https://example.com/reset?token={token}"}))
+		defer zpassword.SetConfig(nil)
+
+		ctx := gctx.New()
+		s := &sAdminMember{}
+
+		t.AssertNil(s.Edit(ctx, &admin.MemberEditInput{Username: "forgotuser", Password: "oldpassword", Email: "forgot@example.com", Status: 1}))
+		var mb *entity.AdminMember
+		t.AssertNil(dao.AdminMember.Ctx(ctx).Where("username", "forgotuser").Scan(&mb))
+
+		// 账号不存在时同样返回成功，不发送通知
+		t.AssertNil(s.ForgotPassword(ctx, &admin.MemberForgotPasswordInput{Account: "nobody"}))
+		t.Assert(gfile.Exists(file), false)
+
+		// 按邮箱找回密码
+		t.AssertNil(s.ForgotPassword(ctx, &admin.MemberForgotPasswordInput{Account: "forgot@example.com"}))
+		var msg *znotify.Message
+		t.AssertNil(gjson.DecodeTo(gfile.GetContents(file), &msg))
+		t.Assert(msg.To, "forgot@example.com")
+		match, err := gregex.MatchString(`token=(\w+)`, msg.Content)
+		t.AssertNil(err)
+		t.Assert(len(match), 2)
+		token := match[1]
+
+		// 数据库中只保存令牌哈希
+		t.AssertNil(dao.AdminMember.Ctx(ctx).WherePri(mb.Id).Scan(&mb))
+		t.AssertNE(mb.PasswordResetToken, "")
+		t.Assert(gstr.Contains(mb.PasswordResetToken, token), false)
+
+		// 短时间内重复请求不再发送
+		t.AssertNil(s.ForgotPassword(ctx, &admin.MemberForgotPasswordInput{Account: "forgotuser"}))
+		t.Assert(len(gstr.SplitAndTrim(gfile.GetContents(file), "\n")), 1)
+
+		// 不符合密码策略时令牌不失效
+		err = s.ConfirmPasswordReset(ctx, &admin.MemberConfirmPasswordResetInput{Token: token, Password: "123"})
+		t.AssertNE(err, nil)
+		err = s.ConfirmPasswordReset(ctx, &admin.MemberConfirmPasswordResetInput{Token: "invalid", Password: "newpassword"})
+		t.Assert(err.Error(), "重置令牌无效或已过期")
+
+		t.AssertNil(s.ConfirmPasswordReset(ctx, &admin.MemberConfirmPasswordResetInput{Token: token, Password: "newpassword"}))
+		t.AssertNil(dao.AdminMember.Ctx(ctx).WherePri(mb.Id).Scan(&mb))
+		ok, err := zpassword.Verify("newpassword", mb.Salt, mb.PasswordHash)
+		t.AssertNil(err)
+		t.Assert(ok, true)
+		t.Assert(mb.PasswordResetToken, "")
+
+		// 令牌只能使用一次
+		err = s.ConfirmPasswordReset(ctx, &admin.MemberConfirmPasswordResetInput{Token: token, Password: "otherpassword"})
+		t.Assert(err.Error(), "重置令牌无效或已过期")
+
+		// 过期的令牌
+		token, err = s.IssuePasswordResetToken(ctx, mb.Id)
+		t.AssertNil(err)
+		_, err = dao.AdminMember.Ctx(ctx).WherePri(mb.Id).Data(g.Map{
+			"password_reset_token": s.hashResetToken(token) + ":1",
+		}).Update()
+		t.AssertNil(err)
+		err = s.ConfirmPasswordReset(ctx, &admin.MemberConfirmPasswordResetInput{Token: token, Password: "otherpassword"})
+		t.Assert(err.Error(), "重置令牌无效或已过期")
+	})
+}
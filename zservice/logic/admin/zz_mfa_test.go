@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/web/ztotp"
+	"github.com/denghuo98/zzframe/zschema/admin"
+	"github.com/denghuo98/zzframe/zschema/zweb"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+// testSystemConfig 测试用的系统配置
+type testSystemConfig struct{}
+
+func (c *testSystemConfig) LoadConfig(ctx g.Ctx) error { return nil }
+
+func (c *testSystemConfig) GetSuperAdmin(ctx g.Ctx) (*zweb.SuperAdminConfig, error) {
+	return &zweb.SuperAdminConfig{}, nil
+}
+
+func (c *testSystemConfig) GetAnonymousConfig(ctx g.Ctx) (*zweb.AnonymousConfig, error) {
+	return &zweb.AnonymousConfig{}, nil
+}
+
+func (c *testSystemConfig) GetMfaConfig(ctx g.Ctx) (*zweb.MfaConfig, error) {
+	return &zweb.MfaConfig{Issuer: "ZZFrame", TicketExpires: 300}, nil
+}
+
+func (c *testSystemConfig) GetLoginProtectConfig(ctx g.Ctx) (*zweb.LoginProtectConfig, error) {
+	return &zweb.LoginProtectConfig{CaptchaAttempts: 3, LockAttempts: 10, LockDuration: 900, Window: 900}, nil
+}
+
+func (c *testSystemConfig) GetRateLimitConfig(ctx g.Ctx) (*zweb.RateLimitConfig, error) {
+	return &zweb.RateLimitConfig{}, nil
+}
+
+func TestAdminMfa(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestDBForMember()
+		defer cleanupTestDBForMember()
+
+		ctx := gctx.New()
+		_, err := g.DB().Exec(ctx, `
+		CREATE TABLE zz_admin_member_mfa (
+			member_id INTEGER PRIMARY KEY,
+			secret TEXT NOT NULL DEFAULT '',
+			recovery_codes TEXT,
+			last_step INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 2,
+			enabled_at TEXT,
+			created_at TEXT,
+			updated_at TEXT
+		);`)
+		t.AssertNil(err)
+		defer g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member_mfa")
+
+		zservice.RegisterSystemConfig(&testSystemConfig{})
+
+		var (
+			member = &sAdminMember{}
+			mfa    = &sAdminMfa{}
+		)
+
+		testRole, err := dao.AdminRole.Ctx(ctx).Where("name", "测试角色").One()
+		t.AssertNil(err)
+		roleId := testRole["id"].Int64()
+
+		err = member.Edit(ctx, &admin.MemberEditInput{
+			Username: "mfauser",
+			Password: "123456",
+			RealName: "双因素用户",
+			RoleIds:  []int64{roleId},
+			Status:   1,
+		})
+		t.AssertNil(err)
+		record, err := dao.AdminMember.Ctx(ctx).Where("username", "mfauser").One()
+		t.AssertNil(err)
+		userId := record["id"].Int64()
+
+		// 绑定并启用
+		enroll, err := mfa.Enroll(ctx, userId)
+		t.AssertNil(err)
+		t.AssertNE(enroll.Secret, "")
+		t.Assert(enroll.QrCode[:22], "data:image/png;base64,")
+
+		enabled, err := mfa.Enabled(ctx, userId)
+		t.AssertNil(err)
+		t.Assert(enabled, false)
+
+		step := ztotp.Step(gtime.Now().Time)
+		code, err := ztotp.Code(enroll.Secret, step-1)
+		t.AssertNil(err)
+		codes, err := mfa.Activate(ctx, &admin.MfaCodeInput{Id: userId, Code: code})
+		t.AssertNil(err)
+		t.Assert(len(codes.RecoveryCodes), recoveryCodeCount)
+
+		// 同一个验证码不能重复使用
+		err = mfa.Verify(ctx, &admin.MfaCodeInput{Id: userId, Code: code})
+		t.AssertNE(err, nil)
+
+		code, err = ztotp.Code(enroll.Secret, step)
+		t.AssertNil(err)
+		t.AssertNil(mfa.Verify(ctx, &admin.MfaCodeInput{Id: userId, Code: code}))
+
+		// 恢复码只能使用一次
+		t.AssertNil(mfa.Verify(ctx, &admin.MfaCodeInput{Id: userId, Code: codes.RecoveryCodes[0]}))
+		t.AssertNE(mfa.Verify(ctx, &admin.MfaCodeInput{Id: userId, Code: codes.RecoveryCodes[0]}), nil)
+
+		status, err := mfa.Status(ctx, userId)
+		t.AssertNil(err)
+		t.Assert(status.Enabled, true)
+		t.Assert(status.RecoveryCodes, recoveryCodeCount-1)
+
+		// 角色强制启用时不能关闭
+		_, err = dao.AdminRole.Ctx(ctx).WherePri(roleId).Data(g.Map{"mfa_required": 1}).Update()
+		t.AssertNil(err)
+		err = mfa.Disable(ctx, &admin.MfaCodeInput{Id: userId, Code: codes.RecoveryCodes[1]})
+		t.AssertNE(err, nil)
+
+		// 管理员重置
+		t.AssertNil(mfa.Reset(ctx, &admin.MfaResetInput{MemberId: userId}))
+		enabled, err = mfa.Enabled(ctx, userId)
+		t.AssertNil(err)
+		t.Assert(enabled, false)
+	})
+}
@@ -12,8 +12,10 @@ import (
 
 	"github.com/denghuo98/zzframe/internal/dao"
 	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/zcache"
 	"github.com/denghuo98/zzframe/zschema/admin"
 	"github.com/denghuo98/zzframe/zschema/zform"
+	"github.com/denghuo98/zzframe/zschema/zweb"
 
 	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
 )
@@ -60,6 +62,9 @@ func setupTestDB() {
 	if err != nil {
 		panic(err)
 	}
+
+	// 修改和删除角色时会删除角色缓存
+	zcache.SetAdapter(ctx, &zweb.CacheConfig{Adapter: "memory"})
 }
 
 // cleanupTestDB 清理测试数据库
@@ -194,6 +199,42 @@ func TestAdminRole_RoleEdit(t *testing.T) {
 	})
 }
 
+func TestAdminRole_FlushCache(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestDB()
+		defer cleanupTestDB()
+
+		ctx := gctx.New()
+		s := &sAdminRole{}
+
+		role, err := s.Edit(ctx, admin.RoleEditInput{
+			AdminRole: entity.AdminRole{Name: "编辑", Key: "editor", Status: 1},
+		})
+		t.AssertNil(err)
+		_, err = dao.AdminRoleMenu.Ctx(ctx).Data(g.Map{"role_id": role.Id, "menu_id": 1}).Insert()
+		t.AssertNil(err)
+
+		menuIds, err := roleMenuIds(ctx, role.Id)
+		t.AssertNil(err)
+		t.Assert(menuIds, []int64{1})
+
+		// 直接修改数据库时读取到的仍然是缓存
+		_, err = dao.AdminRoleMenu.Ctx(ctx).Data(g.Map{"role_id": role.Id, "menu_id": 2}).Insert()
+		t.AssertNil(err)
+		menuIds, err = roleMenuIds(ctx, role.Id)
+		t.AssertNil(err)
+		t.Assert(menuIds, []int64{1})
+
+		// 修改角色后重新加载
+		role.Remark = "内容编辑"
+		_, err = s.Edit(ctx, admin.RoleEditInput{AdminRole: *role})
+		t.AssertNil(err)
+		menuIds, err = roleMenuIds(ctx, role.Id)
+		t.AssertNil(err)
+		t.Assert(menuIds, []int64{1, 2})
+	})
+}
+
 func TestAdminRole_Delete(t *testing.T) {
 	gtest.C(t, func(t *gtest.T) {
 		// 设置测试数据库
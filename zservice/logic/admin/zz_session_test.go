@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/web/ztoken"
+	"github.com/denghuo98/zzframe/zschema/admin"
+	"github.com/denghuo98/zzframe/zschema/zform"
+	"github.com/denghuo98/zzframe/zschema/zweb"
+)
+
+func TestAdminSession(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		setupTestDBForMember()
+		defer cleanupTestDBForMember()
+
+		var (
+			ctx     = gctx.New()
+			member  = &sAdminMember{}
+			session = &sAdminSession{}
+		)
+
+		err := member.Edit(ctx, &admin.MemberEditInput{
+			Username: "sessionuser",
+			Password: "123456",
+			RealName: "会话用户",
+			Status:   1,
+		})
+		t.AssertNil(err)
+
+		record, err := dao.AdminMember.Ctx(ctx).Where("username", "sessionuser").One()
+		t.AssertNil(err)
+		userId := record["id"].Int64()
+
+		identity := &zweb.Identity{Id: userId, Username: "sessionuser", App: "app"}
+		_, err = ztoken.Login(ctx, identity)
+		t.AssertNil(err)
+		_, err = ztoken.Login(ctx, identity)
+		t.AssertNil(err)
+		_, err = ztoken.Login(ctx, &zweb.Identity{Id: userId + 1, Username: "other", App: "app"})
+		t.AssertNil(err)
+
+		// 查询会话
+		out, total, err := session.List(ctx, &admin.SessionListInput{
+			PageReq:  zform.PageReq{Page: 1, PerPage: 10, Pagination: true},
+			MemberId: userId,
+		})
+		t.AssertNil(err)
+		t.Assert(total, 2)
+		t.Assert(out.List[0].Username, "sessionuser")
+
+		_, total, err = session.List(ctx, &admin.SessionListInput{PageReq: zform.PageReq{Page: 1, PerPage: 1, Pagination: true}})
+		t.AssertNil(err)
+		t.Assert(total, 3)
+
+		item, err := session.View(ctx, &admin.SessionViewInput{Id: out.List[0].Id})
+		t.AssertNil(err)
+		t.Assert(item.MemberId, userId)
+
+		// 强制下线一个会话
+		err = session.Kick(ctx, &admin.SessionKickInput{Id: out.List[0].Id})
+		t.AssertNil(err)
+		_, err = session.View(ctx, &admin.SessionViewInput{Id: out.List[0].Id})
+		t.AssertNE(err, nil)
+
+		sessions, err := ztoken.GetMemberSessions(ctx, userId)
+		t.AssertNil(err)
+		t.Assert(len(sessions), 1)
+
+		// 删除用户会注销全部会话
+		err = member.Delete(ctx, &admin.MemberDeleteInput{Id: userId})
+		t.AssertNil(err)
+		sessions, err = ztoken.GetMemberSessions(ctx, userId)
+		t.AssertNil(err)
+		t.Assert(len(sessions), 0)
+
+		sessions, err = ztoken.GetSessions(ctx)
+		t.AssertNil(err)
+		t.Assert(len(sessions), 1)
+	})
+}
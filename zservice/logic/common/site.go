@@ -1,16 +1,25 @@
 package common
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gogf/gf/v2/crypto/gmd5"
 	"github.com/gogf/gf/v2/encoding/gbase64"
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/grand"
 
 	"github.com/denghuo98/zzframe/internal/dao"
 	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zcaptcha"
 	"github.com/denghuo98/zzframe/web/zcontext"
 	"github.com/denghuo98/zzframe/web/zencrypt"
+	"github.com/denghuo98/zzframe/web/zlocation"
+	"github.com/denghuo98/zzframe/web/zlock"
+	"github.com/denghuo98/zzframe/web/zpassword"
 	"github.com/denghuo98/zzframe/web/ztoken"
 	"github.com/denghuo98/zzframe/zconsts"
 	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
@@ -23,6 +32,17 @@ import (
 type sCommonSite struct {
 }
 
+// mfaMaxAttempts 单个二次验证凭证允许的最大错误次数
+const mfaMaxAttempts = 5
+
+// mfaTicket 登录二次验证凭证
+type mfaTicket struct {
+	MemberId int64 `json:"memberId"` // 用户ID
+	Setup    bool  `json:"setup"`    // 是否需要先绑定认证器
+	Attempts int   `json:"attempts"` // 错误次数
+	ExpireAt int64 `json:"expireAt"` // 过期时间
+}
+
 func init() {
 	zservice.RegisterCommonSite(NewCommonSite())
 }
@@ -37,6 +57,11 @@ func (s *sCommonSite) Ping(ctx g.Ctx) (status string) {
 }
 
 func (s *sCommonSite) InitSuperAdmin(ctx g.Ctx) (err error) {
+	// 多个实例同时启动时只由一个实例创建，避免重复创建角色和用户
+	return zlock.Do(ctx, zconsts.LockInitSuperAdmin, 0, s.initSuperAdmin)
+}
+
+func (s *sCommonSite) initSuperAdmin(ctx g.Ctx) (err error) {
 	conf, err := zservice.SystemConfig().GetSuperAdmin(ctx)
 	if err != nil {
 		return err
@@ -89,8 +114,11 @@ func (s *sCommonSite) InitSuperAdmin(ctx g.Ctx) (err error) {
 		g.Log().Info(ctx, "已自动创建超级管理员用户")
 	} else {
 		// 只更新密码
-		passwordHash := gmd5.MustEncryptString(conf.Password + memberEntity.Salt)
-		if passwordHash != memberEntity.PasswordHash {
+		if ok, _ := zpassword.Verify(conf.Password, memberEntity.Salt, memberEntity.PasswordHash); !ok {
+			passwordHash, err := zpassword.Hash(conf.Password)
+			if err != nil {
+				return err
+			}
 			dao.AdminMember.Ctx(ctx).WherePri(memberEntity.Id).Data(g.Map{
 				dao.AdminMember.Columns().PasswordHash: passwordHash,
 			}).Update()
@@ -103,6 +131,10 @@ func (s *sCommonSite) InitSuperAdmin(ctx g.Ctx) (err error) {
 
 func (s *sCommonSite) AccountLogin(ctx g.Ctx, in *commonSchema.SiteAccountLoginInput) (out *commonSchema.SiteLoginOutput, err error) {
 	defer func() {
+		// 等待二次验证时不记录，由 MfaLogin 记录最终的登录结果
+		if err == nil && out != nil && out.MfaTicket != "" {
+			return
+		}
 		// 推送登录事件
 		zservice.SysLoginLog().Push(ctx, &systemSchema.SysLoginLogPushInput{
 			Response: out,
@@ -110,6 +142,23 @@ func (s *sCommonSite) AccountLogin(ctx g.Ctx, in *commonSchema.SiteAccountLoginI
 		})
 	}()
 
+	// 登录失败次数过多时要求验证码或拒绝登录
+	clientIp := zlocation.GetClientIp(g.RequestFromCtx(ctx))
+	captcha, err := zservice.SysLoginProtect().Check(ctx, in.Username, clientIp)
+	if err != nil {
+		return
+	}
+	if captcha {
+		if in.Cid == "" || in.Code == "" {
+			err = gerror.NewCode(zconsts.CodeCaptchaRequired, "请输入验证码")
+			return
+		}
+		if !zcaptcha.Verify(in.Cid, in.Code) {
+			err = gerror.NewCode(zconsts.CodeCaptchaRequired, "验证码错误")
+			return
+		}
+	}
+
 	var mb *entity.AdminMember
 	if err = dao.AdminMember.Ctx(ctx).Where(dao.AdminMember.Columns().Username, in.Username).Scan(&mb); err != nil {
 		err = gerror.Wrap(err, zconsts.ErrorORM)
@@ -117,7 +166,7 @@ func (s *sCommonSite) AccountLogin(ctx g.Ctx, in *commonSchema.SiteAccountLoginI
 	}
 
 	if mb == nil {
-		err = gerror.New("账号不存在")
+		err = s.loginFail(ctx, in.Username, clientIp, gerror.New("账号不存在"))
 		return
 	}
 
@@ -135,33 +184,300 @@ func (s *sCommonSite) AccountLogin(ctx g.Ctx, in *commonSchema.SiteAccountLoginI
 	}
 
 	if err = s.verifyPassword(in.Password, mb.Salt, mb.PasswordHash); err != nil {
+		err = s.loginFail(ctx, in.Username, clientIp, err)
+		return
+	}
+
+	// 旧算法或参数生成的密码哈希，登录成功后升级
+	s.rehashPassword(ctx, mb, in.Password)
+
+	// 启用或被要求启用双因素认证时，先返回二次验证凭证，二次验证通过后才清除失败次数
+	if out, err = s.mfaChallenge(ctx, mb); err != nil || out != nil {
+		return
+	}
+	zservice.SysLoginProtect().Success(ctx, in.Username)
+
+	// 管理员重置密码后，需要先修改密码
+	if out, err = s.mustChangePassword(ctx, mb); err != nil || out != nil {
 		return
 	}
 
 	return s.handleLogin(ctx, mb)
 }
 
+// mustChangePassword 需要修改密码时签发重置令牌，不需要修改时返回nil
+func (s *sCommonSite) mustChangePassword(ctx g.Ctx, mb *entity.AdminMember) (out *commonSchema.SiteLoginOutput, err error) {
+	if mb.MustChangePassword != 1 {
+		return nil, nil
+	}
+	out = new(commonSchema.SiteLoginOutput)
+	out.Id = mb.Id
+	out.Username = mb.Username
+	out.MustChangePwd = true
+	if out.ResetToken, err = zservice.AdminMember().IssuePasswordResetToken(ctx, mb.Id); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasswordForgot 找回密码，通过消息通知发送重置令牌
+func (s *sCommonSite) PasswordForgot(ctx g.Ctx, in *commonSchema.SitePasswordForgotInput) (err error) {
+	if !zcaptcha.Verify(in.Cid, in.Code) {
+		return gerror.New("验证码错误")
+	}
+	return zservice.AdminMember().ForgotPassword(ctx, &adminSchema.MemberForgotPasswordInput{Account: in.Account})
+}
+
+// PasswordReset 使用重置令牌设置新密码
+func (s *sCommonSite) PasswordReset(ctx g.Ctx, in *commonSchema.SitePasswordResetInput) (err error) {
+	plainText, err := s.decryptPassword(in.Password)
+	if err != nil {
+		return gerror.New("密码格式错误")
+	}
+	return zservice.AdminMember().ConfirmPasswordReset(ctx, &adminSchema.MemberConfirmPasswordResetInput{
+		Token:    in.Token,
+		Password: plainText,
+	})
+}
+
+// loginFail 记录一次登录失败，达到阈值时返回验证码或锁定错误码
+func (s *sCommonSite) loginFail(ctx g.Ctx, username, ip string, err error) error {
+	captcha, lockErr := zservice.SysLoginProtect().Fail(ctx, username, ip)
+	if lockErr != nil {
+		return lockErr
+	}
+	if captcha {
+		return gerror.NewCode(zconsts.CodeCaptchaRequired, err.Error())
+	}
+	return err
+}
+
+// mfaChallenge 签发登录二次验证凭证，不需要双因素认证时返回nil
+func (s *sCommonSite) mfaChallenge(ctx g.Ctx, mb *entity.AdminMember) (out *commonSchema.SiteLoginOutput, err error) {
+	enabled, err := zservice.AdminMfa().Enabled(ctx, mb.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		required, err := zservice.AdminMfa().Required(ctx, mb.Id)
+		if err != nil || !required {
+			return nil, err
+		}
+	}
+
+	conf, err := zservice.SystemConfig().GetMfaConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := &mfaTicket{
+		MemberId: mb.Id,
+		Setup:    !enabled,
+		ExpireAt: gtime.Timestamp() + conf.TicketExpires,
+	}
+	out = new(commonSchema.SiteLoginOutput)
+	out.Id = mb.Id
+	out.Username = mb.Username
+	out.MfaSetup = ticket.Setup
+	out.MfaTicket = grand.S(32)
+	if err = s.saveMfaTicket(ctx, out.MfaTicket, ticket); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MfaSetup 登录时绑定认证器，用于角色要求启用双因素认证但尚未绑定的用户
+func (s *sCommonSite) MfaSetup(ctx g.Ctx, in *commonSchema.SiteMfaSetupInput) (out *adminSchema.MfaEnrollOutput, err error) {
+	ticket, err := s.getMfaTicket(ctx, in.Ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !ticket.Setup {
+		return nil, gerror.New("已绑定认证器，请输入验证码")
+	}
+	return zservice.AdminMfa().Enroll(ctx, ticket.MemberId)
+}
+
+// MfaLogin 校验二次验证码后完成登录
+func (s *sCommonSite) MfaLogin(ctx g.Ctx, in *commonSchema.SiteMfaLoginInput) (out *commonSchema.SiteLoginOutput, err error) {
+	var mb *entity.AdminMember
+	defer func() {
+		// 验证失败时也记录登录账号
+		response := out
+		if response == nil && mb != nil {
+			response = &commonSchema.SiteLoginOutput{Id: mb.Id, Username: mb.Username}
+		}
+		// 推送登录事件
+		zservice.SysLoginLog().Push(ctx, &systemSchema.SysLoginLogPushInput{
+			Response: response,
+			Error:    err,
+		})
+	}()
+
+	ticket, err := s.getMfaTicket(ctx, in.Ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dao.AdminMember.Ctx(ctx).WherePri(ticket.MemberId).Scan(&mb); err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if mb == nil || mb.Status != zconsts.StatusEnabled {
+		_, _ = zcache.Instance().Remove(ctx, s.mfaTicketKey(in.Ticket))
+		return nil, gerror.New("账号已禁用")
+	}
+
+	// 验证码错误和密码错误一样计入账号和IP的失败次数，避免重新申请凭证后继续猜测验证码
+	clientIp := zlocation.GetClientIp(g.RequestFromCtx(ctx))
+	if _, err = zservice.SysLoginProtect().Check(ctx, mb.Username, clientIp); err != nil {
+		_, _ = zcache.Instance().Remove(ctx, s.mfaTicketKey(in.Ticket))
+		return nil, err
+	}
+
+	var codes *adminSchema.MfaRecoveryCodesOutput
+	codeInput := &adminSchema.MfaCodeInput{Id: mb.Id, Code: in.Code}
+	if ticket.Setup {
+		codes, err = zservice.AdminMfa().Activate(ctx, codeInput)
+	} else {
+		err = zservice.AdminMfa().Verify(ctx, codeInput)
+	}
+	if err != nil {
+		s.failMfaTicket(ctx, in.Ticket, ticket)
+		if _, lockErr := zservice.SysLoginProtect().Fail(ctx, mb.Username, clientIp); lockErr != nil {
+			_, _ = zcache.Instance().Remove(ctx, s.mfaTicketKey(in.Ticket))
+			return nil, lockErr
+		}
+		return nil, err
+	}
+	zservice.SysLoginProtect().Success(ctx, mb.Username)
+
+	// 凭证只能使用一次
+	if _, err = zcache.Instance().Remove(ctx, s.mfaTicketKey(in.Ticket)); err != nil {
+		return nil, err
+	}
+
+	if out, err = s.mustChangePassword(ctx, mb); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		if out, err = s.handleLogin(ctx, mb); err != nil {
+			return nil, err
+		}
+	}
+	if codes != nil {
+		out.RecoveryCodes = codes.RecoveryCodes
+	}
+	return out, nil
+}
+
+// mfaTicketKey 二次验证凭证缓存key
+func (s *sCommonSite) mfaTicketKey(ticket string) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheMfaTicket, gmd5.MustEncryptString(ticket))
+}
+
+// saveMfaTicket 保存二次验证凭证
+func (s *sCommonSite) saveMfaTicket(ctx g.Ctx, key string, ticket *mfaTicket) error {
+	ttl := ticket.ExpireAt - gtime.Timestamp()
+	if ttl <= 0 {
+		return gerror.New("登录凭证已失效，请重新登录")
+	}
+	return zcache.Instance().Set(ctx, s.mfaTicketKey(key), ticket, time.Duration(ttl)*time.Second)
+}
+
+// getMfaTicket 获取二次验证凭证
+func (s *sCommonSite) getMfaTicket(ctx g.Ctx, key string) (ticket *mfaTicket, err error) {
+	v, err := zcache.Instance().Get(ctx, s.mfaTicketKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || v.IsEmpty() {
+		return nil, gerror.New("登录凭证已失效，请重新登录")
+	}
+	if err = v.Scan(&ticket); err != nil {
+		return nil, err
+	}
+	if ticket == nil || ticket.ExpireAt < gtime.Timestamp() {
+		return nil, gerror.New("登录凭证已失效，请重新登录")
+	}
+	return ticket, nil
+}
+
+// failMfaTicket 记录一次验证失败，错误次数过多时作废凭证
+func (s *sCommonSite) failMfaTicket(ctx g.Ctx, key string, ticket *mfaTicket) {
+	ticket.Attempts++
+	if ticket.Attempts >= mfaMaxAttempts {
+		_, _ = zcache.Instance().Remove(ctx, s.mfaTicketKey(key))
+		return
+	}
+	if err := s.saveMfaTicket(ctx, key, ticket); err != nil {
+		g.Log().Warningf(ctx, "save mfa ticket err:%+v", err)
+	}
+}
+
+// decryptPassword 解密前端传输的密码
+func (s *sCommonSite) decryptPassword(input string) (plainText string, err error) {
+	unBase64, err := gbase64.Decode([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	unAes, err := zencrypt.AesECBDecrypt(unBase64, []byte(zconsts.RequestEncryptKey))
+	if err != nil {
+		return "", err
+	}
+	return string(unAes), nil
+}
+
 // verifyPassword 验证密码
 func (s *sCommonSite) verifyPassword(input, salt, hash string) (err error) {
 	// 先解密文本
-	unBase64, err := gbase64.Decode([]byte(input))
+	plainText, err := s.decryptPassword(input)
 	if err != nil {
 		return err
 	}
 
-	unAes, err := zencrypt.AesECBDecrypt(unBase64, []byte(zconsts.RequestEncryptKey))
+	ok, err := zpassword.Verify(plainText, salt, hash)
 	if err != nil {
 		return err
 	}
-
-	plainText := string(unAes)
-	if hash != gmd5.MustEncryptString(plainText+salt) {
+	if !ok {
 		return gerror.New("用户密码错误")
 	}
 
 	return nil
 }
 
+// rehashPassword 使用当前配置的算法重新生成密码哈希，失败时不影响登录
+func (s *sCommonSite) rehashPassword(ctx g.Ctx, mb *entity.AdminMember, input string) {
+	if !zpassword.NeedsRehash(mb.PasswordHash) {
+		return
+	}
+
+	plainText, err := s.decryptPassword(input)
+	if err != nil {
+		return
+	}
+
+	hash, err := zpassword.Hash(plainText)
+	if err != nil {
+		g.Log().Warningf(ctx, "rehash password err:%+v", err)
+		return
+	}
+
+	// 只在哈希未被修改时更新，避免覆盖并发修改的密码
+	cols := dao.AdminMember.Columns()
+	if _, err = dao.AdminMember.Ctx(ctx).
+		WherePri(mb.Id).
+		Where(cols.PasswordHash, mb.PasswordHash).
+		Data(g.Map{cols.PasswordHash: hash}).
+		Update(); err != nil {
+		g.Log().Warningf(ctx, "rehash password err:%+v", err)
+		return
+	}
+	mb.PasswordHash = hash
+}
+
 func (s *sCommonSite) handleLogin(ctx g.Ctx, mb *entity.AdminMember) (out *commonSchema.SiteLoginOutput, err error) {
 	// 获取用户绑定的角色
 	roles, err := s.getMemberRoles(ctx, mb.Id)
@@ -181,16 +497,47 @@ func (s *sCommonSite) handleLogin(ctx g.Ctx, mb *entity.AdminMember) (out *commo
 		LoginAt:  gtime.Now(),
 	}
 
-	tokenStr, expires, err := ztoken.Login(ctx, identity)
+	pair, err := ztoken.Login(ctx, identity)
 	if err != nil {
 		return nil, err
 	}
 	out = new(commonSchema.SiteLoginOutput)
 	out.Id = mb.Id
 	out.Username = mb.Username
-	out.Token = tokenStr
-	out.Expires = expires
+	out.Token = pair.AccessToken
+	out.Expires = pair.Expires
+	out.RefreshToken = pair.RefreshToken
+	out.RefreshExpires = pair.RefreshExpires
+
+	return out, nil
+}
+
+// TokenRefresh 使用刷新令牌换取新的登录令牌
+func (s *sCommonSite) TokenRefresh(ctx g.Ctx, in *commonSchema.SiteTokenRefreshInput) (out *commonSchema.SiteLoginOutput, err error) {
+	pair, user, err := ztoken.Refresh(ctx, in.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// 账号被禁用后不再允许续期
+	var mb *entity.AdminMember
+	if err = dao.AdminMember.Ctx(ctx).WherePri(user.Id).Scan(&mb); err != nil {
+		return nil, gerror.Wrap(err, zconsts.ErrorORM)
+	}
+	if mb == nil || mb.Status != zconsts.StatusEnabled {
+		if err = ztoken.RevokeMember(ctx, user.Id); err != nil {
+			g.Log().Warningf(ctx, "revoke member sessions err:%+v", err)
+		}
+		return nil, gerror.New("账号已禁用")
+	}
 
+	out = new(commonSchema.SiteLoginOutput)
+	out.Id = mb.Id
+	out.Username = mb.Username
+	out.Token = pair.AccessToken
+	out.Expires = pair.Expires
+	out.RefreshToken = pair.RefreshToken
+	out.RefreshExpires = pair.RefreshExpires
 	return out, nil
 }
 
@@ -1,14 +1,32 @@
 package common
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/gogf/gf/v2/crypto/gmd5"
+	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/encoding/gbase64"
+	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/test/gtest"
 
+	"github.com/denghuo98/zzframe/internal/dao"
+	"github.com/denghuo98/zzframe/internal/model/entity"
+	"github.com/denghuo98/zzframe/web/zcache"
 	"github.com/denghuo98/zzframe/web/zencrypt"
+	"github.com/denghuo98/zzframe/web/ztoken"
 	"github.com/denghuo98/zzframe/zconsts"
+	adminSchema "github.com/denghuo98/zzframe/zschema/admin"
+	commonSchema "github.com/denghuo98/zzframe/zschema/common"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	"github.com/denghuo98/zzframe/zschema/zweb"
+	"github.com/denghuo98/zzframe/zservice"
+	_ "github.com/denghuo98/zzframe/zservice/logic/system"
+
+	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
 )
 
 // encryptPassword 将明文密码加密为前端传输格式
@@ -107,3 +125,224 @@ func TestVerifyPasswordWithDifferentSalts(t *testing.T) {
 	encryptedPassword := encryptPassword(plainPassword)
 	g.Log().Infof(t.Context(), "encryptedPassword: %s", encryptedPassword)
 }
+
+// setupTestDBForSite 设置登录测试数据库和缓存
+func setupTestDBForSite(ctx g.Ctx) *entity.AdminMember {
+	gdb.SetConfig(gdb.Config{
+		"default": gdb.ConfigGroup{
+			gdb.ConfigNode{
+				Link: "sqlite::@file(:memory:)?cache=shared",
+			},
+		},
+	})
+
+	sqls := []string{
+		`CREATE TABLE zz_admin_member (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			dept_id INTEGER DEFAULT 0,
+			real_name VARCHAR(255),
+			username VARCHAR(255) NOT NULL,
+			password_hash VARCHAR(255) NOT NULL,
+			salt VARCHAR(255) NOT NULL,
+			password_reset_token VARCHAR(255),
+			must_change_password INTEGER DEFAULT 0,
+			avatar VARCHAR(255),
+			sex INTEGER DEFAULT 0,
+			email VARCHAR(255),
+			mobile VARCHAR(255),
+			last_active_at DATETIME,
+			remark TEXT,
+			status INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE zz_admin_role (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(255) NOT NULL,
+			"key" VARCHAR(255) NOT NULL,
+			remark TEXT,
+			sort INTEGER DEFAULT 0,
+			status INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE zz_admin_member_role (
+			member_id INTEGER NOT NULL,
+			role_id INTEGER NOT NULL,
+			PRIMARY KEY (member_id, role_id)
+		);`,
+	}
+	for _, sql := range sqls {
+		if _, err := g.DB().Exec(ctx, sql); err != nil {
+			panic(err)
+		}
+	}
+
+	zcache.SetAdapter(ctx, &zweb.CacheConfig{Adapter: "memory"})
+	err := ztoken.SetConfig(&zweb.TokenConfig{
+		SecretKey:       "zzframe",
+		Expires:         7200,
+		RefreshExpires:  604800,
+		MaxRefreshTimes: -1,
+		MultiLogin:      true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	mb := &entity.AdminMember{
+		Username:     "tokenuser",
+		PasswordHash: gmd5.MustEncryptString("123456" + "abc123"),
+		Salt:         "abc123",
+		Status:       zconsts.StatusEnabled,
+	}
+	id, err := dao.AdminMember.Ctx(ctx).Data(mb).OmitEmpty().InsertAndGetId()
+	if err != nil {
+		panic(err)
+	}
+	mb.Id = id
+	return mb
+}
+
+// cleanupTestDBForSite 清理登录测试数据库
+func cleanupTestDBForSite(ctx g.Ctx) {
+	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member_role")
+	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_member")
+	g.DB().Exec(ctx, "DROP TABLE IF EXISTS zz_admin_role")
+}
+
+func TestTokenRefresh(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		mb := setupTestDBForSite(ctx)
+		defer cleanupTestDBForSite(ctx)
+
+		s := NewCommonSite()
+		login, err := s.handleLogin(ctx, mb)
+		t.AssertNil(err)
+		t.AssertNE(login.Token, "")
+		t.AssertNE(login.RefreshToken, "")
+		t.Assert(login.RefreshExpires, 604800)
+
+		// 正常轮换，签发新的令牌对并使旧的访问令牌失效
+		refreshed, err := s.TokenRefresh(ctx, &commonSchema.SiteTokenRefreshInput{RefreshToken: login.RefreshToken})
+		t.AssertNil(err)
+		t.AssertNE(refreshed.Token, login.Token)
+		t.AssertNE(refreshed.RefreshToken, login.RefreshToken)
+		t.Assert(refreshed.Id, mb.Id)
+
+		oldTokenKey := ztoken.GetTokenKey("app", ztoken.GetAuthKey(login.Token))
+		ok, err := zcache.Instance().Contains(ctx, oldTokenKey)
+		t.AssertNil(err)
+		t.Assert(ok, false)
+
+		// 无效的刷新令牌
+		_, err = s.TokenRefresh(ctx, &commonSchema.SiteTokenRefreshInput{RefreshToken: "invalid"})
+		t.AssertNE(err, nil)
+
+		// 另一个会话，用于验证重复使用时注销全部会话
+		other, err := s.handleLogin(ctx, mb)
+		t.AssertNil(err)
+
+		// 已轮换的刷新令牌再次使用
+		_, err = s.TokenRefresh(ctx, &commonSchema.SiteTokenRefreshInput{RefreshToken: login.RefreshToken})
+		t.AssertNE(err, nil)
+
+		for _, token := range []string{refreshed.RefreshToken, other.RefreshToken} {
+			_, err = s.TokenRefresh(ctx, &commonSchema.SiteTokenRefreshInput{RefreshToken: token})
+			t.AssertNE(err, nil)
+		}
+
+		newTokenKey := ztoken.GetTokenKey("app", ztoken.GetAuthKey(refreshed.Token))
+		ok, err = zcache.Instance().Contains(ctx, newTokenKey)
+		t.AssertNil(err)
+		t.Assert(ok, false)
+	})
+}
+
+func TestRehashPassword(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		mb := setupTestDBForSite(ctx)
+		defer cleanupTestDBForSite(ctx)
+
+		s := NewCommonSite()
+		input := encryptPassword("123456")
+
+		// 旧版本的 md5 哈希校验通过后升级为 argon2id
+		t.AssertNil(s.verifyPassword(input, mb.Salt, mb.PasswordHash))
+		s.rehashPassword(ctx, mb, input)
+		t.Assert(strings.HasPrefix(mb.PasswordHash, "$argon2id$"), true)
+
+		var updated *entity.AdminMember
+		t.AssertNil(dao.AdminMember.Ctx(ctx).WherePri(mb.Id).Scan(&updated))
+		t.Assert(updated.PasswordHash, mb.PasswordHash)
+		t.AssertNil(s.verifyPassword(input, updated.Salt, updated.PasswordHash))
+		t.AssertNE(s.verifyPassword(encryptPassword("654321"), updated.Salt, updated.PasswordHash), nil)
+	})
+}
+
+// testMfa 测试用的双因素认证，验证码 123456 校验通过
+type testMfa struct {
+	zservice.IAdminMfa
+}
+
+func (m *testMfa) Enabled(ctx g.Ctx, memberId int64) (bool, error) { return true, nil }
+
+func (m *testMfa) Verify(ctx g.Ctx, in *adminSchema.MfaCodeInput) error {
+	if in.Code != "123456" {
+		return gerror.New("验证码错误")
+	}
+	return nil
+}
+
+func TestMfaLogin_LoginProtect(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		ctx := gctx.New()
+		mb := setupTestDBForSite(ctx)
+		defer cleanupTestDBForSite(ctx)
+
+		adapter, err := gcfg.NewAdapterContent(`
+system:
+  loginProtect:
+    captchaAttempts: -1
+    lockAttempts: 3
+`)
+		t.AssertNil(err)
+		oldAdapter := g.Cfg().GetAdapter()
+		g.Cfg().SetAdapter(adapter)
+		defer g.Cfg().SetAdapter(oldAdapter)
+		zservice.RegisterAdminMfa(&testMfa{})
+
+		s := NewCommonSite()
+		login := func() string {
+			out, err := s.AccountLogin(ctx, &commonSchema.SiteAccountLoginInput{Username: mb.Username, Password: encryptPassword("123456")})
+			t.AssertNil(err)
+			t.AssertNE(out.MfaTicket, "")
+			return out.MfaTicket
+		}
+
+		// 密码正确不会清除失败次数，重新申请凭证后验证码错误继续累计，达到次数后锁定
+		ticket := login()
+		for i := 0; i < 2; i++ {
+			_, err = s.MfaLogin(ctx, &commonSchema.SiteMfaLoginInput{Ticket: ticket, Code: "000000"})
+			t.AssertNE(err, nil)
+		}
+		ticket = login()
+		_, err = s.MfaLogin(ctx, &commonSchema.SiteMfaLoginInput{Ticket: ticket, Code: "000000"})
+		t.Assert(gerror.Code(err), zconsts.CodeLoginLocked)
+		_, err = s.AccountLogin(ctx, &commonSchema.SiteAccountLoginInput{Username: mb.Username, Password: encryptPassword("123456")})
+		t.Assert(gerror.Code(err), zconsts.CodeLoginLocked)
+
+		// 解除锁定后，二次验证通过才清除失败次数
+		t.AssertNil(zservice.SysLoginProtect().Unlock(ctx, &systemSchema.SysLoginUnlockInput{Username: mb.Username}))
+		out, err := s.MfaLogin(ctx, &commonSchema.SiteMfaLoginInput{Ticket: login(), Code: "123456"})
+		t.AssertNil(err)
+		t.AssertNE(out.Token, "")
+		for i := 0; i < 2; i++ {
+			_, err = s.MfaLogin(ctx, &commonSchema.SiteMfaLoginInput{Ticket: login(), Code: "000000"})
+			t.AssertNE(err, nil)
+			t.AssertNE(gerror.Code(err), zconsts.CodeLoginLocked)
+		}
+	})
+}
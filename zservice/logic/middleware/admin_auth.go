@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"time"
 
 	"github.com/gogf/gf/v2/errors/gcode"
@@ -54,19 +55,12 @@ func (s *sMiddleware) AdminAuth(r *ghttp.Request) {
 
 // IsExceptAuth 是否是不需要验证权限的路由地址
 func (s *sMiddleware) IsExceptAuth(ctx g.Ctx, path string) bool {
-
-	var exceptAuth []string
 	// 从缓存中获取不需要验证权限的路由地址
-	v, err := zcache.Instance().Get(ctx, "exceptAuth")
-	if err != nil && !(err.Error() == "cache expired") {
-		g.Log().Error(ctx, "从缓存获取不需要验证权限的路由地址失败", err)
-	}
-
-	if v.IsEmpty() {
-		exceptAuth = g.Cfg().MustGet(ctx, "system.exceptAuth").Strings()
-		zcache.Instance().Set(ctx, "exceptAuth", exceptAuth, time.Hour)
-	} else {
-		exceptAuth = v.Strings()
+	exceptAuth, err := zcache.RememberT(ctx, "exceptAuth", time.Hour, func(ctx context.Context) ([]string, error) {
+		return g.Cfg().MustGet(ctx, "system.exceptAuth").Strings(), nil
+	})
+	if err != nil {
+		g.Log().Error(ctx, "获取不需要验证权限的路由地址失败", err)
 	}
 
 	return lo.Contains(exceptAuth, path)
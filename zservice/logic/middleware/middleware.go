@@ -9,6 +9,7 @@ import (
 
 	"github.com/denghuo98/zzframe/web/zcontext"
 	"github.com/denghuo98/zzframe/web/ztoken"
+	"github.com/denghuo98/zzframe/zconsts"
 	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
 	"github.com/denghuo98/zzframe/zservice"
 )
@@ -53,7 +54,11 @@ func (s *sMiddleware) CORS(r *ghttp.Request) {
 
 // DeliverUserContext 将用户信息传递到上下文中
 func (s *sMiddleware) DeliverUserContext(r *ghttp.Request) (err error) {
-	user, err := ztoken.ParseLoginUser(r)
+	// 限流中间件已解析过令牌时不再重复解析
+	user, ok := r.GetCtxVar(zconsts.ContextKeyLoginUser).Val().(*webSchema.Identity)
+	if !ok {
+		user, err = ztoken.ParseLoginUser(r)
+	}
 	if err != nil {
 		// 若解析失败，检查是否启用了匿名身份
 		anonymousCfg, cfgErr := zservice.SystemConfig().GetAnonymousConfig(r.Context())
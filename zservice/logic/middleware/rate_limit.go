@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/crypto/gmd5"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/samber/lo"
+
+	"github.com/denghuo98/zzframe/web/zcontext"
+	"github.com/denghuo98/zzframe/web/zlocation"
+	"github.com/denghuo98/zzframe/web/zratelimit"
+	"github.com/denghuo98/zzframe/web/zresp"
+	"github.com/denghuo98/zzframe/web/ztoken"
+	"github.com/denghuo98/zzframe/zconsts"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+// 限流维度
+const (
+	rateLimitKeyIp     = "ip"
+	rateLimitKeyUser   = "user"
+	rateLimitKeyApiKey = "apiKey"
+)
+
+// rateLimitWatcher 配置变更时清除缓存的限流配置
+const rateLimitWatcher = "middleware.rateLimit"
+
+// rateLimitConfig 解析后的限流配置，配置适配器变更或通知配置变更时重新加载
+var rateLimitConfig struct {
+	sync.RWMutex
+	adapter gcfg.Adapter
+	conf    *webSchema.RateLimitConfig
+	version int // 每次配置变更加一，避免变更前读取的配置被缓存
+}
+
+// RateLimit 接口限流，按配置的路由规则和维度统计请求数，超过配额时返回 429
+// 限流存储异常时放行请求，只记录日志
+func (s *sMiddleware) RateLimit(r *ghttp.Request) {
+	ctx := r.Context()
+	conf, err := s.getRateLimitConfig(ctx)
+	if err != nil {
+		g.Log().Warningf(ctx, "获取限流配置失败:%+v", err)
+		r.Middleware.Next()
+		return
+	}
+	if !conf.Enabled {
+		r.Middleware.Next()
+		return
+	}
+
+	rule := s.matchRateLimitRule(r, conf.Rules)
+	if rule == nil {
+		r.Middleware.Next()
+		return
+	}
+
+	key := fmt.Sprintf("%s:%s", rule.Pattern, s.rateLimitSubject(r, rule))
+	res, err := zratelimit.Allow(ctx, key, &zratelimit.Rule{
+		Algorithm: rule.Algorithm,
+		Limit:     rule.Limit,
+		Period:    time.Duration(rule.Period) * time.Second,
+		Burst:     rule.Burst,
+	})
+	if err != nil {
+		g.Log().Warningf(ctx, "接口限流失败:%+v", err)
+		r.Middleware.Next()
+		return
+	}
+
+	header := r.Response.Header()
+	header.Set("X-RateLimit-Limit", fmt.Sprint(res.Limit))
+	header.Set("X-RateLimit-Remaining", fmt.Sprint(res.Remaining))
+	header.Set("X-RateLimit-Reset", fmt.Sprint(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		header.Set("Retry-After", fmt.Sprint(ceilSeconds(res.RetryAfter)))
+		r.Response.WriteHeader(http.StatusTooManyRequests)
+		zresp.JsonExit(r, zconsts.CodeTooManyRequests.Code(), zconsts.CodeTooManyRequests.Message())
+		return
+	}
+	r.Middleware.Next()
+}
+
+// getRateLimitConfig 获取限流配置，配置适配器支持变更通知时缓存解析结果
+func (s *sMiddleware) getRateLimitConfig(ctx g.Ctx) (*webSchema.RateLimitConfig, error) {
+	adapter := g.Cfg().GetAdapter()
+	rateLimitConfig.RLock()
+	conf, version := rateLimitConfig.conf, rateLimitConfig.version
+	cached := conf != nil && rateLimitConfig.adapter == adapter
+	rateLimitConfig.RUnlock()
+	if cached {
+		return conf, nil
+	}
+
+	conf, err := zservice.SystemConfig().GetRateLimitConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	watcher, ok := adapter.(gcfg.WatcherAdapter)
+	if !ok {
+		return conf, nil
+	}
+
+	rateLimitConfig.Lock()
+	defer rateLimitConfig.Unlock()
+	if rateLimitConfig.adapter != adapter {
+		if old, ok := rateLimitConfig.adapter.(gcfg.WatcherAdapter); ok {
+			old.RemoveWatcher(rateLimitWatcher)
+		}
+		watcher.AddWatcher(rateLimitWatcher, func(ctx context.Context) {
+			rateLimitConfig.Lock()
+			rateLimitConfig.conf = nil
+			rateLimitConfig.version++
+			rateLimitConfig.Unlock()
+		})
+		rateLimitConfig.adapter = adapter
+		rateLimitConfig.conf = conf
+	} else if rateLimitConfig.version == version {
+		rateLimitConfig.conf = conf
+	}
+	return conf, nil
+}
+
+// matchRateLimitRule 返回第一条匹配请求地址和方法的规则
+func (s *sMiddleware) matchRateLimitRule(r *ghttp.Request, rules []*webSchema.RateLimitRule) *webSchema.RateLimitRule {
+	for _, rule := range rules {
+		if rule.Limit <= 0 {
+			continue
+		}
+		if len(rule.Methods) > 0 && !lo.ContainsBy(rule.Methods, func(method string) bool {
+			return strings.EqualFold(method, r.Method)
+		}) {
+			continue
+		}
+		if matchRatePattern(rule.Pattern, r.URL.Path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// rateLimitSubject 限流对象，无法获取用户或 apiKey 时按客户端IP统计
+func (s *sMiddleware) rateLimitSubject(r *ghttp.Request, rule *webSchema.RateLimitRule) string {
+	switch rule.Key {
+	case rateLimitKeyUser:
+		// 全局中间件执行时尚未绑定用户，直接解析令牌，解析结果留给 AdminAuth 使用
+		user := zcontext.GetUser(r.Context())
+		if user == nil {
+			if user, _ = ztoken.ParseLoginUser(r); user != nil {
+				r.SetCtxVar(zconsts.ContextKeyLoginUser, user)
+			}
+		}
+		if user != nil && user.Id > 0 {
+			return fmt.Sprintf("%s:%d", rateLimitKeyUser, user.Id)
+		}
+	case rateLimitKeyApiKey:
+		// apiKey 不以明文写入限流存储
+		if apiKey := r.Header.Get(rule.Header); apiKey != "" {
+			return fmt.Sprintf("%s:%s", rateLimitKeyApiKey, gmd5.MustEncryptString(apiKey))
+		}
+	}
+	return fmt.Sprintf("%s:%s", rateLimitKeyIp, zlocation.GetClientIp(r))
+}
+
+// matchRatePattern 以 * 结尾时按前缀匹配，/admin/* 同时匹配 /admin，否则按 path.Match 匹配
+func matchRatePattern(pattern, urlPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(urlPath, prefix) || urlPath+"/" == prefix
+	}
+	matched, _ := path.Match(pattern, urlPath)
+	return matched
+}
+
+// ceilSeconds 响应头中的时间，向上取整到秒
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
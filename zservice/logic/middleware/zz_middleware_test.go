@@ -1,11 +1,22 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gcfg"
 	"github.com/gogf/gf/v2/os/gctx"
 	"github.com/gogf/gf/v2/test/gtest"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
 
+	"github.com/denghuo98/zzframe/zconsts"
 	"github.com/denghuo98/zzframe/zservice"
 	_ "github.com/denghuo98/zzframe/zservice/logic/common"
 	_ "github.com/denghuo98/zzframe/zservice/logic/system"
@@ -30,3 +41,94 @@ func TestDeliverUserContext_AnonymousBranch(t *testing.T) {
 		t.AssertNE(m, nil)
 	})
 }
+
+func TestMatchRatePattern(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		t.Assert(matchRatePattern("/admin/login", "/admin/login"), true)
+		t.Assert(matchRatePattern("/admin/login", "/admin/login/x"), false)
+		t.Assert(matchRatePattern("/admin/*", "/admin/member/list"), true)
+		t.Assert(matchRatePattern("/admin/*", "/admin"), true)
+		t.Assert(matchRatePattern("/admin/*", "/administrator"), false)
+		t.Assert(matchRatePattern("/admin/*/list", "/admin/member/list"), true)
+		t.Assert(matchRatePattern("/*", "/"), true)
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		content := `
+system:
+  rateLimit:
+    enabled: true
+    rules:
+      - pattern: /limited
+        key: apiKey
+        limit: 2
+        period: 60
+`
+		adapter, err := gcfg.NewAdapterContent(content)
+		t.AssertNil(err)
+		oldAdapter := g.Cfg().GetAdapter()
+		g.Cfg().SetAdapter(adapter)
+		defer g.Cfg().SetAdapter(oldAdapter)
+
+		m := NewMiddleware()
+		s := g.Server(guid.S())
+		s.SetDumpRouterMap(false)
+		s.SetPort(0)
+		s.BindMiddleware("/*any", m.Ctx, m.ResponseHandler, m.RateLimit)
+		s.BindHandler("/limited", func(r *ghttp.Request) { r.Response.Write("ok") })
+		s.BindHandler("/free", func(r *ghttp.Request) { r.Response.Write("ok") })
+		t.AssertNil(s.Start())
+		defer s.Shutdown()
+
+		ctx := gctx.New()
+		client := g.Client().Prefix(fmt.Sprintf("http://127.0.0.1:%d", s.GetListenedPort()))
+		for i := 1; i >= 0; i-- {
+			res, err := client.Header(g.MapStrStr{"X-Api-Key": "k1"}).Get(ctx, "/limited")
+			t.AssertNil(err)
+			t.Assert(res.StatusCode, http.StatusOK)
+			t.Assert(res.Header.Get("X-RateLimit-Limit"), 2)
+			t.Assert(res.Header.Get("X-RateLimit-Remaining"), i)
+			res.Close()
+		}
+
+		res, err := client.Header(g.MapStrStr{"X-Api-Key": "k1"}).Get(ctx, "/limited")
+		t.AssertNil(err)
+		defer res.Close()
+		t.Assert(res.StatusCode, http.StatusTooManyRequests)
+		t.Assert(res.Header.Get("X-RateLimit-Remaining"), 0)
+		t.AssertGT(gconv.Int(res.Header.Get("Retry-After")), 0)
+		t.Assert(gjson.New(res.ReadAllString()).Get("code").Int(), zconsts.CodeTooManyRequests.Code())
+
+		// 其他 apiKey 和未配置规则的路由不受影响
+		res2, err := client.Header(g.MapStrStr{"X-Api-Key": "k2"}).Get(ctx, "/limited")
+		t.AssertNil(err)
+		defer res2.Close()
+		t.Assert(res2.StatusCode, http.StatusOK)
+		res3, err := client.Header(g.MapStrStr{"X-Api-Key": "k1"}).Get(ctx, "/free")
+		t.AssertNil(err)
+		defer res3.Close()
+		t.Assert(res3.StatusCode, http.StatusOK)
+		t.Assert(res3.Header.Get("X-RateLimit-Limit"), "")
+
+		// 解析后的配置被缓存，配置变更后重新加载
+		conf, err := m.getRateLimitConfig(ctx)
+		t.AssertNil(err)
+		cached, err := m.getRateLimitConfig(ctx)
+		t.AssertNil(err)
+		t.Assert(cached == conf, true)
+		t.AssertNil(adapter.SetContent(strings.Replace(content, "limit: 2", "limit: 5", 1)))
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if conf, err = m.getRateLimitConfig(ctx); err != nil || conf.Rules[0].Limit == 5 {
+				break
+			}
+		}
+		t.AssertNil(err)
+		t.Assert(conf.Rules[0].Limit, 5)
+		res4, err := client.Header(g.MapStrStr{"X-Api-Key": "k3"}).Get(ctx, "/limited")
+		t.AssertNil(err)
+		defer res4.Close()
+		t.Assert(res4.Header.Get("X-RateLimit-Limit"), 5)
+	})
+}
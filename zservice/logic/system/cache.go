@@ -0,0 +1,58 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zlock"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+func init() {
+	zservice.RegisterSysCache(NewSysCache())
+}
+
+// sSysCache 缓存监控
+type sSysCache struct{}
+
+func NewSysCache() *sSysCache {
+	return &sSysCache{}
+}
+
+// Stats 当前进程的缓存统计，二级缓存返回各层的命中次数，同时返回分布式锁的统计
+func (s *sSysCache) Stats(ctx g.Ctx) (out *systemSchema.SysCacheStatsOutput, err error) {
+	lock := zlock.GetStats()
+	out = &systemSchema.SysCacheStatsOutput{
+		Adapter: zcache.AdapterName(),
+		Lock: &systemSchema.SysCacheLock{
+			Backend:   lock.Backend,
+			Held:      lock.Held,
+			Acquired:  lock.Acquired,
+			Contended: lock.Contended,
+			Timeout:   lock.Timeout,
+			WaitTime:  lock.WaitTime,
+			Expired:   lock.Expired,
+			Released:  lock.Released,
+		},
+	}
+	stats, ok := zcache.LayeredStats()
+	if !ok {
+		return
+	}
+	out.L1 = s.layer(stats.L1Hits, stats.L1Misses)
+	out.L1.Size = stats.L1Size
+	out.L2 = s.layer(stats.L2Hits, stats.L2Misses)
+	out.Published = stats.Published
+	out.Received = stats.Received
+	out.Reconnected = stats.Reconnected
+	return
+}
+
+func (s *sSysCache) layer(hits, misses int64) *systemSchema.SysCacheLayer {
+	layer := &systemSchema.SysCacheLayer{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		layer.HitRate = float64(hits) / float64(total)
+	}
+	return layer
+}
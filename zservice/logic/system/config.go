@@ -6,6 +6,9 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/znotify"
+	"github.com/denghuo98/zzframe/web/zpassword"
+	"github.com/denghuo98/zzframe/web/zratelimit"
 	"github.com/denghuo98/zzframe/web/zstorager"
 	"github.com/denghuo98/zzframe/web/ztoken"
 	"github.com/denghuo98/zzframe/zconsts"
@@ -49,7 +52,27 @@ func (s *sSystemConfig) LoadConfig(ctx g.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
-	ztoken.SetConfig(tokenCfg)
+	if err = ztoken.SetConfig(tokenCfg); err != nil {
+		return err
+	}
+
+	// 密码配置
+	passwordCfg, err := s.GetPasswordConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err = zpassword.SetConfig(passwordCfg); err != nil {
+		return err
+	}
+
+	// 消息通知配置
+	notifyCfg, err := s.GetNotifyConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err = znotify.SetConfig(notifyCfg); err != nil {
+		return err
+	}
 
 	// 上传附件配置
 	uploadCfg, err := s.GetUploadConfig(ctx)
@@ -81,6 +104,86 @@ func (s *sSystemConfig) GetAnonymousConfig(ctx g.Ctx) (conf *webSchema.Anonymous
 	return
 }
 
+func (s *sSystemConfig) GetMfaConfig(ctx g.Ctx) (conf *webSchema.MfaConfig, err error) {
+	conf = &webSchema.MfaConfig{}
+	v := g.Cfg().MustGet(ctx, "system.mfa")
+	if v != nil {
+		err = v.Struct(conf)
+	}
+
+	if conf.Issuer == "" {
+		conf.Issuer = "ZZFrame"
+	}
+	if conf.TicketExpires <= 0 {
+		conf.TicketExpires = 300
+	}
+	return
+}
+
+func (s *sSystemConfig) GetPasswordConfig(ctx g.Ctx) (conf *webSchema.PasswordConfig, err error) {
+	conf = &webSchema.PasswordConfig{}
+	v := g.Cfg().MustGet(ctx, "system.password")
+	if v != nil {
+		err = v.Struct(conf)
+	}
+	return
+}
+
+func (s *sSystemConfig) GetNotifyConfig(ctx g.Ctx) (conf *webSchema.NotifyConfig, err error) {
+	conf = &webSchema.NotifyConfig{}
+	v := g.Cfg().MustGet(ctx, "system.notify")
+	if v != nil {
+		err = v.Struct(conf)
+	}
+	return
+}
+
+func (s *sSystemConfig) GetLoginProtectConfig(ctx g.Ctx) (conf *webSchema.LoginProtectConfig, err error) {
+	conf = &webSchema.LoginProtectConfig{}
+	v := g.Cfg().MustGet(ctx, "system.loginProtect")
+	if v != nil {
+		err = v.Struct(conf)
+	}
+
+	if conf.CaptchaAttempts == 0 {
+		conf.CaptchaAttempts = 3
+	}
+	if conf.LockAttempts == 0 {
+		conf.LockAttempts = 10
+	}
+	if conf.LockDuration <= 0 {
+		conf.LockDuration = 900
+	}
+	if conf.Window <= 0 {
+		conf.Window = 900
+	}
+	return
+}
+
+func (s *sSystemConfig) GetRateLimitConfig(ctx g.Ctx) (conf *webSchema.RateLimitConfig, err error) {
+	conf = &webSchema.RateLimitConfig{}
+	v := g.Cfg().MustGet(ctx, "system.rateLimit")
+	if v != nil {
+		err = v.Struct(conf)
+	}
+
+	for _, rule := range conf.Rules {
+		if rule.Key == "" {
+			rule.Key = "ip"
+		}
+		if rule.Header == "" {
+			rule.Header = "X-Api-Key"
+		}
+		if rule.Algorithm == "" {
+			rule.Algorithm = zratelimit.AlgorithmSlidingWindow
+		}
+		if rule.Period <= 0 {
+			rule.Period = 1
+		}
+	}
+	return
+}
+
 func (s *sSystemConfig) GetCacheConfig(ctx g.Ctx) (conf *webSchema.CacheConfig, err error) {
 	conf = &webSchema.CacheConfig{}
 	v := g.Cfg().MustGet(ctx, "system.cache")
@@ -120,13 +223,28 @@ func (s *sSystemConfig) GetTokenConfig(ctx g.Ctx) (conf *webSchema.TokenConfig,
 		err = v.Struct(conf)
 	} else {
 		conf = &webSchema.TokenConfig{
-			Expires:         86400,
-			RefreshInterval: 3600,
-			MaxRefreshTimes: 10,
-			SecretKey:       "zzframe",
+			Expires:         7200,
+			RefreshExpires:  604800,
+			MaxRefreshTimes: -1,
+			SecretKey:       ztoken.DefaultSecretKey,
 			MultiLogin:      true,
 		}
 	}
+
+	// 未配置轮换次数时不限制
+	if conf.MaxRefreshTimes == 0 {
+		conf.MaxRefreshTimes = -1
+	}
+
+	// 未配置刷新令牌有效期时默认7天
+	if conf.RefreshExpires <= 0 {
+		conf.RefreshExpires = 604800
+	}
+
+	// 未配置签名密钥时使用默认密钥
+	if conf.SecretKey == "" && len(conf.Keys) == 0 {
+		conf.SecretKey = ztoken.DefaultSecretKey
+	}
 	return
 }
 
@@ -0,0 +1,117 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/zqueue"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	"github.com/denghuo98/zzframe/zschema/zform"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+func init() {
+	zservice.RegisterSysDeadLetter(NewSysDeadLetter())
+}
+
+// sSysDeadLetter 死信队列管理
+type sSysDeadLetter struct{}
+
+func NewSysDeadLetter() *sSysDeadLetter {
+	return &sSysDeadLetter{}
+}
+
+// Topics 存在死信的主题
+func (s *sSysDeadLetter) Topics(ctx g.Ctx) (out *systemSchema.SysDeadLetterTopicsOutput, err error) {
+	dl, err := zqueue.InstanceDeadLetter()
+	if err != nil {
+		return nil, err
+	}
+	topics, err := dl.DeadLetterTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	out = new(systemSchema.SysDeadLetterTopicsOutput)
+	for _, topic := range topics {
+		list, err := dl.ListDeadLetters(topic)
+		if err != nil {
+			return nil, err
+		}
+		out.List = append(out.List, &systemSchema.SysDeadLetterTopicItem{Topic: topic, Count: len(list)})
+	}
+	return
+}
+
+// List 死信列表，最近进入死信队列的在前
+func (s *sSysDeadLetter) List(ctx g.Ctx, in *systemSchema.SysDeadLetterListInput) (out *systemSchema.SysDeadLetterListOutput, totalCount int, err error) {
+	dl, err := zqueue.InstanceDeadLetter()
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := dl.ListDeadLetters(in.Topic)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out = new(systemSchema.SysDeadLetterListOutput)
+	totalCount = len(list)
+	_, perPage, offset := zform.CalPage(in.Page, in.PerPage)
+	for i := totalCount - 1 - offset; i >= 0 && len(out.List) < perPage; i-- {
+		out.List = append(out.List, s.item(list[i]))
+	}
+	return
+}
+
+// View 死信详情
+func (s *sSysDeadLetter) View(ctx g.Ctx, in *systemSchema.SysDeadLetterViewInput) (out *systemSchema.SysDeadLetterItem, err error) {
+	dl, err := zqueue.InstanceDeadLetter()
+	if err != nil {
+		return nil, err
+	}
+	mqMsg, err := dl.GetDeadLetter(in.Topic, in.MsgId)
+	if err != nil {
+		return nil, err
+	}
+	return s.item(mqMsg), nil
+}
+
+// Replay 重新投递到原始主题
+func (s *sSysDeadLetter) Replay(ctx g.Ctx, in *systemSchema.SysDeadLetterReplayInput) (err error) {
+	if len(in.MsgIds) == 0 {
+		return gerror.New("消息ID不能为空")
+	}
+	for _, msgId := range in.MsgIds {
+		if err = zqueue.ReplayDeadLetter(in.Topic, msgId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge 清除死信，未指定消息ID时清空该主题的全部死信
+func (s *sSysDeadLetter) Purge(ctx g.Ctx, in *systemSchema.SysDeadLetterPurgeInput) (out *systemSchema.SysDeadLetterPurgeOutput, err error) {
+	dl, err := zqueue.InstanceDeadLetter()
+	if err != nil {
+		return nil, err
+	}
+	count, err := dl.DeleteDeadLetters(in.Topic, in.MsgIds...)
+	if err != nil {
+		return nil, err
+	}
+	return &systemSchema.SysDeadLetterPurgeOutput{Count: count}, nil
+}
+
+func (s *sSysDeadLetter) item(mqMsg zqueue.MqMsg) *systemSchema.SysDeadLetterItem {
+	return &systemSchema.SysDeadLetterItem{
+		MsgId:     mqMsg.MsgId,
+		Topic:     mqMsg.Topic,
+		Body:      mqMsg.BodyString(),
+		Attempts:  mqMsg.Attempts(),
+		Error:     mqMsg.Header(zqueue.HeaderError),
+		CreatedAt: gtime.New(mqMsg.Timestamp),
+		FailedAt:  mqMsg.Header(zqueue.HeaderFailedAt),
+		Headers:   mqMsg.Headers,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
@@ -66,11 +67,14 @@ func (s *sSysLoginLog) Push(ctx g.Ctx, in *systemSchema.SysLoginLogPushInput) (e
 	models.UserAgent = r.UserAgent()
 	models.Province = ipData.Province
 	models.City = ipData.City
-	models.Status = zconsts.StatusEnabled
+	models.Status = zconsts.LoginStatusSuccess
 
 	if in.Error != nil {
-		models.Status = zconsts.StatusDisable
+		models.Status = zconsts.LoginStatusFail
 		models.ErrMsg = in.Error.Error()
+		if gerror.Code(in.Error) == zconsts.CodeLoginLocked {
+			models.Status = zconsts.LoginStatusLocked
+		}
 	}
 
 	models.Response = gjson.New(zconsts.NilJsonToString)
@@ -89,6 +93,17 @@ func (s *sSysLoginLog) RealWrite(ctx g.Ctx, data entity.SysLoginLog) (err error)
 	return
 }
 
+// RealWriteBatch 批量写入登录日志，全部写入成功或全部失败
+func (s *sSysLoginLog) RealWriteBatch(ctx g.Ctx, list []entity.SysLoginLog) (err error) {
+	if len(list) == 0 {
+		return
+	}
+	return dao.SysLoginLog.Transaction(ctx, func(ctx g.Ctx, tx gdb.TX) error {
+		_, err := dao.SysLoginLog.Ctx(ctx).Data(list).OmitEmpty().Insert()
+		return err
+	})
+}
+
 func (s *sSysLoginLog) Delete(ctx g.Ctx, in *systemSchema.SysLoginLogDeleteInput) (err error) {
 	if in.Id <= 0 {
 		return gerror.New("ID不能为空")
@@ -252,10 +267,13 @@ func (s *sSysLoginLog) Export(ctx g.Ctx, in *systemSchema.SysLoginLogExportInput
 
 		// 状态文本
 		statusText := "未知"
-		if item.Status == zconsts.StatusEnabled {
+		switch item.Status {
+		case zconsts.LoginStatusSuccess:
 			statusText = "成功"
-		} else if item.Status == zconsts.StatusDisable {
+		case zconsts.LoginStatusFail:
 			statusText = "失败"
+		case zconsts.LoginStatusLocked:
+			statusText = "锁定"
 		}
 
 		// 登录时间
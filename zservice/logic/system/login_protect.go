@@ -0,0 +1,259 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/web/zcache/layered"
+	"github.com/denghuo98/zzframe/web/zlock"
+	"github.com/denghuo98/zzframe/zconsts"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+// 第一次失败时设置统计周期，之后累加不改变过期时间
+const redisIncrScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count`
+
+// 登录失败的统计维度
+const (
+	loginProtectUsername = "username"
+	loginProtectIp       = "ip"
+)
+
+// loginSubject 登录失败的统计对象
+type loginSubject struct {
+	Kind  string // 统计维度
+	Value string // 账号或IP
+}
+
+func (l loginSubject) String() string {
+	return fmt.Sprintf("%v:%v", l.Kind, l.Value)
+}
+
+func init() {
+	zservice.RegisterSysLoginProtect(NewSysLoginProtect())
+}
+
+// sSysLoginProtect 登录防暴力破解
+// 按账号和客户端IP分别统计失败次数，超过阈值后要求输入验证码，继续失败则锁定一段时间
+type sSysLoginProtect struct{}
+
+func NewSysLoginProtect() *sSysLoginProtect {
+	return &sSysLoginProtect{}
+}
+
+// Check 登录前检查，已锁定时返回错误，captcha 表示本次登录是否需要验证码
+func (s *sSysLoginProtect) Check(ctx g.Ctx, username, ip string) (captcha bool, err error) {
+	conf, err := zservice.SystemConfig().GetLoginProtectConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, subject := range s.subjects(username, ip) {
+		if err = s.checkLock(ctx, subject); err != nil {
+			return false, err
+		}
+		if conf.CaptchaAttempts < 0 {
+			continue
+		}
+		count, err := s.failCount(ctx, subject)
+		if err != nil {
+			return false, err
+		}
+		if count >= conf.CaptchaAttempts {
+			captcha = true
+		}
+	}
+	return captcha, nil
+}
+
+// Fail 记录一次登录失败，达到锁定次数时返回锁定错误，captcha 表示下次登录是否需要验证码
+func (s *sSysLoginProtect) Fail(ctx g.Ctx, username, ip string) (captcha bool, err error) {
+	conf, err := zservice.SystemConfig().GetLoginProtectConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var lockErr error
+	for _, subject := range s.subjects(username, ip) {
+		count, err := s.incrFailCount(ctx, subject, conf)
+		if err != nil {
+			return false, err
+		}
+
+		if conf.LockAttempts > 0 && count >= conf.LockAttempts {
+			if err = s.lock(ctx, subject, conf); err != nil {
+				return false, err
+			}
+			if lockErr == nil {
+				lockErr = s.checkLock(ctx, subject)
+			}
+			continue
+		}
+
+		if conf.CaptchaAttempts >= 0 && count >= conf.CaptchaAttempts {
+			captcha = true
+		}
+	}
+	if lockErr != nil {
+		return false, lockErr
+	}
+	return captcha, nil
+}
+
+// Success 登录成功后清除账号的失败次数
+// IP维度的失败次数不清除，避免使用一个有效账号为同一IP下的撞库行为重置计数
+func (s *sSysLoginProtect) Success(ctx g.Ctx, username string) {
+	if username == "" {
+		return
+	}
+	if _, err := zcache.Instance().Remove(ctx, s.failKey(loginSubject{Kind: loginProtectUsername, Value: username})); err != nil {
+		g.Log().Warningf(ctx, "remove login fail count err:%+v", err)
+	}
+}
+
+// Unlock 解除账号或IP的登录锁定，同时清除失败次数
+func (s *sSysLoginProtect) Unlock(ctx g.Ctx, in *systemSchema.SysLoginUnlockInput) (err error) {
+	subjects := s.subjects(in.Username, in.Ip)
+	if len(subjects) == 0 {
+		return gerror.New("账号和IP不能同时为空")
+	}
+
+	for _, subject := range subjects {
+		if _, err = zcache.Instance().Remove(ctx, s.failKey(subject), s.lockKey(subject)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subjects 本次登录需要统计的维度
+func (s *sSysLoginProtect) subjects(username, ip string) (subjects []loginSubject) {
+	if username != "" {
+		subjects = append(subjects, loginSubject{Kind: loginProtectUsername, Value: username})
+	}
+	if ip != "" {
+		subjects = append(subjects, loginSubject{Kind: loginProtectIp, Value: ip})
+	}
+	return
+}
+
+func (s *sSysLoginProtect) failKey(subject loginSubject) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheLoginFail, subject)
+}
+
+func (s *sSysLoginProtect) lockKey(subject loginSubject) string {
+	return fmt.Sprintf("%v:%v", zconsts.CacheLoginLock, subject)
+}
+
+// failCount 统计周期内的失败次数
+func (s *sSysLoginProtect) failCount(ctx g.Ctx, subject loginSubject) (int, error) {
+	var (
+		v   *gvar.Var
+		err error
+	)
+	// 计数直接写入 Redis，二级缓存的本地缓存中可能是旧值
+	if useRedisCounter() {
+		v, err = g.Redis().Do(ctx, "GET", s.failKey(subject))
+	} else {
+		v, err = zcache.Instance().Get(ctx, s.failKey(subject))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, nil
+	}
+	return v.Int(), nil
+}
+
+// incrFailCount 累加失败次数，统计周期从第一次失败开始计算
+// redis 和二级缓存使用 INCR 原子累加，其他适配器加锁后读取并写回
+func (s *sSysLoginProtect) incrFailCount(ctx g.Ctx, subject loginSubject, conf *webSchema.LoginProtectConfig) (count int, err error) {
+	var (
+		key    = s.failKey(subject)
+		window = time.Duration(conf.Window) * time.Second
+	)
+	if useRedisCounter() {
+		v, err := g.Redis().Eval(ctx, redisIncrScript, 1, []string{key}, []interface{}{window.Milliseconds()})
+		if err != nil {
+			return 0, err
+		}
+		return v.Int(), nil
+	}
+
+	lockKey := fmt.Sprintf("%v:%v", zconsts.LockLoginFail, subject)
+	err = zlock.Do(ctx, lockKey, 10*time.Second, func(ctx context.Context) (err error) {
+		if count, err = s.failCount(ctx, subject); err != nil {
+			return err
+		}
+		count++
+
+		// 已有计数时保留原有的过期时间
+		if count > 1 {
+			_, exist, err := zcache.Instance().Update(ctx, key, count)
+			if err != nil || exist {
+				return err
+			}
+		}
+		return zcache.Instance().Set(ctx, key, count, window)
+	})
+	return
+}
+
+// lock 锁定账号或IP，锁定后重新统计失败次数
+func (s *sSysLoginProtect) lock(ctx g.Ctx, subject loginSubject, conf *webSchema.LoginProtectConfig) (err error) {
+	expireAt := gtime.Timestamp() + conf.LockDuration
+	if err = zcache.Instance().Set(ctx, s.lockKey(subject), expireAt, time.Duration(conf.LockDuration)*time.Second); err != nil {
+		return err
+	}
+	_, err = zcache.Instance().Remove(ctx, s.failKey(subject))
+	return
+}
+
+// checkLock 检查是否处于锁定状态
+func (s *sSysLoginProtect) checkLock(ctx g.Ctx, subject loginSubject) error {
+	v, err := zcache.Instance().Get(ctx, s.lockKey(subject))
+	if err != nil {
+		return err
+	}
+	if v == nil || v.IsEmpty() {
+		return nil
+	}
+
+	remain := v.Int64() - gtime.Timestamp()
+	if remain <= 0 {
+		return nil
+	}
+	minutes := int(math.Ceil(float64(remain) / 60))
+
+	if subject.Kind == loginProtectIp {
+		return gerror.NewCodef(zconsts.CodeLoginLocked, "当前IP登录失败次数过多，请%d分钟后再试", minutes)
+	}
+	return gerror.NewCodef(zconsts.CodeLoginLocked, "登录失败次数过多，账号已锁定，请%d分钟后再试", minutes)
+}
+
+// useRedisCounter redis 和二级缓存直接在 Redis 中累加失败次数
+func useRedisCounter() bool {
+	switch zcache.Adapter().(type) {
+	case *gcache.AdapterRedis, *layered.AdapterLayered:
+		return true
+	default:
+		return false
+	}
+}
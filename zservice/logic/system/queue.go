@@ -0,0 +1,82 @@
+package system
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+
+	"github.com/denghuo98/zzframe/web/zqueue"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	"github.com/denghuo98/zzframe/zservice"
+)
+
+func init() {
+	zservice.RegisterSysQueue(NewSysQueue())
+}
+
+// sSysQueue 消息队列监控
+type sSysQueue struct{}
+
+func NewSysQueue() *sSysQueue {
+	return &sSysQueue{}
+}
+
+// Stats 各主题和消费者组的统计
+func (s *sSysQueue) Stats(ctx g.Ctx) (out *systemSchema.SysQueueStatsOutput, err error) {
+	list, err := zqueue.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out = new(systemSchema.SysQueueStatsOutput)
+	for _, t := range list {
+		out.List = append(out.List, s.topicItem(t))
+	}
+	return
+}
+
+func (s *sSysQueue) topicItem(t *zqueue.TopicStats) *systemSchema.SysQueueTopicItem {
+	item := &systemSchema.SysQueueTopicItem{
+		Topic:    t.Topic,
+		Produced: t.Produced,
+		Position: t.Position,
+		Length:   t.Length,
+		Segments: t.Segments,
+		Bytes:    t.Bytes,
+	}
+	for _, g := range t.Groups {
+		group := &systemSchema.SysQueueGroupItem{
+			Group:     g.Group,
+			Committed: g.Committed,
+			Lag:       g.Lag,
+			LagBytes:  g.LagBytes,
+			Pending:   g.Pending,
+			Consumed:  g.Consumed,
+			Failed:    g.Failed,
+			LastError: g.LastError,
+		}
+		if g.LastErrorAt != nil {
+			group.LastErrorAt = gtime.New(*g.LastErrorAt)
+		}
+		if g.Latency != nil {
+			group.Latency = &systemSchema.SysQueueLatency{Count: g.Latency.Count, Total: g.Latency.Total, Max: g.Latency.Max}
+			for _, b := range g.Latency.Buckets {
+				group.Latency.Buckets = append(group.Latency.Buckets, &systemSchema.SysQueueLatencyBucket{Le: b.Le, Count: b.Count})
+			}
+		}
+		item.Groups = append(item.Groups, group)
+	}
+	if r := t.Recovery; r != nil {
+		item.Recovery = &systemSchema.SysQueueRecovery{
+			Segments:       r.Segments,
+			Records:        r.Records,
+			Truncated:      r.Truncated,
+			TruncatedBytes: r.TruncatedBytes,
+			Corrupted:      r.Corrupted,
+			CorruptedBytes: r.CorruptedBytes,
+		}
+		if !r.RecoveredAt.IsZero() {
+			item.Recovery.RecoveredAt = gtime.New(r.RecoveredAt)
+		}
+	}
+	return item
+}
@@ -0,0 +1,31 @@
+package system
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/gogf/gf/v2/test/gtest"
+)
+
+func TestGetTokenConfig_Defaults(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		// 配置了 system.token 但未配置轮换次数和刷新令牌有效期
+		adapter, err := gcfg.NewAdapterContent(`
+system:
+  token:
+    expires: 600
+`)
+		t.AssertNil(err)
+		oldAdapter := g.Cfg().GetAdapter()
+		g.Cfg().SetAdapter(adapter)
+		defer g.Cfg().SetAdapter(oldAdapter)
+
+		conf, err := NewSystemConfig().GetTokenConfig(context.Background())
+		t.AssertNil(err)
+		t.Assert(conf.Expires, 600)
+		t.Assert(conf.MaxRefreshTimes, -1)
+		t.Assert(conf.RefreshExpires, 604800)
+	})
+}
@@ -0,0 +1,122 @@
+package system
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/gogf/gf/v2/test/gtest"
+
+	"github.com/denghuo98/zzframe/web/zcache"
+	"github.com/denghuo98/zzframe/zconsts"
+	systemSchema "github.com/denghuo98/zzframe/zschema/system"
+	webSchema "github.com/denghuo98/zzframe/zschema/zweb"
+
+	_ "github.com/gogf/gf/contrib/drivers/sqlite/v2"
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+)
+
+// setupLoginProtect 失败2次需要验证码，失败4次锁定
+func setupLoginProtect(t *gtest.T, cacheAdapter string) (restore func()) {
+	gdb.SetConfig(gdb.Config{"default": gdb.ConfigGroup{gdb.ConfigNode{Link: "sqlite::@file(:memory:)"}}})
+	zcache.SetAdapter(context.Background(), &webSchema.CacheConfig{Adapter: cacheAdapter})
+
+	adapter, err := gcfg.NewAdapterContent(`
+system:
+  loginProtect:
+    captchaAttempts: 2
+    lockAttempts: 4
+    lockDuration: 600
+    window: 600
+`)
+	t.AssertNil(err)
+	oldAdapter := g.Cfg().GetAdapter()
+	g.Cfg().SetAdapter(adapter)
+	return func() { g.Cfg().SetAdapter(oldAdapter) }
+}
+
+// testLoginProtect 各缓存适配器的公共用例
+func testLoginProtect(t *gtest.T) {
+	var (
+		ctx = context.Background()
+		s   = NewSysLoginProtect()
+	)
+
+	// 未达到次数时不需要验证码
+	captcha, err := s.Fail(ctx, "admin", "10.0.0.1")
+	t.AssertNil(err)
+	t.Assert(captcha, false)
+	captcha, err = s.Check(ctx, "admin", "10.0.0.1")
+	t.AssertNil(err)
+	t.Assert(captcha, false)
+
+	// 达到验证码次数后要求验证码
+	captcha, err = s.Fail(ctx, "admin", "10.0.0.1")
+	t.AssertNil(err)
+	t.Assert(captcha, true)
+	captcha, err = s.Check(ctx, "admin", "10.0.0.2")
+	t.AssertNil(err)
+	t.Assert(captcha, true)
+
+	// 登录成功只清除账号的失败次数
+	s.Success(ctx, "admin")
+	captcha, err = s.Check(ctx, "admin", "")
+	t.AssertNil(err)
+	t.Assert(captcha, false)
+	captcha, err = s.Check(ctx, "", "10.0.0.1")
+	t.AssertNil(err)
+	t.Assert(captcha, true)
+
+	// 达到锁定次数后锁定，IP 已累计2次，再失败2次锁定IP
+	_, err = s.Fail(ctx, "guest", "10.0.0.1")
+	t.AssertNil(err)
+	_, err = s.Fail(ctx, "guest", "10.0.0.1")
+	t.Assert(gerror.Code(err), zconsts.CodeLoginLocked)
+	_, err = s.Check(ctx, "other", "10.0.0.1")
+	t.Assert(gerror.Code(err), zconsts.CodeLoginLocked)
+	_, err = s.Check(ctx, "guest", "10.0.0.3")
+	t.AssertNil(err)
+
+	// 解除锁定后同时清除失败次数
+	t.AssertNE(s.Unlock(ctx, &systemSchema.SysLoginUnlockInput{}), nil)
+	t.AssertNil(s.Unlock(ctx, &systemSchema.SysLoginUnlockInput{Username: "guest", Ip: "10.0.0.1"}))
+	captcha, err = s.Check(ctx, "guest", "10.0.0.1")
+	t.AssertNil(err)
+	t.Assert(captcha, false)
+
+	// 并发失败不会丢失计数
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Fail(ctx, "concurrent", "")
+			t.AssertNil(err)
+		}()
+	}
+	wg.Wait()
+	count, err := s.failCount(ctx, loginSubject{Kind: loginProtectUsername, Value: "concurrent"})
+	t.AssertNil(err)
+	t.Assert(count, 3)
+	_, err = s.Fail(ctx, "concurrent", "")
+	t.Assert(gerror.Code(err), zconsts.CodeLoginLocked)
+}
+
+func TestLoginProtect(t *testing.T) {
+	gtest.C(t, func(t *gtest.T) {
+		defer setupLoginProtect(t, "memory")()
+		testLoginProtect(t)
+	})
+	gtest.C(t, func(t *gtest.T) {
+		gredis.SetConfig(&gredis.Config{Address: miniredis.RunT(t.T).Addr()})
+		defer gredis.RemoveConfig()
+		defer setupLoginProtect(t, "redis")()
+		testLoginProtect(t)
+	})
+}
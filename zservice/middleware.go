@@ -7,6 +7,7 @@ type IMiddleware interface {
 	CORS(r *ghttp.Request)
 	ResponseHandler(r *ghttp.Request)
 	AdminAuth(r *ghttp.Request)
+	RateLimit(r *ghttp.Request)
 }
 
 var (
@@ -12,19 +12,50 @@ type ISystemConfig interface {
 	LoadConfig(ctx g.Ctx) (err error)
 	GetSuperAdmin(ctx g.Ctx) (conf *webSchema.SuperAdminConfig, err error)
 	GetAnonymousConfig(ctx g.Ctx) (conf *webSchema.AnonymousConfig, err error)
+	GetMfaConfig(ctx g.Ctx) (conf *webSchema.MfaConfig, err error)
+	GetLoginProtectConfig(ctx g.Ctx) (conf *webSchema.LoginProtectConfig, err error)
+	GetRateLimitConfig(ctx g.Ctx) (conf *webSchema.RateLimitConfig, err error)
 }
 
 type ISysLoginLog interface {
 	Push(ctx g.Ctx, in *systemSchema.SysLoginLogPushInput) (err error)
 	RealWrite(ctx g.Ctx, data entity.SysLoginLog) (err error)
+	RealWriteBatch(ctx g.Ctx, list []entity.SysLoginLog) (err error)
 	Delete(ctx g.Ctx, in *systemSchema.SysLoginLogDeleteInput) (err error)
 	List(ctx g.Ctx, in *systemSchema.SysLoginLogListInput) (out *systemSchema.SysLoginLogListOutput, totalCount int, err error)
 	Export(ctx g.Ctx, in *systemSchema.SysLoginLogExportInput) (filePath string, err error)
 }
 
+type ISysLoginProtect interface {
+	Check(ctx g.Ctx, username, ip string) (captcha bool, err error)
+	Fail(ctx g.Ctx, username, ip string) (captcha bool, err error)
+	Success(ctx g.Ctx, username string)
+	Unlock(ctx g.Ctx, in *systemSchema.SysLoginUnlockInput) (err error)
+}
+
+type ISysDeadLetter interface {
+	Topics(ctx g.Ctx) (out *systemSchema.SysDeadLetterTopicsOutput, err error)
+	List(ctx g.Ctx, in *systemSchema.SysDeadLetterListInput) (out *systemSchema.SysDeadLetterListOutput, totalCount int, err error)
+	View(ctx g.Ctx, in *systemSchema.SysDeadLetterViewInput) (out *systemSchema.SysDeadLetterItem, err error)
+	Replay(ctx g.Ctx, in *systemSchema.SysDeadLetterReplayInput) (err error)
+	Purge(ctx g.Ctx, in *systemSchema.SysDeadLetterPurgeInput) (out *systemSchema.SysDeadLetterPurgeOutput, err error)
+}
+
+type ISysQueue interface {
+	Stats(ctx g.Ctx) (out *systemSchema.SysQueueStatsOutput, err error)
+}
+
+type ISysCache interface {
+	Stats(ctx g.Ctx) (out *systemSchema.SysCacheStatsOutput, err error)
+}
+
 var (
-	localSystemConfig ISystemConfig
-	localSysLoginLog  ISysLoginLog
+	localSystemConfig    ISystemConfig
+	localSysLoginLog     ISysLoginLog
+	localSysLoginProtect ISysLoginProtect
+	localSysDeadLetter   ISysDeadLetter
+	localSysQueue        ISysQueue
+	localSysCache        ISysCache
 )
 
 func SystemConfig() ISystemConfig {
@@ -48,3 +79,47 @@ func SysLoginLog() ISysLoginLog {
 func RegisterSysLoginLog(i ISysLoginLog) {
 	localSysLoginLog = i
 }
+
+func SysLoginProtect() ISysLoginProtect {
+	if localSysLoginProtect == nil {
+		panic("SysLoginProtect is not initialized, please register it first")
+	}
+	return localSysLoginProtect
+}
+
+func RegisterSysLoginProtect(i ISysLoginProtect) {
+	localSysLoginProtect = i
+}
+
+func SysDeadLetter() ISysDeadLetter {
+	if localSysDeadLetter == nil {
+		panic("SysDeadLetter is not initialized, please register it first")
+	}
+	return localSysDeadLetter
+}
+
+func RegisterSysDeadLetter(i ISysDeadLetter) {
+	localSysDeadLetter = i
+}
+
+func SysQueue() ISysQueue {
+	if localSysQueue == nil {
+		panic("SysQueue is not initialized, please register it first")
+	}
+	return localSysQueue
+}
+
+func RegisterSysQueue(i ISysQueue) {
+	localSysQueue = i
+}
+
+func SysCache() ISysCache {
+	if localSysCache == nil {
+		panic("SysCache is not initialized, please register it first")
+	}
+	return localSysCache
+}
+
+func RegisterSysCache(i ISysCache) {
+	localSysCache = i
+}